@@ -0,0 +1,548 @@
+package api
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/pkg/webauth"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+//go:embed dashboard
+var dashboardFS embed.FS
+
+// dashboardSessionCookie 面板登录会话的 Cookie 名
+const dashboardSessionCookie = "saveany_session"
+
+// Dashboard 面板处理器, 使用 Telegram 登录会话而不是 API Token 鉴权
+type Dashboard struct {
+	factory *TaskFactory
+}
+
+// NewDashboard 创建面板处理器
+func NewDashboard(factory *TaskFactory) *Dashboard {
+	return &Dashboard{factory: factory}
+}
+
+// Handler 返回挂载在 /dashboard/ 下的路由
+func (d *Dashboard) Handler() http.Handler {
+	static, err := fs.Sub(dashboardFS, "dashboard")
+	if err != nil {
+		panic("dashboard assets missing: " + err.Error())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/dashboard/", http.StripPrefix("/dashboard/", http.FileServerFS(static)))
+	mux.HandleFunc("GET "+webauth.LoginPath, d.LoginHandler)
+	mux.HandleFunc("GET /dashboard/api/login/widget", d.WidgetLoginHandler)
+	mux.HandleFunc("GET /dashboard/api/login/config", d.LoginConfigHandler)
+	mux.HandleFunc("POST /dashboard/api/logout", d.LogoutHandler)
+
+	mux.HandleFunc("GET /dashboard/api/me", d.withUser(d.MeHandler))
+	mux.HandleFunc("GET /dashboard/api/tasks", d.withUser(d.ListTasksHandler))
+	mux.HandleFunc("POST /dashboard/api/tasks", d.withUser(d.CreateTaskHandler))
+	mux.HandleFunc("DELETE /dashboard/api/tasks/{id}", d.withUser(d.CancelTaskHandler))
+	mux.HandleFunc("POST /dashboard/api/tasks/{id}/retry", d.withUser(d.RetryTaskHandler))
+	mux.HandleFunc("GET /dashboard/api/storages", d.withUser(d.ListStoragesHandler))
+	mux.HandleFunc("GET /dashboard/api/storages/{name}/files", d.withUser(d.ListFilesHandler))
+	mux.HandleFunc("GET /dashboard/api/rules", d.withUser(d.ListRulesHandler))
+	mux.HandleFunc("POST /dashboard/api/rules", d.withUser(d.CreateRuleHandler))
+	mux.HandleFunc("DELETE /dashboard/api/rules/{id}", d.withUser(d.DeleteRuleHandler))
+	mux.HandleFunc("POST /dashboard/api/rules/test", d.withUser(d.TestRulesHandler))
+	mux.HandleFunc("GET /dashboard/api/dirs", d.withUser(d.ListDirsHandler))
+	mux.HandleFunc("POST /dashboard/api/dirs", d.withUser(d.CreateDirHandler))
+	mux.HandleFunc("DELETE /dashboard/api/dirs/{id}", d.withUser(d.DeleteDirHandler))
+	mux.HandleFunc("/dashboard/api/", NotFoundHandler)
+	return mux
+}
+
+// withUser 校验登录会话并加载当前用户
+func (d *Dashboard) withUser(next func(w http.ResponseWriter, r *http.Request, user *database.User)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(dashboardSessionCookie)
+		if err != nil {
+			WriteError(w, http.StatusUnauthorized, "unauthorized", "not logged in")
+			return
+		}
+		chatID, ok := webauth.LookupSession(cookie.Value)
+		if !ok || !slices.Contains(config.C().GetUsersID(), chatID) {
+			WriteError(w, http.StatusUnauthorized, "unauthorized", "session expired")
+			return
+		}
+		user, err := database.GetUserByChatID(r.Context(), chatID)
+		if err != nil {
+			WriteError(w, http.StatusUnauthorized, "unauthorized", "user not found")
+			return
+		}
+		next(w, r, user)
+	}
+}
+
+// startSession 为用户创建会话并写入 Cookie
+func startSession(w http.ResponseWriter, r *http.Request, chatID int64) {
+	ttl := time.Duration(config.C().API.Dashboard.SessionTTL) * time.Second
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	token, expiresAt := webauth.NewSession(chatID, ttl)
+	http.SetCookie(w, &http.Cookie{
+		Name:     dashboardSessionCookie,
+		Value:    token,
+		Path:     "/dashboard",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || strings.HasPrefix(config.C().API.Dashboard.PublicURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// LoginHandler 使用 Bot 发送的一次性登录码登录
+func (d *Dashboard) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	chatID, ok := webauth.RedeemLoginCode(r.URL.Query().Get("code"))
+	if !ok || !slices.Contains(config.C().GetUsersID(), chatID) {
+		WriteError(w, http.StatusUnauthorized, "unauthorized", "invalid or expired login code")
+		return
+	}
+	startSession(w, r, chatID)
+	http.Redirect(w, r, "/dashboard/", http.StatusFound)
+}
+
+// WidgetLoginHandler 处理 Telegram Login Widget 的回调
+func (d *Dashboard) WidgetLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if config.C().API.Dashboard.BotUsername == "" {
+		WriteError(w, http.StatusNotFound, "not_found", "login widget is disabled")
+		return
+	}
+	chatID, err := webauth.VerifyLoginWidget(config.C().Telegram.Token, r.URL.Query(), 24*time.Hour)
+	if err != nil {
+		WriteError(w, http.StatusUnauthorized, "unauthorized", err.Error())
+		return
+	}
+	if !slices.Contains(config.C().GetUsersID(), chatID) {
+		WriteError(w, http.StatusForbidden, "forbidden", "user is not allowed to use this bot")
+		return
+	}
+	startSession(w, r, chatID)
+	http.Redirect(w, r, "/dashboard/", http.StatusFound)
+}
+
+// LoginConfigHandler 返回登录页需要的公开配置
+func (d *Dashboard) LoginConfigHandler(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, map[string]string{
+		"bot_username": config.C().API.Dashboard.BotUsername,
+	})
+}
+
+// LogoutHandler 注销当前会话
+func (d *Dashboard) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(dashboardSessionCookie); err == nil {
+		webauth.DeleteSession(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:   dashboardSessionCookie,
+		Path:   "/dashboard",
+		MaxAge: -1,
+	})
+	WriteJSON(w, http.StatusOK, map[string]string{"message": "logged out"})
+}
+
+// MeHandler 返回当前用户信息
+func (d *Dashboard) MeHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"chat_id":         user.ChatID,
+		"default_storage": user.DefaultStorage,
+		"apply_rule":      user.ApplyRule,
+		"task_types":      supportedTaskTypes,
+	})
+}
+
+// ListTasksHandler 列出 API 任务以及队列中的其他任务, 管理员以外的用户只能看到自己的任务
+func (d *Dashboard) ListTasksHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	admin := config.C().IsAdmin(user.ChatID)
+	tasks := GetAllTasks()
+	resp := make([]TaskInfoResponse, 0, len(tasks))
+	known := make(map[string]struct{}, len(tasks))
+	for _, task := range tasks {
+		known[task.TaskID] = struct{}{}
+		if admin || task.Owner() == user.ChatID {
+			resp = append(resp, convertTaskProgressToResponse(task))
+		}
+	}
+
+	// 通过 Bot 添加的任务不在 API 任务表中, 仅能展示其排队/运行状态
+	for _, t := range core.GetRunningTasks(r.Context()) {
+		if _, ok := known[t.ID]; !ok && (admin || t.Owner == user.ChatID) {
+			resp = append(resp, TaskInfoResponse{TaskID: t.ID, Title: t.Title, Status: TaskStatusRunning, CreatedAt: t.Created})
+		}
+	}
+	for _, t := range core.GetQueuedTasks(r.Context()) {
+		if _, ok := known[t.ID]; !ok && (admin || t.Owner == user.ChatID) {
+			resp = append(resp, TaskInfoResponse{TaskID: t.ID, Title: t.Title, Status: TaskStatusQueued, CreatedAt: t.Created})
+		}
+	}
+
+	slices.SortFunc(resp, func(a, b TaskInfoResponse) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	WriteJSON(w, http.StatusOK, TasksListResponse{Tasks: resp, Total: len(resp)})
+}
+
+// CreateTaskHandler 从面板表单创建任务, 仅允许使用当前用户可用的存储
+func (d *Dashboard) CreateTaskHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	var req CreateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	d.submit(w, user, &req)
+}
+
+// RetryTaskHandler 使用原始请求重新提交一个已结束的任务
+func (d *Dashboard) RetryTaskHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	taskID := r.PathValue("id")
+	task, ok := GetTask(taskID)
+	if !ok || !d.ownsTask(r, user, taskID) {
+		WriteError(w, http.StatusNotFound, "task_not_found", "task not found: "+taskID)
+		return
+	}
+	status, _, _, _, _, _, _, _ := task.snapshot()
+	if status != TaskStatusFailed && status != TaskStatusCancelled {
+		WriteError(w, http.StatusConflict, "task_not_finished", "only failed or cancelled tasks can be retried")
+		return
+	}
+	req := task.Request()
+	if req == nil {
+		WriteError(w, http.StatusBadRequest, "not_retryable", "task was not created through the API")
+		return
+	}
+	retry := *req
+	d.submit(w, user, &retry)
+}
+
+func (d *Dashboard) submit(w http.ResponseWriter, user *database.User, req *CreateTaskRequest) {
+	if apiErr := validateCreateTaskRequest(req); apiErr != nil {
+		WriteError(w, apiErr.StatusCode, apiErr.ErrorCode, apiErr.Message)
+		return
+	}
+	// 面板挂载在按 token 限流的中间件之前, 任务创建按会话用户计入同样的速率限制
+	var rlErr *RateLimitError
+	if err := allowTasks(sessionLimitKey(user.ChatID), 1); errors.As(err, &rlErr) {
+		writeRateLimitError(w, rlErr)
+		return
+	}
+	if !config.C().HasStorage(user.ChatID, req.Storage) {
+		WriteError(w, http.StatusForbidden, "forbidden", "storage not available: "+req.Storage)
+		return
+	}
+	if req.Type == tasktype.TaskTypeTransfer {
+		var params TransferParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			WriteError(w, http.StatusBadRequest, "invalid_request", "invalid params: "+err.Error())
+			return
+		}
+		if !config.C().HasStorage(user.ChatID, params.SourceStorage) || !config.C().HasStorage(user.ChatID, params.TargetStorage) {
+			WriteError(w, http.StatusForbidden, "forbidden", "storage not available")
+			return
+		}
+	}
+
+	resp, err := d.factory.ForUser(user.ChatID).CreateTask(req)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "task_creation_failed", err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusCreated, resp)
+}
+
+// CancelTaskHandler 取消任务
+func (d *Dashboard) CancelTaskHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	taskID := r.PathValue("id")
+	if !d.ownsTask(r, user, taskID) {
+		WriteError(w, http.StatusNotFound, "task_not_found", "task not found: "+taskID)
+		return
+	}
+	if err := core.CancelTask(r.Context(), taskID); err != nil {
+		WriteError(w, http.StatusNotFound, "cancel_failed", err.Error())
+		return
+	}
+	if task, ok := GetTask(taskID); ok {
+		task.UpdateStatus(TaskStatusCancelled)
+	}
+	WriteJSON(w, http.StatusOK, map[string]string{"message": "task cancelled successfully"})
+}
+
+// ownsTask 检查任务是否属于当前用户, 管理员可以管理所有任务
+func (d *Dashboard) ownsTask(r *http.Request, user *database.User, taskID string) bool {
+	if config.C().IsAdmin(user.ChatID) {
+		return true
+	}
+	if task, ok := GetTask(taskID); ok {
+		return task.Owner() == user.ChatID
+	}
+	for _, t := range slices.Concat(core.GetRunningTasks(r.Context()), core.GetQueuedTasks(r.Context())) {
+		if t.ID == taskID {
+			return t.Owner == user.ChatID
+		}
+	}
+	return false
+}
+
+// ListStoragesHandler 列出当前用户可用的存储
+func (d *Dashboard) ListStoragesHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	stors := storage.GetUserStorages(r.Context(), user.ChatID)
+	resp := make([]DashboardStorageInfo, 0, len(stors))
+	for _, stor := range stors {
+		_, listable := stor.(storage.StorageListable)
+		resp = append(resp, DashboardStorageInfo{
+			StorageInfo: StorageInfo{Name: stor.Name(), Type: string(stor.Type())},
+			Listable:    listable,
+		})
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"storages": resp})
+}
+
+// ListFilesHandler 浏览存储中的目录
+func (d *Dashboard) ListFilesHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	stor, err := storage.GetStorageByUserIDAndName(r.Context(), user.ChatID, r.PathValue("name"))
+	if err != nil {
+		WriteError(w, http.StatusNotFound, "storage_not_found", err.Error())
+		return
+	}
+	listable, ok := stor.(storage.StorageListable)
+	if !ok {
+		WriteError(w, http.StatusBadRequest, "not_listable", "storage does not support listing")
+		return
+	}
+	dirPath := r.URL.Query().Get("path")
+	if dirPath == "" {
+		dirPath = "/"
+	}
+	files, err := listable.ListFiles(r.Context(), dirPath)
+	if err != nil {
+		log.FromContext(r.Context()).Errorf("Failed to list %s:%s: %v", stor.Name(), dirPath, err)
+		WriteError(w, http.StatusInternalServerError, "list_failed", err.Error())
+		return
+	}
+	entries := make([]FileEntry, 0, len(files))
+	for _, f := range files {
+		entries = append(entries, FileEntry{
+			Name:    f.Name,
+			Path:    f.Path,
+			Size:    f.Size,
+			IsDir:   f.IsDir,
+			ModTime: f.ModTime,
+		})
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"path": dirPath, "files": entries})
+}
+
+// ListRulesHandler 列出当前用户的规则
+func (d *Dashboard) ListRulesHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	rules := make([]RuleInfo, 0, len(user.Rules))
+	for _, ru := range user.Rules {
+		rules = append(rules, ruleInfoFromModel(ru))
+	}
+	types := make([]string, 0, len(rule.Values()))
+	for _, t := range rule.Values() {
+		types = append(types, t.String())
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"rules": rules, "types": types})
+}
+
+// CreateRuleHandler 添加规则
+func (d *Dashboard) CreateRuleHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	var req RuleInfo
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if !slices.Contains(rule.Values(), rule.RuleType(strings.ToUpper(req.Type))) {
+		WriteError(w, http.StatusBadRequest, "invalid_request", "invalid rule type: "+req.Type)
+		return
+	}
+	if err := rule.Validate(rule.RuleType(strings.ToUpper(req.Type)), req.Data); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid_request", "invalid rule data: "+err.Error())
+		return
+	}
+	actions, err := rule.ParseActions(req.Action)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid_request", "invalid rule action: "+err.Error())
+		return
+	}
+	if req.StorageName != rule.RuleStorNameChosen && req.StorageName != rule.RuleKeep && !config.C().HasStorage(user.ChatID, req.StorageName) {
+		WriteError(w, http.StatusBadRequest, "invalid_request", "storage not available: "+req.StorageName)
+		return
+	}
+	rd := &database.Rule{
+		UserID:      user.ID,
+		Type:        strings.ToUpper(req.Type),
+		Data:        req.Data,
+		StorageName: req.StorageName,
+		DirPath:     req.DirPath,
+		Priority:    req.Priority,
+		Stop:        req.Stop,
+		Action:      actions.String(),
+	}
+	if err := database.CreateRule(r.Context(), rd); err != nil {
+		WriteError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusCreated, ruleInfoFromModel(*rd))
+}
+
+// DeleteRuleHandler 删除当前用户的规则
+func (d *Dashboard) DeleteRuleHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil || !slices.ContainsFunc(user.Rules, func(ru database.Rule) bool { return ru.ID == uint(id) }) {
+		WriteError(w, http.StatusNotFound, "not_found", "rule not found")
+		return
+	}
+	if err := database.DeleteRule(r.Context(), uint(id)); err != nil {
+		WriteError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]string{"message": "rule deleted"})
+}
+
+// TestRulesHandler 对消息链接或模拟的文件执行当前用户的规则, 不创建任务
+func (d *Dashboard) TestRulesHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	var req RuleTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	resp, apiErr := testRules(r.Context(), &req, user)
+	if apiErr != nil {
+		WriteError(w, apiErr.StatusCode, apiErr.ErrorCode, apiErr.Message)
+		return
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// testRules 对请求中的文件执行用户的规则
+func testRules(ctx context.Context, req *RuleTestRequest, user *database.User) (*RuleTestResponse, *APIError) {
+	if clientCtx, err := getClientContext(); err == nil {
+		// 用于规则中按用户名匹配会话
+		ctx = tgutil.ExtWithContext(ctx, clientCtx)
+	}
+	var files []tfile.TGFileMessage
+	switch {
+	case req.MessageLink != "":
+		var err error
+		files, err = ExtractFilesFromLinks(ctx, []string{req.MessageLink})
+		if err != nil {
+			return nil, &APIError{StatusCode: http.StatusBadRequest, ErrorCode: "invalid_request", Message: err.Error()}
+		}
+	case req.FileName != "":
+		file, err := ruleutil.NewSyntheticFile(ruleutil.SyntheticFile{
+			Name:     req.FileName,
+			Caption:  req.Caption,
+			Size:     req.Size,
+			MimeType: req.MimeType,
+			Duration: req.Duration,
+			Album:    req.Album,
+			ChatID:   req.ChatID,
+		})
+		if err != nil {
+			return nil, &APIError{StatusCode: http.StatusBadRequest, ErrorCode: "invalid_request", Message: err.Error()}
+		}
+		files = append(files, file)
+	default:
+		return nil, &APIError{StatusCode: http.StatusBadRequest, ErrorCode: "invalid_request", Message: "message_link or file_name is required"}
+	}
+	resp := &RuleTestResponse{
+		RuleModeEnabled: user.ApplyRule,
+		Results:         make([]RuleTestResult, 0, len(files)),
+	}
+	for _, file := range files {
+		res := ruleutil.Apply(ctx, user.Rules, ruleutil.NewInput(file))
+		result := RuleTestResult{
+			FileName:    file.Name(),
+			Evaluations: make([]RuleEvaluation, 0, len(res.Evaluations)),
+			Matched:     res.Matched,
+			StorageName: res.StorageName.String(),
+			DirPath:     res.DirPath.String(),
+			Actions:     res.Actions.String(),
+		}
+		for _, ev := range res.Evaluations {
+			re := RuleEvaluation{Rule: ruleInfoFromModel(ev.Rule), Matched: ev.Matched}
+			if ev.Err != nil {
+				re.Error = ev.Err.Error()
+			}
+			result.Evaluations = append(result.Evaluations, re)
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+// ListDirsHandler 列出当前用户的常用目录
+func (d *Dashboard) ListDirsHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	dirs := make([]DirInfo, 0, len(user.Dirs))
+	for _, dir := range user.Dirs {
+		dirs = append(dirs, DirInfo{ID: dir.ID, StorageName: dir.StorageName, Path: dir.Path})
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"dirs": dirs})
+}
+
+// CreateDirHandler 添加常用目录
+func (d *Dashboard) CreateDirHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	var req DirInfo
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.Path == "" || !config.C().HasStorage(user.ChatID, req.StorageName) {
+		WriteError(w, http.StatusBadRequest, "invalid_request", "a valid storage and path are required")
+		return
+	}
+	if err := database.CreateDirForUser(r.Context(), user.ID, req.StorageName, req.Path); err != nil {
+		WriteError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusCreated, map[string]string{"message": "dir created"})
+}
+
+// DeleteDirHandler 删除当前用户的常用目录
+func (d *Dashboard) DeleteDirHandler(w http.ResponseWriter, r *http.Request, user *database.User) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil || !slices.ContainsFunc(user.Dirs, func(dir database.Dir) bool { return dir.ID == uint(id) }) {
+		WriteError(w, http.StatusNotFound, "not_found", "dir not found")
+		return
+	}
+	if err := database.DeleteDirByID(r.Context(), uint(id)); err != nil {
+		WriteError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]string{"message": "dir deleted"})
+}
+
+func ruleInfoFromModel(ru database.Rule) RuleInfo {
+	return RuleInfo{
+		ID:          ru.ID,
+		Type:        ru.Type,
+		Data:        ru.Data,
+		StorageName: ru.StorageName,
+		DirPath:     ru.DirPath,
+		Priority:    ru.Priority,
+		Stop:        ru.Stop,
+		Action:      ru.Action,
+	}
+}
@@ -0,0 +1,424 @@
+"use strict";
+
+const API = "/dashboard/api";
+
+// Parameter fields for each task type, mirroring the *Params structs in api/types.go.
+const TASK_PARAMS = {
+  directlinks: [{ name: "urls", label: "URLs (one per line)", kind: "lines" }],
+  ytdlp: [
+    { name: "urls", label: "URLs (one per line)", kind: "lines" },
+    { name: "flags", label: "Extra yt-dlp flags (space separated)", kind: "words" },
+  ],
+  aria2: [{ name: "urls", label: "URIs (one per line)", kind: "lines" }],
+  parseditem: [{ name: "url", label: "URL", kind: "text" }],
+  tgfiles: [{ name: "message_links", label: "Message links (one per line)", kind: "lines" }],
+  tphpics: [{ name: "telegraph_url", label: "Telegraph URL", kind: "text" }],
+  transfer: [
+    { name: "source_storage", label: "Source storage", kind: "storage" },
+    { name: "source_path", label: "Source path", kind: "text" },
+    { name: "target_storage", label: "Target storage", kind: "storage" },
+    { name: "target_path", label: "Target path", kind: "text" },
+  ],
+};
+
+const state = { me: null, storages: [], tasksTimer: null };
+
+async function request(method, path, body) {
+  const opts = { method, headers: {} };
+  if (body !== undefined) {
+    opts.headers["Content-Type"] = "application/json";
+    opts.body = JSON.stringify(body);
+  }
+  const resp = await fetch(API + path, opts);
+  const data = await resp.json().catch(() => ({}));
+  if (resp.status === 401) {
+    showLogin();
+    throw new Error(data.message || "unauthorized");
+  }
+  if (!resp.ok) {
+    throw new Error(data.message || data.error || resp.statusText);
+  }
+  return data;
+}
+
+function el(tag, attrs = {}, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs)) {
+    if (v === undefined || v === null || v === false) continue;
+    if (k.startsWith("on")) node.addEventListener(k.slice(2), v);
+    else if (k === "class") node.className = v;
+    else node.setAttribute(k, v);
+  }
+  for (const c of children) {
+    if (c !== null && c !== undefined) node.append(c);
+  }
+  return node;
+}
+
+function formatBytes(n) {
+  if (!n) return "0 B";
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  const i = Math.min(Math.floor(Math.log(n) / Math.log(1024)), units.length - 1);
+  return (n / Math.pow(1024, i)).toFixed(i ? 1 : 0) + " " + units[i];
+}
+
+function formatTime(s) {
+  if (!s || s.startsWith("0001")) return "";
+  return new Date(s).toLocaleString();
+}
+
+function setMessage(id, text, isError) {
+  const node = document.getElementById(id);
+  node.textContent = text;
+  node.classList.toggle("error", !!isError);
+}
+
+// ---- views ----
+
+function showView(name) {
+  for (const section of document.querySelectorAll("main > section")) {
+    section.hidden = section.id !== "view-" + name;
+  }
+  for (const a of document.querySelectorAll("nav a")) {
+    a.classList.toggle("active", a.getAttribute("href") === "#" + name);
+  }
+  clearInterval(state.tasksTimer);
+  if (name === "tasks") {
+    loadTasks();
+    state.tasksTimer = setInterval(loadTasks, 2000);
+  } else if (name === "storages") {
+    renderStorages();
+  } else if (name === "rules") {
+    loadRules();
+  } else if (name === "dirs") {
+    loadDirs();
+  }
+}
+
+async function showLogin() {
+  document.getElementById("nav").hidden = true;
+  clearInterval(state.tasksTimer);
+  for (const section of document.querySelectorAll("main > section")) {
+    section.hidden = section.id !== "view-login";
+  }
+  const cfg = await fetch(API + "/login/config").then((r) => r.json()).catch(() => ({}));
+  const widget = document.getElementById("widget");
+  if (cfg.bot_username && !widget.hasChildNodes()) {
+    const script = document.createElement("script");
+    script.async = true;
+    script.src = "https://telegram.org/js/telegram-widget.js?22";
+    script.dataset.telegramLogin = cfg.bot_username;
+    script.dataset.size = "large";
+    script.dataset.authUrl = location.origin + API + "/login/widget";
+    widget.append(script);
+  }
+}
+
+// ---- tasks ----
+
+async function loadTasks() {
+  let data;
+  try {
+    data = await request("GET", "/tasks");
+  } catch (e) {
+    return;
+  }
+  document.getElementById("tasks-total").textContent = "(" + data.total + ")";
+  const body = document.getElementById("tasks-body");
+  body.replaceChildren(
+    ...data.tasks.map((t) => {
+      const p = t.progress;
+      let progress = "";
+      if (p) {
+        const pct = (p.percent || 0).toFixed(1);
+        const detail = p.total_bytes
+          ? formatBytes(p.downloaded_bytes) + " / " + formatBytes(p.total_bytes)
+          : (p.downloaded_files || 0) + " / " + p.total_files + " files";
+        progress = el("div", {},
+          el("div", { class: "progress" }, el("div", { style: "width:" + pct + "%" })),
+          el("small", {}, pct + "% · " + detail));
+      }
+      const actions = el("td");
+      if (t.status === "queued" || t.status === "running") {
+        actions.append(el("button", { class: "danger", onclick: () => taskAction("DELETE", "/tasks/" + t.task_id) }, "Cancel"));
+      } else if ((t.status === "failed" || t.status === "cancelled") && t.type) {
+        actions.append(el("button", { onclick: () => taskAction("POST", "/tasks/" + t.task_id + "/retry") }, "Retry"));
+      }
+      return el("tr", {},
+        el("td", {}, el("code", {}, t.task_id)),
+        el("td", { title: t.error || "" }, t.title || ""),
+        el("td", {}, t.type || ""),
+        el("td", { class: "status-" + t.status }, t.status),
+        el("td", {}, progress),
+        el("td", {}, formatTime(t.created_at)),
+        actions);
+    }),
+  );
+}
+
+async function taskAction(method, path) {
+  try {
+    await request(method, path);
+  } catch (e) {
+    alert(e.message);
+  }
+  loadTasks();
+}
+
+function renderTaskParams() {
+  const type = document.getElementById("task-type").value;
+  const container = document.getElementById("task-params");
+  container.replaceChildren(
+    ...(TASK_PARAMS[type] || []).map((f) => {
+      let input;
+      if (f.kind === "lines") {
+        input = el("textarea", { name: f.name, required: "" });
+      } else if (f.kind === "storage") {
+        input = storageSelect(f.name);
+      } else {
+        input = el("input", { name: f.name, required: f.kind !== "words" && "" });
+      }
+      return el("label", {}, f.label, input);
+    }),
+  );
+}
+
+async function submitTask(event) {
+  event.preventDefault();
+  const form = event.target;
+  const type = form.type.value;
+  const params = {};
+  for (const f of TASK_PARAMS[type] || []) {
+    const value = form.elements[f.name].value.trim();
+    if (f.kind === "lines") params[f.name] = value.split("\n").map((s) => s.trim()).filter(Boolean);
+    else if (f.kind === "words") params[f.name] = value ? value.split(/\s+/) : [];
+    else params[f.name] = value;
+  }
+  try {
+    const resp = await request("POST", "/tasks", {
+      type,
+      storage: form.storage.value,
+      path: form.path.value.trim(),
+      params,
+    });
+    setMessage("task-message", "Task " + resp.task_id + " queued.");
+  } catch (e) {
+    setMessage("task-message", e.message, true);
+  }
+}
+
+// ---- storages ----
+
+function storageSelect(name) {
+  return el("select", { name }, ...state.storages.map((s) => el("option", { value: s.name }, s.name)));
+}
+
+function fillStorageSelects() {
+  for (const select of document.querySelectorAll(".storage-select")) {
+    select.replaceChildren(...state.storages.map((s) => el("option", { value: s.name }, s.name)));
+  }
+}
+
+function renderStorages() {
+  const list = document.getElementById("storages-list");
+  list.replaceChildren(
+    ...state.storages.map((s) =>
+      el("li", {},
+        el("button", {
+          disabled: !s.listable && "",
+          title: !s.listable && "This storage cannot be browsed",
+          onclick: (e) => {
+            for (const b of list.querySelectorAll("button")) b.classList.remove("selected");
+            e.target.classList.add("selected");
+            browse(s.name, "/");
+          },
+        }, s.name + " (" + s.type + ")"))),
+  );
+}
+
+async function browse(storageName, path) {
+  const box = document.getElementById("browser");
+  box.hidden = false;
+  document.getElementById("browser-title").textContent = storageName + ":" + path;
+  const body = document.getElementById("browser-body");
+  let data;
+  try {
+    data = await request("GET", "/storages/" + encodeURIComponent(storageName) + "/files?path=" + encodeURIComponent(path));
+  } catch (e) {
+    body.replaceChildren(el("tr", {}, el("td", { colspan: "3", class: "message error" }, e.message)));
+    return;
+  }
+  const rows = [];
+  if (path !== "/" && path !== "") {
+    const parent = path.replace(/\/+$/, "").split("/").slice(0, -1).join("/") || "/";
+    rows.push(el("tr", {}, el("td", {}, el("button", { class: "link", onclick: () => browse(storageName, parent) }, "..")), el("td"), el("td")));
+  }
+  data.files.sort((a, b) => (b.is_dir - a.is_dir) || a.name.localeCompare(b.name));
+  for (const f of data.files) {
+    const name = f.is_dir
+      ? el("button", { class: "link", onclick: () => browse(storageName, f.path) }, f.name + "/")
+      : f.name;
+    rows.push(el("tr", {}, el("td", {}, name), el("td", {}, f.is_dir ? "" : formatBytes(f.size)), el("td", {}, formatTime(f.mod_time))));
+  }
+  body.replaceChildren(...rows);
+}
+
+// ---- rules ----
+
+async function loadRules() {
+  let data;
+  try {
+    data = await request("GET", "/rules");
+  } catch (e) {
+    return;
+  }
+  const typeSelect = document.getElementById("rule-type");
+  if (!typeSelect.options.length) {
+    typeSelect.replaceChildren(...data.types.map((t) => el("option", { value: t }, t)));
+  }
+  document.getElementById("rules-body").replaceChildren(
+    ...data.rules.map((r) =>
+      el("tr", {},
+        el("td", {}, String(r.id)),
+        el("td", {}, r.type),
+        el("td", {}, el("code", {}, r.data)),
+        el("td", {}, r.storage_name),
+        el("td", {}, r.dir_path),
+        el("td", {}, String(r.priority)),
+        el("td", {}, r.stop ? "yes" : ""),
+        el("td", {}, r.action ? el("code", {}, r.action) : ""),
+        el("td", {}, el("button", { class: "danger", onclick: () => removeItem("/rules/" + r.id, loadRules, "rule-message") }, "Delete")))),
+  );
+}
+
+async function submitRule(event) {
+  event.preventDefault();
+  const form = event.target;
+  try {
+    await request("POST", "/rules", {
+      type: form.type.value,
+      data: form.data.value,
+      storage_name: form.storage_name.value.trim(),
+      dir_path: form.dir_path.value.trim(),
+      priority: Number(form.priority.value) || 0,
+      stop: form.stop.checked,
+      action: form.action.value.trim(),
+    });
+    form.reset();
+    setMessage("rule-message", "Rule added.");
+  } catch (e) {
+    setMessage("rule-message", e.message, true);
+  }
+  loadRules();
+}
+
+async function submitRuleTest(event) {
+  event.preventDefault();
+  const form = event.target;
+  let data;
+  try {
+    data = await request("POST", "/rules/test", {
+      message_link: form.message_link.value.trim(),
+      file_name: form.file_name.value.trim(),
+      caption: form.caption.value,
+      size: Number(form.size.value) || 0,
+      mime_type: form.mime_type.value.trim(),
+    });
+  } catch (e) {
+    setMessage("rule-test-message", e.message, true);
+    return;
+  }
+  setMessage("rule-test-message", data.rule_mode_enabled ? "" : "Rule mode is disabled, these rules are not applied when saving.");
+  document.getElementById("rule-test-results").replaceChildren(
+    ...data.results.map((res) =>
+      el("div", {},
+        el("h4", {}, res.file_name),
+        el("table", {},
+          el("thead", {}, el("tr", {}, el("th", {}, "ID"), el("th", {}, "Type"), el("th", {}, "Data"), el("th", {}, "Result"))),
+          el("tbody", {}, ...res.evaluations.map((ev) =>
+            el("tr", {},
+              el("td", {}, String(ev.rule.id)),
+              el("td", {}, ev.rule.type),
+              el("td", {}, el("code", {}, ev.rule.data)),
+              el("td", {}, ev.error ? "error: " + ev.error : ev.matched ? "matched" : "not matched"))))),
+        el("p", {}, res.matched
+          ? `Result: storage ${res.storage_name || "-"}, path ${res.dir_path || "-"}`
+          : "Result: no rule matched"),
+        res.actions ? el("p", {}, "Actions: ", el("code", {}, res.actions)) : null)),
+  );
+}
+
+// ---- dirs ----
+
+async function loadDirs() {
+  let data;
+  try {
+    data = await request("GET", "/dirs");
+  } catch (e) {
+    return;
+  }
+  document.getElementById("dirs-body").replaceChildren(
+    ...data.dirs.map((d) =>
+      el("tr", {},
+        el("td", {}, String(d.id)),
+        el("td", {}, d.storage_name),
+        el("td", {}, d.path),
+        el("td", {}, el("button", { class: "danger", onclick: () => removeItem("/dirs/" + d.id, loadDirs, "dir-message") }, "Delete")))),
+  );
+}
+
+async function submitDir(event) {
+  event.preventDefault();
+  const form = event.target;
+  try {
+    await request("POST", "/dirs", { storage_name: form.storage_name.value, path: form.path.value.trim() });
+    form.path.value = "";
+    setMessage("dir-message", "Dir added.");
+  } catch (e) {
+    setMessage("dir-message", e.message, true);
+  }
+  loadDirs();
+}
+
+async function removeItem(path, reload, messageId) {
+  if (!confirm("Delete this item?")) return;
+  try {
+    await request("DELETE", path);
+  } catch (e) {
+    setMessage(messageId, e.message, true);
+  }
+  reload();
+}
+
+// ---- boot ----
+
+async function boot() {
+  try {
+    state.me = await request("GET", "/me");
+  } catch (e) {
+    return;
+  }
+  const storages = await request("GET", "/storages");
+  state.storages = storages.storages;
+
+  document.getElementById("nav").hidden = false;
+  document.getElementById("task-type").replaceChildren(...state.me.task_types.map((t) => el("option", { value: t }, t)));
+  fillStorageSelects();
+  renderTaskParams();
+
+  const route = () => showView((location.hash || "#tasks").slice(1));
+  window.addEventListener("hashchange", route);
+  route();
+}
+
+document.getElementById("task-type").addEventListener("change", renderTaskParams);
+document.getElementById("task-form").addEventListener("submit", submitTask);
+document.getElementById("rule-form").addEventListener("submit", submitRule);
+document.getElementById("rule-test-form").addEventListener("submit", submitRuleTest);
+document.getElementById("dir-form").addEventListener("submit", submitDir);
+document.getElementById("logout").addEventListener("click", async () => {
+  await fetch(API + "/logout", { method: "POST" });
+  showLogin();
+});
+
+boot();
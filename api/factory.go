@@ -28,7 +28,8 @@ import (
 
 // TaskFactory 任务工厂
 type TaskFactory struct {
-	ctx context.Context
+	ctx   context.Context
+	owner int64 // 创建的任务所属用户的 chat ID, 通过 API Token 创建时为 0
 }
 
 // NewTaskFactory 创建任务工厂
@@ -36,6 +37,11 @@ func NewTaskFactory(ctx context.Context) *TaskFactory {
 	return &TaskFactory{ctx: ctx}
 }
 
+// ForUser 返回创建属于 chatID 用户的任务的工厂
+func (f *TaskFactory) ForUser(chatID int64) *TaskFactory {
+	return &TaskFactory{ctx: core.WithOwner(f.ctx, chatID), owner: chatID}
+}
+
 // CreateTask 创建任务
 func (f *TaskFactory) CreateTask(req *CreateTaskRequest) (*CreateTaskResponse, error) {
 	// 验证存储
@@ -47,29 +53,41 @@ func (f *TaskFactory) CreateTask(req *CreateTaskRequest) (*CreateTaskResponse, e
 	taskID := xid.New().String()
 	createdAt := time.Now()
 
+	var resp *CreateTaskResponse
+	var err error
 	switch req.Type {
 	case tasktype.TaskTypeDirectlinks:
-		return f.createDirectLinksTask(taskID, createdAt, req, stor)
+		resp, err = f.createDirectLinksTask(taskID, createdAt, req, stor)
 	case tasktype.TaskTypeYtdlp:
-		return f.createYTDLPTask(taskID, createdAt, req, stor)
+		resp, err = f.createYTDLPTask(taskID, createdAt, req, stor)
 	case tasktype.TaskTypeAria2:
-		return f.createAria2Task(taskID, createdAt, req, stor)
+		resp, err = f.createAria2Task(taskID, createdAt, req, stor)
 	case tasktype.TaskTypeParseditem:
-		return f.createParsedTask(taskID, createdAt, req, stor)
+		resp, err = f.createParsedTask(taskID, createdAt, req, stor)
 	case tasktype.TaskTypeTgfiles:
-		return f.createTGFilesTask(taskID, createdAt, req, stor)
+		resp, err = f.createTGFilesTask(taskID, createdAt, req, stor)
 	case tasktype.TaskTypeTphpics:
-		return f.createTPHPicsTask(taskID, createdAt, req, stor)
+		resp, err = f.createTPHPicsTask(taskID, createdAt, req, stor)
 	case tasktype.TaskTypeTransfer:
-		return f.createTransferTask(taskID, createdAt, req)
+		resp, err = f.createTransferTask(taskID, createdAt, req)
 	default:
 		return nil, fmt.Errorf("unsupported task type: %s", req.Type)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 记录原始请求, 以便之后重试
+	if info, ok := GetTask(resp.TaskID); ok {
+		info.setRequest(req)
+	}
+	return resp, nil
 }
 
 func (f *TaskFactory) registerAndEnqueueTask(task core.Executable, taskType tasktype.TaskType, storageName, path, webhook string) error {
 	taskID := task.TaskID()
 	info := RegisterTask(taskID, string(taskType), storageName, path, task.Title(), webhook)
+	info.setOwner(f.owner)
 
 	// Inject the progress sink into the context so the task's Emit calls update
 	// the API store (and fire the webhook on terminal states) without the task
@@ -95,6 +113,9 @@ func (f *TaskFactory) createDirectLinksTask(taskID string, createdAt time.Time,
 	if len(params.URLs) == 0 {
 		return nil, fmt.Errorf("no URLs provided")
 	}
+	if err := checkURLCount(len(params.URLs)); err != nil {
+		return nil, err
+	}
 
 	task := directlinks.NewTask(taskID, f.ctx, params.URLs, stor, req.Path, nil)
 
@@ -121,6 +142,9 @@ func (f *TaskFactory) createYTDLPTask(taskID string, createdAt time.Time, req *C
 	if len(params.URLs) == 0 {
 		return nil, fmt.Errorf("no URLs provided")
 	}
+	if err := checkURLCount(len(params.URLs)); err != nil {
+		return nil, err
+	}
 
 	task := ytdlp.NewTask(taskID, f.ctx, params.URLs, params.Flags, stor, req.Path, nil)
 
@@ -147,6 +171,9 @@ func (f *TaskFactory) createAria2Task(taskID string, createdAt time.Time, req *C
 	if len(params.URLs) == 0 {
 		return nil, fmt.Errorf("no URLs provided")
 	}
+	if err := checkURLCount(len(params.URLs)); err != nil {
+		return nil, err
+	}
 
 	// 检查 Aria2 是否启用
 	cfg := config.C().Aria2
@@ -235,6 +262,9 @@ func (f *TaskFactory) createTGFilesTask(taskID string, createdAt time.Time, req
 	if len(params.MessageLinks) == 0 {
 		return nil, fmt.Errorf("no message links provided")
 	}
+	if err := checkURLCount(len(params.MessageLinks)); err != nil {
+		return nil, err
+	}
 
 	// 提取文件
 	files, err := ExtractFilesFromLinks(f.ctx, params.MessageLinks)
@@ -0,0 +1,311 @@
+package api
+
+//go:generate protoc -I proto --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative saveany.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/api/pb"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// idempotencyKeyMetadata 与 REST API 的 Idempotency-Key 请求头对应
+const idempotencyKeyMetadata = "idempotency-key"
+
+// GRPCServer 实现 pb.SaveAnyServer, 与 REST API 共用 TaskFactory 和任务存储
+type GRPCServer struct {
+	pb.UnimplementedSaveAnyServer
+	factory *TaskFactory
+}
+
+// NewGRPCServer 创建 gRPC 服务
+func NewGRPCServer(factory *TaskFactory) *GRPCServer {
+	return &GRPCServer{factory: factory}
+}
+
+// CreateTask 创建任务
+func (s *GRPCServer) CreateTask(ctx context.Context, in *pb.CreateTaskRequest) (*pb.CreateTaskResponse, error) {
+	req := CreateTaskRequest{
+		Type:    tasktype.TaskType(in.GetType()),
+		Storage: in.GetStorage(),
+		Path:    in.GetPath(),
+		Webhook: in.GetWebhook(),
+	}
+	if in.GetParams() != nil {
+		params, err := protojson.Marshal(in.GetParams())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid params: %v", err)
+		}
+		req.Params = params
+	}
+	if apiErr := validateCreateTaskRequest(&req); apiErr != nil {
+		return nil, status.Error(codes.InvalidArgument, apiErr.Message)
+	}
+
+	var key string
+	if v := metadata.ValueFromIncomingContext(ctx, idempotencyKeyMetadata); len(v) > 0 {
+		key = v[0]
+	}
+	token := grpcToken(ctx)
+	resp, replayed, err := idempotency.Do(ctx, token, key, requestHash(&req), func() (*CreateTaskResponse, error) {
+		if err := allowTasks(token, 1); err != nil {
+			return nil, err
+		}
+		return s.factory.CreateTask(&req)
+	})
+	if err != nil {
+		var rlErr *RateLimitError
+		if errors.As(err, &rlErr) {
+			return nil, grpcRateLimitError(ctx, rlErr)
+		}
+		if errors.Is(err, ErrIdempotencyKeyReused) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.CreateTaskResponse{
+		TaskId:    resp.TaskID,
+		Type:      string(resp.Type),
+		Status:    string(resp.Status),
+		CreatedAt: timestamppb.New(resp.CreatedAt),
+		Replayed:  replayed,
+	}, nil
+}
+
+// GetTask 获取单个任务
+func (s *GRPCServer) GetTask(ctx context.Context, in *pb.GetTaskRequest) (*pb.Task, error) {
+	task, err := lookupTask(in.GetTaskId())
+	if err != nil {
+		return nil, err
+	}
+	return taskToProto(task), nil
+}
+
+// ListTasks 列出任务
+func (s *GRPCServer) ListTasks(ctx context.Context, in *pb.ListTasksRequest) (*pb.ListTasksResponse, error) {
+	tasks := GetAllTasks()
+	resp := &pb.ListTasksResponse{
+		Tasks: make([]*pb.Task, 0, len(tasks)),
+		Total: int32(len(tasks)),
+	}
+	for _, task := range tasks {
+		resp.Tasks = append(resp.Tasks, taskToProto(task))
+	}
+	return resp, nil
+}
+
+// CancelTask 取消任务
+func (s *GRPCServer) CancelTask(ctx context.Context, in *pb.CancelTaskRequest) (*pb.CancelTaskResponse, error) {
+	task, err := lookupTask(in.GetTaskId())
+	if err != nil {
+		return nil, err
+	}
+	if err := core.CancelTask(ctx, task.TaskID); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to cancel task: %v", err)
+	}
+	task.UpdateStatus(TaskStatusCancelled)
+	return &pb.CancelTaskResponse{}, nil
+}
+
+// ListStorages 列出存储
+func (s *GRPCServer) ListStorages(ctx context.Context, in *pb.ListStoragesRequest) (*pb.ListStoragesResponse, error) {
+	resp := &pb.ListStoragesResponse{
+		Storages: make([]*pb.Storage, 0, len(storage.Storages)),
+	}
+	for name, stor := range storage.Storages {
+		resp.Storages = append(resp.Storages, &pb.Storage{
+			Name: name,
+			Type: string(stor.Type()),
+		})
+	}
+	return resp, nil
+}
+
+// ListTaskTypes 获取支持的任务类型
+func (s *GRPCServer) ListTaskTypes(ctx context.Context, in *pb.ListTaskTypesRequest) (*pb.ListTaskTypesResponse, error) {
+	resp := &pb.ListTaskTypesResponse{
+		Types: make([]string, 0, len(supportedTaskTypes)),
+	}
+	for _, t := range supportedTaskTypes {
+		resp.Types = append(resp.Types, string(t))
+	}
+	return resp, nil
+}
+
+// WatchTask 推送任务的当前状态, 之后每次任务事件推送一次快照, 任务结束后关闭流
+func (s *GRPCServer) WatchTask(in *pb.WatchTaskRequest, stream grpc.ServerStreamingServer[pb.Task]) error {
+	task, err := lookupTask(in.GetTaskId())
+	if err != nil {
+		return err
+	}
+
+	changed, stop := task.Watch()
+	defer stop()
+
+	for {
+		if err := stream.Send(taskToProto(task)); err != nil {
+			return err
+		}
+		if task.IsTerminal() {
+			return nil
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-changed:
+		}
+	}
+}
+
+func lookupTask(taskID string) (*TaskProgressInfo, error) {
+	if taskID == "" {
+		return nil, status.Error(codes.InvalidArgument, "task ID is required")
+	}
+	task, ok := GetTask(taskID)
+	if !ok {
+		return nil, status.Error(codes.NotFound, "task not found: "+taskID)
+	}
+	return task, nil
+}
+
+// taskToProto 复用 REST API 的响应转换, 保证两种接口返回的数据一致
+func taskToProto(task *TaskProgressInfo) *pb.Task {
+	info := convertTaskProgressToResponse(task)
+	t := &pb.Task{
+		TaskId:    info.TaskID,
+		Type:      string(info.Type),
+		Status:    string(info.Status),
+		Title:     info.Title,
+		Storage:   info.Storage,
+		Path:      info.Path,
+		Error:     info.Error,
+		CreatedAt: timestamppb.New(info.CreatedAt),
+		UpdatedAt: timestamppb.New(info.UpdatedAt),
+	}
+	if p := info.Progress; p != nil {
+		t.Progress = &pb.TaskProgress{
+			TotalBytes:      p.TotalBytes,
+			DownloadedBytes: p.DownloadedBytes,
+			TotalFiles:      int32(p.TotalFiles),
+			DownloadedFiles: int32(p.DownloadedFiles),
+			Percent:         p.Percent,
+			SpeedMbps:       p.SpeedMBPS,
+		}
+	}
+	return t
+}
+
+// checkGRPCToken 校验 authorization 元数据, 规则与 AuthMiddleware 相同, 并检查请求速率限制
+func checkGRPCToken(ctx context.Context) error {
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+	parts := strings.SplitN(values[0], " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+	}
+	if subtle.ConstantTimeCompare([]byte(parts[1]), []byte(config.C().API.Token)) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if err := allowRequest(parts[1]); err != nil {
+		return grpcRateLimitError(ctx, err)
+	}
+	return nil
+}
+
+// grpcToken 返回已通过校验的 token
+func grpcToken(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return ""
+	}
+	_, token, _ := strings.Cut(values[0], " ")
+	return token
+}
+
+// grpcRateLimitError 返回 ResourceExhausted, 并通过 retry-after 元数据告知重试等待秒数
+func grpcRateLimitError(ctx context.Context, err *RateLimitError) error {
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(err.retryAfterSeconds())))
+	return status.Error(codes.ResourceExhausted, err.Error())
+}
+
+func grpcAuthUnaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := checkGRPCToken(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func grpcAuthStreamInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := checkGRPCToken(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// newGRPCServer 创建带鉴权拦截器的 grpc.Server
+func newGRPCServer(factory *TaskFactory) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(grpcAuthUnaryInterceptor),
+		grpc.ChainStreamInterceptor(grpcAuthStreamInterceptor),
+	}
+	if limit := config.C().API.Limit.MaxBodySize; limit > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(limit)))
+	}
+	server := grpc.NewServer(opts...)
+	pb.RegisterSaveAnyServer(server, NewGRPCServer(factory))
+	return server
+}
+
+// startGRPC 启动 gRPC 服务, 与 HTTP API 共用 TaskFactory
+func startGRPC(ctx context.Context, factory *TaskFactory) error {
+	cfg := config.C().API
+	logger := log.FromContext(ctx).With("module", "grpc")
+
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.GRPC.Port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := newGRPCServer(factory)
+	logger.Infof("Starting gRPC server on %s", addr)
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			logger.Errorf("gRPC server error: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		// WatchTask 流可能一直保持, 超时后强制关闭
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			server.Stop()
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/api/pb"
+	"github.com/krau/SaveAny-Bot/pkg/taskevent"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// setupTestGRPC starts the gRPC service on an in-memory listener. The API
+// token in the test config is empty, so an empty bearer token authenticates.
+func setupTestGRPC(t *testing.T) pb.SaveAnyClient {
+	lis := bufconn.Listen(1 << 20)
+	server := newGRPCServer(NewTaskFactory(t.Context()))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewSaveAnyClient(conn)
+}
+
+func authed(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer ")
+}
+
+func TestGRPCAuth(t *testing.T) {
+	client := setupTestGRPC(t)
+
+	_, err := client.ListTaskTypes(t.Context(), &pb.ListTaskTypesRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without token, got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer wrong")
+	_, err = client.ListTaskTypes(ctx, &pb.ListTaskTypesRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated with wrong token, got %v", err)
+	}
+
+	resp, err := client.ListTaskTypes(authed(t.Context()), &pb.ListTaskTypesRequest{})
+	if err != nil {
+		t.Fatalf("ListTaskTypes failed: %v", err)
+	}
+	if len(resp.GetTypes()) != len(supportedTaskTypes) {
+		t.Errorf("expected %d task types, got %d", len(supportedTaskTypes), len(resp.GetTypes()))
+	}
+}
+
+func TestGRPCCreateAndGetTask(t *testing.T) {
+	client := setupTestGRPC(t)
+	ctx := authed(t.Context())
+
+	_, err := client.CreateTask(ctx, &pb.CreateTaskRequest{Type: "directlinks"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for missing storage, got %v", err)
+	}
+
+	_, err = client.GetTask(ctx, &pb.GetTaskRequest{TaskId: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+
+	RegisterTask("grpc-get", "directlinks", "local", "/x", "title", "")
+	t.Cleanup(func() { DeleteTask("grpc-get") })
+	task, err := client.GetTask(ctx, &pb.GetTaskRequest{TaskId: "grpc-get"})
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	if task.GetStatus() != string(TaskStatusQueued) || task.GetStorage() != "local" {
+		t.Errorf("unexpected task: %v", task)
+	}
+}
+
+func TestGRPCWatchTask(t *testing.T) {
+	client := setupTestGRPC(t)
+	ctx, cancel := context.WithTimeout(authed(t.Context()), 5*time.Second)
+	defer cancel()
+
+	info := RegisterTask("grpc-watch", "directlinks", "local", "/x", "title", "")
+	t.Cleanup(func() { DeleteTask("grpc-watch") })
+	taskCtx := taskevent.WithSink(t.Context(), info)
+
+	stream, err := client.WatchTask(ctx, &pb.WatchTaskRequest{TaskId: "grpc-watch"})
+	if err != nil {
+		t.Fatalf("WatchTask failed: %v", err)
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if first.GetStatus() != string(TaskStatusQueued) {
+		t.Errorf("expected first snapshot to be queued, got %s", first.GetStatus())
+	}
+
+	taskevent.Emit(taskCtx, taskevent.Event{TaskID: "grpc-watch", Phase: taskevent.PhaseStart, TotalBytes: 100})
+	taskevent.Emit(taskCtx, taskevent.Event{TaskID: "grpc-watch", Phase: taskevent.PhaseDone})
+
+	var last *pb.Task
+	for {
+		task, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		last = task
+	}
+	if last == nil || last.GetStatus() != string(TaskStatusCompleted) {
+		t.Fatalf("expected stream to end with a completed snapshot, got %v", last)
+	}
+}
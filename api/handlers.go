@@ -2,11 +2,17 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
 	"github.com/krau/SaveAny-Bot/storage"
 )
@@ -30,31 +36,136 @@ func (h *Handlers) CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 
 	var req CreateTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteError(w, http.StatusBadRequest, "invalid_request", "failed to decode request body: "+err.Error())
+		writeDecodeError(w, err)
 		return
 	}
 
 	// 验证请求
-	if req.Type == "" {
-		WriteError(w, http.StatusBadRequest, "invalid_request", "task type is required")
-		return
-	}
-
-	if req.Storage == "" {
-		WriteError(w, http.StatusBadRequest, "invalid_request", "storage is required")
+	if apiErr := validateCreateTaskRequest(&req); apiErr != nil {
+		WriteError(w, apiErr.StatusCode, apiErr.ErrorCode, apiErr.Message)
 		return
 	}
 
-	// 创建任务
-	resp, err := h.factory.CreateTask(&req)
+	// 创建任务, 相同的 Idempotency-Key 直接返回原任务, 重放不计入任务速率限制
+	token := tokenFromContext(r.Context())
+	resp, replayed, err := idempotency.Do(r.Context(), token, r.Header.Get(IdempotencyKeyHeader), requestHash(&req), func() (*CreateTaskResponse, error) {
+		if err := allowTasks(token, 1); err != nil {
+			return nil, err
+		}
+		return h.factory.CreateTask(&req)
+	})
 	if err != nil {
+		var rlErr *RateLimitError
+		if errors.As(err, &rlErr) {
+			writeRateLimitError(w, rlErr)
+			return
+		}
+		if errors.Is(err, ErrIdempotencyKeyReused) {
+			WriteError(w, http.StatusUnprocessableEntity, "idempotency_key_reused", err.Error())
+			return
+		}
 		WriteError(w, http.StatusBadRequest, "task_creation_failed", err.Error())
 		return
 	}
 
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+		WriteJSON(w, http.StatusOK, resp)
+		return
+	}
 	WriteJSON(w, http.StatusCreated, resp)
 }
 
+// BatchCreateTaskHandler 批量创建任务处理器
+// 请求体为 CreateTaskRequest 数组, 每个任务独立创建并返回各自的结果.
+// 若提供了 Idempotency-Key, 第 i 个任务使用 "<key>/<i>" 作为其幂等键.
+func (h *Handlers) BatchCreateTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, "method_not_allowed", "only POST method is allowed")
+		return
+	}
+
+	var reqs []CreateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if len(reqs) == 0 {
+		WriteError(w, http.StatusBadRequest, "invalid_request", "at least one task is required")
+		return
+	}
+	if maxSize := maxBatchSize(); maxSize > 0 && len(reqs) > maxSize {
+		WriteError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("too many tasks in batch: %d > %d", len(reqs), maxSize))
+		return
+	}
+
+	token := tokenFromContext(r.Context())
+	key := r.Header.Get(IdempotencyKeyHeader)
+	resp := BatchCreateTaskResponse{
+		Results: make([]BatchTaskResult, 0, len(reqs)),
+	}
+	for i := range reqs {
+		result := BatchTaskResult{Index: i}
+		if apiErr := validateCreateTaskRequest(&reqs[i]); apiErr != nil {
+			result.Error = &ErrorResponse{Error: apiErr.ErrorCode, Message: apiErr.Message}
+			resp.Results = append(resp.Results, result)
+			resp.Failed++
+			continue
+		}
+
+		itemKey := ""
+		if key != "" {
+			itemKey = key + "/" + strconv.Itoa(i)
+		}
+		// 每个新建的任务计入任务速率限制, 重放和校验失败的任务不计入
+		task, replayed, err := idempotency.Do(r.Context(), token, itemKey, requestHash(&reqs[i]), func() (*CreateTaskResponse, error) {
+			if err := allowTasks(token, 1); err != nil {
+				return nil, err
+			}
+			return h.factory.CreateTask(&reqs[i])
+		})
+		var rlErr *RateLimitError
+		if errors.As(err, &rlErr) {
+			result.Error = &ErrorResponse{Error: "rate_limited", Message: err.Error()}
+			resp.Failed++
+		} else if errors.Is(err, ErrIdempotencyKeyReused) {
+			result.Error = &ErrorResponse{Error: "idempotency_key_reused", Message: err.Error()}
+			resp.Failed++
+		} else if err != nil {
+			result.Error = &ErrorResponse{Error: "task_creation_failed", Message: err.Error()}
+			resp.Failed++
+		} else {
+			result.Task = task
+			result.Replayed = replayed
+			resp.Succeeded++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// maxBatchSize 返回单次批量请求允许的最大任务数, 不超过每分钟的任务额度, 0 为不限制
+func maxBatchSize() int {
+	maxSize := config.C().API.MaxBatchSize
+	if perMinute := config.C().API.Limit.TasksPerMinute; perMinute > 0 && (maxSize <= 0 || perMinute < maxSize) {
+		maxSize = perMinute
+	}
+	return maxSize
+}
+
+// validateCreateTaskRequest 检查创建任务请求的必填字段
+func validateCreateTaskRequest(req *CreateTaskRequest) *APIError {
+	if req.Type == "" {
+		return &APIError{StatusCode: http.StatusBadRequest, ErrorCode: "invalid_request", Message: "task type is required"}
+	}
+	if req.Storage == "" {
+		return &APIError{StatusCode: http.StatusBadRequest, ErrorCode: "invalid_request", Message: "storage is required"}
+	}
+	return nil
+}
+
 // ListTasksHandler 列出任务处理器
 func (h *Handlers) ListTasksHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -146,6 +257,40 @@ func (h *Handlers) ListStoragesHandler(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, StoragesResponse{Storages: storages})
 }
 
+// TestRulesHandler 对消息链接或模拟的文件执行 user_id 指定用户的规则, 不创建任务
+func (h *Handlers) TestRulesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteError(w, http.StatusMethodNotAllowed, "method_not_allowed", "only POST method is allowed")
+		return
+	}
+
+	var req RuleTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if req.UserID == 0 {
+		WriteError(w, http.StatusBadRequest, "invalid_request", "user_id is required")
+		return
+	}
+	if !slices.Contains(config.C().GetUsersID(), req.UserID) {
+		WriteError(w, http.StatusNotFound, "user_not_found", fmt.Sprintf("user not found: %d", req.UserID))
+		return
+	}
+	user, err := database.GetUserByChatID(r.Context(), req.UserID)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, "user_not_found", err.Error())
+		return
+	}
+
+	resp, apiErr := testRules(r.Context(), &req, user)
+	if apiErr != nil {
+		WriteError(w, apiErr.StatusCode, apiErr.ErrorCode, apiErr.Message)
+		return
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
 // GetTaskTypesHandler 获取支持的任务类型
 func (h *Handlers) GetTaskTypesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -153,21 +298,22 @@ func (h *Handlers) GetTaskTypesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	types := []tasktype.TaskType{
-		tasktype.TaskTypeDirectlinks,
-		tasktype.TaskTypeYtdlp,
-		tasktype.TaskTypeAria2,
-		tasktype.TaskTypeParseditem,
-		tasktype.TaskTypeTgfiles,
-		tasktype.TaskTypeTphpics,
-		tasktype.TaskTypeTransfer,
-	}
-
 	WriteJSON(w, http.StatusOK, map[string]any{
-		"types": types,
+		"types": supportedTaskTypes,
 	})
 }
 
+// supportedTaskTypes 可通过 API 创建的任务类型
+var supportedTaskTypes = []tasktype.TaskType{
+	tasktype.TaskTypeDirectlinks,
+	tasktype.TaskTypeYtdlp,
+	tasktype.TaskTypeAria2,
+	tasktype.TaskTypeParseditem,
+	tasktype.TaskTypeTgfiles,
+	tasktype.TaskTypeTphpics,
+	tasktype.TaskTypeTransfer,
+}
+
 // HealthCheckHandler 健康检查处理器
 func (h *Handlers) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, map[string]string{
@@ -4,15 +4,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
 	"github.com/krau/SaveAny-Bot/pkg/taskevent"
 )
 
@@ -693,3 +697,533 @@ func TestWebhookPayloadWithNilCompletedAt(t *testing.T) {
 		t.Error("expected completed_at to be omitted when nil")
 	}
 }
+
+// TestBatchCreateTaskHandler tests the batch create endpoint
+func TestBatchCreateTaskHandler(t *testing.T) {
+	handlers, _ := setupTestServer(t)
+
+	tests := []struct {
+		name        string
+		method      string
+		body        string
+		wantStatus  int
+		wantResults int
+		wantFailed  int
+	}{
+		{
+			name:       "Method not allowed",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "Not an array",
+			method:     http.MethodPost,
+			body:       `{"type":"directlinks"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Empty array",
+			method:     http.MethodPost,
+			body:       `[]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Per-item errors",
+			method: http.MethodPost,
+			body: `[
+				{"storage":"local"},
+				{"type":"directlinks"},
+				{"type":"directlinks","storage":"non-existent-storage","params":{"urls":["https://example.com/a"]}}
+			]`,
+			wantStatus:  http.StatusOK,
+			wantResults: 3,
+			wantFailed:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/tasks/batch", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			handlers.BatchCreateTaskHandler(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			var resp BatchCreateTaskResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if len(resp.Results) != tt.wantResults {
+				t.Errorf("expected %d results, got %d", tt.wantResults, len(resp.Results))
+			}
+			if resp.Failed != tt.wantFailed {
+				t.Errorf("expected %d failed, got %d", tt.wantFailed, resp.Failed)
+			}
+			for i, res := range resp.Results {
+				if res.Index != i {
+					t.Errorf("result %d has index %d", i, res.Index)
+				}
+				if res.Error == nil {
+					t.Errorf("result %d: expected error", i)
+				}
+			}
+		})
+	}
+}
+
+// TestBatchRateLimit tests that a batch bigger than the task burst is charged per created item
+func TestBatchRateLimit(t *testing.T) {
+	handlers, _ := setupTestServer(t)
+	const token = "batch-rate-limit-test"
+	limiters.mu.Lock()
+	limiters.limiters[token] = &tokenLimiter{
+		requests: newPerMinuteLimiter(0),
+		tasks:    newPerMinuteLimiter(2),
+	}
+	limiters.mu.Unlock()
+	t.Cleanup(func() {
+		limiters.mu.Lock()
+		delete(limiters.limiters, token)
+		limiters.mu.Unlock()
+	})
+
+	body := `[
+		{"storage":"local"},
+		{"type":"directlinks","storage":"non-existent-storage","params":{"urls":["https://example.com/a"]}},
+		{"type":"directlinks","storage":"non-existent-storage","params":{"urls":["https://example.com/b"]}},
+		{"type":"directlinks","storage":"non-existent-storage","params":{"urls":["https://example.com/c"]}}
+	]`
+	ctx := context.WithValue(t.Context(), tokenContextKey{}, token)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks/batch", strings.NewReader(body)).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handlers.BatchCreateTaskHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var resp BatchCreateTaskResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	want := []string{"invalid_request", "task_creation_failed", "task_creation_failed", "rate_limited"}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(resp.Results))
+	}
+	for i, res := range resp.Results {
+		if res.Error == nil || res.Error.Error != want[i] {
+			t.Errorf("result %d: expected error %q, got %+v", i, want[i], res.Error)
+		}
+	}
+	if tasks := limiters.get(token).taskCount.Load(); tasks != 2 {
+		t.Errorf("expected 2 tasks charged, got %d", tasks)
+	}
+}
+
+// TestIdempotencyStore tests that a key replays the first successful response
+func TestIdempotencyStore(t *testing.T) {
+	s := &idempotencyStore{entries: make(map[string]*idempotencyEntry)}
+
+	var calls int
+	var mu sync.Mutex
+	create := func() (*CreateTaskResponse, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return &CreateTaskResponse{TaskID: "task-1", Status: TaskStatusQueued}, nil
+	}
+
+	var wg sync.WaitGroup
+	var replays int
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, replayed, err := s.Do(t.Context(), "token", "key-1", "body", create)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if resp.TaskID != "task-1" {
+				t.Errorf("expected task-1, got %s", resp.TaskID)
+			}
+			if replayed {
+				mu.Lock()
+				replays++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected create to run once, ran %d times", calls)
+	}
+	if replays != 9 {
+		t.Errorf("expected 9 replays, got %d", replays)
+	}
+
+	// A failed submission must not be remembered.
+	_, _, err := s.Do(t.Context(), "token", "key-2", "body", func() (*CreateTaskResponse, error) {
+		return nil, fmt.Errorf("boom")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	resp, replayed, err := s.Do(t.Context(), "token", "key-2", "body", create)
+	if err != nil || replayed || resp.TaskID != "task-1" {
+		t.Errorf("expected fresh submission after failure, got resp=%v replayed=%v err=%v", resp, replayed, err)
+	}
+
+	// Empty key never deduplicates.
+	_, replayed, _ = s.Do(t.Context(), "token", "", "body", create)
+	if replayed {
+		t.Error("empty key must not replay")
+	}
+
+	// A key reused with another request is rejected.
+	if _, _, err := s.Do(t.Context(), "token", "key-1", "other body", create); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("expected ErrIdempotencyKeyReused, got %v", err)
+	}
+
+	// Keys of different tokens are independent.
+	_, replayed, err = s.Do(t.Context(), "other token", "key-1", "other body", create)
+	if err != nil || replayed {
+		t.Errorf("expected a fresh submission for another token, got replayed=%v err=%v", replayed, err)
+	}
+}
+
+// memoryIdempotencyRecords stands in for the database in tests
+type memoryIdempotencyRecords struct {
+	mu      sync.Mutex
+	records map[string]database.IdempotencyKey
+}
+
+func (m *memoryIdempotencyRecords) GetIdempotencyKey(ctx context.Context, tokenHash, key string, since time.Time) (*database.IdempotencyKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[tokenHash+"/"+key]
+	if !ok || !record.CreatedAt.After(since) {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (m *memoryIdempotencyRecords) SaveIdempotencyKey(ctx context.Context, record *database.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record.CreatedAt = time.Now()
+	m.records[record.TokenHash+"/"+record.Key] = *record
+	return nil
+}
+
+func (m *memoryIdempotencyRecords) DeleteIdempotencyKeys(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, record := range m.records {
+		if record.CreatedAt.Before(before) {
+			delete(m.records, id)
+		}
+	}
+	return nil
+}
+
+// TestIdempotencyPersisted tests that keys survive a restart, i.e. a new store over the same records
+func TestIdempotencyPersisted(t *testing.T) {
+	records := &memoryIdempotencyRecords{records: make(map[string]database.IdempotencyKey)}
+	create := func() (*CreateTaskResponse, error) {
+		return &CreateTaskResponse{TaskID: "task-1", Status: TaskStatusQueued}, nil
+	}
+	first := &idempotencyStore{entries: make(map[string]*idempotencyEntry), records: records}
+	if _, replayed, err := first.Do(t.Context(), "token", "key", "body", create); err != nil || replayed {
+		t.Fatalf("first submission: replayed=%v err=%v", replayed, err)
+	}
+
+	restarted := &idempotencyStore{entries: make(map[string]*idempotencyEntry), records: records}
+	resp, replayed, err := restarted.Do(t.Context(), "token", "key", "body", func() (*CreateTaskResponse, error) {
+		t.Fatal("a persisted key must not create the task again")
+		return nil, nil
+	})
+	if err != nil || !replayed || resp.TaskID != "task-1" {
+		t.Fatalf("expected replay after restart, got resp=%v replayed=%v err=%v", resp, replayed, err)
+	}
+
+	restarted = &idempotencyStore{entries: make(map[string]*idempotencyEntry), records: records}
+	if _, _, err := restarted.Do(t.Context(), "token", "key", "other body", create); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("expected ErrIdempotencyKeyReused after restart, got %v", err)
+	}
+}
+
+// TestIdempotencyKeyReusedHandler tests the replay of a key and the 422 response of a key reused with another body
+func TestIdempotencyKeyReusedHandler(t *testing.T) {
+	handlers, _ := setupTestServer(t)
+	key := fmt.Sprintf("reuse-%d", time.Now().UnixNano())
+	body := `{"type":"directlinks","storage":"local","params":{"urls":["https://example.com/a"]}}`
+	var first CreateTaskRequest
+	if err := json.Unmarshal([]byte(body), &first); err != nil {
+		t.Fatal(err)
+	}
+	// There is no storage in tests, so record the first submission of the key directly.
+	if _, _, err := idempotency.Do(t.Context(), "", key, requestHash(&first), func() (*CreateTaskResponse, error) {
+		return &CreateTaskResponse{TaskID: "task-1", Status: TaskStatusQueued}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusUnprocessableEntity} {
+		if i == 1 {
+			body = `{"type":"directlinks","storage":"local","params":{"urls":["https://example.com/b"]}}`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rr := httptest.NewRecorder()
+		handlers.CreateTaskHandler(rr, req)
+		if rr.Code != want {
+			t.Fatalf("request %d: expected status %d, got %d: %s", i, want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// TestDashboardRoutes tests the dashboard's public and session-protected routes
+func TestDashboardRoutes(t *testing.T) {
+	_, factory := setupTestServer(t)
+	handler := NewDashboard(factory).Handler()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"Index page", http.MethodGet, "/dashboard/", http.StatusOK},
+		{"Static asset", http.MethodGet, "/dashboard/app.js", http.StatusOK},
+		{"Login config", http.MethodGet, "/dashboard/api/login/config", http.StatusOK},
+		{"Invalid login code", http.MethodGet, "/dashboard/login?code=invalid", http.StatusUnauthorized},
+		{"Me without session", http.MethodGet, "/dashboard/api/me", http.StatusUnauthorized},
+		{"Tasks without session", http.MethodGet, "/dashboard/api/tasks", http.StatusUnauthorized},
+		{"Cancel without session", http.MethodDelete, "/dashboard/api/tasks/abc", http.StatusUnauthorized},
+		{"Rule test without session", http.MethodPost, "/dashboard/api/rules/test", http.StatusUnauthorized},
+		{"Unknown api route", http.MethodGet, "/dashboard/api/nope", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestDashboardTaskOwnership tests that dashboard users only see and manage their own tasks
+func TestDashboardTaskOwnership(t *testing.T) {
+	_, factory := setupTestServer(t)
+	d := NewDashboard(factory)
+	RegisterTask("owned-by-1", "directlinks", "local", "/a", "mine", "").setOwner(1)
+	RegisterTask("owned-by-2", "directlinks", "local", "/b", "theirs", "").setOwner(2)
+	t.Cleanup(func() {
+		DeleteTask("owned-by-1")
+		DeleteTask("owned-by-2")
+	})
+	user := &database.User{ChatID: 1}
+
+	rr := httptest.NewRecorder()
+	d.ListTasksHandler(rr, httptest.NewRequest(http.MethodGet, "/dashboard/api/tasks", nil), user)
+	var list TasksListResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	for _, task := range list.Tasks {
+		if task.TaskID == "owned-by-2" {
+			t.Error("task of another user is listed")
+		}
+	}
+	if !slices.ContainsFunc(list.Tasks, func(task TaskInfoResponse) bool { return task.TaskID == "owned-by-1" }) {
+		t.Error("own task is not listed")
+	}
+
+	if task, ok := GetTask("owned-by-2"); ok {
+		task.UpdateStatus(TaskStatusFailed)
+	}
+	for name, handler := range map[string]func(http.ResponseWriter, *http.Request, *database.User){
+		"cancel": d.CancelTaskHandler,
+		"retry":  d.RetryTaskHandler,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/dashboard/api/tasks/owned-by-2", nil)
+		req.SetPathValue("id", "owned-by-2")
+		rr := httptest.NewRecorder()
+		handler(rr, req, user)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s of another user's task: expected status %d, got %d", name, http.StatusNotFound, rr.Code)
+		}
+	}
+}
+
+// TestDashboardTaskRateLimit tests that dashboard task creation is limited per session user
+func TestDashboardTaskRateLimit(t *testing.T) {
+	_, factory := setupTestServer(t)
+	d := NewDashboard(factory)
+	user := &database.User{ChatID: 1 << 40}
+	key := sessionLimitKey(user.ChatID)
+	limiters.mu.Lock()
+	limiters.limiters[key] = &tokenLimiter{
+		requests: newPerMinuteLimiter(0),
+		tasks:    newPerMinuteLimiter(1),
+	}
+	limiters.mu.Unlock()
+	t.Cleanup(func() {
+		limiters.mu.Lock()
+		delete(limiters.limiters, key)
+		limiters.mu.Unlock()
+	})
+
+	body := `{"type":"directlinks","storage":"local","params":{"urls":["https://example.com/a"]}}`
+	for i, want := range []int{http.StatusForbidden, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPost, "/dashboard/api/tasks", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		d.CreateTaskHandler(rr, req, user)
+		if rr.Code != want {
+			t.Fatalf("request %d: expected status %d, got %d: %s", i, want, rr.Code, rr.Body.String())
+		}
+	}
+	if limiters.get(key).taskLimited.Load() != 1 {
+		t.Error("limited dashboard task was not counted")
+	}
+}
+
+// TestRateLimit tests per-token limiting and the 429 response
+func TestRateLimit(t *testing.T) {
+	const token = "rate-limit-test"
+	limiters.mu.Lock()
+	limiters.limiters[token] = &tokenLimiter{
+		requests: newPerMinuteLimiter(2),
+		tasks:    newPerMinuteLimiter(1),
+	}
+	limiters.mu.Unlock()
+	t.Cleanup(func() {
+		limiters.mu.Lock()
+		delete(limiters.limiters, token)
+		limiters.mu.Unlock()
+	})
+
+	handler := rateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	ctx := context.WithValue(t.Context(), tokenContextKey{}, token)
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/tasks", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("request %d: expected status %d, got %d", i, want, w.Code)
+		}
+		if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Error("expected Retry-After header on 429 response")
+		}
+	}
+
+	if err := allowTasks(token, 1); err != nil {
+		t.Fatalf("first task should be allowed: %v", err)
+	}
+	if err := allowTasks(token, 1); err == nil {
+		t.Fatal("second task should be rate limited")
+	}
+	// A batch larger than the burst can never be satisfied.
+	if err := allowTasks(token, 5); err == nil {
+		t.Fatal("oversized batch should be rate limited")
+	}
+
+	var stats *LimitStats
+	for _, s := range GetLimitStats().Tokens {
+		if s.Token == maskToken(token) {
+			stats = &s
+		}
+	}
+	if stats == nil {
+		t.Fatal("token missing from limit stats")
+	}
+	if stats.Requests != 3 || stats.RequestsLimited != 1 || stats.Tasks != 1 || stats.TasksLimited != 6 {
+		t.Errorf("unexpected stats: %+v", *stats)
+	}
+	if strings.Contains(stats.Token, token) {
+		t.Error("token should be masked in stats")
+	}
+}
+
+// TestBodyLimit tests that oversized bodies are rejected with 413
+func TestBodyLimit(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 16)
+		var req CreateTaskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	body := `{"type":"directlinks","storage":"local","params":{"urls":["https://example.com/file"]}}`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/tasks", strings.NewReader("{bad")))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// TestTestRulesHandler tests the token API rule test endpoint
+func TestTestRulesHandler(t *testing.T) {
+	handlers, _ := setupTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"Method not allowed", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"Invalid body", http.MethodPost, "{bad", http.StatusBadRequest},
+		{"Missing user", http.MethodPost, `{"file_name":"a.mkv"}`, http.StatusBadRequest},
+		{"Unknown user", http.MethodPost, `{"file_name":"a.mkv","user_id":42}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/rules/test", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			handlers.TestRulesHandler(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+
+	user := &database.User{ApplyRule: true, Rules: []database.Rule{
+		{Type: rule.FileNameRegex.String(), Data: `\.mkv$`, StorageName: "local", DirPath: "/videos"},
+	}}
+	resp, apiErr := testRules(t.Context(), &RuleTestRequest{FileName: "movie.mkv"}, user)
+	if apiErr != nil {
+		t.Fatalf("unexpected error: %v", apiErr)
+	}
+	if !resp.RuleModeEnabled || len(resp.Results) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if res := resp.Results[0]; !res.Matched || res.StorageName != "local" || res.DirPath != "/videos" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if _, apiErr := testRules(t.Context(), &RuleTestRequest{}, user); apiErr == nil || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without a file, got %v", apiErr)
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/database"
+)
+
+// IdempotencyKeyHeader is the request header clients use to make task
+// submissions safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// ErrIdempotencyKeyReused is returned when a key is sent again with a
+// different request than the one it was first used with.
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+// idempotencyRecords persists idempotency keys, so submissions retried after
+// a restart are replayed as well. database.IdempotencyKeys implements it.
+type idempotencyRecords interface {
+	// GetIdempotencyKey returns nil if the key has no record created after since
+	GetIdempotencyKey(ctx context.Context, tokenHash, key string, since time.Time) (*database.IdempotencyKey, error)
+	SaveIdempotencyKey(ctx context.Context, record *database.IdempotencyKey) error
+	DeleteIdempotencyKeys(ctx context.Context, before time.Time) error
+}
+
+// idempotencyEntry records the outcome of a submission made with a given key.
+// done is closed once resp/err are final, so concurrent retries carrying the
+// same key wait for the first submission instead of enqueueing a duplicate.
+type idempotencyEntry struct {
+	done      chan struct{}
+	bodyHash  string
+	resp      *CreateTaskResponse
+	err       error
+	createdAt time.Time
+}
+
+// idempotencyStore remembers successful submissions for a configurable window.
+// Failed submissions are forgotten immediately so the client can retry them.
+// Keys are scoped to the token that sent them.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	records idempotencyRecords // nil keeps the keys in memory only
+}
+
+var idempotency = &idempotencyStore{
+	entries: make(map[string]*idempotencyEntry),
+}
+
+// idempotencyTTL returns the configured retention window for idempotency keys.
+func idempotencyTTL() time.Duration {
+	ttl := config.C().API.IdempotencyTTL
+	if ttl <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// hashString returns the hex SHA-256 of s.
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestHash returns the hash of a decoded request, so requests differing
+// only in formatting get the same hash.
+func requestHash(req any) string {
+	data, _ := json.Marshal(req)
+	return hashString(string(data))
+}
+
+// Do runs create at most once per token and key within the retention window.
+// If a submission with the same key already succeeded (or is still in flight),
+// its response is returned instead and replayed reports true, unless it was
+// made with another request, then ErrIdempotencyKeyReused is returned.
+// An empty key disables deduplication.
+func (s *idempotencyStore) Do(ctx context.Context, token, key, bodyHash string, create func() (*CreateTaskResponse, error)) (resp *CreateTaskResponse, replayed bool, err error) {
+	if key == "" {
+		resp, err = create()
+		return resp, false, err
+	}
+
+	tokenHash := hashString(token)
+	id := tokenHash + "/" + key
+	s.mu.Lock()
+	if entry, ok := s.entries[id]; ok && time.Since(entry.createdAt) <= idempotencyTTL() {
+		s.mu.Unlock()
+		<-entry.done
+		if entry.err != nil {
+			// The first attempt failed and has been dropped; try again.
+			return s.Do(ctx, token, key, bodyHash, create)
+		}
+		if entry.bodyHash != bodyHash {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+		return entry.resp, true, nil
+	}
+	entry := &idempotencyEntry{
+		done:      make(chan struct{}),
+		bodyHash:  bodyHash,
+		createdAt: time.Now(),
+	}
+	s.entries[id] = entry
+	s.mu.Unlock()
+	defer close(entry.done)
+
+	replayed, entry.err = s.load(ctx, tokenHash, key, entry)
+	if entry.err == nil && !replayed {
+		entry.resp, entry.err = create()
+		if entry.err == nil {
+			s.save(ctx, tokenHash, key, entry)
+		}
+	}
+	if entry.err != nil {
+		s.mu.Lock()
+		if s.entries[id] == entry {
+			delete(s.entries, id)
+		}
+		s.mu.Unlock()
+		return nil, false, entry.err
+	}
+	if entry.bodyHash != bodyHash {
+		return nil, false, ErrIdempotencyKeyReused
+	}
+	return entry.resp, replayed, nil
+}
+
+// load fills the entry from the stored record of the key, reporting whether there is one.
+func (s *idempotencyStore) load(ctx context.Context, tokenHash, key string, entry *idempotencyEntry) (bool, error) {
+	if s.records == nil {
+		return false, nil
+	}
+	record, err := s.records.GetIdempotencyKey(ctx, tokenHash, key, time.Now().Add(-idempotencyTTL()))
+	if err != nil || record == nil {
+		return false, err
+	}
+	var resp CreateTaskResponse
+	if err := json.Unmarshal([]byte(record.Response), &resp); err != nil {
+		return false, err
+	}
+	entry.resp = &resp
+	entry.bodyHash = record.BodyHash
+	entry.createdAt = record.CreatedAt
+	return true, nil
+}
+
+// save stores the successful submission, the task is created already so failures are only logged.
+func (s *idempotencyStore) save(ctx context.Context, tokenHash, key string, entry *idempotencyEntry) {
+	if s.records == nil {
+		return
+	}
+	data, err := json.Marshal(entry.resp)
+	if err == nil {
+		err = s.records.SaveIdempotencyKey(ctx, &database.IdempotencyKey{
+			TokenHash: tokenHash,
+			Key:       key,
+			BodyHash:  entry.bodyHash,
+			Response:  string(data),
+		})
+	}
+	if err != nil {
+		log.FromContext(ctx).Warn("Failed to save idempotency key", "error", err)
+	}
+}
+
+// cleanup drops keys older than the retention window.
+func (s *idempotencyStore) cleanup() {
+	ttl := idempotencyTTL()
+	now := time.Now()
+	s.mu.Lock()
+	for key, entry := range s.entries {
+		select {
+		case <-entry.done:
+		default:
+			continue // still in flight
+		}
+		if now.Sub(entry.createdAt) > ttl {
+			delete(s.entries, key)
+		}
+	}
+	s.mu.Unlock()
+	if s.records != nil {
+		if err := s.records.DeleteIdempotencyKeys(context.Background(), now.Add(-ttl)); err != nil {
+			log.Warn("Failed to delete expired idempotency keys", "error", err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/common/metrics"
+	"github.com/krau/SaveAny-Bot/config"
+	"golang.org/x/time/rate"
+)
+
+// limitKind 区分请求限制与任务创建限制
+type limitKind string
+
+const (
+	limitRequests limitKind = "requests"
+	limitTasks    limitKind = "tasks"
+)
+
+// RateLimitError 表示触发了速率限制, RetryAfter 为建议的重试等待时间
+type RateLimitError struct {
+	Kind       limitKind
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limit exceeded, retry after %s", e.Kind, e.RetryAfter)
+}
+
+// retryAfterSeconds 返回 Retry-After 头使用的整数秒数
+func (e *RateLimitError) retryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// tokenLimiter 单个 Token 的限流器和计数
+type tokenLimiter struct {
+	requests *rate.Limiter
+	tasks    *rate.Limiter
+
+	requestCount   atomic.Int64
+	requestLimited atomic.Int64
+	taskCount      atomic.Int64
+	taskLimited    atomic.Int64
+	lastSeen       atomic.Int64
+}
+
+type limiterStore struct {
+	mu       sync.Mutex
+	limiters map[string]*tokenLimiter
+}
+
+var limiters = &limiterStore{
+	limiters: make(map[string]*tokenLimiter),
+}
+
+func newPerMinuteLimiter(n int) *rate.Limiter {
+	if n <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Limit(float64(n)/60), n)
+}
+
+func (s *limiterStore) get(token string) *tokenLimiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.limiters[token]
+	if !ok {
+		cfg := config.C().API.Limit
+		l = &tokenLimiter{
+			requests: newPerMinuteLimiter(cfg.RequestsPerMinute),
+			tasks:    newPerMinuteLimiter(cfg.TasksPerMinute),
+		}
+		s.limiters[token] = l
+	}
+	l.lastSeen.Store(time.Now().Unix())
+	return l
+}
+
+// reserve 尝试立即获取 n 个令牌, 失败时返回 RateLimitError 且不消耗令牌
+func reserve(lim *rate.Limiter, kind limitKind, n int) *RateLimitError {
+	now := time.Now()
+	r := lim.ReserveN(now, n)
+	if !r.OK() {
+		// n 超过了桶容量, 永远无法满足, 按一分钟后重试处理
+		metrics.APIRateLimited.WithLabelValues(string(kind)).Inc()
+		return &RateLimitError{Kind: kind, RetryAfter: time.Minute}
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		metrics.APIRateLimited.WithLabelValues(string(kind)).Inc()
+		return &RateLimitError{Kind: kind, RetryAfter: delay}
+	}
+	return nil
+}
+
+// allowRequest 记录一次请求并检查请求速率限制
+func allowRequest(token string) *RateLimitError {
+	l := limiters.get(token)
+	l.requestCount.Add(1)
+	if err := reserve(l.requests, limitRequests, 1); err != nil {
+		l.requestLimited.Add(1)
+		return err
+	}
+	return nil
+}
+
+// allowTasks 检查 n 个任务的创建速率限制
+func allowTasks(token string, n int) error {
+	l := limiters.get(token)
+	if err := reserve(l.tasks, limitTasks, n); err != nil {
+		l.taskLimited.Add(int64(n))
+		return err
+	}
+	l.taskCount.Add(int64(n))
+	return nil
+}
+
+// sessionLimitKey 返回面板用户在限流器中使用的 key, 面板使用登录会话而非 token 鉴权
+func sessionLimitKey(chatID int64) string {
+	return "user:" + strconv.FormatInt(chatID, 10)
+}
+
+// tokenFromContext 返回鉴权中间件写入的 token
+func tokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(tokenContextKey{}).(string)
+	return token
+}
+
+// checkURLCount 检查单个任务中的 URL 数量
+func checkURLCount(n int) error {
+	if limit := config.C().API.Limit.MaxURLs; limit > 0 && n > limit {
+		return fmt.Errorf("too many URLs in task: %d > %d", n, limit)
+	}
+	return nil
+}
+
+// writeRateLimitError 写入 429 响应
+func writeRateLimitError(w http.ResponseWriter, err *RateLimitError) {
+	w.Header().Set("Retry-After", strconv.Itoa(err.retryAfterSeconds()))
+	WriteError(w, http.StatusTooManyRequests, "rate_limited", err.Error())
+}
+
+// writeDecodeError 写入请求体解析失败的响应, 超过大小限制时返回 413
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		WriteError(w, http.StatusRequestEntityTooLarge, "request_too_large", fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit))
+		return
+	}
+	WriteError(w, http.StatusBadRequest, "invalid_request", "failed to decode request body: "+err.Error())
+}
+
+// bodyLimitMiddleware 限制请求体大小
+func bodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limit := config.C().API.Limit.MaxBodySize; limit > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitMiddleware 按 token 限制请求速率, 需在鉴权中间件之后执行
+func rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := allowRequest(tokenFromContext(r.Context())); err != nil {
+			writeRateLimitError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitStats 单个 Token 的限流计数
+type LimitStats struct {
+	Token           string    `json:"token"`
+	Requests        int64     `json:"requests"`
+	RequestsLimited int64     `json:"requests_limited"`
+	Tasks           int64     `json:"tasks"`
+	TasksLimited    int64     `json:"tasks_limited"`
+	LastSeen        time.Time `json:"last_seen"`
+}
+
+// LimitsResponse 限流状态响应
+type LimitsResponse struct {
+	RequestsPerMinute int          `json:"requests_per_minute"`
+	TasksPerMinute    int          `json:"tasks_per_minute"`
+	MaxBodySize       int64        `json:"max_body_size"`
+	MaxURLs           int          `json:"max_urls"`
+	Tokens            []LimitStats `json:"tokens"`
+}
+
+// maskToken 只保留 token 的前 4 位
+func maskToken(token string) string {
+	if len(token) <= 4 {
+		return "****"
+	}
+	return token[:4] + "****"
+}
+
+// GetLimitStats 返回当前配置与各 Token 的计数
+func GetLimitStats() LimitsResponse {
+	cfg := config.C().API.Limit
+	resp := LimitsResponse{
+		RequestsPerMinute: cfg.RequestsPerMinute,
+		TasksPerMinute:    cfg.TasksPerMinute,
+		MaxBodySize:       cfg.MaxBodySize,
+		MaxURLs:           cfg.MaxURLs,
+		Tokens:            make([]LimitStats, 0),
+	}
+	limiters.mu.Lock()
+	for token, l := range limiters.limiters {
+		resp.Tokens = append(resp.Tokens, LimitStats{
+			Token:           maskToken(token),
+			Requests:        l.requestCount.Load(),
+			RequestsLimited: l.requestLimited.Load(),
+			Tasks:           l.taskCount.Load(),
+			TasksLimited:    l.taskLimited.Load(),
+			LastSeen:        time.Unix(l.lastSeen.Load(), 0),
+		})
+	}
+	limiters.mu.Unlock()
+	sort.Slice(resp.Tokens, func(i, j int) bool {
+		return resp.Tokens[i].Token < resp.Tokens[j].Token
+	})
+	return resp
+}
+
+// LimitsHandler 查看限流状态
+func (h *Handlers) LimitsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, "method_not_allowed", "only GET method is allowed")
+		return
+	}
+	WriteJSON(w, http.StatusOK, GetLimitStats())
+}
@@ -0,0 +1,369 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: saveany.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SaveAny_CreateTask_FullMethodName    = "/saveany.v1.SaveAny/CreateTask"
+	SaveAny_GetTask_FullMethodName       = "/saveany.v1.SaveAny/GetTask"
+	SaveAny_ListTasks_FullMethodName     = "/saveany.v1.SaveAny/ListTasks"
+	SaveAny_CancelTask_FullMethodName    = "/saveany.v1.SaveAny/CancelTask"
+	SaveAny_ListStorages_FullMethodName  = "/saveany.v1.SaveAny/ListStorages"
+	SaveAny_ListTaskTypes_FullMethodName = "/saveany.v1.SaveAny/ListTaskTypes"
+	SaveAny_WatchTask_FullMethodName     = "/saveany.v1.SaveAny/WatchTask"
+)
+
+// SaveAnyClient is the client API for SaveAny service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SaveAny exposes the same operations as the REST API.
+//
+// Requests must carry the API token in the "authorization" metadata as
+// "Bearer <token>". CreateTask honours an optional "idempotency-key" metadata
+// entry the same way the REST API honours the Idempotency-Key header.
+type SaveAnyClient interface {
+	CreateTask(ctx context.Context, in *CreateTaskRequest, opts ...grpc.CallOption) (*CreateTaskResponse, error)
+	GetTask(ctx context.Context, in *GetTaskRequest, opts ...grpc.CallOption) (*Task, error)
+	ListTasks(ctx context.Context, in *ListTasksRequest, opts ...grpc.CallOption) (*ListTasksResponse, error)
+	CancelTask(ctx context.Context, in *CancelTaskRequest, opts ...grpc.CallOption) (*CancelTaskResponse, error)
+	ListStorages(ctx context.Context, in *ListStoragesRequest, opts ...grpc.CallOption) (*ListStoragesResponse, error)
+	ListTaskTypes(ctx context.Context, in *ListTaskTypesRequest, opts ...grpc.CallOption) (*ListTaskTypesResponse, error)
+	// WatchTask sends the current state of a task and then a new snapshot on
+	// every progress event, ending once the task reaches a terminal status.
+	WatchTask(ctx context.Context, in *WatchTaskRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Task], error)
+}
+
+type saveAnyClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSaveAnyClient(cc grpc.ClientConnInterface) SaveAnyClient {
+	return &saveAnyClient{cc}
+}
+
+func (c *saveAnyClient) CreateTask(ctx context.Context, in *CreateTaskRequest, opts ...grpc.CallOption) (*CreateTaskResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateTaskResponse)
+	err := c.cc.Invoke(ctx, SaveAny_CreateTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *saveAnyClient) GetTask(ctx context.Context, in *GetTaskRequest, opts ...grpc.CallOption) (*Task, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Task)
+	err := c.cc.Invoke(ctx, SaveAny_GetTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *saveAnyClient) ListTasks(ctx context.Context, in *ListTasksRequest, opts ...grpc.CallOption) (*ListTasksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTasksResponse)
+	err := c.cc.Invoke(ctx, SaveAny_ListTasks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *saveAnyClient) CancelTask(ctx context.Context, in *CancelTaskRequest, opts ...grpc.CallOption) (*CancelTaskResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelTaskResponse)
+	err := c.cc.Invoke(ctx, SaveAny_CancelTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *saveAnyClient) ListStorages(ctx context.Context, in *ListStoragesRequest, opts ...grpc.CallOption) (*ListStoragesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListStoragesResponse)
+	err := c.cc.Invoke(ctx, SaveAny_ListStorages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *saveAnyClient) ListTaskTypes(ctx context.Context, in *ListTaskTypesRequest, opts ...grpc.CallOption) (*ListTaskTypesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTaskTypesResponse)
+	err := c.cc.Invoke(ctx, SaveAny_ListTaskTypes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *saveAnyClient) WatchTask(ctx context.Context, in *WatchTaskRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Task], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SaveAny_ServiceDesc.Streams[0], SaveAny_WatchTask_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchTaskRequest, Task]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SaveAny_WatchTaskClient = grpc.ServerStreamingClient[Task]
+
+// SaveAnyServer is the server API for SaveAny service.
+// All implementations must embed UnimplementedSaveAnyServer
+// for forward compatibility.
+//
+// SaveAny exposes the same operations as the REST API.
+//
+// Requests must carry the API token in the "authorization" metadata as
+// "Bearer <token>". CreateTask honours an optional "idempotency-key" metadata
+// entry the same way the REST API honours the Idempotency-Key header.
+type SaveAnyServer interface {
+	CreateTask(context.Context, *CreateTaskRequest) (*CreateTaskResponse, error)
+	GetTask(context.Context, *GetTaskRequest) (*Task, error)
+	ListTasks(context.Context, *ListTasksRequest) (*ListTasksResponse, error)
+	CancelTask(context.Context, *CancelTaskRequest) (*CancelTaskResponse, error)
+	ListStorages(context.Context, *ListStoragesRequest) (*ListStoragesResponse, error)
+	ListTaskTypes(context.Context, *ListTaskTypesRequest) (*ListTaskTypesResponse, error)
+	// WatchTask sends the current state of a task and then a new snapshot on
+	// every progress event, ending once the task reaches a terminal status.
+	WatchTask(*WatchTaskRequest, grpc.ServerStreamingServer[Task]) error
+	mustEmbedUnimplementedSaveAnyServer()
+}
+
+// UnimplementedSaveAnyServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSaveAnyServer struct{}
+
+func (UnimplementedSaveAnyServer) CreateTask(context.Context, *CreateTaskRequest) (*CreateTaskResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateTask not implemented")
+}
+func (UnimplementedSaveAnyServer) GetTask(context.Context, *GetTaskRequest) (*Task, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetTask not implemented")
+}
+func (UnimplementedSaveAnyServer) ListTasks(context.Context, *ListTasksRequest) (*ListTasksResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListTasks not implemented")
+}
+func (UnimplementedSaveAnyServer) CancelTask(context.Context, *CancelTaskRequest) (*CancelTaskResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CancelTask not implemented")
+}
+func (UnimplementedSaveAnyServer) ListStorages(context.Context, *ListStoragesRequest) (*ListStoragesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListStorages not implemented")
+}
+func (UnimplementedSaveAnyServer) ListTaskTypes(context.Context, *ListTaskTypesRequest) (*ListTaskTypesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListTaskTypes not implemented")
+}
+func (UnimplementedSaveAnyServer) WatchTask(*WatchTaskRequest, grpc.ServerStreamingServer[Task]) error {
+	return status.Error(codes.Unimplemented, "method WatchTask not implemented")
+}
+func (UnimplementedSaveAnyServer) mustEmbedUnimplementedSaveAnyServer() {}
+func (UnimplementedSaveAnyServer) testEmbeddedByValue()                 {}
+
+// UnsafeSaveAnyServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SaveAnyServer will
+// result in compilation errors.
+type UnsafeSaveAnyServer interface {
+	mustEmbedUnimplementedSaveAnyServer()
+}
+
+func RegisterSaveAnyServer(s grpc.ServiceRegistrar, srv SaveAnyServer) {
+	// If the following call panics, it indicates UnimplementedSaveAnyServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SaveAny_ServiceDesc, srv)
+}
+
+func _SaveAny_CreateTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SaveAnyServer).CreateTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SaveAny_CreateTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SaveAnyServer).CreateTask(ctx, req.(*CreateTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SaveAny_GetTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SaveAnyServer).GetTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SaveAny_GetTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SaveAnyServer).GetTask(ctx, req.(*GetTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SaveAny_ListTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTasksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SaveAnyServer).ListTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SaveAny_ListTasks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SaveAnyServer).ListTasks(ctx, req.(*ListTasksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SaveAny_CancelTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SaveAnyServer).CancelTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SaveAny_CancelTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SaveAnyServer).CancelTask(ctx, req.(*CancelTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SaveAny_ListStorages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStoragesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SaveAnyServer).ListStorages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SaveAny_ListStorages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SaveAnyServer).ListStorages(ctx, req.(*ListStoragesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SaveAny_ListTaskTypes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTaskTypesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SaveAnyServer).ListTaskTypes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SaveAny_ListTaskTypes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SaveAnyServer).ListTaskTypes(ctx, req.(*ListTaskTypesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SaveAny_WatchTask_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTaskRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SaveAnyServer).WatchTask(m, &grpc.GenericServerStream[WatchTaskRequest, Task]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SaveAny_WatchTaskServer = grpc.ServerStreamingServer[Task]
+
+// SaveAny_ServiceDesc is the grpc.ServiceDesc for SaveAny service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SaveAny_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "saveany.v1.SaveAny",
+	HandlerType: (*SaveAnyServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateTask",
+			Handler:    _SaveAny_CreateTask_Handler,
+		},
+		{
+			MethodName: "GetTask",
+			Handler:    _SaveAny_GetTask_Handler,
+		},
+		{
+			MethodName: "ListTasks",
+			Handler:    _SaveAny_ListTasks_Handler,
+		},
+		{
+			MethodName: "CancelTask",
+			Handler:    _SaveAny_CancelTask_Handler,
+		},
+		{
+			MethodName: "ListStorages",
+			Handler:    _SaveAny_ListStorages_Handler,
+		},
+		{
+			MethodName: "ListTaskTypes",
+			Handler:    _SaveAny_ListTaskTypes_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTask",
+			Handler:       _SaveAny_WatchTask_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "saveany.proto",
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/krau/SaveAny-Bot/pkg/taskevent"
+	"github.com/krau/SaveAny-Bot/pkg/webauth"
 )
 
 // TaskProgressInfo stores the progress of an API-submitted task. All fields are
@@ -28,6 +29,9 @@ type TaskProgressInfo struct {
 	StartedAt        time.Time
 	Webhook          string
 	webhookNotified  bool
+	request          *CreateTaskRequest
+	owner            int64 // chat ID of the dashboard user who created the task, zero for API tokens
+	watchers         map[chan struct{}]struct{}
 }
 
 // progressStore holds all API tasks. Entries are removed a fixed duration after
@@ -99,7 +103,7 @@ func CleanupExpired() {
 	defer store.mu.Unlock()
 	for id, info := range store.tasks {
 		info.mu.Lock()
-		terminal := info.Status == TaskStatusCompleted || info.Status == TaskStatusFailed || info.Status == TaskStatusCancelled
+		terminal := info.Status.IsTerminal()
 		stale := terminal && now.Sub(info.UpdatedAt) > store.retention
 		info.mu.Unlock()
 		if stale {
@@ -108,7 +112,8 @@ func CleanupExpired() {
 	}
 }
 
-// StartCleanupLoop runs CleanupExpired on a fixed interval until ctx is done.
+// StartCleanupLoop runs CleanupExpired and drops expired idempotency keys and
+// dashboard sessions on a fixed interval until ctx is done.
 // It should be started once during API server initialization.
 func StartCleanupLoop(ctx interface{ Done() <-chan struct{} }) {
 	go func() {
@@ -120,6 +125,8 @@ func StartCleanupLoop(ctx interface{ Done() <-chan struct{} }) {
 				return
 			case <-ticker.C:
 				CleanupExpired()
+				idempotency.cleanup()
+				webauth.Cleanup()
 			}
 		}
 	}()
@@ -133,6 +140,7 @@ func (t *TaskProgressInfo) UpdateStatus(status TaskStatus) {
 	if status == TaskStatusRunning && t.StartedAt.IsZero() {
 		t.StartedAt = t.UpdatedAt
 	}
+	t.notifyLocked()
 	t.mu.Unlock()
 }
 
@@ -142,9 +150,73 @@ func (t *TaskProgressInfo) SetError(err string) {
 	t.Error = err
 	t.Status = TaskStatusFailed
 	t.UpdatedAt = time.Now()
+	t.notifyLocked()
 	t.mu.Unlock()
 }
 
+// setRequest records the request the task was created from so it can be
+// resubmitted later.
+func (t *TaskProgressInfo) setRequest(req *CreateTaskRequest) {
+	t.mu.Lock()
+	t.request = req
+	t.mu.Unlock()
+}
+
+func (t *TaskProgressInfo) setOwner(chatID int64) {
+	t.mu.Lock()
+	t.owner = chatID
+	t.mu.Unlock()
+}
+
+// Owner returns the chat ID of the user who created the task, zero if it was created with an API token.
+func (t *TaskProgressInfo) Owner() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.owner
+}
+
+// Request returns the request the task was created from, or nil.
+func (t *TaskProgressInfo) Request() *CreateTaskRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.request
+}
+
+// Watch returns a channel that receives a signal whenever the task changes,
+// and a function that stops watching. Signals are coalesced, so a slow reader
+// only learns that something changed and should take a fresh snapshot.
+func (t *TaskProgressInfo) Watch() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	t.mu.Lock()
+	if t.watchers == nil {
+		t.watchers = make(map[chan struct{}]struct{})
+	}
+	t.watchers[ch] = struct{}{}
+	t.mu.Unlock()
+	return ch, func() {
+		t.mu.Lock()
+		delete(t.watchers, ch)
+		t.mu.Unlock()
+	}
+}
+
+// notifyLocked signals all watchers. t.mu must be held.
+func (t *TaskProgressInfo) notifyLocked() {
+	for ch := range t.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// IsTerminal reports whether the task has finished.
+func (t *TaskProgressInfo) IsTerminal() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Status.IsTerminal()
+}
+
 // snapshot returns a point-in-time copy of the fields needed to render a
 // response, so callers never touch the mutex directly.
 func (t *TaskProgressInfo) snapshot() (status TaskStatus, total, downloaded int64, totalFiles, downloadedFiles int, startedAt time.Time, err string, updatedAt time.Time) {
@@ -191,6 +263,7 @@ func (t *TaskProgressInfo) Emit(e taskevent.Event) {
 	if notify {
 		t.webhookNotified = true
 	}
+	t.notifyLocked()
 	t.mu.Unlock()
 
 	if notify {
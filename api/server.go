@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/common/metrics"
 	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/database"
 )
 
 // Server API 服务器
@@ -40,6 +42,7 @@ func NewServer(ctx context.Context) *Server {
 			MethodNotAllowedHandler(w, r)
 		}
 	})
+	mux.HandleFunc("/api/v1/tasks/batch", handlers.BatchCreateTaskHandler)
 	mux.HandleFunc("/api/v1/tasks/", func(w http.ResponseWriter, r *http.Request) {
 		// 根据方法和路径分发
 		switch r.Method {
@@ -53,12 +56,20 @@ func NewServer(ctx context.Context) *Server {
 	})
 	mux.HandleFunc("/api/v1/storages", handlers.ListStoragesHandler)
 	mux.HandleFunc("/api/v1/task-types", handlers.GetTaskTypesHandler)
+	mux.HandleFunc("/api/v1/limits", handlers.LimitsHandler)
+	mux.HandleFunc("/api/v1/rules/test", handlers.TestRulesHandler)
+
+	// Prometheus 指标, 未配置独立监听地址时由 API 服务器提供
+	if mcfg := config.C().Metrics; mcfg.Enable && mcfg.Listen == "" {
+		mux.Handle("/metrics", metrics.Handler())
+	}
 
 	// 404 处理
 	mux.HandleFunc("/", NotFoundHandler)
 
-	// Apply middleware chain.
-	var handler http.Handler = mux
+	// Apply middleware chain. Rate limits are counted per token, so they run
+	// after authentication.
+	var handler http.Handler = rateLimitMiddleware(mux)
 
 	// Apply auth middleware when a token is configured.
 	token := cfg.Token
@@ -66,6 +77,20 @@ func NewServer(ctx context.Context) *Server {
 		handler = AuthMiddleware()(handler)
 	}
 
+	// The dashboard authenticates with Telegram login sessions instead of the
+	// API token, so it is mounted in front of the auth middleware. Its task
+	// creation is rate limited per session user instead.
+	if cfg.Dashboard.Enable {
+		root := http.NewServeMux()
+		root.Handle("/dashboard/", NewDashboard(factory).Handler())
+		root.Handle("/dashboard", http.RedirectHandler("/dashboard/", http.StatusMovedPermanently))
+		root.Handle("/", handler)
+		handler = root
+	}
+
+	// Limit request body size, including dashboard requests.
+	handler = bodyLimitMiddleware(handler)
+
 	// Add logging middleware.
 	handler = loggingMiddleware(handler)
 
@@ -161,10 +186,17 @@ func Start(ctx context.Context) error {
 		return fmt.Errorf("API server is enabled but no token is set; refusing to start insecurely")
 	}
 
+	// 幂等键保存在数据库中, 重启后重试的请求也能得到原响应
+	idempotency.records = database.IdempotencyKeys{}
 	server := NewServer(ctx)
 	if err := server.Start(ctx); err != nil {
 		return err
 	}
+	if cfg.GRPC.Enable {
+		if err := startGRPC(ctx, server.factory); err != nil {
+			return err
+		}
+	}
 	StartCleanupLoop(ctx)
 	return nil
 }
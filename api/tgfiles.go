@@ -66,64 +66,19 @@ func resolveChatID(_ context.Context, idOrUsername string) (int64, error) {
 // - https://t.me/c/123456789/111/456 (topic id)
 // - https://t.me/username/123?comment=2 (评论)
 func ParseMessageLink(ctx context.Context, link string) (int64, int, error) {
-	u, err := url.Parse(link)
+	parts, err := tgutil.SplitMessageLink(link)
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid URL: %w", err)
+		return 0, 0, err
 	}
-	paths := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
-
-	if cmt := u.Query().Get("comment"); cmt != "" {
-		// 频道评论的消息链接
-		if len(paths) < 1 {
-			return 0, 0, fmt.Errorf("invalid message link format: %s", link)
-		}
+	if parts.Comment != 0 {
 		// 简化处理：返回错误，提示不支持评论链接
 		return 0, 0, fmt.Errorf("comment links are not supported")
 	}
-
-	switch len(paths) {
-	case 2: // https://t.me/username/123
-		chatID, err := resolveChatID(ctx, paths[0])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to resolve chat ID: %w", err)
-		}
-		msgID, err := strconv.Atoi(paths[1])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
-		}
-		return chatID, msgID, nil
-	case 3:
-		// https://t.me/c/123456789/123
-		// https://t.me/username/123/456 , 123: topic id
-		chatPart, msgPart := paths[1], paths[2]
-		if paths[0] != "c" {
-			chatPart = paths[0]
-		}
-		chatID, err := resolveChatID(ctx, chatPart)
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to resolve chat ID: %w", err)
-		}
-		msgID, err := strconv.Atoi(msgPart)
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
-		}
-		return chatID, msgID, nil
-	case 4:
-		// https://t.me/c/123456789/111/456 111: topic id
-		if paths[0] != "c" {
-			return 0, 0, fmt.Errorf("invalid message link format: %s", link)
-		}
-		chatID, err := resolveChatID(ctx, paths[1])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to resolve chat ID: %w", err)
-		}
-		msgID, err := strconv.Atoi(paths[3])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
-		}
-		return chatID, msgID, nil
+	chatID, err := resolveChatID(ctx, parts.Chat)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to resolve chat ID: %w", err)
 	}
-	return 0, 0, fmt.Errorf("invalid message link format: %s", link)
+	return chatID, parts.MsgID, nil
 }
 
 // getMessageWithContext 通过 ID 获取消息，返回消息和使用的 context
@@ -239,7 +194,7 @@ func ExtractFilesFromLinks(ctx context.Context, links []string) ([]tfile.TGFileM
 						continue
 					}
 					// 使用获取消息时使用的同一个 client context 创建文件
-					file, err := tfile.FromMediaMessage(gmedia, clientCtx.Raw, gmsg)
+					file, err := tfile.FromMediaMessage(gmedia, clientCtx.Raw, gmsg, tgutil.WithFileRefresher(clientCtx, gmsg))
 					if err != nil {
 						logger.Errorf("Failed to create file from media: %v", err)
 						continue
@@ -251,7 +206,7 @@ func ExtractFilesFromLinks(ctx context.Context, links []string) ([]tfile.TGFileM
 		}
 
 		// 单个文件 - 使用获取消息时使用的同一个 client context 创建文件
-		file, err := tfile.FromMediaMessage(media, clientCtx.Raw, msg)
+		file, err := tfile.FromMediaMessage(media, clientCtx.Raw, msg, tgutil.WithFileRefresher(clientCtx, msg))
 		if err != nil {
 			logger.Errorf("Failed to create file from media: %v", err)
 			continue
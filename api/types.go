@@ -19,6 +19,11 @@ const (
 	TaskStatusCancelled TaskStatus = "cancelled"
 )
 
+// IsTerminal 任务是否已结束
+func (s TaskStatus) IsTerminal() bool {
+	return s == TaskStatusCompleted || s == TaskStatusFailed || s == TaskStatusCancelled
+}
+
 // CreateTaskRequest 创建任务请求
 type CreateTaskRequest struct {
 	Type    tasktype.TaskType `json:"type"`
@@ -36,6 +41,21 @@ type CreateTaskResponse struct {
 	CreatedAt time.Time         `json:"created_at"`
 }
 
+// BatchTaskResult 批量创建任务中单个任务的结果
+type BatchTaskResult struct {
+	Index    int                 `json:"index"`
+	Task     *CreateTaskResponse `json:"task,omitempty"`
+	Replayed bool                `json:"replayed,omitempty"`
+	Error    *ErrorResponse      `json:"error,omitempty"`
+}
+
+// BatchCreateTaskResponse 批量创建任务响应
+type BatchCreateTaskResponse struct {
+	Results   []BatchTaskResult `json:"results"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+}
+
 // TaskProgress 任务进度
 type TaskProgress struct {
 	TotalBytes      int64   `json:"total_bytes,omitempty"`
@@ -77,6 +97,76 @@ type StorageInfo struct {
 	Type string `json:"type"`
 }
 
+// DashboardStorageInfo 面板中的存储信息
+type DashboardStorageInfo struct {
+	StorageInfo
+	Listable bool `json:"listable"`
+}
+
+// FileEntry 存储中的文件或目录
+type FileEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	IsDir   bool      `json:"is_dir"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// RuleInfo 用户规则
+type RuleInfo struct {
+	ID          uint   `json:"id"`
+	Type        string `json:"type"`
+	Data        string `json:"data"`
+	StorageName string `json:"storage_name"`
+	DirPath     string `json:"dir_path"`
+	Priority    int    `json:"priority"`
+	Stop        bool   `json:"stop"`
+	Action      string `json:"action,omitempty"`
+}
+
+// RuleTestRequest 规则测试请求, 提供消息链接或模拟的文件信息
+type RuleTestRequest struct {
+	MessageLink string  `json:"message_link,omitempty"`
+	FileName    string  `json:"file_name,omitempty"`
+	Caption     string  `json:"caption,omitempty"`
+	Size        int64   `json:"size,omitempty"`
+	MimeType    string  `json:"mime_type,omitempty"`
+	Duration    float64 `json:"duration,omitempty"` // 秒
+	Album       bool    `json:"album,omitempty"`
+	ChatID      int64   `json:"chat_id,omitempty"`
+	UserID      int64   `json:"user_id,omitempty"` // 执行其规则的用户, 仅 /api/v1 使用
+}
+
+// RuleEvaluation 单条规则的执行结果
+type RuleEvaluation struct {
+	Rule    RuleInfo `json:"rule"`
+	Matched bool     `json:"matched"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// RuleTestResult 单个文件的规则测试结果
+type RuleTestResult struct {
+	FileName    string           `json:"file_name"`
+	Evaluations []RuleEvaluation `json:"evaluations"`
+	Matched     bool             `json:"matched"`
+	StorageName string           `json:"storage_name,omitempty"`
+	DirPath     string           `json:"dir_path,omitempty"`
+	Actions     string           `json:"actions,omitempty"`
+}
+
+// RuleTestResponse 规则测试响应, 消息链接指向媒体组时包含组内每个文件的结果
+type RuleTestResponse struct {
+	RuleModeEnabled bool             `json:"rule_mode_enabled"`
+	Results         []RuleTestResult `json:"results"`
+}
+
+// DirInfo 用户常用目录
+type DirInfo struct {
+	ID          uint   `json:"id"`
+	StorageName string `json:"storage_name"`
+	Path        string `json:"path"`
+}
+
 // WebhookPayload Webhook 回调负载
 type WebhookPayload struct {
 	TaskID      string     `json:"task_id"`
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/common/metrics"
 )
 
 // webhookClient Webhook 客户端
@@ -40,6 +41,7 @@ func SendWebhook(ctx context.Context, payload *WebhookPayload) {
 
 		payloadBytes, err := json.Marshal(payload)
 		if err != nil {
+			metrics.WebhookFailures.Inc()
 			logger.Errorf("Failed to marshal webhook payload: %v", err)
 			return
 		}
@@ -48,6 +50,7 @@ func SendWebhook(ctx context.Context, payload *WebhookPayload) {
 		for i := range 3 {
 			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, webhookURL, bytes.NewBuffer(payloadBytes))
 			if err != nil {
+				metrics.WebhookFailures.Inc()
 				logger.Errorf("Failed to create webhook request: %v", err)
 				return
 			}
@@ -72,6 +75,7 @@ func SendWebhook(ctx context.Context, payload *WebhookPayload) {
 			time.Sleep(time.Second * time.Duration(i+1))
 		}
 
+		metrics.WebhookFailures.Inc()
 		logger.Errorf("Failed to send webhook after 3 attempts")
 	}()
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/krau/SaveAny-Bot/client/bot/handlers"
 	"github.com/krau/SaveAny-Bot/client/middleware"
 	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/tdler"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/database"
@@ -94,8 +95,9 @@ func Init(ctx context.Context) <-chan struct{} {
 		if result.err != nil {
 			log.FromContext(ctx).Fatalf("Failed to initialize Bot: %s", result.err)
 		}
-		handlers.Register(result.client.Dispatcher)
 		ectx = result.client.CreateContext()
+		tdler.AddPrimaryAccount(tdler.NewAccount("bot", ectx.Raw, nil))
+		handlers.Register(result.client.Dispatcher, ectx)
 		log.FromContext(ctx).Info("Bot initialization completed.")
 	}
 	return shouldRestart
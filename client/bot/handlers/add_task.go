@@ -105,6 +105,8 @@ func handleAddCallback(ctx *ext.Context, update *ext.Update) error {
 		shortcut.CreateAndAddYtdlpTaskWithEdit(ctx, selectedStorage, dirPath, data.YtdlpURLs, data.YtdlpFlags, msgID, userID)
 	case tasktype.TaskTypeTransfer:
 		return handleTransferCallback(ctx, userID, selectedStorage, dirPath, data, msgID)
+	case tasktype.TaskTypeArchive:
+		return handleArchiveCallback(ctx, userID, selectedStorage, dirPath, data, msgID)
 	default:
 		return fmt.Errorf("unexcept task type: %s", data.TaskType)
 	}
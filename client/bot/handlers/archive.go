@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"path"
+	"strconv"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/common/utils/strutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+// /archive <chat> [--reset]
+func handleArchiveCmd(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	args := strutil.ParseArgsRespectQuotes(update.EffectiveMessage.Text)
+	if len(args) < 2 || len(args) > 3 || (len(args) == 3 && args[2] != "--reset") {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgArchiveHelp)), nil)
+		return dispatcher.EndGroups
+	}
+	if userclient.GetCtx() == nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgArchiveErrorUserbotRequired)), nil)
+		return dispatcher.EndGroups
+	}
+	userID := update.GetUserChat().GetID()
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorGetUserFailed)), nil)
+		return dispatcher.EndGroups
+	}
+	chatArg := args[1]
+	chatID, err := tgutil.ParseChatID(ctx, chatArg)
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorInvalidIdOrUsername, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	record, err := user.GetArchive(ctx, chatID)
+	if err != nil {
+		logger.Errorf("Failed to get archive of chat %d: %s", chatID, err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgArchiveErrorGetArchiveFailed, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	if record != nil && len(args) == 3 {
+		if err := database.DeleteArchive(ctx, record.ID); err != nil {
+			logger.Errorf("Failed to delete archive %d: %s", record.ID, err)
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgArchiveErrorGetArchiveFailed, map[string]any{"Error": err.Error()})), nil)
+			return dispatcher.EndGroups
+		}
+		record = nil
+	}
+
+	if record != nil {
+		// 继续归档到原来的位置
+		stor, err := storage.GetStorageByUserIDAndName(ctx, userID, record.StorageName)
+		if err != nil {
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorGetStorageFailed, map[string]any{"Error": err.Error()})), nil)
+			return dispatcher.EndGroups
+		}
+		replied, err := ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgArchiveInfoResuming, map[string]any{"Chat": chatArg})), nil)
+		if err != nil {
+			logger.Errorf("Failed to reply: %s", err)
+			return dispatcher.EndGroups
+		}
+		return shortcut.CreateAndAddArchiveTaskWithEdit(ctx, userID, stor, record, replied.ID)
+	}
+
+	markup, err := msgelem.BuildAddSelectStorageKeyboard(storage.GetUserStorages(ctx, userID), tcbdata.Add{
+		TaskType:      tasktype.TaskTypeArchive,
+		ArchiveChatID: chatID,
+	})
+	if err != nil {
+		logger.Errorf("Failed to build storage selection keyboard: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorBuildStorageSelectKeyboardFailed, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgArchiveInfoSelectStorage, map[string]any{"Chat": chatArg})), &ext.ReplyOpts{
+		Markup: markup,
+	})
+	return dispatcher.EndGroups
+}
+
+func handleArchiveCallback(ctx *ext.Context, userID int64, stor storage.Storage, dirPath string, data tcbdata.Add, msgID int) error {
+	logger := log.FromContext(ctx)
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      msgID,
+			Message: i18n.T(i18nk.BotMsgCommonErrorGetUserFailed),
+		})
+		return dispatcher.EndGroups
+	}
+	record, err := user.GetArchive(ctx, data.ArchiveChatID)
+	if err == nil && record == nil {
+		record = &database.Archive{
+			UserID:      user.ID,
+			ChatID:      data.ArchiveChatID,
+			StorageName: stor.Name(),
+			Path:        path.Join(dirPath, strconv.FormatInt(data.ArchiveChatID, 10)),
+		}
+		err = database.CreateArchive(ctx, record)
+	}
+	if err != nil {
+		logger.Errorf("Failed to create archive of chat %d: %s", data.ArchiveChatID, err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      msgID,
+			Message: i18n.T(i18nk.BotMsgArchiveErrorGetArchiveFailed, map[string]any{"Error": err.Error()}),
+		})
+		return dispatcher.EndGroups
+	}
+	if record.StorageName != stor.Name() {
+		// the chat was archived to another storage meanwhile
+		stor, err = storage.GetStorageByUserIDAndName(ctx, userID, record.StorageName)
+		if err != nil {
+			ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+				ID:      msgID,
+				Message: i18n.T(i18nk.BotMsgCommonErrorGetStorageFailed, map[string]any{"Error": err.Error()}),
+			})
+			return dispatcher.EndGroups
+		}
+	}
+	return shortcut.CreateAndAddArchiveTaskWithEdit(ctx, userID, stor, record, msgID)
+}
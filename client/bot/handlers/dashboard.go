@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/webauth"
+)
+
+func handleDashboardCmd(ctx *ext.Context, update *ext.Update) error {
+	cfg := config.C().API
+	if !cfg.Enable || !cfg.Dashboard.Enable {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgDashboardErrorDisabled, nil)), nil)
+		return dispatcher.EndGroups
+	}
+	code := webauth.IssueLoginCode(update.GetUserChat().GetID())
+	ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgDashboardInfoLoginLink, map[string]any{
+		"Minutes": int(webauth.LoginCodeTTL.Minutes()),
+		"URL":     webauth.LoginURL(cfg.Dashboard.PublicURL, code),
+	})), nil)
+	return dispatcher.EndGroups
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
 	"github.com/krau/SaveAny-Bot/common/i18n"
 	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/storage"
 )
@@ -27,7 +28,7 @@ func handleMediaMessage(ctx *ext.Context, update *ext.Update) error {
 	if err != nil {
 		return err
 	}
-	tfOpts := mediautil.TfileOptions(ctx, userDB, message)
+	tfOpts := append(mediautil.TfileOptions(ctx, userDB, message), tgutil.WithFileRefresher(ctx, message))
 	msg, file, err := shortcut.GetFileFromMessageWithReply(ctx, update, message, tfOpts...)
 	if err != nil {
 		return err
@@ -60,7 +61,7 @@ func handleSilentSaveMedia(ctx *ext.Context, update *ext.Update) error {
 	if err != nil {
 		return err
 	}
-	tfOpts := mediautil.TfileOptions(ctx, userDB, message)
+	tfOpts := append(mediautil.TfileOptions(ctx, userDB, message), tgutil.WithFileRefresher(ctx, message))
 	msg, file, err := shortcut.GetFileFromMessageWithReply(ctx, update, message, tfOpts...)
 	if err != nil {
 		return err
@@ -13,6 +13,7 @@ import (
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
 	"github.com/krau/SaveAny-Bot/common/i18n"
 	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
@@ -59,7 +60,7 @@ func handleGroupMediaMessage(ctx *ext.Context, update *ext.Update, message *tg.M
 		return err
 	}
 	tfOpts := mediautil.TfileOptions(ctx, userDB, message)
-	file, err := tfile.FromMediaMessage(media, ctx.Raw, message, tfOpts...)
+	file, err := tfile.FromMediaMessage(media, ctx.Raw, message, append(tfOpts, tgutil.WithFileRefresher(ctx, message))...)
 	if err != nil {
 		logger.Errorf("Failed to get file from media: %s", err)
 		return dispatcher.EndGroups
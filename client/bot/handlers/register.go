@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"regexp"
+	"time"
 
 	"github.com/celestix/gotgproto/dispatcher"
 	"github.com/celestix/gotgproto/dispatcher/handlers"
@@ -27,6 +28,8 @@ var CommandHandlers = []DescCommandHandler{
 	{"storage", i18nk.BotMsgCmdStorage, handleStorageCmd},
 	{"dir", i18nk.BotMsgCmdDir, handleDirCmd},
 	{"rule", i18nk.BotMsgCmdRule, handleRuleCmd},
+	{"export", i18nk.BotMsgCmdExport, handleExportCmd},
+	{"import", i18nk.BotMsgCmdImport, handleImportCmd},
 	{"save", i18nk.BotMsgCmdSave, handleSilentMode(handleSaveCmd, handleSilentSaveReplied)},
 	{"dl", i18nk.BotMsgCmdDl, handleDlCmd},
 	{"aria2dl", i18nk.BotMsgCmdAria2dl, handleAria2DlCmd},
@@ -41,11 +44,17 @@ var CommandHandlers = []DescCommandHandler{
 	{"watch", i18nk.BotMsgCmdWatch, handleWatchCmd},
 	{"unwatch", i18nk.BotMsgCmdUnwatch, handleUnwatchCmd},
 	{"lswatch", i18nk.BotMsgCmdLswatch, handleLswatchCmd},
+	{"archive", i18nk.BotMsgCmdArchive, handleArchiveCmd},
+	{"subscribe", i18nk.BotMsgCmdSubscribe, handleSubscribeCmd},
+	{"unsubscribe", i18nk.BotMsgCmdUnsubscribe, handleUnsubscribeCmd},
+	{"lssub", i18nk.BotMsgCmdLssub, handleLssubCmd},
 	{"syncpeers", i18nk.BotMsgCmdSyncpeers, handleSyncpeersCmd},
 	{"update", i18nk.BotMsgCmdUpdate, handleUpdateCmd},
+	{"dashboard", i18nk.BotMsgCmdDashboard, handleDashboardCmd},
 }
 
-func Register(disp dispatcher.Dispatcher) {
+// Register adds the handlers to the dispatcher, ctx is the bot context used for messages not replying to an update.
+func Register(disp dispatcher.Dispatcher, ctx *ext.Context) {
 	disp.AddHandler(handlers.NewMessage(filters.Message.ChatType(filters.ChatTypeChannel), func(ctx *ext.Context, u *ext.Update) error {
 		return dispatcher.EndGroups
 	}))
@@ -61,12 +70,16 @@ func Register(disp dispatcher.Dispatcher) {
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeSetDefault), handleSetDefaultCallback))
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeCancel), handleCancelCallback))
 	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeConfig), handleConfigCallback))
+	disp.AddHandler(handlers.NewCallbackQuery(filters.CallbackQuery.Prefix(tcbdata.TypeSaveRange), handleSaveRangeCallback))
 	disp.AddHandler(handlers.NewMessage(sabotfilters.RegexUrl(regexp.MustCompile(re.TgMessageLinkRegexString)), handleSilentMode(handleMessageLink, handleSilentSaveLink)))
+	disp.AddHandler(handlers.NewMessage(sabotfilters.RegexUrl(regexp.MustCompile(re.TgStoryLinkRegexString)), handleSilentMode(handleMessageLink, handleSilentSaveLink)))
 	disp.AddHandler(handlers.NewMessage(sabotfilters.RegexUrl(regexp.MustCompile(re.TelegraphUrlRegexString)), handleSilentMode(handleTelegraphUrlMessage, handleSilentSaveTelegraph)))
 	disp.AddHandler(handlers.NewMessage(filters.Message.Media, handleSilentMode(handleMediaMessage, handleSilentSaveMedia)))
 	disp.AddHandler(handlers.NewMessage(filters.Message.Text, handleSilentMode(handleTextMessage, handleSilentSaveText)))
 
 	if config.C().Telegram.Userbot.Enable {
 		go listenMediaMessageEvent(userclient.GetMediaMessageCh())
+		go runSubscriptionDigest(ctx, time.Duration(max(config.C().Telegram.Userbot.DigestInterval, 60))*time.Second)
+		go runStoryWatch(time.Duration(max(config.C().Telegram.Userbot.StoryInterval, 60)) * time.Second)
 	}
 }
@@ -9,13 +9,17 @@ import (
 	"github.com/celestix/gotgproto/ext"
 	"github.com/charmbracelet/log"
 	"github.com/duke-git/lancet/v2/slice"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
 	"github.com/krau/SaveAny-Bot/common/i18n"
 	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
 	"github.com/krau/SaveAny-Bot/common/utils/strutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/pkg/rule"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
 )
 
 func handleRuleCmd(ctx *ext.Context, update *ext.Update) error {
@@ -46,7 +50,7 @@ func handleRuleCmd(ctx *ext.Context, update *ext.Update) error {
 			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgRuleInfoRuleModeDisabled, nil)), nil)
 		}
 	case "add":
-		// /rule add <type> <data> <storage> <dirpath>
+		// /rule add <type> <data> <storage> <dirpath> [--priority N] [--stop] [--action <actions>] [--watch <chat>]
 		if len(args) < 6 {
 			ctx.Reply(update, ext.ReplyTextStyledTextArray(msgelem.BuildRuleHelpStyling(user.ApplyRule, user.Rules)), nil)
 			return dispatcher.EndGroups
@@ -71,6 +75,12 @@ func handleRuleCmd(ctx *ext.Context, update *ext.Update) error {
 		ruleData := args[3]
 		storageName := args[4]
 		dirPath := args[5]
+		if err := rule.Validate(ruleType, ruleData); err != nil {
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgRuleErrorInvalidRuleData, map[string]any{
+				"Error": err.Error(),
+			})), nil)
+			return dispatcher.EndGroups
+		}
 
 		rd := &database.Rule{
 			Type:        ruleType.String(),
@@ -79,6 +89,62 @@ func handleRuleCmd(ctx *ext.Context, update *ext.Update) error {
 			DirPath:     dirPath,
 			UserID:      user.ID,
 		}
+		invalidOption := func(option string) error {
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgRuleErrorInvalidRuleOption, map[string]any{
+				"Option": option,
+			})), nil)
+			return dispatcher.EndGroups
+		}
+		for i := 6; i < len(args); i++ {
+			var value string
+			if i+1 < len(args) {
+				value = args[i+1]
+			}
+			switch args[i] {
+			case "--stop":
+				rd.Stop = true
+			case "--priority":
+				p, err := strconv.Atoi(value)
+				if err != nil {
+					return invalidOption(args[i])
+				}
+				rd.Priority = p
+				i++
+			case "--action":
+				if value == "" {
+					return invalidOption(args[i])
+				}
+				actions, err := rule.ParseActions(value)
+				if err != nil {
+					ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgRuleErrorInvalidRuleData, map[string]any{
+						"Error": err.Error(),
+					})), nil)
+					return dispatcher.EndGroups
+				}
+				rd.Action = actions.String()
+				i++
+			case "--watch":
+				// 规则只属于该监听, 不属于用户
+				if value == "" {
+					return invalidOption(args[i])
+				}
+				chatID, err := tgutil.ParseChatID(ctx, value)
+				if err != nil {
+					ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorInvalidIdOrUsername, map[string]any{"Error": err.Error()})), nil)
+					return dispatcher.EndGroups
+				}
+				watchChat, err := user.GetWatchChat(ctx, chatID)
+				if err != nil {
+					ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgRuleErrorWatchChatNotFound, map[string]any{"Chat": value})), nil)
+					return dispatcher.EndGroups
+				}
+				rd.WatchChatID = watchChat.ID
+				rd.UserID = 0
+				i++
+			default:
+				return invalidOption(args[i])
+			}
+		}
 		if err := database.CreateRule(ctx, rd); err != nil {
 			logger.Errorf("failed to create rule: %s", err)
 			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgRuleErrorCreateRuleFailed, nil)), nil)
@@ -143,9 +209,48 @@ func handleRuleCmd(ctx *ext.Context, update *ext.Update) error {
 			return dispatcher.EndGroups
 		}
 		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgRuleInfoDeleteRuleSuccess, nil)), nil)
+	case "test":
+		// /rule test [file_name] [caption]
+		return handleRuleTest(ctx, update, user, args[2:])
 	default:
 		ctx.Reply(update, ext.ReplyTextStyledTextArray(msgelem.BuildRuleHelpStyling(user.ApplyRule, user.Rules)), nil)
 		return dispatcher.EndGroups
 	}
 	return dispatcher.EndGroups
 }
+
+// handleRuleTest 对回复的消息或给定的文件名执行规则, 只展示结果而不创建任务
+func handleRuleTest(ctx *ext.Context, update *ext.Update, user *database.User, args []string) error {
+	logger := log.FromContext(ctx)
+	var file tfile.TGFileMessage
+	if replyTo := update.EffectiveMessage.ReplyToMessage; len(args) == 0 && replyTo != nil && replyTo.Message != nil && mediautil.IsSupported(replyTo.Message.Media) {
+		var err error
+		file, err = tfile.FromMediaMessage(replyTo.Message.Media, ctx.Raw, replyTo.Message, append(mediautil.TfileOptions(ctx, user, replyTo.Message), tgutil.WithFileRefresher(ctx, replyTo.Message))...)
+		if err != nil {
+			logger.Errorf("Failed to get file from media: %s", err)
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorGetFileFailed, map[string]any{
+				"Error": err.Error(),
+			})), nil)
+			return dispatcher.EndGroups
+		}
+	} else if len(args) > 0 && args[0] != "" {
+		sf := ruleutil.SyntheticFile{Name: args[0]}
+		if len(args) > 1 {
+			sf.Caption = strings.Join(args[1:], " ")
+		}
+		var err error
+		if file, err = ruleutil.NewSyntheticFile(sf); err != nil {
+			logger.Errorf("Failed to build synthetic file: %s", err)
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorGetFileFailed, map[string]any{
+				"Error": err.Error(),
+			})), nil)
+			return dispatcher.EndGroups
+		}
+	} else {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgRulePromptTestInput, nil)), nil)
+		return dispatcher.EndGroups
+	}
+	res := ruleutil.Apply(ctx, user.Rules, ruleutil.NewInput(file))
+	ctx.Reply(update, ext.ReplyTextStyledTextArray(msgelem.BuildRuleTestStyling(user.ApplyRule, file.Name(), res)), nil)
+	return dispatcher.EndGroups
+}
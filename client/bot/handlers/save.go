@@ -28,6 +28,9 @@ import (
 func handleSaveCmd(ctx *ext.Context, update *ext.Update) error {
 	logger := log.FromContext(ctx)
 	args := strings.Split(update.EffectiveMessage.Text, " ")
+	if rangeArgs := strutil.ParseArgsRespectQuotes(update.EffectiveMessage.Text)[1:]; isSaveRangeArgs(rangeArgs) {
+		return handleSaveRange(ctx, update, rangeArgs)
+	}
 	if len(args) >= 3 {
 		return handleBatchSave(ctx, update, args[1:])
 	}
@@ -40,7 +43,7 @@ func handleSaveCmd(ctx *ext.Context, update *ext.Update) error {
 	if err != nil {
 		return err
 	}
-	opts := mediautil.TfileOptions(ctx, userDB, replyTo.Message)
+	opts := append(mediautil.TfileOptions(ctx, userDB, replyTo.Message), tgutil.WithFileRefresher(ctx, replyTo.Message))
 	if len(args) > 1 {
 		// custom filename via command arg
 		opts = append(opts, tfile.WithName(strings.Join(args[1:], " ")))
@@ -63,6 +66,9 @@ func handleSaveCmd(ctx *ext.Context, update *ext.Update) error {
 
 func handleSilentSaveReplied(ctx *ext.Context, update *ext.Update) error {
 	args := strings.Split(string(update.EffectiveMessage.Text), " ")
+	if rangeArgs := strutil.ParseArgsRespectQuotes(update.EffectiveMessage.Text)[1:]; isSaveRangeArgs(rangeArgs) {
+		return handleSaveRange(ctx, update, rangeArgs)
+	}
 	if len(args) >= 3 {
 		return handleBatchSave(ctx, update, args[1:])
 	}
@@ -76,7 +82,7 @@ func handleSilentSaveReplied(ctx *ext.Context, update *ext.Update) error {
 	if err != nil {
 		return err
 	}
-	opts := mediautil.TfileOptions(ctx, userDB, replyTo.Message)
+	opts := append(mediautil.TfileOptions(ctx, userDB, replyTo.Message), tgutil.WithFileRefresher(ctx, replyTo.Message))
 	if len(args) > 1 {
 		// custom filename via command arg
 		opts = append(opts, tfile.WithName(strings.Join(args[1:], " ")))
@@ -148,7 +154,7 @@ func handleBatchSave(ctx *ext.Context, update *ext.Update, args []string) error
 		if !supported {
 			continue
 		}
-		file, err := tfile.FromMediaMessage(media, tctx.Raw, msg, tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)))
+		file, err := tfile.FromMediaMessage(media, tctx.Raw, msg, tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)), tgutil.WithFileRefresher(tctx, msg))
 		if err != nil {
 			log.FromContext(ctx).Errorf("Failed to get file from message: %s", err)
 			continue
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/dirutil"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/re"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/cache"
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/common/utils/dlutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+// saveRangeMaxMessages 一次范围保存最多读取的消息数
+const saveRangeMaxMessages = 10000
+
+// saveRange 是 /save 的消息链接范围和过滤条件
+type saveRange struct {
+	chat       string
+	start, end int
+	filter     rule.FilterOptions
+}
+
+// parseSaveRange parses the arguments of /save <link> <link|+n> [filter] [--media <types>] [--size <range>].
+// +n saves the linked message and the n messages after it.
+func parseSaveRange(args []string) (*saveRange, error) {
+	if len(args) < 2 {
+		return nil, errors.New("a message link and the end of the range are required")
+	}
+	first, err := tgutil.SplitMessageLink(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if first.Comment != 0 {
+		return nil, errors.New("comment links can't be used for a range")
+	}
+	r := &saveRange{chat: first.Chat, start: first.MsgID}
+	if n, ok := strings.CutPrefix(args[1], "+"); ok {
+		count, err := strconv.Atoi(n)
+		if err != nil || count <= 0 {
+			return nil, fmt.Errorf("invalid message count: %s", args[1])
+		}
+		r.end = r.start + count
+	} else {
+		last, err := tgutil.SplitMessageLink(args[1])
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(last.Chat, first.Chat) || last.Comment != 0 {
+			return nil, errors.New("both links must be messages of the same chat")
+		}
+		r.start, r.end = min(first.MsgID, last.MsgID), max(first.MsgID, last.MsgID)
+	}
+	if r.end-r.start+1 > saveRangeMaxMessages {
+		return nil, fmt.Errorf("the range has %d messages, at most %d can be saved at once", r.end-r.start+1, saveRangeMaxMessages)
+	}
+
+	// 不以 -- 开头的参数组成消息过滤器, 与 /watch 相同
+	var filterArgs []string
+	for i := 2; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "--") {
+			filterArgs = append(filterArgs, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("missing value of option %s", args[i])
+		}
+		switch args[i] {
+		case "--media":
+			r.filter.Media = args[i+1]
+		case "--size":
+			r.filter.Size = args[i+1]
+		default:
+			return nil, fmt.Errorf("unknown option %s", args[i])
+		}
+		i++
+	}
+	r.filter.Message = strings.Join(filterArgs, " ")
+	if _, err := rule.NewFilter(r.filter); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// handleSaveRange 保存两个消息链接之间的所有文件, 显示文件数量并在确认后开始
+func handleSaveRange(ctx *ext.Context, update *ext.Update, args []string) error {
+	logger := log.FromContext(ctx)
+	r, err := parseSaveRange(args)
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSaveErrorInvalidRange, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	tctx := ctx
+	if config.C().Telegram.Userbot.Enable {
+		if uctx := userclient.GetCtx(); uctx != nil {
+			tctx = uctx
+		}
+	}
+	chatID, err := tgutil.ParseChatID(tctx, r.chat)
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorInvalidIdOrUsername, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	userID := update.GetUserChat().GetID()
+	user, err := database.GetUserByChatID(ctx, userID)
+	if err != nil {
+		logger.Errorf("Failed to get user: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorGetUserFailed)), nil)
+		return dispatcher.EndGroups
+	}
+	replied, err := ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonInfoFetchingMessages)), nil)
+	if err != nil {
+		logger.Errorf("Failed to reply: %s", err)
+		return dispatcher.EndGroups
+	}
+	editReplied := func(text string, markup tg.ReplyMarkupClass) {
+		if _, err := ctx.EditMessage(update.EffectiveChat().GetID(), &tg.MessagesEditMessageRequest{
+			ID:          replied.ID,
+			Message:     text,
+			ReplyMarkup: markup,
+		}); err != nil {
+			logger.Errorf("Failed to edit message: %s", err)
+		}
+	}
+
+	msgs, err := tgutil.GetMessagesRange(tctx, chatID, r.start, r.end)
+	if err != nil {
+		editReplied(i18n.T(i18nk.BotMsgCommonErrorGetMessagesFailed, map[string]any{"Error": err.Error()}), nil)
+		return dispatcher.EndGroups
+	}
+	msgs = withBoundaryAlbums(tctx, chatID, msgs)
+	if len(msgs) == 0 {
+		editReplied(i18n.T(i18nk.BotMsgCommonErrorNoMessagesInRange), nil)
+		return dispatcher.EndGroups
+	}
+
+	files := make([]tfile.TGFileMessage, 0, len(msgs))
+	seen := make(map[int64]struct{})
+	albums := make(map[int64]struct{})
+	var totalSize int64
+	for _, msg := range msgs {
+		media, ok := msg.GetMedia()
+		if !ok || (!mediautil.IsSupported(media) && !mediautil.IsInlineResult(msg)) {
+			continue
+		}
+		opts := append(mediautil.TfileOptions(ctx, user, msg), tgutil.WithFileRefresher(tctx, msg))
+		file, err := tfile.FromMediaMessage(media, tctx.Raw, msg, opts...)
+		if err != nil {
+			logger.Errorf("Failed to get file from message %d: %s", msg.GetID(), err)
+			continue
+		}
+		if ok, err := ruleutil.MatchFilter(ctx, r.filter, file); err != nil || !ok {
+			continue
+		}
+		// 同一个文件被多次发送时只保存一次
+		if id, ok := fileID(file); ok {
+			if _, dup := seen[id]; dup {
+				continue
+			}
+			seen[id] = struct{}{}
+		}
+		if groupID, ok := msg.GetGroupedID(); ok && groupID != 0 {
+			albums[groupID] = struct{}{}
+		}
+		totalSize += file.Size()
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		editReplied(i18n.T(i18nk.BotMsgCommonErrorNoSavableMessagesInRange), nil)
+		return dispatcher.EndGroups
+	}
+
+	data := tcbdata.Add{
+		TaskType: tasktype.TaskTypeTgfiles,
+		Files:    files,
+		AsBatch:  true,
+	}
+	if stor := storage.FromContext(ctx); stor != nil {
+		// 静默模式下确认后直接保存到默认存储
+		data.SelectedStorName = stor.Name()
+		data.SelectedDirPath = dirutil.PathFromContext(ctx)
+	}
+	markup, err := msgelem.BuildSaveRangeConfirmMarkup(data)
+	if err != nil {
+		logger.Errorf("Failed to build confirm keyboard: %s", err)
+		editReplied(i18n.T(i18nk.BotMsgCommonErrorBuildStorageSelectKeyboardFailed, map[string]any{"Error": err.Error()}), nil)
+		return dispatcher.EndGroups
+	}
+	editReplied(i18n.T(i18nk.BotMsgSaveInfoRangePreview, map[string]any{
+		"Count":  len(files),
+		"Albums": len(albums),
+		"Size":   dlutil.FormatSize(totalSize),
+		"Start":  r.start,
+		"End":    r.end,
+	}), markup)
+	return dispatcher.EndGroups
+}
+
+// withBoundaryAlbums adds the messages of the albums cut by the start or the end of the range,
+// returning the messages without duplicates sorted by ID.
+func withBoundaryAlbums(ctx *ext.Context, chatID int64, msgs []*tg.Message) []*tg.Message {
+	msgs = slices.DeleteFunc(msgs, func(msg *tg.Message) bool { return msg == nil })
+	if len(msgs) == 0 {
+		return msgs
+	}
+	slices.SortFunc(msgs, func(a, b *tg.Message) int { return a.GetID() - b.GetID() })
+	ids := make(map[int]struct{}, len(msgs))
+	for _, msg := range msgs {
+		ids[msg.GetID()] = struct{}{}
+	}
+	for _, boundary := range []*tg.Message{msgs[0], msgs[len(msgs)-1]} {
+		if groupID, ok := boundary.GetGroupedID(); !ok || groupID == 0 {
+			continue
+		}
+		group, err := tgutil.GetGroupedMessages(ctx, chatID, boundary)
+		if err != nil {
+			log.FromContext(ctx).Warnf("Failed to get album of message %d: %s", boundary.GetID(), err)
+			continue
+		}
+		for _, msg := range group {
+			if _, ok := ids[msg.GetID()]; !ok {
+				ids[msg.GetID()] = struct{}{}
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	slices.SortFunc(msgs, func(a, b *tg.Message) int { return a.GetID() - b.GetID() })
+	return msgs
+}
+
+// fileID returns the ID of the document or photo of a file, the same for every message sending it.
+func fileID(file tfile.TGFile) (int64, bool) {
+	switch loc := file.Location().(type) {
+	case *tg.InputDocumentFileLocation:
+		return loc.ID, true
+	case *tg.InputPhotoFileLocation:
+		return loc.ID, true
+	}
+	return 0, false
+}
+
+func handleSaveRangeCallback(ctx *ext.Context, update *ext.Update) error {
+	args := strings.Fields(string(update.CallbackQuery.Data))
+	userID := update.CallbackQuery.GetUserID()
+	msgID := update.CallbackQuery.GetMsgID()
+	if len(args) < 2 || args[1] == "cancel" {
+		if len(args) > 2 {
+			cache.Delete(args[2])
+		}
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      msgID,
+			Message: i18n.T(i18nk.BotMsgSaveInfoRangeCancelled),
+		})
+		return dispatcher.EndGroups
+	}
+	data, err := shortcut.GetCallbackDataWithAnswer[tcbdata.Add](ctx, update, args[1])
+	if err != nil {
+		return err
+	}
+	cache.Delete(args[1])
+	if data.SelectedStorName == "" {
+		stors := storage.GetUserStorages(ctx, userID)
+		markup, err := msgelem.BuildAddSelectStorageKeyboard(stors, data)
+		if err != nil {
+			log.FromContext(ctx).Errorf("Failed to build storage selection keyboard: %s", err)
+			ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+				ID:      msgID,
+				Message: i18n.T(i18nk.BotMsgCommonErrorBuildStorageSelectKeyboardFailed, map[string]any{"Error": err.Error()}),
+			})
+			return dispatcher.EndGroups
+		}
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:          msgID,
+			Message:     i18n.T(i18nk.BotMsgCommonInfoFoundFilesSelectStorage, map[string]any{"Count": len(data.Files)}),
+			ReplyMarkup: markup,
+		})
+		return dispatcher.EndGroups
+	}
+	stor, err := storage.GetStorageByUserIDAndName(ctx, userID, data.SelectedStorName)
+	if err != nil {
+		ctx.AnswerCallback(msgelem.AlertCallbackAnswer(update.CallbackQuery.GetQueryID(), i18n.T(i18nk.BotMsgCommonErrorGetStorageFailed, map[string]any{
+			"Error": err.Error(),
+		})))
+		return dispatcher.EndGroups
+	}
+	return shortcut.CreateAndAddBatchTGFileTaskWithEdit(ctx, userID, stor, data.SelectedDirPath, data.Files, msgID)
+}
+
+// isSaveRangeArgs reports whether the /save arguments start with a message link instead of a chat.
+func isSaveRangeArgs(args []string) bool {
+	return len(args) >= 2 && re.TgMessageLinkRegexp.MatchString(args[0])
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/krau/SaveAny-Bot/pkg/rule"
+)
+
+func TestParseSaveRange(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected saveRange
+	}{
+		{
+			args:     []string{"https://t.me/c/123/100", "https://t.me/c/123/500"},
+			expected: saveRange{chat: "123", start: 100, end: 500},
+		},
+		{
+			args:     []string{"https://t.me/acherkrau/500", "https://t.me/AcherKrau/100"},
+			expected: saveRange{chat: "acherkrau", start: 100, end: 500},
+		},
+		{
+			args: []string{"https://t.me/c/123/100", "+200", "msgre:cat", "--media", "video", "--size", "10MB-"},
+			expected: saveRange{chat: "123", start: 100, end: 300, filter: rule.FilterOptions{
+				Message: "msgre:cat",
+				Media:   "video",
+				Size:    "10MB-",
+			}},
+		},
+	}
+	for _, test := range tests {
+		r, err := parseSaveRange(test.args)
+		if err != nil {
+			t.Errorf("parseSaveRange(%q) failed: %v", test.args, err)
+			continue
+		}
+		if *r != test.expected {
+			t.Errorf("parseSaveRange(%q) = %+v, expected %+v", test.args, *r, test.expected)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"https://t.me/c/123/100"},
+		{"https://t.me/c/123/100", "https://t.me/c/456/500"},
+		{"https://t.me/c/123/100", "+0"},
+		{"https://t.me/c/123/100", "+abc"},
+		{"https://t.me/c/123/100", "+20000"},
+		{"https://t.me/acherkrau/100?comment=2", "+10"},
+		{"https://t.me/c/123/100", "+10", "--media"},
+		{"https://t.me/c/123/100", "+10", "--storage", "nas"},
+		{"https://t.me/c/123/100", "+10", "--size", "big"},
+	} {
+		if _, err := parseSaveRange(args); err == nil {
+			t.Errorf("expected an error for %q", args)
+		}
+	}
+	if !isSaveRangeArgs([]string{"https://t.me/c/123/100", "+10"}) || isSaveRangeArgs([]string{"@acherkrau", "114-514"}) {
+		t.Error("isSaveRangeArgs doesn't tell message links from chats")
+	}
+}
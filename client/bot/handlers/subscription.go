@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/constant"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/common/utils/strutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	storconfig "github.com/krau/SaveAny-Bot/config/storage"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// /subscribe <name> [msgre:<regex>] [--tag <tags>] [--media <types>] [--size <range>] [--sender <senders>] [--storage <name>] [--dir <path>] [--template <template>] [--limit <n>] [--reset]
+func handleSubscribeCmd(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	args := strutil.ParseArgsRespectQuotes(update.EffectiveMessage.Text)
+	if len(args) < 2 {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionHelp)), nil)
+		return dispatcher.EndGroups
+	}
+	name := args[1]
+	if strings.HasPrefix(name, "--") {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionErrorNameRequired)), nil)
+		return dispatcher.EndGroups
+	}
+	user, err := database.GetUserByChatID(ctx, update.GetUserChat().GetID())
+	if err != nil {
+		logger.Errorf("Failed to get user: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorGetUserFailed)), nil)
+		return dispatcher.EndGroups
+	}
+	sub, err := user.GetSubscription(ctx, name)
+	if err != nil {
+		logger.Errorf("Failed to get subscription %s: %s", name, err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionErrorSubscribeFailed, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	if sub == nil {
+		sub = &database.Subscription{UserID: user.ID, Name: name}
+	}
+
+	// 与 /watch 相同, 不以 -- 开头的参数组成消息过滤器
+	var filterArgs []string
+	for i := 2; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "--") {
+			filterArgs = append(filterArgs, args[i])
+			continue
+		}
+		if args[i] == "--reset" {
+			*sub = database.Subscription{Model: sub.Model, UserID: user.ID, Name: name}
+			continue
+		}
+		if i+1 >= len(args) {
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorInvalidOption, map[string]any{"Option": args[i]})), nil)
+			return dispatcher.EndGroups
+		}
+		opt, value := args[i], args[i+1]
+		i++
+		switch opt {
+		case "--storage":
+			sub.StorageName = value
+		case "--dir":
+			sub.DirPath = value
+		case "--template":
+			sub.FilenameTemplate = value
+		case "--media":
+			sub.MediaTypes = value
+		case "--size":
+			sub.SizeRange = value
+		case "--sender":
+			sub.Senders = value
+		case "--tag":
+			sub.Hashtags = value
+		case "--limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionErrorInvalidLimit, map[string]any{"Limit": value})), nil)
+				return dispatcher.EndGroups
+			}
+			sub.MaxPerHour = limit
+		default:
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorInvalidOption, map[string]any{"Option": opt})), nil)
+			return dispatcher.EndGroups
+		}
+	}
+	if len(filterArgs) > 0 {
+		sub.Filter = strings.Join(filterArgs, " ")
+	}
+
+	opts := ruleutil.SubscriptionFilterOptions(sub)
+	if opts.IsZero() {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionErrorFilterRequired)), nil)
+		return dispatcher.EndGroups
+	}
+	if _, err := rule.NewFilter(opts); err != nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorFilterInvalid, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	if sub.StorageName == "" && user.DefaultStorage == "" {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorDefaultStorageNotSet)), nil)
+		return dispatcher.EndGroups
+	}
+	if sub.StorageName != "" && !config.C().HasStorage(user.ChatID, sub.StorageName) {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorStorageNotFound, map[string]any{"Storage": sub.StorageName})), nil)
+		return dispatcher.EndGroups
+	}
+	if sub.FilenameTemplate != "" {
+		if _, err := template.New("filename").Parse(sub.FilenameTemplate); err != nil {
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorInvalidTemplate, map[string]any{"Error": err.Error()})), nil)
+			return dispatcher.EndGroups
+		}
+	}
+	if err := database.SaveSubscription(ctx, sub); err != nil {
+		logger.Errorf("Failed to save subscription %s: %s", name, err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionErrorSubscribeFailed, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionInfoSubscribed, map[string]any{"Name": name})), nil)
+	return dispatcher.EndGroups
+}
+
+func handleUnsubscribeCmd(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	args := strutil.ParseArgsRespectQuotes(update.EffectiveMessage.Text)
+	if len(args) < 2 {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionErrorNameRequired)), nil)
+		return dispatcher.EndGroups
+	}
+	user, err := database.GetUserByChatID(ctx, update.GetUserChat().GetID())
+	if err != nil {
+		logger.Errorf("Failed to get user: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorGetUserFailed)), nil)
+		return dispatcher.EndGroups
+	}
+	name := args[1]
+	if err := user.DeleteSubscription(ctx, name); err != nil {
+		logger.Errorf("Failed to delete subscription %s: %s", name, err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionErrorUnsubscribeFailed, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionInfoUnsubscribed, map[string]any{"Name": name})), nil)
+	return dispatcher.EndGroups
+}
+
+func handleLssubCmd(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	user, err := database.GetUserByChatID(ctx, update.GetUserChat().GetID())
+	if err != nil {
+		logger.Errorf("Failed to get user: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorGetUserFailed)), nil)
+		return dispatcher.EndGroups
+	}
+	if len(user.Subscriptions) == 0 {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgSubscriptionInfoListEmpty)), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(update, ext.ReplyTextString(msgelem.BuildSubscriptionListText(user.Subscriptions)), nil)
+	return dispatcher.EndGroups
+}
+
+// handleSubscriptions saves the file of a media message event for every user whose subscriptions match it.
+// Users watching the chat are skipped, their watch already handles the message.
+func handleSubscriptions(ctx *ext.Context, event userclient.MediaMessageEvent, watchers map[uint]struct{}) {
+	logger := log.FromContext(ctx)
+	if subscriptionIgnored(event.ChatID, event.File.Message(), ctx.Self.ID, botID(), telegramStorageChats()) {
+		return
+	}
+	subs, err := database.GetAllSubscriptions(ctx)
+	if err != nil {
+		logger.Errorf("Failed to get subscriptions: %v", err)
+		return
+	}
+	// 同一用户的多个订阅匹配同一文件时只保存一次
+	handled := make(map[uint]struct{})
+	for _, sub := range subs {
+		if _, ok := watchers[sub.UserID]; ok {
+			continue
+		}
+		if _, ok := handled[sub.UserID]; ok {
+			continue
+		}
+		if ok, err := ruleutil.MatchSubscription(ctx, sub, event.File); err != nil {
+			logger.Warnf("Invalid filter of subscription %d, skipping: %s", sub.ID, err)
+			continue
+		} else if !ok {
+			continue
+		}
+		target, err := newSaveTarget(ctx, sub.UserID, sub.StorageName, sub.DirPath, sub.FilenameTemplate, nil)
+		if err != nil {
+			logger.Errorf("Failed to resolve save target of subscription %d, skipping: %s", sub.ID, err)
+			continue
+		}
+		if !subscriptionLimits.allow(sub.ID, sub.MaxPerHour, time.Now()) {
+			logger.Debugf("Subscription %d reached its hourly limit, skipping %s", sub.ID, event.File.Name())
+			subscriptionDigests.skip(target.user.ChatID, sub.Name)
+			continue
+		}
+		handled[sub.UserID] = struct{}{}
+		chatTitle, err := tgutil.GetPeerTitle(ctx, event.ChatID)
+		if err != nil || chatTitle == "" {
+			chatTitle = strconv.FormatInt(event.ChatID, 10)
+		}
+		save := func(files []tfile.TGFileMessage) {
+			items := target.plan(ctx, files)
+			addWatchItemTasks(ctx, target.user.ChatID, items)
+			for _, item := range items {
+				subscriptionDigests.add(target.user.ChatID, msgelem.SubscriptionDigestItem{
+					Subscription: sub.Name,
+					Chat:         chatTitle,
+					Path:         fmt.Sprintf("[%s]:%s", item.stor.Name(), item.path),
+				})
+			}
+		}
+		file := target.prepareFile(ctx, event.File)
+		if target.needAlbumFolder(ctx, file) {
+			watchMediaGroupMgr.addFile(event.ChatID, target.user.ID, file, time.Duration(max(config.C().Telegram.MediaGroupTimeout, 1))*time.Second, save)
+			continue
+		}
+		save([]tfile.TGFileMessage{file})
+	}
+}
+
+// subscriptionIgnored reports whether subscriptions skip a message: files in the chats of telegram storages
+// are saved by the bot itself, and so are messages sent by the userbot or the bot, saving them again would loop.
+func subscriptionIgnored(chatID int64, msg *tg.Message, selfID, botID int64, storageChats []int64) bool {
+	plain := constant.TDLibPeerID(chatID).ToPlain()
+	for _, storageChat := range storageChats {
+		if constant.TDLibPeerID(storageChat).ToPlain() == plain {
+			return true
+		}
+	}
+	if plain == botID {
+		return true
+	}
+	if msg == nil {
+		return false
+	}
+	if msg.Out {
+		return true
+	}
+	if from, ok := msg.GetFromID(); ok {
+		sender := tgutil.ChatIdFromPeer(from)
+		return sender == selfID || sender == botID
+	}
+	return false
+}
+
+// botID returns the user ID of the bot, which is the part of the bot token before the colon.
+func botID() int64 {
+	id, _ := strconv.ParseInt(strings.SplitN(config.C().Telegram.Token, ":", 2)[0], 10, 64)
+	return id
+}
+
+// telegramStorageChats returns the chat IDs of the configured telegram storages.
+func telegramStorageChats() []int64 {
+	var chats []int64
+	for _, cfg := range config.C().Storages {
+		if tgCfg, ok := cfg.(*storconfig.TelegramStorageConfig); ok {
+			chats = append(chats, tgCfg.ChatID)
+		}
+	}
+	return chats
+}
+
+// subscriptionLimiter 按小时统计每个订阅保存的文件数
+type subscriptionLimiter struct {
+	mu      sync.Mutex
+	windows map[uint]subscriptionWindow // subscription ID -> window
+}
+
+type subscriptionWindow struct {
+	start time.Time
+	count int
+}
+
+var subscriptionLimits = &subscriptionLimiter{windows: make(map[uint]subscriptionWindow)}
+
+// allow reports whether the subscription may save another file in the hour starting with its first file, limit 0 means no limit.
+func (l *subscriptionLimiter) allow(id uint, limit int, now time.Time) bool {
+	if limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w := l.windows[id]
+	if now.Sub(w.start) >= time.Hour {
+		w = subscriptionWindow{start: now}
+	}
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+	l.windows[id] = w
+	return true
+}
+
+// subscriptionDigest 收集订阅保存的文件, 定期给每个用户发送一条汇总通知
+type subscriptionDigest struct {
+	mu      sync.Mutex
+	items   map[int64][]msgelem.SubscriptionDigestItem // user chat ID -> saved files
+	limited map[int64]map[string]int                   // user chat ID -> subscription name -> skipped files
+}
+
+var subscriptionDigests = &subscriptionDigest{
+	items:   make(map[int64][]msgelem.SubscriptionDigestItem),
+	limited: make(map[int64]map[string]int),
+}
+
+func (d *subscriptionDigest) add(userChatID int64, item msgelem.SubscriptionDigestItem) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items[userChatID] = append(d.items[userChatID], item)
+}
+
+func (d *subscriptionDigest) skip(userChatID int64, name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.limited[userChatID] == nil {
+		d.limited[userChatID] = make(map[string]int)
+	}
+	d.limited[userChatID][name]++
+}
+
+// take returns the digest text of every user and starts a new digest.
+func (d *subscriptionDigest) take() map[int64]string {
+	d.mu.Lock()
+	items, limited := d.items, d.limited
+	d.items = make(map[int64][]msgelem.SubscriptionDigestItem)
+	d.limited = make(map[int64]map[string]int)
+	d.mu.Unlock()
+
+	texts := make(map[int64]string)
+	for userChatID, userItems := range items {
+		texts[userChatID] = msgelem.BuildSubscriptionDigestText(userItems, limited[userChatID])
+	}
+	for userChatID, userLimited := range limited {
+		if _, ok := texts[userChatID]; !ok {
+			texts[userChatID] = msgelem.BuildSubscriptionDigestText(nil, userLimited)
+		}
+	}
+	return texts
+}
+
+// runSubscriptionDigest sends the digests with the bot until the context is done.
+func runSubscriptionDigest(ctx *ext.Context, interval time.Duration) {
+	logger := log.FromContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for userChatID, text := range subscriptionDigests.take() {
+			if _, err := ctx.SendMessage(userChatID, &tg.MessagesSendMessageRequest{Message: text}); err != nil {
+				logger.Errorf("Failed to send subscription digest to user %d: %s", userChatID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+)
+
+func TestSubscriptionLimiter(t *testing.T) {
+	l := &subscriptionLimiter{windows: make(map[uint]subscriptionWindow)}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := range 3 {
+		if !l.allow(1, 3, now.Add(time.Duration(i)*time.Minute)) {
+			t.Fatalf("file %d should be allowed", i+1)
+		}
+	}
+	if l.allow(1, 3, now.Add(30*time.Minute)) {
+		t.Error("file over the limit should not be allowed")
+	}
+	if !l.allow(2, 3, now.Add(30*time.Minute)) {
+		t.Error("limits of other subscriptions should not be affected")
+	}
+	if !l.allow(1, 3, now.Add(time.Hour)) {
+		t.Error("a new window should start after an hour")
+	}
+	for range 10 {
+		if !l.allow(3, 0, now) {
+			t.Fatal("zero limit should not limit anything")
+		}
+	}
+}
+
+func TestSubscriptionDigestTake(t *testing.T) {
+	d := &subscriptionDigest{
+		items:   make(map[int64][]msgelem.SubscriptionDigestItem),
+		limited: make(map[int64]map[string]int),
+	}
+	d.add(100, msgelem.SubscriptionDigestItem{Subscription: "wallpaper", Chat: "chat", Path: "[local]:/a.jpg"})
+	d.skip(200, "memes")
+	d.skip(200, "memes")
+
+	texts := d.take()
+	if len(texts) != 2 {
+		t.Fatalf("expected digests for 2 users, got %d", len(texts))
+	}
+	for userChatID, text := range texts {
+		if text == "" {
+			t.Errorf("digest of user %d should not be empty", userChatID)
+		}
+	}
+	if texts := d.take(); len(texts) != 0 {
+		t.Errorf("digest should be empty after take, got %d", len(texts))
+	}
+}
+
+func sentBy(userID int64) *tg.Message {
+	msg := &tg.Message{}
+	msg.SetFromID(&tg.PeerUser{UserID: userID})
+	return msg
+}
+
+func TestSubscriptionIgnored(t *testing.T) {
+	const (
+		selfID  = 100
+		botID   = 200
+		storage = -1001234567890
+	)
+	storageChats := []int64{storage}
+	tests := []struct {
+		name   string
+		chatID int64
+		msg    *tg.Message
+		want   bool
+	}{
+		{"telegram storage chat", storage, &tg.Message{}, true},
+		{"telegram storage chat without prefix", 1234567890, &tg.Message{}, true},
+		{"private chat with the bot", botID, &tg.Message{}, true},
+		{"sent by the userbot", -100999, &tg.Message{Out: true}, true},
+		{"sent by the bot", -100999, sentBy(botID), true},
+		{"sent by the userbot as sender", -100999, sentBy(selfID), true},
+		{"sent by someone else", -100999, sentBy(300), false},
+		{"channel post", -100999, &tg.Message{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subscriptionIgnored(tt.chatID, tt.msg, selfID, botID, storageChats); got != tt.want {
+				t.Errorf("subscriptionIgnored() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -227,7 +227,7 @@ func handleTransferCallback(ctx *ext.Context, userID int64, targetStorage storag
 
 	// Create and add task
 	taskID := xid.New().String()
-	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	injectCtx := core.WithOwner(tgutil.ExtWithContext(ctx.Context, ctx), userID)
 	task := transfer.NewTransferTask(
 		taskID,
 		injectCtx,
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/userdata"
+)
+
+// maxUserDataFileSize 导入文件的大小上限
+const maxUserDataFileSize = 1024 * 1024
+
+// /export [yaml|json]
+func handleExportCmd(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	args := strings.Fields(update.EffectiveMessage.Text)
+	formatArg := ""
+	if len(args) > 1 {
+		formatArg = args[1]
+	}
+	format, err := userdata.ParseFormat(formatArg)
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataErrorInvalidArgument, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	data, err := database.ExportUserData(ctx, update.GetUserChat().GetID())
+	if err != nil {
+		logger.Errorf("Failed to export user data: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataErrorExportFailed, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	content, err := userdata.Marshal(data, format)
+	if err != nil {
+		logger.Errorf("Failed to marshal user data: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataErrorExportFailed, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	fileName := fmt.Sprintf("saveany-%s%s", time.Now().Format("20060102-150405"), format.Ext())
+	caption := i18n.T(i18nk.BotMsgUserdataInfoExportCaption, map[string]any{
+		"Rules":      len(data.Rules),
+		"Dirs":       len(data.Dirs),
+		"WatchChats": len(data.WatchChats),
+	})
+	_, err = ctx.Sender.To(update.GetUserChat().AsInputPeer()).
+		Reply(update.EffectiveMessage.ID).
+		Upload(message.FromBytes(fileName, content)).
+		File(ctx, styling.Plain(caption))
+	if err != nil {
+		logger.Errorf("Failed to send exported file: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataErrorExportFailed, map[string]any{"Error": err.Error()})), nil)
+	}
+	return dispatcher.EndGroups
+}
+
+// /import [merge|replace], 回复一个导出的文件
+func handleImportCmd(ctx *ext.Context, update *ext.Update) error {
+	logger := log.FromContext(ctx)
+	args := strings.Fields(update.EffectiveMessage.Text)
+	modeArg := ""
+	if len(args) > 1 {
+		modeArg = args[1]
+	}
+	mode, err := userdata.ParseMode(modeArg)
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataErrorInvalidArgument, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	replyTo := update.EffectiveMessage.ReplyToMessage
+	if replyTo == nil || replyTo.Media == nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataImportHelp, nil)), nil)
+		return dispatcher.EndGroups
+	}
+	media, ok := replyTo.Media.(*tg.MessageMediaDocument)
+	if !ok {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataImportHelp, nil)), nil)
+		return dispatcher.EndGroups
+	}
+	doc, ok := media.Document.AsNotEmpty()
+	if !ok {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataImportHelp, nil)), nil)
+		return dispatcher.EndGroups
+	}
+	if doc.Size > maxUserDataFileSize {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataErrorFileTooLarge, nil)), nil)
+		return dispatcher.EndGroups
+	}
+	buf := bytes.NewBuffer(nil)
+	if _, err := ctx.DownloadMedia(media, ext.DownloadOutputStream{Writer: buf}, nil); err != nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataErrorDownloadFailed, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	data, err := userdata.Unmarshal(buf.Bytes())
+	if err != nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataErrorInvalidFile, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	userChatID := update.GetUserChat().GetID()
+	if err := data.Validate(func(name string) bool { return config.C().HasStorage(userChatID, name) }); err != nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataErrorValidationFailed, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	data.Normalize()
+	stats, err := database.ImportUserData(ctx, userChatID, data, mode)
+	if err != nil {
+		logger.Errorf("Failed to import user data: %s", err)
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataErrorImportFailed, map[string]any{"Error": err.Error()})), nil)
+		return dispatcher.EndGroups
+	}
+	ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgUserdataInfoImportSuccess, map[string]any{
+		"Rules":      stats.Rules,
+		"Dirs":       stats.Dirs,
+		"WatchChats": stats.WatchChats,
+		"Mode":       mode,
+	})), nil)
+	return dispatcher.EndGroups
+}
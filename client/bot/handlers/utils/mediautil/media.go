@@ -25,6 +25,15 @@ func IsSupported(media tg.MessageMediaClass) bool {
 	}
 }
 
+// IsInlineResult reports whether the message is a result of an inline bot sent as a link preview with a photo or document.
+func IsInlineResult(msg *tg.Message) bool {
+	if _, ok := msg.GetViaBotID(); !ok {
+		return false
+	}
+	_, ok := tfile.WebPageMedia(msg.Media)
+	return ok
+}
+
 type FilenameTemplateData struct {
 	MsgID    string `json:"msgid,omitempty"`
 	MsgTags  string `json:"msgtags,omitempty"`
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/gotd/td/telegram/message/styling"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
 	"github.com/krau/SaveAny-Bot/common/i18n"
 	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
 	"github.com/krau/SaveAny-Bot/database"
@@ -28,14 +29,71 @@ func BuildRuleHelpStyling(enabled bool, rules []database.Rule) []styling.StyledT
 		styling.Plain(i18n.T(i18nk.BotMsgRuleHelpPresetSuffix, nil)),
 		styling.Code("del"),
 		styling.Plain(i18n.T(i18nk.BotMsgRuleHelpDelSuffix, nil)),
+		styling.Code("test"),
+		styling.Plain(i18n.T(i18nk.BotMsgRuleHelpTestSuffix, nil)),
 		styling.Plain(i18n.T(i18nk.BotMsgRuleHelpExistingRulesPrefix, nil)),
 		styling.Blockquote(func() string {
 			var sb strings.Builder
 			for _, rule := range rules {
-				ruleText := fmt.Sprintf("%s %s %s %s", rule.Type, rule.Data, rule.StorageName, rule.DirPath)
-				sb.WriteString(fmt.Sprintf("%d: %s\n", rule.ID, ruleText))
+				sb.WriteString(fmt.Sprintf("%d: %s\n", rule.ID, ruleText(rule)))
 			}
 			return sb.String()
 		}(), true),
 	}
 }
+
+func ruleText(rule database.Rule) string {
+	text := fmt.Sprintf("%s %s %s %s", rule.Type, rule.Data, rule.StorageName, rule.DirPath)
+	if rule.Priority != 0 {
+		text += fmt.Sprintf(" --priority %d", rule.Priority)
+	}
+	if rule.Stop {
+		text += " --stop"
+	}
+	if rule.Action != "" {
+		text += fmt.Sprintf(" --action %q", rule.Action)
+	}
+	return text
+}
+
+// BuildRuleTestStyling 构建 /rule test 的结果消息
+func BuildRuleTestStyling(enabled bool, fileName string, res ruleutil.Result) []styling.StyledTextOption {
+	opts := []styling.StyledTextOption{
+		styling.Bold(i18n.T(i18nk.BotMsgRuleTestFile, map[string]any{"Name": fileName})),
+	}
+	if !enabled {
+		opts = append(opts, styling.Italic(i18n.T(i18nk.BotMsgRuleTestModeDisabled, nil)))
+	}
+	if len(res.Evaluations) == 0 {
+		opts = append(opts, styling.Plain(i18n.T(i18nk.BotMsgRuleTestNoRulesEvaluated, nil)))
+	}
+	for _, ev := range res.Evaluations {
+		opts = append(opts, styling.Code(fmt.Sprintf("%d: %s", ev.Rule.ID, ruleText(ev.Rule))))
+		switch {
+		case ev.Err != nil:
+			opts = append(opts, styling.Plain(i18n.T(i18nk.BotMsgRuleTestError, map[string]any{"Error": ev.Err.Error()})))
+		case ev.Matched:
+			opts = append(opts, styling.Plain(i18n.T(i18nk.BotMsgRuleTestMatched, nil)))
+		default:
+			opts = append(opts, styling.Plain(i18n.T(i18nk.BotMsgRuleTestNotMatched, nil)))
+		}
+	}
+	if res.Matched {
+		orKeep := func(s string) string {
+			if s == "" {
+				return "-"
+			}
+			return s
+		}
+		opts = append(opts, styling.Bold(i18n.T(i18nk.BotMsgRuleTestResult, map[string]any{
+			"Storage": orKeep(res.StorageName.String()),
+			"Path":    orKeep(res.DirPath.String()),
+		})))
+	} else {
+		opts = append(opts, styling.Bold(i18n.T(i18nk.BotMsgRuleTestResultNoMatch, nil)))
+	}
+	if !res.Actions.IsZero() {
+		opts = append(opts, styling.Plain(i18n.T(i18nk.BotMsgRuleTestActions, map[string]any{"Actions": res.Actions.String()})))
+	}
+	return opts
+}
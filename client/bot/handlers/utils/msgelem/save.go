@@ -0,0 +1,34 @@
+package msgelem
+
+import (
+	"fmt"
+
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/cache"
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/rs/xid"
+)
+
+// BuildSaveRangeConfirmMarkup builds the confirm and cancel buttons of a range save, the files are kept in the cache until confirmed.
+func BuildSaveRangeConfirmMarkup(adddata tcbdata.Add) (*tg.ReplyInlineMarkup, error) {
+	dataid := xid.New().String()
+	if err := cache.Set(dataid, adddata); err != nil {
+		return nil, err
+	}
+	return &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{
+			Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{
+					Text: i18n.T(i18nk.BotMsgSaveRangeConfirmButtonText, nil),
+					Data: fmt.Appendf(nil, "%s %s", tcbdata.TypeSaveRange, dataid),
+				},
+				&tg.KeyboardButtonCallback{
+					Text: i18n.T(i18nk.BotMsgCommonCancelButtonText, nil),
+					Data: fmt.Appendf(nil, "%s cancel %s", tcbdata.TypeSaveRange, dataid),
+				},
+			},
+		}},
+	}, nil
+}
@@ -59,6 +59,8 @@ func BuildAddSelectStorageKeyboard(stors []storage.Storage, adddata tcbdata.Add)
 			TransferSourceStorName: adddata.TransferSourceStorName,
 			TransferSourcePath:     adddata.TransferSourcePath,
 			TransferFiles:          adddata.TransferFiles,
+
+			ArchiveChatID: adddata.ArchiveChatID,
 		}
 		dataid := xid.New().String()
 		err := cache.Set(dataid, data)
@@ -0,0 +1,80 @@
+package msgelem
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/database"
+)
+
+// BuildSubscriptionListText 构建 /lssub 的消息, 选项以 /subscribe 的参数形式展示
+func BuildSubscriptionListText(subs []database.Subscription) string {
+	var sb strings.Builder
+	sb.WriteString(i18n.T(i18nk.BotMsgSubscriptionInfoListHeader))
+	for _, sub := range subs {
+		sb.WriteString("- " + sub.Name)
+		if sub.Filter != "" {
+			sb.WriteString(i18n.T(i18nk.BotMsgWatchInfoWatchListFilterPrefix))
+			sb.WriteString(sub.Filter)
+			sb.WriteString(")")
+		}
+		sb.WriteString("\n")
+		var options []string
+		for _, opt := range []struct{ name, value string }{
+			{"--tag", sub.Hashtags},
+			{"--media", sub.MediaTypes},
+			{"--size", sub.SizeRange},
+			{"--sender", sub.Senders},
+			{"--storage", sub.StorageName},
+			{"--dir", sub.DirPath},
+			{"--template", sub.FilenameTemplate},
+		} {
+			if opt.value != "" {
+				options = append(options, fmt.Sprintf("%s %q", opt.name, opt.value))
+			}
+		}
+		if len(options) > 0 {
+			sb.WriteString("  " + strings.Join(options, " ") + "\n")
+		}
+		if sub.MaxPerHour > 0 {
+			sb.WriteString(i18n.T(i18nk.BotMsgSubscriptionInfoListLimit, map[string]any{"Limit": sub.MaxPerHour}))
+		}
+	}
+	return sb.String()
+}
+
+// SubscriptionDigestItem 是汇总通知中的一个已保存文件
+type SubscriptionDigestItem struct {
+	Subscription string
+	Chat         string
+	Path         string // "[storage]:path"
+}
+
+// maxSubscriptionDigestItems 是一条汇总通知最多列出的文件数
+const maxSubscriptionDigestItems = 30
+
+// BuildSubscriptionDigestText 构建订阅的汇总通知, limited 是各订阅因达到每小时上限而跳过的文件数
+func BuildSubscriptionDigestText(items []SubscriptionDigestItem, limited map[string]int) string {
+	var sb strings.Builder
+	if len(items) > 0 {
+		sb.WriteString(i18n.T(i18nk.BotMsgSubscriptionInfoDigestHeader, map[string]any{"Count": len(items)}))
+		for _, item := range items[:min(len(items), maxSubscriptionDigestItems)] {
+			sb.WriteString(fmt.Sprintf("- [%s] %s: %s\n", item.Subscription, item.Chat, item.Path))
+		}
+		if len(items) > maxSubscriptionDigestItems {
+			sb.WriteString(i18n.T(i18nk.BotMsgSubscriptionInfoDigestMore, map[string]any{"Count": len(items) - maxSubscriptionDigestItems}))
+		}
+	}
+	names := make([]string, 0, len(limited))
+	for name := range limited {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		sb.WriteString(i18n.T(i18nk.BotMsgSubscriptionInfoDigestLimited, map[string]any{"Name": name, "Count": limited[name]}))
+	}
+	return sb.String()
+}
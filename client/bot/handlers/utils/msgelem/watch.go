@@ -0,0 +1,49 @@
+package msgelem
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/database"
+)
+
+// BuildWatchListText 构建 /lswatch 的消息, 选项以 /watch 的参数形式展示
+func BuildWatchListText(chats []database.WatchChat) string {
+	var sb strings.Builder
+	sb.WriteString(i18n.T(i18nk.BotMsgWatchInfoWatchListHeader))
+	for _, chat := range chats {
+		sb.WriteString(fmt.Sprintf("- %d", chat.ChatID))
+		if chat.Filter != "" {
+			sb.WriteString(i18n.T(i18nk.BotMsgWatchInfoWatchListFilterPrefix))
+			sb.WriteString(chat.Filter)
+			sb.WriteString(")")
+		}
+		sb.WriteString("\n")
+		var options []string
+		for _, opt := range []struct{ name, value string }{
+			{"--storage", chat.StorageName},
+			{"--dir", chat.DirPath},
+			{"--template", chat.FilenameTemplate},
+			{"--media", chat.MediaTypes},
+			{"--size", chat.SizeRange},
+			{"--sender", chat.Senders},
+			{"--tag", chat.Hashtags},
+		} {
+			if opt.value != "" {
+				options = append(options, fmt.Sprintf("%s %q", opt.name, opt.value))
+			}
+		}
+		if len(options) > 0 {
+			sb.WriteString("  " + strings.Join(options, " ") + "\n")
+		}
+		if chat.BackfillRanges != "" {
+			sb.WriteString(i18n.T(i18nk.BotMsgWatchInfoWatchListBackfillPrefix) + chat.BackfillRanges + "\n")
+		}
+		for _, rule := range chat.Rules {
+			sb.WriteString(fmt.Sprintf("  %d: %s\n", rule.ID, ruleText(rule)))
+		}
+	}
+	return sb.String()
+}
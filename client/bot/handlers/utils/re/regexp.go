@@ -5,6 +5,8 @@ import "regexp"
 var (
 	TgMessageLinkRegexString = `https?://t\.me/(?:c/\d+|[A-Za-z0-9_]+)/\d+(?:/\d+)?(?:\?[^\s#]*[A-Za-z0-9_])?\b`
 	TgMessageLinkRegexp      = regexp.MustCompile(TgMessageLinkRegexString)
+	TgStoryLinkRegexString   = `https?://t\.me/[A-Za-z0-9_]+/s/\d+\b`
+	TgStoryLinkRegexp        = regexp.MustCompile(TgStoryLinkRegexString)
 	TelegraphUrlRegexString  = `https://telegra.ph/.*`
 	TelegraphUrlRegexp       = regexp.MustCompile(TelegraphUrlRegexString)
 )
@@ -0,0 +1,69 @@
+package ruleutil
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
+)
+
+// placeholderPeer returns the chat that dir path placeholders refer to: the matched chat for
+// SOURCE-CHAT and FORWARD-FROM rules, otherwise the forward origin or the chat of the message.
+func placeholderPeer(ur database.Rule, env *rule.ExprEnv) rule.Peer {
+	switch ur.Type {
+	case rule.SourceChat.String():
+		return env.Chat
+	case rule.ForwardFrom.String():
+		return env.Forward
+	}
+	if env.Forward != (rule.Peer{}) {
+		return env.Forward
+	}
+	return env.Chat
+}
+
+// renderDirPath replaces chat placeholders such as {{chat_title}} in a matched dir path.
+func renderDirPath(ctx context.Context, dir string, peer rule.Peer) string {
+	if !strings.Contains(dir, "{{") {
+		return dir
+	}
+	id := ""
+	if peer.ID != 0 {
+		id = strconv.FormatInt(peer.ID, 10)
+	}
+	title := peer.Title
+	if title == "" && peer.ID != 0 {
+		if extCtx := extContext(ctx); extCtx != nil {
+			t, err := tgutil.GetPeerTitle(extCtx, peer.ID)
+			if err != nil {
+				log.FromContext(ctx).Warnf("Failed to get title of chat %d: %s", peer.ID, err)
+			}
+			title = t
+		}
+	}
+	username := peer.Username
+	if username == "" {
+		username = id
+	}
+	if title == "" {
+		title = username
+	}
+	replacer := strings.NewReplacer(
+		rule.DirPlaceholderChatID, orUnknown(id),
+		rule.DirPlaceholderChatTitle, orUnknown(fsutil.NormalizePathname(title)),
+		rule.DirPlaceholderChatUsername, orUnknown(fsutil.NormalizePathname(username)),
+	)
+	return replacer.Replace(dir)
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,66 @@
+package ruleutil
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// SyntheticFile describes a file that does not exist in Telegram, used to dry-run rules.
+type SyntheticFile struct {
+	Name     string
+	Caption  string
+	Size     int64
+	MimeType string
+	Duration float64 // seconds, only used when MimeType is audio or video
+	Album    bool
+	ChatID   int64 // bare or bot API style (-100...) chat ID
+}
+
+// NewSyntheticFile builds a file message from a synthetic file for NewInput, the file can not be downloaded.
+func NewSyntheticFile(sf SyntheticFile) (tfile.TGFileMessage, error) {
+	if sf.Name == "" {
+		return nil, errors.New("file name is required")
+	}
+	attrs := []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: sf.Name}}
+	if sf.Duration > 0 {
+		switch {
+		case strings.HasPrefix(sf.MimeType, "audio/"):
+			attrs = append(attrs, &tg.DocumentAttributeAudio{Duration: int(sf.Duration)})
+		case strings.HasPrefix(sf.MimeType, "video/"):
+			attrs = append(attrs, &tg.DocumentAttributeVideo{Duration: sf.Duration})
+		}
+	}
+	media := &tg.MessageMediaDocument{
+		Document: &tg.Document{
+			Size:       sf.Size,
+			MimeType:   sf.MimeType,
+			Attributes: attrs,
+		},
+	}
+	msg := &tg.Message{
+		Message: sf.Caption,
+		Media:   media,
+		Date:    int(time.Now().Unix()),
+	}
+	if sf.Album {
+		msg.GroupedID = 1
+	}
+	msg.PeerID = &tg.PeerChannel{ChannelID: bareChatID(sf.ChatID)}
+	return tfile.FromMediaMessage(media, nil, msg)
+}
+
+// bareChatID converts a bot API style chat ID like -1001234567890 to 1234567890.
+func bareChatID(id int64) int64 {
+	if id >= 0 {
+		return id
+	}
+	id = -id
+	if id > 1e12 {
+		id -= 1e12
+	}
+	return id
+}
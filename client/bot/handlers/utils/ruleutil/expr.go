@@ -0,0 +1,70 @@
+package ruleutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// buildExprEnv collects the attributes used by EXPR rules from the input file and its message.
+func buildExprEnv(ctx context.Context, input *ruleInput) *rule.ExprEnv {
+	env := &rule.ExprEnv{
+		Name: input.File.Name(),
+		Size: input.File.Size(),
+	}
+	msg := input.File.Message()
+	if msg == nil {
+		return env
+	}
+	env.Caption = msg.GetMessage()
+	env.Album = msg.GroupedID != 0
+	if msg.Date != 0 {
+		env.Date = time.Unix(int64(msg.Date), 0)
+	}
+	env.Mime = tfile.MediaMimeType(msg.Media)
+	env.Duration, _ = tfile.MediaDuration(msg.Media)
+
+	lookup := peerLookup(ctx)
+	env.Chat = lookup(msg.GetPeerID())
+	if from, ok := msg.GetFromID(); ok {
+		env.Sender = lookup(from)
+	} else if _, isUser := msg.GetPeerID().(*tg.PeerUser); isUser {
+		// private messages have no FromID, the sender is the peer itself
+		env.Sender = env.Chat
+	}
+	if fwd, ok := msg.GetFwdFrom(); ok {
+		if from, ok := fwd.GetFromID(); ok {
+			env.Forward = lookup(from)
+		} else if name, ok := fwd.GetFromName(); ok {
+			env.Forward = rule.Peer{Title: name}
+		}
+	}
+	return env
+}
+
+// peerLookup converts peers to rule.Peer, filling in usernames from the peer storage when an ext.Context is available.
+func peerLookup(ctx context.Context) func(tg.PeerClass) rule.Peer {
+	extCtx := extContext(ctx)
+	return func(p tg.PeerClass) rule.Peer {
+		peer := rule.Peer{ID: tgutil.ChatIdFromPeer(p)}
+		if peer.ID == 0 || extCtx == nil || extCtx.PeerStorage == nil {
+			return peer
+		}
+		if stored := extCtx.PeerStorage.GetPeerById(peer.ID); stored != nil {
+			peer.Username = stored.Username
+		}
+		return peer
+	}
+}
+
+func extContext(ctx context.Context) *ext.Context {
+	if extCtx, ok := ctx.(*ext.Context); ok {
+		return extCtx
+	}
+	return tgutil.ExtFromContext(ctx)
+}
@@ -1,11 +1,18 @@
 package ruleutil
 
 import (
+	"cmp"
 	"context"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
 	"github.com/duke-git/lancet/v2/convertor"
 
 	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
 	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/pkg/rule"
 	"github.com/krau/SaveAny-Bot/pkg/tfile"
@@ -48,66 +55,266 @@ func (m MatchedDirPath) NeedNewForAlbum() bool {
 	return m != "" && m == rule.RuleDirPathNewForAlbum
 }
 
+// Result is the outcome of applying rules to a file.
+type Result struct {
+	Matched     bool // a rule changed the storage or dir path
+	StorageName matchedStorName
+	DirPath     MatchedDirPath
+	Actions     rule.Actions
+	// Evaluations records every evaluated rule in evaluation order, rules after a matching stop rule are not included
+	Evaluations []Evaluation
+}
+
+// Evaluation is the outcome of evaluating a single rule.
+type Evaluation struct {
+	Rule    database.Rule
+	Matched bool
+	Err     error
+}
+
+// ApplyRule evaluates rules from the highest priority to the lowest, rules of the same priority from the last added.
+// The first matching rule that sets a storage or path wins, and the first matching rule with Stop set ends the evaluation.
 func ApplyRule(ctx context.Context, rules []database.Rule, inputs *ruleInput) (matched bool, matchedStorageName matchedStorName, dirPath MatchedDirPath) {
+	res := Apply(ctx, rules, inputs)
+	return res.Matched, res.StorageName, res.DirPath
+}
+
+// Apply is like ApplyRule, but also collects the actions of all matched rules.
+func Apply(ctx context.Context, rules []database.Rule, inputs *ruleInput) Result {
+	var res Result
 	if inputs == nil || len(rules) == 0 {
-		return false, "", ""
+		return res
 	}
 	logger := log.FromContext(ctx)
-	for _, ur := range rules {
-		switch ur.Type {
-		case rule.FileNameRegex.String():
-			ru, err := rule.NewRuleFileNameRegex(ur.StorageName, ur.DirPath, ur.Data)
-			if err != nil {
-				logger.Errorf("Failed to create rule: %s", err)
-				continue
-			}
-			ok, err := ru.Match(inputs.File)
-			if err != nil {
-				logger.Errorf("Failed to match rule: %s", err)
-				continue
-			}
-			if ok {
-				dirPath = MatchedDirPath(ru.StoragePath())
-				matchedStorageName = matchedStorName(ru.StorageName())
-			}
-		case rule.MessageRegex.String():
-			ru, err := rule.NewRuleMessageRegex(ur.StorageName, ur.DirPath, ur.Data)
-			if err != nil {
-				logger.Errorf("Failed to create rule: %s", err)
-				continue
-			}
-			ok, err := ru.Match(inputs.File.Message().GetMessage())
-			if err != nil {
-				logger.Errorf("Failed to match rule: %s", err)
-				continue
-			}
-			if ok {
-				dirPath = MatchedDirPath(ru.StoragePath())
-				matchedStorageName = matchedStorName(ru.StorageName())
-			}
-		case rule.IsAlbum.String():
-			matchAlbum, err := convertor.ToBool(ur.Data)
-			if err != nil {
-				matchAlbum = false
-			}
-			ru, err := rule.NewRuleMediaType(ur.StorageName, ur.DirPath, matchAlbum)
-			if err != nil {
-				logger.Errorf("Failed to create rule: %s", err)
-				continue
-			}
-			ok, err := ru.Match(inputs.File.Message().GroupedID != 0)
-			if err != nil {
-				logger.Errorf("Failed to match rule: %s", err)
-				continue
-			}
-			if ok {
-				dirPath = MatchedDirPath(ru.StoragePath())
-				matchedStorageName = matchedStorName(ru.StorageName())
+	sorted := slices.Clone(rules)
+	slices.SortStableFunc(sorted, func(a, b database.Rule) int {
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+	// 同优先级中后添加的规则与之前一样优先生效
+	slices.Reverse(sorted)
+	var env *rule.ExprEnv
+	var dirRule *database.Rule
+	for i, ur := range sorted {
+		if env == nil && needsEnv(ur.Type) {
+			env = buildExprEnv(ctx, inputs)
+		}
+		ok, err := matchRule(ur, inputs, env)
+		res.Evaluations = append(res.Evaluations, Evaluation{Rule: ur, Matched: ok && err == nil, Err: err})
+		if err != nil {
+			logger.Errorf("Failed to match rule %d: %s", ur.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if ur.DirPath != rule.RuleKeep && dirRule == nil {
+			res.DirPath = MatchedDirPath(ur.DirPath)
+			dirRule = &sorted[i]
+		}
+		if ur.StorageName != rule.RuleKeep && res.StorageName == "" {
+			res.StorageName = matchedStorName(ur.StorageName)
+		}
+		if ur.Action != "" {
+			c := compiled(ur)
+			if c.actionErr != nil {
+				logger.Errorf("Invalid action of rule %d: %s", ur.ID, c.actionErr)
+			} else {
+				// 已匹配的规则优先级更高, 覆盖当前规则的动作
+				actions := c.actions
+				actions.Merge(res.Actions)
+				res.Actions = actions
 			}
 		}
+		if ur.Stop {
+			break
+		}
+	}
+	if dirRule != nil && strings.Contains(res.DirPath.String(), "{{") {
+		if env == nil {
+			env = buildExprEnv(ctx, inputs)
+		}
+		res.DirPath = MatchedDirPath(renderDirPath(ctx, res.DirPath.String(), placeholderPeer(*dirRule, env)))
+	}
+	res.Matched = res.StorageName != "" || res.DirPath != ""
+	if !res.Matched {
+		res.StorageName, res.DirPath = "", ""
+	}
+	return res
+}
+
+// RenameFile sets the file name from the filename template of the matched actions, if any.
+func (r Result) RenameFile(ctx context.Context, file tfile.TGFileMessage) {
+	if r.Actions.FilenameTemplate == "" || file.Message() == nil {
+		return
+	}
+	tmpl, err := template.New("filename").Parse(r.Actions.FilenameTemplate)
+	if err != nil {
+		log.FromContext(ctx).Errorf("Failed to parse rule filename template: %s", err)
+		return
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, mediautil.BuildFilenameTemplateData(file.Message())); err != nil {
+		log.FromContext(ctx).Errorf("Failed to execute rule filename template: %s", err)
+		return
+	}
+	if name := strings.TrimSpace(sb.String()); name != "" {
+		file.SetName(name)
+	}
+}
+
+// needsEnv reports whether a rule type matches against the message attributes in rule.ExprEnv.
+func needsEnv(ruleType string) bool {
+	switch ruleType {
+	case rule.Expr.String(), rule.SourceChat.String(), rule.ForwardFrom.String():
+		return true
 	}
-	if matchedStorageName != "" || dirPath != "" {
-		return true, matchedStorageName, dirPath
+	return false
+}
+
+// matcher matches the inputs against a compiled rule.
+type matcher func(inputs *ruleInput, env *rule.ExprEnv) (bool, error)
+
+// compiledRule is a rule with its data and actions parsed, so regexes and expressions are compiled only once.
+type compiledRule struct {
+	updatedAt time.Time
+	match     matcher
+	err       error
+	actions   rule.Actions
+	actionErr error
+}
+
+var (
+	compiledMu    sync.Mutex
+	compiledRules = make(map[uint]*compiledRule)
+)
+
+// compiled returns the compiled rule, cached by rule ID until the rule is updated.
+// Rules that are not saved yet (ID 0) are compiled every time.
+func compiled(ur database.Rule) *compiledRule {
+	if ur.ID == 0 {
+		return compileRule(ur)
+	}
+	compiledMu.Lock()
+	defer compiledMu.Unlock()
+	if c, ok := compiledRules[ur.ID]; ok && c.updatedAt.Equal(ur.UpdatedAt) {
+		return c
+	}
+	c := compileRule(ur)
+	compiledRules[ur.ID] = c
+	return c
+}
+
+func init() {
+	database.OnRulesDeleted(forgetRules)
+}
+
+// forgetRules drops the compiled rules of deleted rules.
+func forgetRules(ids []uint) {
+	compiledMu.Lock()
+	defer compiledMu.Unlock()
+	for _, id := range ids {
+		delete(compiledRules, id)
+	}
+}
+
+func compileRule(ur database.Rule) *compiledRule {
+	c := &compiledRule{updatedAt: ur.UpdatedAt}
+	c.match, c.err = newMatcher(ur)
+	if ur.Action != "" {
+		c.actions, c.actionErr = rule.ParseActions(ur.Action)
+	}
+	return c
+}
+
+func matchRule(ur database.Rule, inputs *ruleInput, env *rule.ExprEnv) (bool, error) {
+	c := compiled(ur)
+	if c.err != nil {
+		return false, c.err
+	}
+	if c.match == nil {
+		return false, nil
+	}
+	return c.match(inputs, env)
+}
+
+func newMatcher(ur database.Rule) (matcher, error) {
+	switch ur.Type {
+	case rule.FileNameRegex.String():
+		ru, err := rule.NewRuleFileNameRegex(ur.StorageName, ur.DirPath, ur.Data)
+		if err != nil {
+			return nil, err
+		}
+		return func(inputs *ruleInput, _ *rule.ExprEnv) (bool, error) {
+			return ru.Match(inputs.File)
+		}, nil
+	case rule.MessageRegex.String():
+		ru, err := rule.NewRuleMessageRegex(ur.StorageName, ur.DirPath, ur.Data)
+		if err != nil {
+			return nil, err
+		}
+		return func(inputs *ruleInput, _ *rule.ExprEnv) (bool, error) {
+			return ru.Match(inputs.File.Message().GetMessage())
+		}, nil
+	case rule.IsAlbum.String():
+		matchAlbum, err := convertor.ToBool(ur.Data)
+		if err != nil {
+			matchAlbum = false
+		}
+		ru, err := rule.NewRuleMediaType(ur.StorageName, ur.DirPath, matchAlbum)
+		if err != nil {
+			return nil, err
+		}
+		return func(inputs *ruleInput, _ *rule.ExprEnv) (bool, error) {
+			return ru.Match(inputs.File.Message().GroupedID != 0)
+		}, nil
+	case rule.FileSize.String():
+		ru, err := rule.NewRuleFileSize(ur.StorageName, ur.DirPath, ur.Data)
+		if err != nil {
+			return nil, err
+		}
+		return func(inputs *ruleInput, _ *rule.ExprEnv) (bool, error) {
+			return ru.Match(inputs.File)
+		}, nil
+	case rule.MimeType.String():
+		ru, err := rule.NewRuleMimeType(ur.StorageName, ur.DirPath, ur.Data)
+		if err != nil {
+			return nil, err
+		}
+		return func(inputs *ruleInput, _ *rule.ExprEnv) (bool, error) {
+			return ru.Match(inputs.File)
+		}, nil
+	case rule.MediaDuration.String():
+		ru, err := rule.NewRuleMediaDuration(ur.StorageName, ur.DirPath, ur.Data)
+		if err != nil {
+			return nil, err
+		}
+		return func(inputs *ruleInput, _ *rule.ExprEnv) (bool, error) {
+			return ru.Match(inputs.File)
+		}, nil
+	case rule.SourceChat.String():
+		ru, err := rule.NewRuleSourceChat(ur.StorageName, ur.DirPath, ur.Data)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ *ruleInput, env *rule.ExprEnv) (bool, error) {
+			return ru.Match(env.Chat)
+		}, nil
+	case rule.ForwardFrom.String():
+		ru, err := rule.NewRuleForwardFrom(ur.StorageName, ur.DirPath, ur.Data)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ *ruleInput, env *rule.ExprEnv) (bool, error) {
+			return ru.Match(env.Forward)
+		}, nil
+	case rule.Expr.String():
+		ru, err := rule.NewRuleExpr(ur.StorageName, ur.DirPath, ur.Data)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ *ruleInput, env *rule.ExprEnv) (bool, error) {
+			return ru.Match(env)
+		}, nil
 	}
-	return false, "", ""
+	return nil, nil
 }
@@ -0,0 +1,134 @@
+package ruleutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
+)
+
+func TestApplyEvaluations(t *testing.T) {
+	file, err := NewSyntheticFile(SyntheticFile{
+		Name:     "movie.mkv",
+		Caption:  "#movie",
+		Size:     600 << 20,
+		MimeType: "video/x-matroska",
+		Duration: 5400,
+		ChatID:   -1001234567890,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []database.Rule{
+		{Type: rule.FileNameRegex.String(), Data: `\.mkv$`, StorageName: "local", DirPath: "/videos", Priority: 1},
+		{Type: rule.MessageRegex.String(), Data: `#music`, StorageName: "local", DirPath: "/music", Priority: 4},
+		{Type: rule.Expr.String(), Data: `size > 500MB && chat == 1234567890`, StorageName: rule.RuleKeep, DirPath: "/large", Priority: 2, Stop: true, Action: "conflict=skip"},
+		{Type: rule.FileSize.String(), Data: `>1MB`, StorageName: "other", DirPath: "/", Action: "low-priority"},
+		{Type: rule.FileNameRegex.String(), Data: `(`, StorageName: "local", DirPath: "/broken", Priority: 3},
+		{Type: rule.MimeType.String(), Data: `video/*`, StorageName: "local", DirPath: rule.RuleKeep, Priority: 5, Action: "conflict=overwrite"},
+	}
+	res := Apply(context.Background(), rules, NewInput(file))
+
+	// 从高优先级到低优先级执行, 匹配的 stop 规则之后的规则不再执行
+	want := []struct {
+		data    string
+		matched bool
+		err     bool
+	}{
+		{`video/*`, true, false},
+		{`#music`, false, false},
+		{`(`, false, true},
+		{`size > 500MB && chat == 1234567890`, true, false},
+	}
+	if len(res.Evaluations) != len(want) {
+		t.Fatalf("got %d evaluations, want %d", len(res.Evaluations), len(want))
+	}
+	for i, w := range want {
+		ev := res.Evaluations[i]
+		if ev.Rule.Data != w.data || ev.Matched != w.matched || (ev.Err != nil) != w.err {
+			t.Errorf("evaluation %d: got %q matched=%v err=%v", i, ev.Rule.Data, ev.Matched, ev.Err)
+		}
+	}
+	if !res.Matched || res.StorageName != "local" || res.DirPath != "/large" {
+		t.Errorf("got storage %q path %q", res.StorageName, res.DirPath)
+	}
+	if want := (rule.Actions{ConflictStrategy: "overwrite"}); res.Actions != want {
+		t.Errorf("got actions %+v, want %+v", res.Actions, want)
+	}
+
+	// 同优先级的规则中后添加的优先生效
+	res = Apply(context.Background(), []database.Rule{
+		{Type: rule.FileNameRegex.String(), Data: `\.mkv$`, StorageName: "local", DirPath: "/videos"},
+		{Type: rule.FileSize.String(), Data: `>1MB`, StorageName: "other", DirPath: "/"},
+	}, NewInput(file))
+	if res.StorageName != "other" || res.DirPath != "/" {
+		t.Errorf("got storage %q path %q for rules of the same priority", res.StorageName, res.DirPath)
+	}
+}
+
+func TestNewSyntheticFileRequiresName(t *testing.T) {
+	if _, err := NewSyntheticFile(SyntheticFile{}); err == nil {
+		t.Error("expected error for empty name")
+	}
+}
+
+func TestCompiledRuleCache(t *testing.T) {
+	updated := time.Now()
+	ur := database.Rule{Type: rule.FileNameRegex.String(), Data: `\.mkv$`, Action: "skip"}
+	ur.ID, ur.UpdatedAt = 1<<30, updated
+	t.Cleanup(func() { forgetRules([]uint{1 << 30}) })
+
+	first := compiled(ur)
+	if compiled(ur) != first {
+		t.Error("unchanged rule was compiled again")
+	}
+	if !first.actions.Skip {
+		t.Errorf("got actions %+v", first.actions)
+	}
+
+	ur.Data, ur.UpdatedAt = `(`, updated.Add(time.Second)
+	if c := compiled(ur); c == first || c.err == nil {
+		t.Error("updated rule was not compiled again")
+	}
+
+	forgetRules([]uint{ur.ID})
+	compiledMu.Lock()
+	_, ok := compiledRules[ur.ID]
+	compiledMu.Unlock()
+	if ok {
+		t.Error("deleted rule is still cached")
+	}
+
+	ur.ID = 0
+	if compiled(ur) == compiled(ur) {
+		t.Error("unsaved rule should not be cached")
+	}
+}
+
+func TestSubscriptionFilterCache(t *testing.T) {
+	sub := &database.Subscription{Filter: `msgre:#movie`}
+	sub.ID, sub.UpdatedAt = 1<<30, time.Now()
+	t.Cleanup(func() { forgetFilters([]uint{sub.ID}) })
+
+	first, err := subscriptionFilter(sub, SubscriptionFilterOptions(sub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := subscriptionFilter(sub, SubscriptionFilterOptions(sub)); again != first {
+		t.Error("unchanged subscription was compiled again")
+	}
+	sub.Filter, sub.UpdatedAt = `msgre:(`, sub.UpdatedAt.Add(time.Second)
+	if _, err := subscriptionFilter(sub, SubscriptionFilterOptions(sub)); err == nil {
+		t.Error("updated subscription was not compiled again")
+	}
+
+	forgetFilters([]uint{sub.ID})
+	compiledFiltersMu.Lock()
+	_, ok := compiledFilters[sub.ID]
+	compiledFiltersMu.Unlock()
+	if ok {
+		t.Error("deleted subscription is still cached")
+	}
+}
@@ -0,0 +1,110 @@
+package ruleutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// WatchFilterOptions returns the filter conditions of a watch.
+func WatchFilterOptions(wc *database.WatchChat) rule.FilterOptions {
+	return rule.FilterOptions{
+		Message:  wc.Filter,
+		Media:    wc.MediaTypes,
+		Size:     wc.SizeRange,
+		Senders:  wc.Senders,
+		Hashtags: wc.Hashtags,
+	}
+}
+
+// MatchWatch reports whether a file from a watched chat passes the filters of the watch.
+func MatchWatch(ctx context.Context, wc *database.WatchChat, file tfile.TGFileMessage) (bool, error) {
+	return MatchFilter(ctx, WatchFilterOptions(wc), file)
+}
+
+// SubscriptionFilterOptions returns the filter conditions of a subscription.
+func SubscriptionFilterOptions(sub *database.Subscription) rule.FilterOptions {
+	return rule.FilterOptions{
+		Message:  sub.Filter,
+		Media:    sub.MediaTypes,
+		Size:     sub.SizeRange,
+		Senders:  sub.Senders,
+		Hashtags: sub.Hashtags,
+	}
+}
+
+// MatchSubscription reports whether a file from any chat passes the filters of the subscription.
+// Unlike watches, a subscription without filters matches nothing.
+func MatchSubscription(ctx context.Context, sub *database.Subscription, file tfile.TGFileMessage) (bool, error) {
+	opts := SubscriptionFilterOptions(sub)
+	if opts.IsZero() {
+		return false, nil
+	}
+	filter, err := subscriptionFilter(sub, opts)
+	if err != nil {
+		return false, err
+	}
+	return matchFilter(ctx, filter, file), nil
+}
+
+func MatchFilter(ctx context.Context, opts rule.FilterOptions, file tfile.TGFileMessage) (bool, error) {
+	if opts.IsZero() {
+		return true, nil
+	}
+	filter, err := rule.NewFilter(opts)
+	if err != nil {
+		return false, err
+	}
+	return matchFilter(ctx, filter, file), nil
+}
+
+func matchFilter(ctx context.Context, filter *rule.Filter, file tfile.TGFileMessage) bool {
+	var env *rule.ExprEnv
+	if filter.NeedsEnv() {
+		env = buildExprEnv(ctx, NewInput(file))
+	}
+	return filter.Match(file, env)
+}
+
+// compiledFilter is the filter of a subscription, compiled once as every media message is matched against all subscriptions.
+type compiledFilter struct {
+	updatedAt time.Time
+	filter    *rule.Filter
+	err       error
+}
+
+var (
+	compiledFiltersMu sync.Mutex
+	compiledFilters   = make(map[uint]compiledFilter)
+)
+
+// subscriptionFilter returns the compiled filter of the subscription, cached by subscription ID until it is updated.
+func subscriptionFilter(sub *database.Subscription, opts rule.FilterOptions) (*rule.Filter, error) {
+	compiledFiltersMu.Lock()
+	defer compiledFiltersMu.Unlock()
+	if c, ok := compiledFilters[sub.ID]; ok && c.updatedAt.Equal(sub.UpdatedAt) {
+		return c.filter, c.err
+	}
+	filter, err := rule.NewFilter(opts)
+	if sub.ID != 0 {
+		compiledFilters[sub.ID] = compiledFilter{updatedAt: sub.UpdatedAt, filter: filter, err: err}
+	}
+	return filter, err
+}
+
+func init() {
+	database.OnSubscriptionsDeleted(forgetFilters)
+}
+
+// forgetFilters drops the compiled filters of deleted subscriptions.
+func forgetFilters(ids []uint) {
+	compiledFiltersMu.Lock()
+	defer compiledFiltersMu.Unlock()
+	for _, id := range ids {
+		delete(compiledFilters, id)
+	}
+}
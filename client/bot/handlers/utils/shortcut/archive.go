@@ -0,0 +1,78 @@
+package shortcut
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/celestix/gotgproto/dispatcher"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/rs/xid"
+
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/tasks/archive"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/tgarchive"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+// archiveStateStore 将归档进度保存到数据库
+type archiveStateStore struct {
+	archiveID uint
+}
+
+func (s archiveStateStore) SaveState(ctx context.Context, state archive.State) error {
+	return database.UpdateArchiveProgress(ctx, s.archiveID, state.LastMessageID, tgarchive.FormatPages(state.Pages))
+}
+
+// CreateAndAddArchiveTaskWithEdit archives the chat of a record, continuing from its last archived message.
+func CreateAndAddArchiveTaskWithEdit(ctx *ext.Context, userID int64, stor storage.Storage, record *database.Archive, msgID int) error {
+	logger := log.FromContext(ctx)
+	uctx := userclient.GetCtx()
+	if uctx == nil {
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:      msgID,
+			Message: i18n.T(i18nk.BotMsgArchiveErrorUserbotRequired, nil),
+		})
+		return dispatcher.EndGroups
+	}
+	pages, err := tgarchive.ParsePages(record.Pages)
+	if err != nil {
+		logger.Warnf("Invalid pages of archive %d, the viewer index will only list new pages: %s", record.ID, err)
+	}
+	injectCtx := core.WithOwner(tgutil.ExtWithContext(ctx.Context, ctx), userID)
+	task := archive.NewTask(
+		xid.New().String(),
+		injectCtx,
+		record.ChatID,
+		stor,
+		record.Path,
+		uctx,
+		archive.State{LastMessageID: record.LastMessageID, Pages: pages},
+		archiveStateStore{archiveID: record.ID},
+		archive.NewProgress(msgID, userID),
+	)
+	if err := core.AddTask(injectCtx, task); err != nil {
+		logger.Errorf("Failed to add task: %s", err)
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID: msgID,
+			Message: i18n.T(i18nk.BotMsgCommonErrorTaskAddFailed, map[string]any{
+				"Error": err.Error(),
+			}),
+		})
+		return dispatcher.EndGroups
+	}
+	text, entities := msgelem.BuildTaskAddedEntities(ctx, fmt.Sprintf("archive %d", record.ChatID), core.GetLength(ctx))
+	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+		ID:       msgID,
+		Message:  text,
+		Entities: entities,
+	})
+	return dispatcher.EndGroups
+}
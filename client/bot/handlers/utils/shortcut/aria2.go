@@ -17,7 +17,7 @@ import (
 
 func CreateAndAddAria2TaskWithEdit(ctx *ext.Context, stor storage.Storage, dirPath string, uris []string, aria2Client *aria2.Client, msgID int, userID int64) error {
 	logger := log.FromContext(ctx)
-	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	injectCtx := core.WithOwner(tgutil.ExtWithContext(ctx.Context, ctx), userID)
 
 	// Now add to aria2 after user selected storage
 	logger.Infof("Adding download to aria2, uris type: %T, value: %+v", uris, uris)
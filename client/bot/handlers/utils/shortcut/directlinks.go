@@ -15,7 +15,7 @@ import (
 )
 
 func CreateAndAddDirectTaskWithEdit(ctx *ext.Context, stor storage.Storage, dirPath string, links []string, msgID int, userID int64) error {
-	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	injectCtx := core.WithOwner(tgutil.ExtWithContext(ctx.Context, ctx), userID)
 	task := directlinks.NewTask(xid.New().String(), injectCtx, links, stor, dirPath, directlinks.NewProgress(msgID, userID))
 	if err := core.AddTask(injectCtx, task); err != nil {
 		log.FromContext(ctx).Errorf("Failed to add task: %s", err)
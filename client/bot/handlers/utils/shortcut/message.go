@@ -10,7 +10,6 @@ import (
 	"github.com/celestix/gotgproto/ext"
 	"github.com/celestix/gotgproto/types"
 	"github.com/charmbracelet/log"
-	"github.com/gotd/td/telegram/downloader"
 	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
@@ -33,7 +32,7 @@ func GetFileFromMessageWithReply(ctx *ext.Context, update *ext.Update, message *
 ) {
 	logger := log.FromContext(ctx)
 	media := message.Media
-	supported := mediautil.IsSupported(media)
+	supported := mediautil.IsSupported(media) || mediautil.IsInlineResult(message)
 	if !supported {
 		return nil, nil, dispatcher.ContinueGroups
 	}
@@ -67,8 +66,10 @@ type EditMessageFunc func(text string, markup tg.ReplyMarkupClass)
 // 获取链接中的文件并回复等待消息
 func GetFilesFromUpdateLinkMessageWithReplyEdit(ctx *ext.Context, update *ext.Update) (replied *types.Message, files []tfile.TGFileMessage, editReplied EditMessageFunc, err error) {
 	logger := log.FromContext(ctx)
-	msgLinks := re.TgMessageLinkRegexp.FindAllString(tgutil.ExtractMessageEntityUrlsText(update.EffectiveMessage.Message), -1)
-	if len(msgLinks) == 0 {
+	urlsText := tgutil.ExtractMessageEntityUrlsText(update.EffectiveMessage.Message)
+	msgLinks := re.TgMessageLinkRegexp.FindAllString(urlsText, -1)
+	storyLinks := re.TgStoryLinkRegexp.FindAllString(urlsText, -1)
+	if len(msgLinks) == 0 && len(storyLinks) == 0 {
 		logger.Warn("no matched message links but called handleMessageLink")
 		return nil, nil, nil, dispatcher.EndGroups
 	}
@@ -94,8 +95,8 @@ func GetFilesFromUpdateLinkMessageWithReplyEdit(ctx *ext.Context, update *ext.Up
 		}), nil)
 		return nil, nil, nil, dispatcher.EndGroups
 	}
-	files = make([]tfile.TGFileMessage, 0, len(msgLinks))
-	addFile := func(client downloader.Client, msg *tg.Message) {
+	files = make([]tfile.TGFileMessage, 0, len(msgLinks)+len(storyLinks))
+	addFile := func(client *ext.Context, msg *tg.Message) {
 		if msg == nil || msg.Media == nil {
 			logger.Warn("message is nil, skipping")
 			return
@@ -105,8 +106,12 @@ func GetFilesFromUpdateLinkMessageWithReplyEdit(ctx *ext.Context, update *ext.Up
 			logger.Debugf("message %d has no media", msg.GetID())
 			return
 		}
-		opts := mediautil.TfileOptions(ctx, user, msg)
-		file, err := tfile.FromMediaMessage(media, client, msg, opts...)
+		if !mediautil.IsSupported(media) && !mediautil.IsInlineResult(msg) {
+			logger.Debugf("message %d has unsupported media %s", msg.GetID(), media.TypeName())
+			return
+		}
+		opts := append(mediautil.TfileOptions(ctx, user, msg), tgutil.WithFileRefresher(client, msg))
+		file, err := tfile.FromMediaMessage(media, client.Raw, msg, opts...)
 		if err != nil {
 			logger.Errorf("failed to create file from media: %s", err)
 			return
@@ -144,12 +149,38 @@ func GetFilesFromUpdateLinkMessageWithReplyEdit(ctx *ext.Context, update *ext.Up
 				logger.Errorf("failed to get grouped messages: %s", err)
 			} else {
 				for _, gmsg := range gmsgs {
-					addFile(tctx.Raw, gmsg)
+					addFile(tctx, gmsg)
 				}
 			}
 		} else {
-			addFile(tctx.Raw, msg)
+			addFile(tctx, msg)
+		}
+	}
+	// 故事只能由 userbot 获取
+	for _, link := range storyLinks {
+		chat, storyID, err := tgutil.SplitStoryLink(link)
+		if err != nil {
+			logger.Errorf("failed to parse story link %s: %s", link, err)
+			continue
+		}
+		chatID, err := tgutil.ParseChatID(tctx, chat)
+		if err != nil {
+			logger.Errorf("failed to parse chat of story link %s: %s", link, err)
+			continue
 		}
+		story, peer, err := tgutil.GetStory(tctx, chatID, storyID)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		msg := tfile.StoryMessage(peer, story)
+		opts := append(mediautil.TfileOptions(ctx, user, msg), tgutil.WithStoryRefresher(tctx, chatID, storyID))
+		file, err := tfile.FromStory(story, peer, tctx.Raw, opts...)
+		if err != nil {
+			logger.Errorf("failed to create file from story: %s", err)
+			continue
+		}
+		files = append(files, file)
 	}
 	if len(files) == 0 {
 		editReplied(i18n.T(i18nk.BotMsgCommonErrorNoSavableFilesFound, nil), nil)
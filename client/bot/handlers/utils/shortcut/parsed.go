@@ -17,7 +17,7 @@ import (
 )
 
 func CreateAndAddParsedTaskWithEdit(ctx *ext.Context, stor storage.Storage, dirPath string, item *parser.Item, msgID int, userID int64) error {
-	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	injectCtx := core.WithOwner(tgutil.ExtWithContext(ctx.Context, ctx), userID)
 	task := parsed.NewTask(xid.New().String(), injectCtx, stor, dirPath, item, parsed.NewProgress(msgID, userID))
 	if err := core.AddTask(injectCtx, task); err != nil {
 		log.FromContext(ctx).Errorf("Failed to add task: %s", err)
@@ -1,6 +1,7 @@
 package shortcut
 
 import (
+	"context"
 	"path"
 	"strings"
 
@@ -19,6 +20,7 @@ import (
 	tftask "github.com/krau/SaveAny-Bot/core/tasks/tfile"
 	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
 	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
 	"github.com/krau/SaveAny-Bot/pkg/tfile"
 	"github.com/krau/SaveAny-Bot/storage"
@@ -40,17 +42,31 @@ func CreateAndAddTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor storage
 		})
 		return dispatcher.EndGroups
 	}
-	strategy = conflictutil.ResolveStrategy(user, strategy)
+	var actions rule.Actions
 	if user.ApplyRule && user.Rules != nil {
-		matched, matchedStorageName, matchedDirPath := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewInput(file))
-		if !matched {
+		res := ruleutil.Apply(ctx, user.Rules, ruleutil.NewInput(file))
+		actions = res.Actions
+		if actions.Skip {
+			ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+				ID: trackMsgID,
+				Message: i18n.T(i18nk.BotMsgCommonInfoSkippedByRule, map[string]any{
+					"Skipped": file.Name(),
+				}),
+			})
+			return dispatcher.EndGroups
+		}
+		res.RenameFile(ctx, file)
+		if strategy == "" {
+			strategy = actions.ConflictStrategy
+		}
+		if !res.Matched {
 			goto startCreateTask
 		}
-		if matchedDirPath != "" {
-			dirPath = matchedDirPath.String()
+		if res.DirPath != "" {
+			dirPath = res.DirPath.String()
 		}
-		if matchedStorageName.Usable() {
-			stor, err = storage.GetStorageByUserIDAndName(ctx, user.ChatID, matchedStorageName.String())
+		if res.StorageName.Usable() {
+			stor, err = storage.GetStorageByUserIDAndName(ctx, user.ChatID, res.StorageName.String())
 			if err != nil {
 				logger.Errorf("Failed to get storage by user ID and name: %s", err)
 				ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
@@ -64,6 +80,7 @@ func CreateAndAddTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor storage
 		}
 	}
 startCreateTask:
+	strategy = conflictutil.ResolveStrategy(user, strategy)
 	storagePath := path.Join(dirPath, file.Name())
 	if strategy == tcbdata.ConflictStrategyAsk || strategy == tcbdata.ConflictStrategySkip {
 		exists := stor.Exists(ctx, storagePath)
@@ -81,7 +98,7 @@ startCreateTask:
 			return dispatcher.EndGroups
 		}
 	}
-	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	injectCtx := core.WithOwner(tgutil.ExtWithContext(ctx.Context, ctx), userID)
 	if strategy == tcbdata.ConflictStrategyOverwrite {
 		injectCtx = storage.WithOverwrite(injectCtx)
 	}
@@ -100,7 +117,7 @@ startCreateTask:
 		})
 		return dispatcher.EndGroups
 	}
-	if err := core.AddTask(injectCtx, task); err != nil {
+	if err := AddTask(injectCtx, task, actions); err != nil {
 		logger.Errorf("add task failed: %s", err)
 		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
 			ID: trackMsgID,
@@ -139,32 +156,56 @@ func CreateAndAddBatchTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor st
 
 	useRule := user.ApplyRule && user.Rules != nil
 
-	applyRule := func(file tfile.TGFileMessage) (string, ruleutil.MatchedDirPath) {
+	applyRule := func(file tfile.TGFileMessage) (string, ruleutil.MatchedDirPath, rule.Actions) {
 		if !useRule {
-			return stor.Name(), ruleutil.MatchedDirPath(dirPath)
+			return stor.Name(), ruleutil.MatchedDirPath(dirPath), rule.Actions{}
 		}
-		matched, storName, dirP := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewInput(file))
-		if !matched {
-			return stor.Name(), ruleutil.MatchedDirPath(dirPath)
+		res := ruleutil.Apply(ctx, user.Rules, ruleutil.NewInput(file))
+		if res.Actions.Skip {
+			return "", "", res.Actions
 		}
-		storname := storName.String()
-		if !storName.Usable() {
+		res.RenameFile(ctx, file)
+		if !res.Matched {
+			return stor.Name(), ruleutil.MatchedDirPath(dirPath), res.Actions
+		}
+		storname := res.StorageName.String()
+		if !res.StorageName.Usable() {
 			storname = stor.Name()
 		}
-		return storname, dirP
+		dirP := res.DirPath
+		if dirP == "" {
+			dirP = ruleutil.MatchedDirPath(dirPath)
+		}
+		return storname, dirP, res.Actions
+	}
+	// 规则中的冲突策略仅在用户未通过按钮明确选择时生效
+	fileStrategy := func(actions rule.Actions) string {
+		if selectedConflictStrategy(conflictStrategy) == "" && actions.ConflictStrategy != "" {
+			return actions.ConflictStrategy
+		}
+		return strategy
 	}
 
 	skipped := make([]string, 0)
+	ruleSkipped := make([]string, 0)
 	conflicts := make([]string, 0)
 	elems := make([]batchtfile.TaskElement, 0, len(files))
+	lowPriority := true
 	type albumFile struct {
-		file    tfile.TGFileMessage
-		storage storage.Storage
-		dirPath string
+		file        tfile.TGFileMessage
+		storage     storage.Storage
+		dirPath     string
+		strategy    string
+		lowPriority bool
 	}
 	albumFiles := make(map[int64][]albumFile, 0)
 	for _, file := range files {
-		storName, matchedDirPath := applyRule(file)
+		storName, matchedDirPath, actions := applyRule(file)
+		if actions.Skip {
+			ruleSkipped = append(ruleSkipped, file.Name())
+			continue
+		}
+		strategy := fileStrategy(actions)
 		fileStor := stor
 		if storName != stor.Name() && storName != "" {
 			fileStor, err = storage.GetStorageByUserIDAndName(ctx, user.ChatID, storName)
@@ -203,6 +244,8 @@ func CreateAndAddBatchTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor st
 				})
 				return dispatcher.EndGroups
 			}
+			elem.Overwrite = strategy == tcbdata.ConflictStrategyOverwrite
+			lowPriority = lowPriority && actions.LowPriority
 			elems = append(elems, *elem)
 		} else {
 			groupId, isGroup := file.Message().GetGroupedID()
@@ -218,9 +261,11 @@ func CreateAndAddBatchTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor st
 				albumFiles[groupId] = make([]albumFile, 0)
 			}
 			albumFiles[groupId] = append(albumFiles[groupId], albumFile{
-				file:    file,
-				storage: fileStor,
-				dirPath: fileDirPath,
+				file:        file,
+				storage:     fileStor,
+				dirPath:     fileDirPath,
+				strategy:    strategy,
+				lowPriority: actions.LowPriority,
 			})
 		}
 	}
@@ -234,9 +279,9 @@ func CreateAndAddBatchTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor st
 		albumStor := afiles[0].storage
 		for _, af := range afiles {
 			afstorPath := path.Join(af.dirPath, albumDir, af.file.Name())
-			if strategy == tcbdata.ConflictStrategyAsk || strategy == tcbdata.ConflictStrategySkip {
+			if af.strategy == tcbdata.ConflictStrategyAsk || af.strategy == tcbdata.ConflictStrategySkip {
 				exists := albumStor.Exists(ctx, afstorPath)
-				if exists && strategy == tcbdata.ConflictStrategyAsk {
+				if exists && af.strategy == tcbdata.ConflictStrategyAsk {
 					conflicts = append(conflicts, conflictutil.FormatPath(albumStor.Name(), afstorPath))
 					continue
 				}
@@ -256,31 +301,42 @@ func CreateAndAddBatchTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor st
 				})
 				return dispatcher.EndGroups
 			}
+			elem.Overwrite = af.strategy == tcbdata.ConflictStrategyOverwrite
+			lowPriority = lowPriority && af.lowPriority
 			elems = append(elems, *elem)
 		}
 	}
 
-	if strategy == tcbdata.ConflictStrategyAsk && len(conflicts) > 0 {
+	if len(conflicts) > 0 {
 		return promptTGFileConflictStrategy(ctx, userID, stor.Name(), dirPath, files, true, conflicts, trackMsgID)
 	}
 
-	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	injectCtx := core.WithOwner(tgutil.ExtWithContext(ctx.Context, ctx), userID)
 	if strategy == tcbdata.ConflictStrategyOverwrite {
 		injectCtx = storage.WithOverwrite(injectCtx)
 	}
 	if len(elems) == 0 {
-		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
-			ID: trackMsgID,
-			Message: i18n.T(i18nk.BotMsgCommonInfoAllConflictFilesSkipped, map[string]any{
+		var message string
+		if len(skipped) > 0 {
+			message = i18n.T(i18nk.BotMsgCommonInfoAllConflictFilesSkipped, map[string]any{
 				"Skipped": strings.Join(skipped, "\n"),
-			}),
+			})
+		}
+		if len(ruleSkipped) > 0 {
+			message = strings.TrimSpace(message + "\n" + i18n.T(i18nk.BotMsgCommonInfoSkippedByRule, map[string]any{
+				"Skipped": strings.Join(ruleSkipped, "\n"),
+			}))
+		}
+		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
+			ID:          trackMsgID,
+			Message:     message,
 			ReplyMarkup: nil,
 		})
 		return dispatcher.EndGroups
 	}
 	taskid := xid.New().String()
 	task := batchtfile.NewBatchTGFileTask(taskid, injectCtx, elems, batchtfile.NewProgressTrackerWithSkipped(trackMsgID, userID, skipped), true)
-	if err := core.AddTask(injectCtx, task); err != nil {
+	if err := AddTask(injectCtx, task, rule.Actions{LowPriority: lowPriority}); err != nil {
 		logger.Errorf("Failed to add batch task: %s", err)
 		ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
 			ID: trackMsgID,
@@ -292,7 +348,7 @@ func CreateAndAddBatchTGFileTaskWithEdit(ctx *ext.Context, userID int64, stor st
 	}
 	ctx.EditMessage(userID, &tg.MessagesEditMessageRequest{
 		ID:          trackMsgID,
-		Message:     buildBatchAddedMessage(len(elems), skipped),
+		Message:     buildBatchAddedMessage(len(elems), skipped, ruleSkipped),
 		ReplyMarkup: nil,
 	})
 	return dispatcher.EndGroups
@@ -325,14 +381,30 @@ func selectedConflictStrategy(strategies []string) string {
 	return strategies[0]
 }
 
-func buildBatchAddedMessage(count int, skipped []string) string {
+func buildBatchAddedMessage(count int, skipped, ruleSkipped []string) string {
+	var msg string
 	if len(skipped) == 0 {
-		return i18n.T(i18nk.BotMsgCommonInfoBatchTasksAdded, map[string]any{
+		msg = i18n.T(i18nk.BotMsgCommonInfoBatchTasksAdded, map[string]any{
 			"Count": count,
 		})
+	} else {
+		msg = i18n.T(i18nk.BotMsgCommonInfoBatchTasksAddedWithSkipped, map[string]any{
+			"Count":   count,
+			"Skipped": strings.Join(skipped, "\n"),
+		})
 	}
-	return i18n.T(i18nk.BotMsgCommonInfoBatchTasksAddedWithSkipped, map[string]any{
-		"Count":   count,
-		"Skipped": strings.Join(skipped, "\n"),
-	})
+	if len(ruleSkipped) > 0 {
+		msg += "\n" + i18n.T(i18nk.BotMsgCommonInfoSkippedByRule, map[string]any{
+			"Skipped": strings.Join(ruleSkipped, "\n"),
+		})
+	}
+	return msg
+}
+
+// AddTask 将任务添加到任务队列中, 规则动作要求低优先级时排在普通任务之后
+func AddTask(ctx context.Context, task core.Executable, actions rule.Actions) error {
+	if actions.LowPriority {
+		return core.AddLowPriorityTask(ctx, task)
+	}
+	return core.AddTask(ctx, task)
 }
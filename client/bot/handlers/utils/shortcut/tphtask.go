@@ -26,7 +26,7 @@ func CreateAndAddtelegraphWithEdit(
 	stor storage.Storage,
 	trackMsgID int) error {
 
-	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
+	injectCtx := core.WithOwner(tgutil.ExtWithContext(ctx.Context, ctx), userID)
 	task := tphtask.NewTask(xid.New().String(),
 		injectCtx,
 		tphpage.Path,
@@ -1,9 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"path"
-	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"text/template"
@@ -13,24 +14,30 @@ import (
 	"github.com/celestix/gotgproto/ext"
 	"github.com/charmbracelet/log"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/msgelem"
 	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/shortcut"
 	userclient "github.com/krau/SaveAny-Bot/client/user"
 	"github.com/krau/SaveAny-Bot/common/i18n"
 	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/common/utils/strutil"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/core"
 	coretfile "github.com/krau/SaveAny-Bot/core/tasks/tfile"
 	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/pkg/enums/fnamest"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
 	"github.com/krau/SaveAny-Bot/pkg/tfile"
 	"github.com/krau/SaveAny-Bot/storage"
 	"github.com/rs/xid"
 )
 
+// /watch <chat> [msgre:<regex>] [--storage <name>] [--dir <path>] [--template <template>] [--media <types>] [--size <range>] [--sender <senders>] [--tag <tags>] [--reset] [--backfill <n> | --since <date>] [--until <date>]
 func handleWatchCmd(ctx *ext.Context, update *ext.Update) error {
 	logger := log.FromContext(ctx)
-	args := strings.Split(update.EffectiveMessage.Text, " ")
+	args := strutil.ParseArgsRespectQuotes(update.EffectiveMessage.Text)
 	if len(args) < 2 {
 		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchHelpText)), nil)
 		return dispatcher.EndGroups
@@ -42,10 +49,6 @@ func handleWatchCmd(ctx *ext.Context, update *ext.Update) error {
 		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorGetUserFailed)), nil)
 		return dispatcher.EndGroups
 	}
-	if user.DefaultStorage == "" {
-		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorDefaultStorageNotSet)), nil)
-		return dispatcher.EndGroups
-	}
 	chatArg := args[1]
 	chatID, err := tgutil.ParseChatID(ctx, chatArg)
 	if err != nil {
@@ -57,45 +60,189 @@ func handleWatchCmd(ctx *ext.Context, update *ext.Update) error {
 		logger.Errorf("Failed to check if user is watching chat %d: %s", chatID, err)
 		return dispatcher.EndGroups
 	}
+	watchChat := &database.WatchChat{UserID: user.ID, ChatID: chatID}
 	if watching {
-		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchInfoAlreadyWatchingChat)), nil)
-		return dispatcher.EndGroups
+		watchChat, err = user.GetWatchChat(ctx, chatID)
+		if err != nil {
+			logger.Errorf("Failed to get watch of chat %d: %s", chatID, err)
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorWatchChatFailed, map[string]any{"Error": err.Error()})), nil)
+			return dispatcher.EndGroups
+		}
 	}
-	filter := ""
-	if len(args) > 2 {
-		filterArg := strings.Join(args[2:], " ")
-		filterType := strings.Split(filterArg, ":")[0]
-		filterData := strings.Split(filterArg, ":")[1]
-		if filterType == "" || filterData == "" {
-			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorFilterFormatInvalid)), nil)
+
+	// 不以 -- 开头的参数组成消息过滤器, 以兼容包含空格的正则
+	var filterArgs []string
+	changed := false
+	// 回溯历史消息的选项
+	var (
+		backfillCount int
+		since, until  time.Time
+	)
+	for i := 2; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "--") {
+			filterArgs = append(filterArgs, args[i])
+			continue
+		}
+		if args[i] == "--reset" {
+			*watchChat = database.WatchChat{Model: watchChat.Model, UserID: user.ID, ChatID: chatID}
+			changed = true
+			continue
+		}
+		if i+1 >= len(args) {
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorInvalidOption, map[string]any{"Option": args[i]})), nil)
 			return dispatcher.EndGroups
 		}
-		switch filterType {
-		case "msgre":
-			_, err := regexp.Compile(filterData)
+		opt, value := args[i], args[i+1]
+		i++
+		switch opt {
+		case "--backfill":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 || !since.IsZero() {
+				ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorInvalidOption, map[string]any{"Option": opt})), nil)
+				return dispatcher.EndGroups
+			}
+			backfillCount = n
+			continue
+		case "--since", "--until":
+			date, err := time.ParseInLocation(time.DateOnly, value, time.Local)
 			if err != nil {
-				ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorInvalidRegex, map[string]any{"Error": err.Error()})), nil)
+				ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorInvalidDate, map[string]any{"Date": value})), nil)
+				return dispatcher.EndGroups
+			}
+			if opt == "--until" {
+				until = date
+				continue
+			}
+			if backfillCount > 0 {
+				ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorInvalidOption, map[string]any{"Option": opt})), nil)
 				return dispatcher.EndGroups
 			}
-			filter = filterType + ":" + filterData
+			since = date
+			continue
+		}
+		changed = true
+		switch opt {
+		case "--storage":
+			watchChat.StorageName = value
+		case "--dir":
+			watchChat.DirPath = value
+		case "--template":
+			watchChat.FilenameTemplate = value
+		case "--media":
+			watchChat.MediaTypes = value
+		case "--size":
+			watchChat.SizeRange = value
+		case "--sender":
+			watchChat.Senders = value
+		case "--tag":
+			watchChat.Hashtags = value
 		default:
-			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorFilterTypeUnsupported)), nil)
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorInvalidOption, map[string]any{"Option": opt})), nil)
+			return dispatcher.EndGroups
+		}
+	}
+	if len(filterArgs) > 0 {
+		watchChat.Filter = strings.Join(filterArgs, " ")
+		changed = true
+	}
+	backfill := backfillCount > 0 || !since.IsZero() || !until.IsZero()
+	if watching && !changed && !backfill {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchInfoAlreadyWatchingChat)), nil)
+		return dispatcher.EndGroups
+	}
+
+	if watchChat.StorageName == "" && user.DefaultStorage == "" {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgCommonErrorDefaultStorageNotSet)), nil)
+		return dispatcher.EndGroups
+	}
+	if watchChat.StorageName != "" && !config.C().HasStorage(user.ChatID, watchChat.StorageName) {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorStorageNotFound, map[string]any{"Storage": watchChat.StorageName})), nil)
+		return dispatcher.EndGroups
+	}
+	if watchChat.FilenameTemplate != "" {
+		if _, err := template.New("filename").Parse(watchChat.FilenameTemplate); err != nil {
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorInvalidTemplate, map[string]any{"Error": err.Error()})), nil)
 			return dispatcher.EndGroups
 		}
 	}
-	if err := user.WatchChat(ctx, database.WatchChat{
-		UserID: user.ID,
-		ChatID: chatID,
-		Filter: filter,
-	}); err != nil {
-		logger.Errorf("Failed to watch chat %d: %s", chatID, err)
-		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorWatchChatFailed, map[string]any{"Error": err.Error()})), nil)
+	if _, err := rule.NewFilter(ruleutil.WatchFilterOptions(watchChat)); err != nil {
+		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorFilterInvalid, map[string]any{"Error": err.Error()})), nil)
 		return dispatcher.EndGroups
 	}
-	ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchInfoWatchChatStarted, map[string]any{"Chat": chatArg})), nil)
+
+	// 历史消息通过 userbot 获取, 新的监听从当前最新的消息开始记录
+	uctx := userclient.GetCtx()
+	var backfillRange msgIDRange
+	if backfill {
+		if uctx == nil {
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorBackfillFailed, map[string]any{"Error": "userbot is not logged in"})), nil)
+			return dispatcher.EndGroups
+		}
+		latest, err := tgutil.GetNthLatestMessageID(uctx, chatID, 1)
+		if err == nil {
+			backfillRange, err = resolveBackfillRange(uctx, chatID, latest, backfillCount, since, until)
+		}
+		if err != nil {
+			logger.Errorf("Failed to resolve backfill range of chat %d: %s", chatID, err)
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorBackfillFailed, map[string]any{"Error": err.Error()})), nil)
+			return dispatcher.EndGroups
+		}
+		if !watching {
+			watchChat.LastMessageID = latest
+		}
+	} else if !watching && uctx != nil {
+		latest, err := tgutil.GetNthLatestMessageID(uctx, chatID, 1)
+		if err != nil {
+			logger.Warnf("Failed to get the latest message of chat %d: %s", chatID, err)
+		}
+		watchChat.LastMessageID = latest
+	}
+
+	var text string
+	if watching {
+		if changed {
+			if err := database.UpdateWatchChat(ctx, watchChat); err != nil {
+				logger.Errorf("Failed to update watch of chat %d: %s", chatID, err)
+				ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorWatchChatFailed, map[string]any{"Error": err.Error()})), nil)
+				return dispatcher.EndGroups
+			}
+			text = i18n.T(i18nk.BotMsgWatchInfoWatchChatUpdated, map[string]any{"Chat": chatArg})
+		}
+	} else {
+		if err := user.WatchChat(ctx, *watchChat); err != nil {
+			logger.Errorf("Failed to watch chat %d: %s", chatID, err)
+			ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchErrorWatchChatFailed, map[string]any{"Error": err.Error()})), nil)
+			return dispatcher.EndGroups
+		}
+		text = i18n.T(i18nk.BotMsgWatchInfoWatchChatStarted, map[string]any{"Chat": chatArg})
+	}
+	if backfill {
+		if text != "" {
+			text += "\n"
+		}
+		text += startBackfillFromCmd(ctx, uctx, user, chatID, backfillRange)
+	}
+	ctx.Reply(update, ext.ReplyTextString(text), nil)
 	return dispatcher.EndGroups
 }
 
+// startBackfillFromCmd queues the range requested by /watch and returns the reply text.
+func startBackfillFromCmd(ctx *ext.Context, uctx *ext.Context, user *database.User, chatID int64, r msgIDRange) string {
+	if r.start > r.end {
+		return i18n.T(i18nk.BotMsgWatchInfoBackfillNothing)
+	}
+	watchChat, err := user.GetWatchChat(ctx, chatID)
+	if err == nil {
+		err = appendWatchBackfill(ctx, watchChat.ID, r)
+	}
+	if err != nil {
+		log.FromContext(ctx).Errorf("Failed to queue backfill of chat %d: %s", chatID, err)
+		return i18n.T(i18nk.BotMsgWatchErrorBackfillFailed, map[string]any{"Error": err.Error()})
+	}
+	startWatchBackfill(uctx, watchChat.ID)
+	return i18n.T(i18nk.BotMsgWatchInfoBackfillStarted, map[string]any{"Start": r.start, "End": r.end})
+}
+
 func handleLswatchCmd(ctx *ext.Context, update *ext.Update) error {
 	logger := log.FromContext(ctx)
 	userChatID := update.GetUserChat().GetID()
@@ -110,19 +257,7 @@ func handleLswatchCmd(ctx *ext.Context, update *ext.Update) error {
 		ctx.Reply(update, ext.ReplyTextString(i18n.T(i18nk.BotMsgWatchInfoWatchListEmpty)), nil)
 		return dispatcher.EndGroups
 	}
-	var sb strings.Builder
-	sb.WriteString(i18n.T(i18nk.BotMsgWatchInfoWatchListHeader))
-	for _, chat := range chats {
-		sb.WriteString("- ")
-		sb.WriteString(fmt.Sprintf("%d", chat.ChatID))
-		if chat.Filter != "" {
-			sb.WriteString(i18n.T(i18nk.BotMsgWatchInfoWatchListFilterPrefix))
-			sb.WriteString(chat.Filter)
-			sb.WriteString(")")
-		}
-		sb.WriteString("\n")
-	}
-	ctx.Reply(update, ext.ReplyTextString(sb.String()), nil)
+	ctx.Reply(update, ext.ReplyTextString(msgelem.BuildWatchListText(chats)), nil)
 	return dispatcher.EndGroups
 }
 
@@ -203,233 +338,238 @@ func (w *watchMediaGroupHandler) addFile(chatID int64, userID uint, file tfile.T
 }
 
 func listenMediaMessageEvent(ch chan userclient.MediaMessageEvent) {
-	if userclient.GetCtx() == nil {
+	uctx := userclient.GetCtx()
+	if uctx == nil {
 		return
 	}
-	logger := log.FromContext(userclient.GetCtx())
+	logger := log.FromContext(uctx)
+	resumeWatchBackfills(uctx)
 	for event := range ch {
 		logger.Debug("Received media message event", "chat_id", event.ChatID, "file_name", event.File.Name())
 		ctx := event.Ctx
-		file := event.File
 		chats, err := database.GetWatchChatsByChatID(ctx, event.ChatID)
 		if err != nil {
 			logger.Errorf("Failed to get watch chats for chat ID %d: %v", event.ChatID, err)
 			continue
 		}
-		msgText := event.File.Message().GetMessage()
+		watchers := make(map[uint]struct{}, len(chats))
 		for _, chat := range chats {
-			if chat.Filter != "" {
-				filter := strings.Split(chat.Filter, ":")
-				if len(filter) != 2 {
-					logger.Warnf("Invalid filter format in chat %d, skipping", chat.ChatID)
-					continue
-				}
-				filterType := filter[0]
-				filterData := filter[1]
-				switch filterType {
-				case "msgre": // [TODO] enums for filter types
-					if ok, err := regexp.MatchString(filterData, msgText); err != nil {
-						continue
-					} else if !ok {
-						continue
-					}
-				default:
-					logger.Warnf("Unsupported filter type %s in chat %d, skipping", filterType, chat.ChatID)
-					continue
-				}
-			}
-			user, err := database.GetUserByID(ctx, chat.UserID)
-			if err != nil {
-				logger.Errorf("Failed to get user by ID %d: %v", chat.UserID, err)
-				continue
-			}
-			if user.DefaultStorage == "" {
-				logger.Warnf("User %d has no default storage set, skipping media message handling", chat.UserID)
-				continue
-			}
-			stor, err := storage.GetStorageByUserIDAndName(ctx, user.ChatID, user.DefaultStorage)
-			if err != nil {
-				logger.Errorf("Failed to get storage by user ID %d and name %s: %v", user.ChatID, user.DefaultStorage, err)
-				continue
-			}
-			// Resolve the default directory path from user.DefaultDir
-			var defaultDirPath string
-			if user.DefaultDir != 0 {
-				dir, err := database.GetDirByID(ctx, user.DefaultDir)
-				if err != nil {
-					logger.Warnf("Failed to get default dir for user %d: %v, using root", user.ChatID, err)
-				} else {
-					defaultDirPath = dir.Path
-				}
-			}
-			switch user.FilenameStrategy {
-			case fnamest.Message.String():
-				file.SetName(tgutil.GenFileNameFromMessage(*file.Message()))
-			case fnamest.Template.String():
-				if user.FilenameTemplate == "" {
-					logger.Warnf("Empty filename template for user %d, using default filename", user.ChatID)
-					break
-				}
-				message := file.Message()
-				tmpl, err := template.New("filename").Parse(user.FilenameTemplate)
-				if err != nil {
-					logger.Errorf("Failed to parse filename template for user %d: %s", user.ChatID, err)
-					break
-				}
-				data := mediautil.BuildFilenameTemplateData(message)
-				var sb strings.Builder
-				err = tmpl.Execute(&sb, data)
-				if err != nil {
-					log.FromContext(ctx).Errorf("failed to execute filename template: %s", err)
-					break
-				}
-				file.SetName(sb.String())
-			}
-
-			// Check if this is a media group and if rules specify NEW-FOR-ALBUM
-			groupID, isGroup := file.Message().GetGroupedID()
-			needAlbumHandling := false
-			if isGroup && groupID != 0 && user.ApplyRule && user.Rules != nil {
-				_, _, matchedDirPath := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewInput(file))
-				needAlbumHandling = matchedDirPath.NeedNewForAlbum()
-			}
-
-			if needAlbumHandling {
-				// For media groups with NEW-FOR-ALBUM rule, collect all files of the same group
-				watchMediaGroupMgr.addFile(event.ChatID, user.ID, file, time.Duration(max(config.C().Telegram.MediaGroupTimeout, 1))*time.Second, func(files []tfile.TGFileMessage) {
-					processWatchMediaGroup(ctx, user, stor, defaultDirPath, files)
-				})
-				continue
-			}
-
-			// Process single file or media group without album folder creation
-			dirPath := defaultDirPath
-			if user.ApplyRule && user.Rules != nil {
-				matched, matchedStorageName, matchedDirPath := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewInput(file))
-				if !matched {
-					goto startCreateTask
-				}
-				dirPath = matchedDirPath.String()
-				if matchedStorageName.Usable() {
-					stor, err = storage.GetStorageByUserIDAndName(ctx, user.ChatID, matchedStorageName.String())
-					if err != nil {
-						logger.Errorf("Failed to get storage by user ID and name: %s", err)
-						continue
-					}
-				}
-			}
-		startCreateTask:
-			storagePath := path.Join(dirPath, file.Name())
-			injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
-			taskid := xid.New().String()
-			task, err := coretfile.NewTGFileTask(taskid, injectCtx, file, stor, storagePath, nil)
-			if err != nil {
-				logger.Errorf("create task failed: %s", err)
+			watchers[chat.UserID] = struct{}{}
+			if event.MessageID <= chat.LastMessageID {
+				// already handled, e.g. by the catch-up after a restart
 				continue
 			}
-			if err := core.AddTask(injectCtx, task); err != nil {
-				logger.Errorf("add task failed: %s", err)
-				continue
+			if err := database.UpdateWatchChatLastMessageID(ctx, chat.ID, event.MessageID); err != nil {
+				logger.Warnf("Failed to update last message ID of watch %d: %v", chat.ID, err)
 			}
-			logger.Infof("Added media message task for user %d in chat %d: %s", chat.UserID, event.ChatID, file.Name())
+			saveWatchedFile(ctx, chat, event.File)
 		}
+		handleSubscriptions(ctx, event, watchers)
 	}
 }
 
-func processWatchMediaGroup(ctx *ext.Context, user *database.User, stor storage.Storage, dirPath string, files []tfile.TGFileMessage) {
+// saveWatchedFile adds the tasks saving a new file of a watched chat if it passes the filters of the watch.
+func saveWatchedFile(ctx *ext.Context, chat *database.WatchChat, src tfile.TGFileMessage) {
 	logger := log.FromContext(ctx)
-	if len(files) == 0 {
+	if ok, err := ruleutil.MatchWatch(ctx, chat, src); err != nil {
+		logger.Warnf("Invalid filter of watch %d in chat %d, skipping: %s", chat.ID, chat.ChatID, err)
+		return
+	} else if !ok {
 		return
 	}
+	target, err := newWatchTarget(ctx, chat)
+	if err != nil {
+		logger.Errorf("Failed to resolve save target of watch %d, skipping: %s", chat.ID, err)
+		return
+	}
+	file := target.prepareFile(ctx, src)
+	if target.needAlbumFolder(ctx, file) {
+		// For media groups with NEW-FOR-ALBUM rule, collect all files of the same group
+		watchMediaGroupMgr.addFile(chat.ChatID, target.user.ID, file, time.Duration(max(config.C().Telegram.MediaGroupTimeout, 1))*time.Second, func(files []tfile.TGFileMessage) {
+			addWatchItemTasks(ctx, target.user.ChatID, target.plan(ctx, files))
+		})
+		return
+	}
+	addWatchItemTasks(ctx, target.user.ChatID, target.plan(ctx, []tfile.TGFileMessage{file}))
+}
 
-	useRule := user.ApplyRule && user.Rules != nil
+// watchTarget 是一个监听或订阅的保存位置, 文件名与规则
+type watchTarget struct {
+	user             *database.User
+	stor             storage.Storage
+	dirPath          string
+	filenameTemplate string
+	rules            []database.Rule
+}
+
+func newWatchTarget(ctx context.Context, chat *database.WatchChat) (*watchTarget, error) {
+	return newSaveTarget(ctx, chat.UserID, chat.StorageName, chat.DirPath, chat.FilenameTemplate, chat.Rules)
+}
 
-	applyRule := func(file tfile.TGFileMessage) (string, ruleutil.MatchedDirPath) {
-		if !useRule {
-			return stor.Name(), ruleutil.MatchedDirPath(dirPath)
+// newSaveTarget resolves where the files of a watch or subscription are saved, empty settings fall back to the user's defaults.
+func newSaveTarget(ctx context.Context, userID uint, storName, dirPath, filenameTemplate string, rules []database.Rule) (*watchTarget, error) {
+	user, err := database.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by ID %d: %w", userID, err)
+	}
+	if storName == "" {
+		storName = user.DefaultStorage
+	}
+	if storName == "" {
+		return nil, fmt.Errorf("user %d has no default storage set", user.ChatID)
+	}
+	stor, err := storage.GetStorageByUserIDAndName(ctx, user.ChatID, storName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage %s of user %d: %w", storName, user.ChatID, err)
+	}
+	// Resolve the default directory path from user.DefaultDir
+	if dirPath == "" && user.DefaultDir != 0 {
+		dir, err := database.GetDirByID(ctx, user.DefaultDir)
+		if err != nil {
+			log.FromContext(ctx).Warnf("Failed to get default dir for user %d: %v, using root", user.ChatID, err)
+		} else {
+			dirPath = dir.Path
 		}
-		matched, storName, dirP := ruleutil.ApplyRule(ctx, user.Rules, ruleutil.NewInput(file))
-		if !matched {
-			return stor.Name(), ruleutil.MatchedDirPath(dirPath)
+	}
+	// Rules of the watch are used instead of the user's rules, even if rule mode is off
+	if len(rules) == 0 && user.ApplyRule {
+		rules = user.Rules
+	}
+	return &watchTarget{user: user, stor: stor, dirPath: dirPath, filenameTemplate: filenameTemplate, rules: rules}, nil
+}
+
+// prepareFile returns a copy of the file named by the filename template of the watch or the user's filename strategy.
+// Every watch renames its own copy, so watches of the same chat don't affect each other.
+func (t *watchTarget) prepareFile(ctx context.Context, src tfile.TGFileMessage) tfile.TGFileMessage {
+	logger := log.FromContext(ctx)
+	file := tfile.Copy(src)
+	filenameStrategy, filenameTemplate := t.user.FilenameStrategy, t.user.FilenameTemplate
+	if t.filenameTemplate != "" {
+		filenameStrategy, filenameTemplate = fnamest.Template.String(), t.filenameTemplate
+	}
+	switch filenameStrategy {
+	case fnamest.Message.String():
+		file.SetName(tgutil.GenFileNameFromMessage(*file.Message()))
+	case fnamest.Template.String():
+		if filenameTemplate == "" {
+			logger.Warnf("Empty filename template for user %d, using default filename", t.user.ChatID)
+			break
+		}
+		tmpl, err := template.New("filename").Parse(filenameTemplate)
+		if err != nil {
+			logger.Errorf("Failed to parse filename template for user %d: %s", t.user.ChatID, err)
+			break
 		}
-		storname := storName.String()
-		if !storName.Usable() {
-			storname = stor.Name()
+		data := mediautil.BuildFilenameTemplateData(file.Message())
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, data); err != nil {
+			logger.Errorf("failed to execute filename template: %s", err)
+			break
 		}
-		return storname, dirP
+		file.SetName(sb.String())
 	}
+	return file
+}
 
-	type albumFile struct {
-		file    tfile.TGFileMessage
-		storage storage.Storage
-		dirPath string
+// needAlbumFolder reports whether the file is in a media group that the rules save to a new folder (NEW-FOR-ALBUM).
+func (t *watchTarget) needAlbumFolder(ctx context.Context, file tfile.TGFileMessage) bool {
+	groupID, isGroup := file.Message().GetGroupedID()
+	if !isGroup || groupID == 0 || len(t.rules) == 0 {
+		return false
 	}
-	albumFiles := make(map[int64][]albumFile)
+	_, _, matchedDirPath := ruleutil.ApplyRule(ctx, t.rules, ruleutil.NewInput(file))
+	return matchedDirPath.NeedNewForAlbum()
+}
 
-	// Collect files by group ID
+// watchItem is a file of a watch with the place it is saved to
+type watchItem struct {
+	file    tfile.TGFileMessage
+	stor    storage.Storage
+	path    string
+	actions rule.Actions
+}
+
+// plan applies the rules to the files and returns where to save them, files skipped by rules are left out.
+func (t *watchTarget) plan(ctx context.Context, files []tfile.TGFileMessage) []watchItem {
+	logger := log.FromContext(ctx)
+	items := make([]watchItem, 0, len(files))
+	albums := make(map[int64][]watchItem)
+	var albumIDs []int64
 	for _, file := range files {
-		storName, ruleDirPath := applyRule(file)
-		fileStor := stor
-		if storName != stor.Name() && storName != "" {
-			var err error
-			fileStor, err = storage.GetStorageByUserIDAndName(ctx, user.ChatID, storName)
-			if err != nil {
-				logger.Errorf("Failed to get storage by user ID and name: %s", err)
+		item := watchItem{file: file, stor: t.stor}
+		dirPath := ruleutil.MatchedDirPath(t.dirPath)
+		if len(t.rules) > 0 {
+			res := ruleutil.Apply(ctx, t.rules, ruleutil.NewInput(file))
+			item.actions = res.Actions
+			if res.Actions.Skip {
+				logger.Infof("Skipped watched file by rule: %s", file.Name())
 				continue
 			}
+			res.RenameFile(ctx, file)
+			if res.Matched && res.DirPath != "" {
+				dirPath = res.DirPath
+			}
+			if res.Matched && res.StorageName.Usable() && res.StorageName.String() != t.stor.Name() {
+				stor, err := storage.GetStorageByUserIDAndName(ctx, t.user.ChatID, res.StorageName.String())
+				if err != nil {
+					logger.Errorf("Failed to get storage by user ID and name: %s", err)
+					continue
+				}
+				item.stor = stor
+			}
 		}
-
-		groupId, isGroup := file.Message().GetGroupedID()
-		if !isGroup || groupId == 0 {
-			logger.Warnf("File %s is not in a group, skipping", file.Name())
-			continue
+		if dirPath.NeedNewForAlbum() {
+			if groupID, isGroup := file.Message().GetGroupedID(); isGroup && groupID != 0 {
+				if _, ok := albums[groupID]; !ok {
+					albumIDs = append(albumIDs, groupID)
+				}
+				albums[groupID] = append(albums[groupID], item)
+				continue
+			}
+			dirPath = ruleutil.MatchedDirPath(t.dirPath)
 		}
-
-		// Use the effective dirPath: if rule returns NEW-FOR-ALBUM sentinel, fall back to the
-		// base dirPath passed in (which is defaultDirPath from the caller).
-		effectiveDirPath := string(ruleDirPath)
-		if ruleDirPath.NeedNewForAlbum() {
-			effectiveDirPath = dirPath
+		item.path = path.Join(dirPath.String(), file.Name())
+		items = append(items, item)
+	}
+	for _, groupID := range albumIDs {
+		album := albums[groupID]
+		// 将第一个文件的文件名(去除扩展名)作为相册目录名, 存储以第一个文件的存储为准
+		albumDir := strings.TrimSuffix(path.Base(album[0].file.Name()), path.Ext(album[0].file.Name()))
+		logger.Infof("Creating album folder for group %d: %s with %d files", groupID, albumDir, len(album))
+		for _, item := range album {
+			item.stor = album[0].stor
+			item.path = path.Join(t.dirPath, albumDir, item.file.Name())
+			items = append(items, item)
 		}
+	}
 
-		if _, ok := albumFiles[groupId]; !ok {
-			albumFiles[groupId] = make([]albumFile, 0)
+	result := items[:0]
+	for _, item := range items {
+		if item.actions.ConflictStrategy == tcbdata.ConflictStrategySkip && item.stor.Exists(ctx, item.path) {
+			logger.Infof("Skipped existing file %s by rule", item.path)
+			continue
 		}
-		albumFiles[groupId] = append(albumFiles[groupId], albumFile{
-			file:    file,
-			storage: fileStor,
-			dirPath: effectiveDirPath,
-		})
+		result = append(result, item)
 	}
+	return result
+}
 
-	// Process album files with folder creation
-	injectCtx := tgutil.ExtWithContext(ctx.Context, ctx)
-	totalTasks := 0
-	for groupID, afiles := range albumFiles {
-		if len(afiles) <= 1 {
+func addWatchItemTasks(ctx *ext.Context, owner int64, items []watchItem) {
+	logger := log.FromContext(ctx)
+	for _, item := range items {
+		injectCtx := core.WithOwner(tgutil.ExtWithContext(ctx.Context, ctx), owner)
+		if item.actions.ConflictStrategy == tcbdata.ConflictStrategyOverwrite {
+			injectCtx = storage.WithOverwrite(injectCtx)
+		}
+		task, err := coretfile.NewTGFileTask(xid.New().String(), injectCtx, item.file, item.stor, item.path, nil)
+		if err != nil {
+			logger.Errorf("create task failed: %s", err)
 			continue
 		}
-
-		// Use first file's name (without extension) as album folder name
-		albumDir := strings.TrimSuffix(path.Base(afiles[0].file.Name()), path.Ext(afiles[0].file.Name()))
-		albumStor := afiles[0].storage
-
-		logger.Infof("Creating album folder for group %d: %s with %d files", groupID, albumDir, len(afiles))
-
-		for _, af := range afiles {
-			afstorPath := path.Join(af.dirPath, albumDir, af.file.Name())
-			taskid := xid.New().String()
-			task, err := coretfile.NewTGFileTask(taskid, injectCtx, af.file, albumStor, afstorPath, nil)
-			if err != nil {
-				logger.Errorf("create task failed for album file: %s", err)
-				continue
-			}
-			if err := core.AddTask(injectCtx, task); err != nil {
-				logger.Errorf("add task failed: %s", err)
-				continue
-			}
-			totalTasks++
+		if err := shortcut.AddTask(injectCtx, task, item.actions); err != nil {
+			logger.Errorf("add task failed: %s", err)
+			continue
 		}
+		logger.Infof("Added watch task: %s", item.path)
 	}
-	logger.Infof("Added %d watch media tasks for user %d", totalTasks, user.ChatID)
 }
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/mediautil"
+	"github.com/krau/SaveAny-Bot/client/bot/handlers/utils/ruleutil"
+	"github.com/krau/SaveAny-Bot/common/utils/strutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/core/tasks/batchtfile"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/taskevent"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/rs/xid"
+)
+
+// 回溯监听聊天的历史消息.
+// 待处理的消息 ID 范围保存在 WatchChat.BackfillRanges 中, 每一页的任务完成后才会前移, 重启后从中断处继续.
+
+// backfillPageSize 每页的消息 ID 数量, 每页创建一个批量任务
+const backfillPageSize = 100
+
+type msgIDRange struct {
+	start, end int
+}
+
+func (r msgIDRange) String() string {
+	return fmt.Sprintf("%d-%d", r.start, r.end)
+}
+
+func parseMsgIDRanges(s string) ([]msgIDRange, error) {
+	var ranges []msgIDRange
+	for part := range strings.SplitSeq(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		start, end, err := strutil.ParseIntStrRange(part, "-")
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, msgIDRange{start: int(start), end: int(end)})
+	}
+	return ranges, nil
+}
+
+func formatMsgIDRanges(ranges []msgIDRange) string {
+	parts := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// resolveBackfillRange returns the messages selected by --backfill, --since and --until, latest is the newest message ID.
+// The day of until is included.
+func resolveBackfillRange(ctx *ext.Context, chatID int64, latest, count int, since, until time.Time) (msgIDRange, error) {
+	r := msgIDRange{start: 1, end: latest}
+	if count > 0 {
+		start, err := tgutil.GetNthLatestMessageID(ctx, chatID, count)
+		if err != nil {
+			return r, err
+		}
+		r.start = max(start, 1)
+	}
+	if !since.IsZero() {
+		before, err := tgutil.GetLastMessageIDBefore(ctx, chatID, since)
+		if err != nil {
+			return r, err
+		}
+		r.start = before + 1
+	}
+	if !until.IsZero() {
+		before, err := tgutil.GetLastMessageIDBefore(ctx, chatID, until.AddDate(0, 0, 1))
+		if err != nil {
+			return r, err
+		}
+		r.end = before
+	}
+	return r, nil
+}
+
+var (
+	backfillMu      sync.Mutex // guards BackfillRanges updates and backfillRunning
+	backfillRunning = make(map[uint]bool)
+)
+
+// appendWatchBackfill queues a range of messages to be backfilled, call startWatchBackfill to process it.
+func appendWatchBackfill(ctx context.Context, watchID uint, r msgIDRange) error {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+	chat, err := database.GetWatchChatByID(ctx, watchID)
+	if err != nil {
+		return err
+	}
+	ranges, err := parseMsgIDRanges(chat.BackfillRanges)
+	if err != nil {
+		return err
+	}
+	ranges = append(ranges, r)
+	return database.UpdateWatchChatBackfillRanges(ctx, watchID, formatMsgIDRanges(ranges))
+}
+
+// startWatchBackfill processes the queued ranges of a watch in the background, it does nothing if they are already being processed.
+func startWatchBackfill(ctx *ext.Context, watchID uint) {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+	if backfillRunning[watchID] {
+		return
+	}
+	backfillRunning[watchID] = true
+	go runWatchBackfill(ctx, watchID)
+}
+
+// resumeWatchBackfills queues the messages received while the bot was offline and resumes unfinished backfills.
+func resumeWatchBackfills(ctx *ext.Context) {
+	logger := log.FromContext(ctx)
+	chats, err := database.GetAllWatchChats(ctx)
+	if err != nil {
+		logger.Errorf("Failed to get watch chats: %s", err)
+		return
+	}
+	for _, chat := range chats {
+		if chat.LastMessageID > 0 {
+			latest, err := tgutil.GetNthLatestMessageID(ctx, chat.ChatID, 1)
+			if err != nil {
+				logger.Warnf("Failed to get the latest message of chat %d: %s", chat.ChatID, err)
+			} else if latest > chat.LastMessageID {
+				missed := msgIDRange{start: chat.LastMessageID + 1, end: latest}
+				if err := appendWatchBackfill(ctx, chat.ID, missed); err != nil {
+					logger.Errorf("Failed to queue missed messages %s of watch %d: %s", missed, chat.ID, err)
+					continue
+				}
+				if err := database.UpdateWatchChatLastMessageID(ctx, chat.ID, latest); err != nil {
+					logger.Warnf("Failed to update last message ID of watch %d: %s", chat.ID, err)
+				}
+				logger.Infof("Catching up messages %s of watched chat %d", missed, chat.ChatID)
+			}
+		}
+		startWatchBackfill(ctx, chat.ID)
+	}
+}
+
+func runWatchBackfill(ctx *ext.Context, watchID uint) {
+	logger := log.FromContext(ctx)
+	for {
+		chat, page, ok := nextBackfillPage(ctx, watchID)
+		if !ok {
+			return
+		}
+		err := backfillPage(ctx, chat, page)
+		if errors.Is(err, context.Canceled) {
+			logger.Infof("Backfill of watch %d was canceled, dropping the queued ranges", watchID)
+			stopWatchBackfill(ctx, watchID, true)
+			return
+		}
+		if err != nil {
+			logger.Errorf("Backfill of watch %d stopped at %s, it will be resumed after a restart: %s", watchID, page, err)
+			stopWatchBackfill(ctx, watchID, false)
+			return
+		}
+		if err := finishBackfillPage(ctx, watchID, page); err != nil {
+			logger.Errorf("Failed to save backfill progress of watch %d: %s", watchID, err)
+			stopWatchBackfill(ctx, watchID, false)
+			return
+		}
+	}
+}
+
+// nextBackfillPage returns the next page of a watch, ok is false when there is nothing left and the runner should exit.
+func nextBackfillPage(ctx context.Context, watchID uint) (chat *database.WatchChat, page msgIDRange, ok bool) {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+	chat, err := database.GetWatchChatByID(ctx, watchID)
+	if err != nil {
+		// unwatched
+		delete(backfillRunning, watchID)
+		return nil, page, false
+	}
+	ranges, err := parseMsgIDRanges(chat.BackfillRanges)
+	if err != nil || len(ranges) == 0 {
+		if err != nil {
+			log.FromContext(ctx).Errorf("Invalid backfill ranges of watch %d: %s", watchID, err)
+		}
+		delete(backfillRunning, watchID)
+		return nil, page, false
+	}
+	page = msgIDRange{start: ranges[0].start, end: min(ranges[0].start+backfillPageSize-1, ranges[0].end)}
+	return chat, page, true
+}
+
+func finishBackfillPage(ctx context.Context, watchID uint, page msgIDRange) error {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+	chat, err := database.GetWatchChatByID(ctx, watchID)
+	if err != nil {
+		return err
+	}
+	ranges, err := parseMsgIDRanges(chat.BackfillRanges)
+	if err != nil {
+		return err
+	}
+	if len(ranges) == 0 || ranges[0].start != page.start {
+		return nil
+	}
+	if page.end >= ranges[0].end {
+		ranges = ranges[1:]
+	} else {
+		ranges[0].start = page.end + 1
+	}
+	return database.UpdateWatchChatBackfillRanges(ctx, watchID, formatMsgIDRanges(ranges))
+}
+
+func stopWatchBackfill(ctx context.Context, watchID uint, dropRanges bool) {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+	delete(backfillRunning, watchID)
+	if dropRanges {
+		if err := database.UpdateWatchChatBackfillRanges(ctx, watchID, ""); err != nil {
+			log.FromContext(ctx).Errorf("Failed to clear backfill ranges of watch %d: %s", watchID, err)
+		}
+	}
+}
+
+// backfillPage saves the matching media of a page of messages in one batch task and waits for the task to finish.
+// It returns context.Canceled if the task was canceled.
+func backfillPage(ctx *ext.Context, chat *database.WatchChat, page msgIDRange) error {
+	logger := log.FromContext(ctx)
+	msgs, err := tgutil.GetMessagesRange(ctx, chat.ChatID, page.start, page.end)
+	if err != nil {
+		return err
+	}
+	msgs = slices.DeleteFunc(msgs, func(msg *tg.Message) bool { return msg == nil })
+	slices.SortFunc(msgs, func(a, b *tg.Message) int { return a.GetID() - b.GetID() })
+	target, err := newWatchTarget(ctx, chat)
+	if err != nil {
+		return err
+	}
+	files := make([]tfile.TGFileMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		media, ok := msg.GetMedia()
+		if !ok || !mediautil.IsSupported(media) {
+			continue
+		}
+		file, err := tfile.FromMediaMessage(media, ctx.Raw, msg, tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)), tgutil.WithFileRefresher(ctx, msg))
+		if err != nil {
+			logger.Warnf("Failed to get file from message %d: %s", msg.GetID(), err)
+			continue
+		}
+		matched, err := ruleutil.MatchWatch(ctx, chat, file)
+		if err != nil {
+			return err
+		}
+		if matched {
+			files = append(files, target.prepareFile(ctx, file))
+		}
+	}
+	items := target.plan(ctx, files)
+	if len(items) == 0 {
+		return nil
+	}
+
+	elems := make([]batchtfile.TaskElement, 0, len(items))
+	lowPriority := true
+	for _, item := range items {
+		elem, err := batchtfile.NewTaskElement(item.stor, item.path, item.file)
+		if err != nil {
+			return err
+		}
+		elem.Overwrite = item.actions.ConflictStrategy == tcbdata.ConflictStrategyOverwrite
+		lowPriority = lowPriority && item.actions.LowPriority
+		elems = append(elems, *elem)
+	}
+	done := make(chan error, 1)
+	taskCtx := taskevent.WithSink(core.WithOwner(tgutil.ExtWithContext(ctx.Context, ctx), target.user.ChatID), taskevent.SinkFunc(func(e taskevent.Event) {
+		if e.Phase != taskevent.PhaseDone {
+			return
+		}
+		select {
+		case done <- e.Err:
+		default:
+		}
+	}))
+	taskID := xid.New().String()
+	task := batchtfile.NewBatchTGFileTask(taskID, taskCtx, elems, nil, true)
+	if lowPriority {
+		err = core.AddLowPriorityTask(taskCtx, task)
+	} else {
+		err = core.AddTask(taskCtx, task)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Infof("Added backfill task %s for messages %s of chat %d with %d files", taskID, page, chat.ChatID, len(elems))
+	err = waitWatchTask(ctx, taskID, done)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		// failed files are not retried, otherwise one bad file would block the whole backfill
+		logger.Warnf("Backfill task %s finished with errors: %s", taskID, err)
+		return nil
+	}
+	return err
+}
+
+// waitWatchTask waits for a task to finish. Tasks canceled before they start never emit events, so the queue is checked as well.
+func waitWatchTask(ctx context.Context, taskID string, done <-chan error) error {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if taskInQueue(ctx, taskID) {
+				continue
+			}
+			select {
+			case err := <-done:
+				return err
+			default:
+				return context.Canceled
+			}
+		}
+	}
+}
+
+func taskInQueue(ctx context.Context, taskID string) bool {
+	for _, info := range slices.Concat(core.GetQueuedTasks(ctx), core.GetRunningTasks(ctx)) {
+		if info.ID == taskID {
+			return true
+		}
+	}
+	return false
+}
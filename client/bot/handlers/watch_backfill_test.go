@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMsgIDRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []msgIDRange
+		output   string
+	}{
+		{
+			name:     "Empty",
+			input:    "",
+			expected: nil,
+			output:   "",
+		},
+		{
+			name:     "Single range",
+			input:    "1-500",
+			expected: []msgIDRange{{1, 500}},
+			output:   "1-500",
+		},
+		{
+			name:     "Multiple ranges keep their order",
+			input:    "900-950,1-500",
+			expected: []msgIDRange{{900, 950}, {1, 500}},
+			output:   "900-950,1-500",
+		},
+		{
+			name:     "Reversed range and empty parts",
+			input:    "500-1,,",
+			expected: []msgIDRange{{1, 500}},
+			output:   "1-500",
+		},
+		{
+			name:     "Single message",
+			input:    "42-42",
+			expected: []msgIDRange{{42, 42}},
+			output:   "42-42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges, err := parseMsgIDRanges(tt.input)
+			if err != nil {
+				t.Fatalf("parseMsgIDRanges(%q) error: %v", tt.input, err)
+			}
+			if !slices.Equal(ranges, tt.expected) {
+				t.Errorf("parseMsgIDRanges(%q) = %v, want %v", tt.input, ranges, tt.expected)
+			}
+			if got := formatMsgIDRanges(ranges); got != tt.output {
+				t.Errorf("formatMsgIDRanges(%v) = %q, want %q", ranges, got, tt.output)
+			}
+		})
+	}
+}
+
+func TestParseMsgIDRangesInvalid(t *testing.T) {
+	for _, input := range []string{"abc", "1-", "1-2-3"} {
+		if _, err := parseMsgIDRanges(input); err == nil {
+			t.Errorf("parseMsgIDRanges(%q) expected error", input)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"slices"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// 监听的用户和频道的故事会过期, 无法像消息一样回溯, 因此定期检查并保存新的故事.
+// 已保存的最新故事 ID 记录在 WatchChat.LastStoryID 中.
+
+// runStoryWatch checks the watched chats for new stories every interval until the userbot stops.
+func runStoryWatch(interval time.Duration) {
+	uctx := userclient.GetCtx()
+	if uctx == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		checkWatchedStories(uctx)
+		select {
+		case <-uctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkWatchedStories saves the active stories of the watched chats that are newer than the last saved story of each watch.
+func checkWatchedStories(uctx *ext.Context) {
+	logger := log.FromContext(uctx)
+	chats, err := database.GetAllWatchChats(uctx)
+	if err != nil {
+		logger.Errorf("Failed to get watch chats: %s", err)
+		return
+	}
+	watches := make(map[int64][]*database.WatchChat)
+	for _, chat := range chats {
+		watches[chat.ChatID] = append(watches[chat.ChatID], chat)
+	}
+	for chatID, chats := range watches {
+		stories, peer, err := tgutil.GetActiveStories(uctx, chatID)
+		if err != nil {
+			// 群组没有故事
+			logger.Debugf("Failed to get stories of chat %d: %s", chatID, err)
+			continue
+		}
+		slices.SortFunc(stories, func(a, b *tg.StoryItem) int {
+			return a.ID - b.ID
+		})
+		for _, story := range stories {
+			saveWatchedStory(uctx, chats, chatID, peer, story)
+		}
+	}
+}
+
+func saveWatchedStory(uctx *ext.Context, chats []*database.WatchChat, chatID int64, peer tg.PeerClass, story *tg.StoryItem) {
+	logger := log.FromContext(uctx)
+	var file tfile.TGFileMessage
+	for _, chat := range chats {
+		if story.ID <= chat.LastStoryID {
+			continue
+		}
+		chat.LastStoryID = story.ID
+		if err := database.UpdateWatchChatLastStoryID(uctx, chat.ID, story.ID); err != nil {
+			logger.Warnf("Failed to update last story ID of watch %d: %v", chat.ID, err)
+		}
+		if file == nil {
+			msg := tfile.StoryMessage(peer, story)
+			var err error
+			file, err = tfile.FromStory(story, peer, uctx.Raw,
+				tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)),
+				tgutil.WithStoryRefresher(uctx, chatID, story.ID),
+			)
+			if err != nil {
+				logger.Warnf("Failed to create file from story %d of chat %d: %s", story.ID, chatID, err)
+				return
+			}
+		}
+		logger.Debug("Received story of watched chat", "chat_id", chatID, "story_id", story.ID)
+		saveWatchedFile(uctx, chat, file)
+	}
+}
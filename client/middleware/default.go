@@ -18,6 +18,17 @@ func NewDefaultMiddlewares(ctx context.Context, timeout time.Duration) []telegra
 		recovery.New(ctx, func() backoff.BackOff { return newBackoff(timeout) }),
 		retry.New(config.C().Telegram.RpcRetry),
 		floodwait.NewSimpleWaiter(),
+		floodWaitCounter{},
+	}
+}
+
+// NewPoolMiddlewares returns the middlewares of the download pool accounts,
+// which do not wait out flood waits as the pool switches to another account instead.
+func NewPoolMiddlewares(ctx context.Context, timeout time.Duration) []telegram.Middleware {
+	return []telegram.Middleware{
+		recovery.New(ctx, func() backoff.BackOff { return newBackoff(timeout) }),
+		retry.New(config.C().Telegram.RpcRetry),
+		floodWaitCounter{},
 	}
 }
 
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+func TestPoolMiddlewaresKeepFloodWait(t *testing.T) {
+	calls := 0
+	var invoker tg.Invoker = telegram.InvokeFunc(func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		calls++
+		return tgerr.New(420, "FLOOD_WAIT_30")
+	})
+	mws := NewPoolMiddlewares(context.Background(), time.Second)
+	for i := len(mws) - 1; i >= 0; i-- {
+		invoker = mws[i].Handle(invoker)
+	}
+
+	start := time.Now()
+	_, err := tg.NewClient(invoker).UploadGetFile(context.Background(), &tg.UploadGetFileRequest{})
+	d, ok := tgerr.AsFloodWait(err)
+	if !ok || d != 30*time.Second {
+		t.Fatalf("got error %v, want FLOOD_WAIT of 30s", err)
+	}
+	if calls != 1 {
+		t.Errorf("request was sent %d times, want once", calls)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("pool middlewares waited out the flood wait")
+	}
+}
@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"context"
 	"time"
 
 	"github.com/gotd/contrib/middleware/floodwait"
 	"github.com/gotd/contrib/middleware/ratelimit"
+	"github.com/gotd/td/bin"
 	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/krau/SaveAny-Bot/common/metrics"
 	"golang.org/x/time/rate"
 )
 
@@ -14,6 +19,22 @@ func NewFloodWaitMiddlewares(maxRetries uint) []telegram.Middleware {
 	ratelimiter := ratelimit.New(rate.Every(time.Millisecond*100), 5)
 	return []telegram.Middleware{
 		waiter,
+		floodWaitCounter{},
 		ratelimiter,
 	}
 }
+
+// floodWaitCounter records FLOOD_WAIT errors. It must sit below the flood wait
+// waiter so that it sees every error before the waiter retries the call.
+type floodWaitCounter struct{}
+
+func (floodWaitCounter) Handle(next tg.Invoker) telegram.InvokeFunc {
+	return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		err := next.Invoke(ctx, input, output)
+		if d, ok := tgerr.AsFloodWait(err); ok {
+			metrics.FloodWaits.Inc()
+			metrics.FloodWaitSeconds.Add(d.Seconds())
+		}
+		return err
+	}
+}
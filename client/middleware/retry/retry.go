@@ -49,6 +49,10 @@ func (r retry) Handle(next tg.Invoker) telegram.InvokeFunc {
 
 // New returns middleware that retries request if it fails with one of provided errors.
 func New(max int, errors ...string) telegram.Middleware {
+	// always send the request once, so errors such as FLOOD_WAIT reach the caller
+	if max < 1 {
+		max = 1
+	}
 	return retry{
 		max:    max,
 		errors: append(errors, internalErrors...), // #373
@@ -0,0 +1,97 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/celestix/gotgproto"
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/sessionMaker"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/client/middleware"
+	userclient "github.com/krau/SaveAny-Bot/client/user"
+	"github.com/krau/SaveAny-Bot/common/tdler"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// Login logs in the accounts of the download pool and adds them to the downloader.
+func Login(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+	for _, account := range config.C().Telegram.Pool {
+		logger.Infof("Logging in pool account %s", account.Name)
+		client, err := login(ctx, account.Token, account.Session)
+		if err != nil {
+			return fmt.Errorf("failed to log in pool account %s: %w", account.Name, err)
+		}
+		ectx := client.CreateContext()
+		if !account.IsBot() {
+			// 没有接收更新, 需要同步对话才能通过 ID 访问账号加入的频道
+			go func() {
+				if count, err := tgutil.SyncDialogPeers(ctx, ectx); err != nil {
+					logger.Warnf("Failed to sync peers of pool account %s: %s", account.Name, err)
+				} else {
+					logger.Debugf("Synced %d peers of pool account %s", count, account.Name)
+				}
+			}()
+		}
+		tdler.AddAccount(tdler.NewAccount(account.Name, ectx.Raw, locator(ectx)))
+		logger.Infof("Pool account %s logged in: %s", account.Name, client.Self.FirstName)
+	}
+	return nil
+}
+
+func login(ctx context.Context, token, session string) (*gotgproto.Client, error) {
+	resolver, err := tgutil.NewConfigProxyResolver()
+	if err != nil {
+		return nil, err
+	}
+	clientType := gotgproto.ClientTypePhone("")
+	if token != "" {
+		clientType = gotgproto.ClientTypeBot(token)
+	}
+	return gotgproto.NewClient(
+		config.C().Telegram.AppID,
+		config.C().Telegram.AppHash,
+		clientType,
+		&gotgproto.ClientOpts{
+			Session:          sessionMaker.SqlSession(database.GetDialect(session)),
+			AuthConversator:  userclient.NewTerminalAuthConversator(),
+			Context:          ctx,
+			DisableCopyright: true,
+			Resolver:         resolver,
+			MaxRetries:       config.C().Telegram.RpcRetry,
+			NoUpdates:        true,
+			Middlewares:      middleware.NewPoolMiddlewares(ctx, 5*time.Minute),
+		},
+	)
+}
+
+// locator gets the file of a message by getting the message with the account.
+// Only channel messages are located, messages of private chats and basic groups have IDs of their own in every account.
+func locator(ectx *ext.Context) tdler.Locator {
+	return func(ctx context.Context, msg *tg.Message) (tg.InputFileLocationClass, error) {
+		peer, ok := msg.GetPeerID().(*tg.PeerChannel)
+		if !ok {
+			return nil, errors.New("not a channel message")
+		}
+		fresh, err := tgutil.GetMessageByID(ectx, peer.ChannelID, msg.GetID())
+		if err != nil {
+			return nil, err
+		}
+		media, ok := fresh.GetMedia()
+		if !ok {
+			return nil, errors.New("message has no media")
+		}
+		file, err := tfile.FromMedia(media, ectx.Raw)
+		if err != nil {
+			return nil, err
+		}
+		return file.Location(), nil
+	}
+}
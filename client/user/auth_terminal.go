@@ -12,6 +12,11 @@ import (
 
 type terminalAuthConversator struct{}
 
+// NewTerminalAuthConversator asks for the login information of a user account in the terminal.
+func NewTerminalAuthConversator() gotgproto.AuthConversator {
+	return &terminalAuthConversator{}
+}
+
 func readLine(prompt string) (string, error) {
 	fmt.Print(prompt)
 	reader := bufio.NewReader(os.Stdin)
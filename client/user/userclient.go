@@ -14,6 +14,7 @@ import (
 	"github.com/charmbracelet/log"
 	"github.com/gotd/td/tg"
 	"github.com/krau/SaveAny-Bot/client/middleware"
+	"github.com/krau/SaveAny-Bot/common/tdler"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/database"
@@ -93,6 +94,7 @@ func Login(ctx context.Context) (*gotgproto.Client, error) {
 			return nil, r.err
 		}
 		uc = r.client
+		tdler.AddPrimaryAccount(tdler.NewAccount("userbot", uc.API(), nil))
 		uc.Dispatcher.AddHandler(handlers.NewMessage(filters.Message.Media, func(ctx *ext.Context, u *ext.Update) error {
 			switch u.UpdateClass.(type) {
 			case *tg.UpdateEditChannelMessage, *tg.UpdateEditMessage, *tg.UpdateDeleteChannelMessages, *tg.UpdateDeleteMessages:
@@ -100,10 +102,14 @@ func Login(ctx context.Context) (*gotgproto.Client, error) {
 			}
 			chatId := u.EffectiveChat().GetID()
 			watchChats, err := database.GetWatchChatsByChatID(ctx, chatId)
-			if err != nil || len(watchChats) == 0 {
-				return dispatcher.EndGroups
+			if err == nil && len(watchChats) > 0 {
+				return dispatcher.ContinueGroups
+			}
+			// 订阅对所有聊天生效
+			if subscribed, err := database.HasSubscriptions(ctx); err == nil && subscribed {
+				return dispatcher.ContinueGroups
 			}
-			return dispatcher.ContinueGroups
+			return dispatcher.EndGroups
 		}))
 		uc.Dispatcher.AddHandler(handlers.NewMessage(filters.Message.Media, handleMediaMessage))
 		log.FromContext(ctx).Infof("User client logged in successfully: %s", uc.Self.FirstName+" "+uc.Self.LastName)
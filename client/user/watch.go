@@ -76,6 +76,11 @@ func handleMediaMessage(ctx *ext.Context, update *ext.Update) error {
 		switch media.(type) {
 		case *tg.MessageMediaDocument, *tg.MessageMediaPhoto:
 			return true
+		case *tg.MessageMediaWebPage:
+			// 通过 inline bot 发送的图片或文件
+			_, viaBot := message.GetViaBotID()
+			_, ok := tfile.WebPageMedia(media)
+			return viaBot && ok
 		default:
 			return false
 		}
@@ -85,7 +90,7 @@ func handleMediaMessage(ctx *ext.Context, update *ext.Update) error {
 	}
 	file, err := tfile.FromMediaMessage(media, ctx.Raw, message.Message, tfile.WithNameIfEmpty(
 		tgutil.GenFileNameFromMessage(*message.Message),
-	))
+	), tgutil.WithFileRefresher(ctx, message.Message))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,178 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/common/cache"
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/database"
+	"github.com/krau/SaveAny-Bot/pkg/userdata"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export a user's rules, dirs, watched chats and settings",
+	Long: `Export a user's rules, dirs, watched chats and settings to a YAML or JSON file,
+the same format as the /export bot command.
+
+Example:
+  saveany-bot export -u 123456789 -o backup.yaml`,
+	RunE: runExport,
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import a user's rules, dirs, watched chats and settings",
+	Long: `Import a file created by the export subcommand or the /export bot command.
+All records are validated before anything is written.
+
+Example:
+  saveany-bot import -u 123456789 -i backup.yaml --mode replace`,
+	RunE: runImport,
+}
+
+func Register(root *cobra.Command) {
+	exportCmd.Flags().Int64P("user", "u", 0, "user ID, can be omitted when only one user is configured")
+	exportCmd.Flags().StringP("output", "o", "", "output file, default is stdout")
+	exportCmd.Flags().StringP("format", "f", "", "output format, yaml or json, default is detected from the output file extension")
+	root.AddCommand(exportCmd)
+
+	importCmd.Flags().Int64P("user", "u", 0, "user ID, can be omitted when only one user is configured")
+	importCmd.Flags().StringP("input", "i", "", "file to import")
+	importCmd.MarkFlagRequired("input")
+	importCmd.Flags().String("mode", string(userdata.ModeMerge), "import mode, merge or replace")
+	importCmd.Flags().Bool("dry-run", false, "only validate the file")
+	root.AddCommand(importCmd)
+}
+
+func runExport(cmd *cobra.Command, _ []string) error {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	formatArg, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	if formatArg == "" && output != "" {
+		formatArg = filepath.Ext(output)
+		if len(formatArg) > 0 {
+			formatArg = formatArg[1:]
+		}
+	}
+	format, err := userdata.ParseFormat(formatArg)
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	if err := initEnv(ctx, cmd); err != nil {
+		return err
+	}
+	userID, err := getUserID(cmd)
+	if err != nil {
+		return err
+	}
+
+	data, err := database.ExportUserData(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to export user %d: %w", userID, err)
+	}
+	content, err := userdata.Marshal(data, format)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	if err := os.WriteFile(output, content, 0o600); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Infof("Exported %d rules, %d dirs and %d watched chats to %s", len(data.Rules), len(data.Dirs), len(data.WatchChats), output)
+	return nil
+}
+
+func runImport(cmd *cobra.Command, _ []string) error {
+	input, err := cmd.Flags().GetString("input")
+	if err != nil {
+		return err
+	}
+	modeArg, err := cmd.Flags().GetString("mode")
+	if err != nil {
+		return err
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	mode, err := userdata.ParseMode(modeArg)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	data, err := userdata.Unmarshal(content)
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	if err := initEnv(ctx, cmd); err != nil {
+		return err
+	}
+	userID, err := getUserID(cmd)
+	if err != nil {
+		return err
+	}
+
+	if err := data.Validate(func(name string) bool { return config.C().HasStorage(userID, name) }); err != nil {
+		return fmt.Errorf("invalid records, nothing was imported:\n%w", err)
+	}
+	logger := log.FromContext(ctx)
+	if dryRun {
+		logger.Infof("%s is valid: %d rules, %d dirs, %d watched chats", input, len(data.Rules), len(data.Dirs), len(data.WatchChats))
+		return nil
+	}
+	data.Normalize()
+	stats, err := database.ImportUserData(ctx, userID, data, mode)
+	if err != nil {
+		return fmt.Errorf("failed to import: %w", err)
+	}
+	logger.Infof("Imported %d rules, %d dirs and %d watched chats for user %d (%s)", stats.Rules, stats.Dirs, stats.WatchChats, userID, mode)
+	return nil
+}
+
+func initEnv(ctx context.Context, cmd *cobra.Command) error {
+	configFile := config.GetConfigFile(cmd)
+	if err := config.Init(ctx, configFile); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	i18n.Init(config.C().Lang)
+	cache.Init()
+	database.Init(ctx)
+	return nil
+}
+
+// getUserID returns the --user flag, or the only configured user.
+func getUserID(cmd *cobra.Command) (int64, error) {
+	userID, err := cmd.Flags().GetInt64("user")
+	if err != nil {
+		return 0, err
+	}
+	if userID != 0 {
+		return userID, nil
+	}
+	users := config.C().GetUsersID()
+	if len(users) != 1 {
+		return 0, errors.New("multiple users are configured, please specify one with --user")
+	}
+	return users[0], nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/krau/SaveAny-Bot/cmd/backup"
 	"github.com/krau/SaveAny-Bot/cmd/upload"
 	"github.com/krau/SaveAny-Bot/cmd/watch"
 	"github.com/krau/SaveAny-Bot/config"
@@ -20,6 +21,7 @@ func init() {
 	config.RegisterFlags(rootCmd)
 	upload.Register(rootCmd)
 	watch.Register(rootCmd)
+	backup.Register(rootCmd)
 }
 
 func Execute(ctx context.Context) {
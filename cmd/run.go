@@ -12,15 +12,20 @@ import (
 	"github.com/charmbracelet/log"
 	"github.com/krau/SaveAny-Bot/api"
 	"github.com/krau/SaveAny-Bot/client/bot"
+	"github.com/krau/SaveAny-Bot/client/pool"
 	userclient "github.com/krau/SaveAny-Bot/client/user"
 	"github.com/krau/SaveAny-Bot/common/cache"
 	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/metrics"
+	"github.com/krau/SaveAny-Bot/common/tdler"
 	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/core"
 	"github.com/krau/SaveAny-Bot/database"
 	"github.com/krau/SaveAny-Bot/parsers"
+	"github.com/krau/SaveAny-Bot/pkg/botapi"
 	"github.com/krau/SaveAny-Bot/storage"
+	"github.com/krau/SaveAny-Bot/storage/telegram"
 	"github.com/spf13/cobra"
 )
 
@@ -70,6 +75,7 @@ func initAll(ctx context.Context) (<-chan struct{}, error) {
 	i18n.Init(config.C().Lang)
 	logger.Info("Initializing...")
 	database.Init(ctx)
+	telegram.SetIndex(database.TelegramIndex{})
 	storage.LoadStorages(ctx)
 	if config.C().Parser.PluginEnable {
 		for _, dir := range config.C().Parser.PluginDirs {
@@ -86,9 +92,21 @@ func initAll(ctx context.Context) (<-chan struct{}, error) {
 			logger.Fatal("User login failed", "error", err)
 		}
 	}
+	if err := pool.Login(ctx); err != nil {
+		logger.Fatal("Pool login failed", "error", err)
+	}
+	if cfg := config.C().Telegram.BotAPI; cfg.Enable {
+		client, err := botapi.NewClient(cfg.URL, config.C().Telegram.Token, cfg.Dir, nil)
+		if err != nil {
+			logger.Fatal("Invalid Bot API server", "error", err)
+		}
+		tdler.UseBotAPI(client)
+		logger.Info("Downloading files through the Bot API server", "url", cfg.URL)
+	}
 	if err := api.Start(ctx); err != nil {
 		logger.Error("Failed to start API server", "error", err)
 	}
+	metrics.Start(ctx)
 	return bot.Init(ctx), nil
 }
 
@@ -113,7 +131,8 @@ func cleanCache() {
 			return
 		}
 		log.Info("Cleaning cache directory", "path", cachePath)
-		if err := fsutil.RemoveAllInDir(cachePath); err != nil {
+		// 保留未完成的可续传下载
+		if err := fsutil.RemoveAllInDirExcept(cachePath, tdler.KeepOnCleanup); err != nil {
 			log.Error("Failed to clean cache directory", "error", err)
 		}
 	}
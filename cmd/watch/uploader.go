@@ -213,7 +213,7 @@ func (u *Uploader) upload(ctx context.Context, job uploadJob) error {
 	}
 
 	u.logger.Infof("uploading %s -> %s (%d bytes)", job.localPath, storagePath, info.Size())
-	if err := u.stor.Save(uploadCtx, file, storagePath); err != nil {
+	if err := storage.Save(uploadCtx, u.stor, file, storagePath); err != nil {
 		return fmt.Errorf("failed to save to storage: %w", err)
 	}
 	u.logger.Infof("uploaded %s", storagePath)
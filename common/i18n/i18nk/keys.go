@@ -4,6 +4,11 @@ package i18nk
 type Key string
 
 const (
+	BotMsgArchiveErrorGetArchiveFailed                    Key = "bot.msg.archive.error_get_archive_failed"
+	BotMsgArchiveErrorUserbotRequired                     Key = "bot.msg.archive.error_userbot_required"
+	BotMsgArchiveHelp                                     Key = "bot.msg.archive.help"
+	BotMsgArchiveInfoResuming                             Key = "bot.msg.archive.info_resuming"
+	BotMsgArchiveInfoSelectStorage                        Key = "bot.msg.archive.info_select_storage"
 	BotMsgAria2ErrorAddingAria2Download                   Key = "bot.msg.aria2.error_adding_aria2_download"
 	BotMsgAria2ErrorAria2ClientInitFailed                 Key = "bot.msg.aria2.error_aria2_client_init_failed"
 	BotMsgAria2ErrorAria2NotEnabled                       Key = "bot.msg.aria2.error_aria2_not_enabled"
@@ -14,14 +19,18 @@ const (
 	BotMsgCancelInfoCancelRequested                       Key = "bot.msg.cancel.info_cancel_requested"
 	BotMsgCancelInfoCancellingTask                        Key = "bot.msg.cancel.info_cancelling_task"
 	BotMsgCancelUsage                                     Key = "bot.msg.cancel.usage"
+	BotMsgCmdArchive                                      Key = "bot.msg.cmd.archive"
 	BotMsgCmdAria2dl                                      Key = "bot.msg.cmd.aria2dl"
 	BotMsgCmdCancel                                       Key = "bot.msg.cmd.cancel"
 	BotMsgCmdConfig                                       Key = "bot.msg.cmd.config"
+	BotMsgCmdDashboard                                    Key = "bot.msg.cmd.dashboard"
 	BotMsgCmdDir                                          Key = "bot.msg.cmd.dir"
 	BotMsgCmdDl                                           Key = "bot.msg.cmd.dl"
+	BotMsgCmdExport                                       Key = "bot.msg.cmd.export"
 	BotMsgCmdFnametmpl                                    Key = "bot.msg.cmd.fnametmpl"
 	BotMsgCmdHelp                                         Key = "bot.msg.cmd.help"
 	BotMsgCmdImport                                       Key = "bot.msg.cmd.import"
+	BotMsgCmdLssub                                        Key = "bot.msg.cmd.lssub"
 	BotMsgCmdLswatch                                      Key = "bot.msg.cmd.lswatch"
 	BotMsgCmdParser                                       Key = "bot.msg.cmd.parser"
 	BotMsgCmdRule                                         Key = "bot.msg.cmd.rule"
@@ -29,9 +38,11 @@ const (
 	BotMsgCmdSilent                                       Key = "bot.msg.cmd.silent"
 	BotMsgCmdStart                                        Key = "bot.msg.cmd.start"
 	BotMsgCmdStorage                                      Key = "bot.msg.cmd.storage"
+	BotMsgCmdSubscribe                                    Key = "bot.msg.cmd.subscribe"
 	BotMsgCmdSyncpeers                                    Key = "bot.msg.cmd.syncpeers"
 	BotMsgCmdTask                                         Key = "bot.msg.cmd.task"
 	BotMsgCmdTransfer                                     Key = "bot.msg.cmd.transfer"
+	BotMsgCmdUnsubscribe                                  Key = "bot.msg.cmd.unsubscribe"
 	BotMsgCmdUnwatch                                      Key = "bot.msg.cmd.unwatch"
 	BotMsgCmdUpdate                                       Key = "bot.msg.cmd.update"
 	BotMsgCmdWatch                                        Key = "bot.msg.cmd.watch"
@@ -78,6 +89,7 @@ const (
 	BotMsgCommonInfoFoundFilesSelectStorage               Key = "bot.msg.common.info_found_files_select_storage"
 	BotMsgCommonInfoSilentModeOff                         Key = "bot.msg.common.info_silent_mode_off"
 	BotMsgCommonInfoSilentModeOn                          Key = "bot.msg.common.info_silent_mode_on"
+	BotMsgCommonInfoSkippedByRule                         Key = "bot.msg.common.info_skipped_by_rule"
 	BotMsgCommonInfoTaskAdded                             Key = "bot.msg.common.info_task_added"
 	BotMsgCommonPromptConflictMoreFiles                   Key = "bot.msg.common.prompt_conflict_more_files"
 	BotMsgCommonPromptSelectConflictStrategy              Key = "bot.msg.common.prompt_select_conflict_strategy"
@@ -100,6 +112,8 @@ const (
 	BotMsgConfigPromptSelectConflictStrategy              Key = "bot.msg.config.prompt_select_conflict_strategy"
 	BotMsgConfigPromptSelectFilenameStrategy              Key = "bot.msg.config.prompt_select_filename_strategy"
 	BotMsgConfigPromptSelectOption                        Key = "bot.msg.config.prompt_select_option"
+	BotMsgDashboardErrorDisabled                          Key = "bot.msg.dashboard.error_disabled"
+	BotMsgDashboardInfoLoginLink                          Key = "bot.msg.dashboard.info_login_link"
 	BotMsgDirButtonDefault                                Key = "bot.msg.dir.button_default"
 	BotMsgDirErrorCreateDirFailed                         Key = "bot.msg.dir.error_create_dir_failed"
 	BotMsgDirErrorDeleteDirFailed                         Key = "bot.msg.dir.error_delete_dir_failed"
@@ -146,6 +160,9 @@ const (
 	BotMsgParserInfoInstallPluginSuccess                  Key = "bot.msg.parser.info_install_plugin_success"
 	BotMsgParserPluginNotEnabled                          Key = "bot.msg.parser.plugin_not_enabled"
 	BotMsgParserPromptReplyWithParserFile                 Key = "bot.msg.parser.prompt_reply_with_parser_file"
+	BotMsgProgressArchiveDone                             Key = "bot.msg.progress.archive_done"
+	BotMsgProgressArchiveProgress                         Key = "bot.msg.progress.archive_progress"
+	BotMsgProgressArchiveStart                            Key = "bot.msg.progress.archive_start"
 	BotMsgProgressAria2Done                               Key = "bot.msg.progress.aria2_done"
 	BotMsgProgressAria2Downloading                        Key = "bot.msg.progress.aria2_downloading"
 	BotMsgProgressAria2Start                              Key = "bot.msg.progress.aria2_start"
@@ -198,10 +215,13 @@ const (
 	BotMsgRuleErrorCreateRuleFailed                       Key = "bot.msg.rule.error_create_rule_failed"
 	BotMsgRuleErrorDeleteRuleFailed                       Key = "bot.msg.rule.error_delete_rule_failed"
 	BotMsgRuleErrorGetUserRulesFailed                     Key = "bot.msg.rule.error_get_user_rules_failed"
+	BotMsgRuleErrorInvalidRuleData                        Key = "bot.msg.rule.error_invalid_rule_data"
 	BotMsgRuleErrorInvalidRuleId                          Key = "bot.msg.rule.error_invalid_rule_id"
+	BotMsgRuleErrorInvalidRuleOption                      Key = "bot.msg.rule.error_invalid_rule_option"
 	BotMsgRuleErrorInvalidRuleType                        Key = "bot.msg.rule.error_invalid_rule_type"
 	BotMsgRuleErrorStorageNotFound                        Key = "bot.msg.rule.error_storage_not_found"
 	BotMsgRuleErrorUpdateUserFailed                       Key = "bot.msg.rule.error_update_user_failed"
+	BotMsgRuleErrorWatchChatNotFound                      Key = "bot.msg.rule.error_watch_chat_not_found"
 	BotMsgRuleHelpAddSuffix                               Key = "bot.msg.rule.help_add_suffix"
 	BotMsgRuleHelpAvailableOps                            Key = "bot.msg.rule.help_available_ops"
 	BotMsgRuleHelpCurrentModeDisabled                     Key = "bot.msg.rule.help_current_mode_disabled"
@@ -210,6 +230,7 @@ const (
 	BotMsgRuleHelpExistingRulesPrefix                     Key = "bot.msg.rule.help_existing_rules_prefix"
 	BotMsgRuleHelpPresetSuffix                            Key = "bot.msg.rule.help_preset_suffix"
 	BotMsgRuleHelpSwitchSuffix                            Key = "bot.msg.rule.help_switch_suffix"
+	BotMsgRuleHelpTestSuffix                              Key = "bot.msg.rule.help_test_suffix"
 	BotMsgRuleHelpUsage                                   Key = "bot.msg.rule.help_usage"
 	BotMsgRuleInfoCreateRuleSuccess                       Key = "bot.msg.rule.info_create_rule_success"
 	BotMsgRuleInfoDeleteRuleSuccess                       Key = "bot.msg.rule.info_delete_rule_success"
@@ -218,10 +239,38 @@ const (
 	BotMsgRuleInfoRuleModeEnabled                         Key = "bot.msg.rule.info_rule_mode_enabled"
 	BotMsgRulePromptProvideRuleId                         Key = "bot.msg.rule.prompt_provide_rule_id"
 	BotMsgRulePromptProvideStorageName                    Key = "bot.msg.rule.prompt_provide_storage_name"
+	BotMsgRulePromptTestInput                             Key = "bot.msg.rule.prompt_test_input"
+	BotMsgRuleTestActions                                 Key = "bot.msg.rule.test_actions"
+	BotMsgRuleTestError                                   Key = "bot.msg.rule.test_error"
+	BotMsgRuleTestFile                                    Key = "bot.msg.rule.test_file"
+	BotMsgRuleTestMatched                                 Key = "bot.msg.rule.test_matched"
+	BotMsgRuleTestModeDisabled                            Key = "bot.msg.rule.test_mode_disabled"
+	BotMsgRuleTestNoRulesEvaluated                        Key = "bot.msg.rule.test_no_rules_evaluated"
+	BotMsgRuleTestNotMatched                              Key = "bot.msg.rule.test_not_matched"
+	BotMsgRuleTestResult                                  Key = "bot.msg.rule.test_result"
+	BotMsgRuleTestResultNoMatch                           Key = "bot.msg.rule.test_result_no_match"
 	BotMsgSaveErrorInvalidIdOrUsername                    Key = "bot.msg.save.error_invalid_id_or_username"
+	BotMsgSaveErrorInvalidRange                           Key = "bot.msg.save.error_invalid_range"
+	BotMsgSaveInfoRangeCancelled                          Key = "bot.msg.save.info_range_cancelled"
+	BotMsgSaveInfoRangePreview                            Key = "bot.msg.save.info_range_preview"
+	BotMsgSaveRangeConfirmButtonText                      Key = "bot.msg.save.range_confirm_button_text"
 	BotMsgSaveHelpText                                    Key = "bot.msg.save_help_text"
 	BotMsgStorageInfoFilenamePrefix                       Key = "bot.msg.storage.info_filename_prefix"
 	BotMsgStorageInfoPromptSelectStorage                  Key = "bot.msg.storage.info_prompt_select_storage"
+	BotMsgSubscriptionErrorFilterRequired                 Key = "bot.msg.subscription.error_filter_required"
+	BotMsgSubscriptionErrorInvalidLimit                   Key = "bot.msg.subscription.error_invalid_limit"
+	BotMsgSubscriptionErrorNameRequired                   Key = "bot.msg.subscription.error_name_required"
+	BotMsgSubscriptionErrorSubscribeFailed                Key = "bot.msg.subscription.error_subscribe_failed"
+	BotMsgSubscriptionErrorUnsubscribeFailed              Key = "bot.msg.subscription.error_unsubscribe_failed"
+	BotMsgSubscriptionHelp                                Key = "bot.msg.subscription.help"
+	BotMsgSubscriptionInfoDigestHeader                    Key = "bot.msg.subscription.info_digest_header"
+	BotMsgSubscriptionInfoDigestLimited                   Key = "bot.msg.subscription.info_digest_limited"
+	BotMsgSubscriptionInfoDigestMore                      Key = "bot.msg.subscription.info_digest_more"
+	BotMsgSubscriptionInfoListEmpty                       Key = "bot.msg.subscription.info_list_empty"
+	BotMsgSubscriptionInfoListHeader                      Key = "bot.msg.subscription.info_list_header"
+	BotMsgSubscriptionInfoListLimit                       Key = "bot.msg.subscription.info_list_limit"
+	BotMsgSubscriptionInfoSubscribed                      Key = "bot.msg.subscription.info_subscribed"
+	BotMsgSubscriptionInfoUnsubscribed                    Key = "bot.msg.subscription.info_unsubscribed"
 	BotMsgSyncpeersDone                                   Key = "bot.msg.syncpeers.done"
 	BotMsgSyncpeersFailed                                 Key = "bot.msg.syncpeers.failed"
 	BotMsgSyncpeersStart                                  Key = "bot.msg.syncpeers.start"
@@ -278,14 +327,32 @@ const (
 	BotMsgUpdateInfoNewVersionPromptUpgrade               Key = "bot.msg.update.info_new_version_prompt_upgrade"
 	BotMsgUpdateInfoUpgradeSuccess                        Key = "bot.msg.update.info_upgrade_success"
 	BotMsgUpdateInfoUpgradingWithVersion                  Key = "bot.msg.update.info_upgrading_with_version"
-	BotMsgWatchErrorFilterFormatInvalid                   Key = "bot.msg.watch.error_filter_format_invalid"
-	BotMsgWatchErrorFilterTypeUnsupported                 Key = "bot.msg.watch.error_filter_type_unsupported"
+	BotMsgUserdataErrorDownloadFailed                     Key = "bot.msg.userdata.error_download_failed"
+	BotMsgUserdataErrorExportFailed                       Key = "bot.msg.userdata.error_export_failed"
+	BotMsgUserdataErrorFileTooLarge                       Key = "bot.msg.userdata.error_file_too_large"
+	BotMsgUserdataErrorImportFailed                       Key = "bot.msg.userdata.error_import_failed"
+	BotMsgUserdataErrorInvalidArgument                    Key = "bot.msg.userdata.error_invalid_argument"
+	BotMsgUserdataErrorInvalidFile                        Key = "bot.msg.userdata.error_invalid_file"
+	BotMsgUserdataErrorValidationFailed                   Key = "bot.msg.userdata.error_validation_failed"
+	BotMsgUserdataImportHelp                              Key = "bot.msg.userdata.import_help"
+	BotMsgUserdataInfoExportCaption                       Key = "bot.msg.userdata.info_export_caption"
+	BotMsgUserdataInfoImportSuccess                       Key = "bot.msg.userdata.info_import_success"
+	BotMsgWatchErrorBackfillFailed                        Key = "bot.msg.watch.error_backfill_failed"
+	BotMsgWatchErrorFilterInvalid                         Key = "bot.msg.watch.error_filter_invalid"
+	BotMsgWatchErrorInvalidDate                           Key = "bot.msg.watch.error_invalid_date"
+	BotMsgWatchErrorInvalidOption                         Key = "bot.msg.watch.error_invalid_option"
+	BotMsgWatchErrorInvalidTemplate                       Key = "bot.msg.watch.error_invalid_template"
+	BotMsgWatchErrorStorageNotFound                       Key = "bot.msg.watch.error_storage_not_found"
 	BotMsgWatchErrorUnwatchChatFailed                     Key = "bot.msg.watch.error_unwatch_chat_failed"
 	BotMsgWatchErrorUnwatchNoChatProvided                 Key = "bot.msg.watch.error_unwatch_no_chat_provided"
 	BotMsgWatchErrorWatchChatFailed                       Key = "bot.msg.watch.error_watch_chat_failed"
 	BotMsgWatchInfoAlreadyWatchingChat                    Key = "bot.msg.watch.info_already_watching_chat"
+	BotMsgWatchInfoBackfillNothing                        Key = "bot.msg.watch.info_backfill_nothing"
+	BotMsgWatchInfoBackfillStarted                        Key = "bot.msg.watch.info_backfill_started"
 	BotMsgWatchInfoWatchChatStarted                       Key = "bot.msg.watch.info_watch_chat_started"
 	BotMsgWatchInfoWatchChatStopped                       Key = "bot.msg.watch.info_watch_chat_stopped"
+	BotMsgWatchInfoWatchChatUpdated                       Key = "bot.msg.watch.info_watch_chat_updated"
+	BotMsgWatchInfoWatchListBackfillPrefix                Key = "bot.msg.watch.info_watch_list_backfill_prefix"
 	BotMsgWatchInfoWatchListEmpty                         Key = "bot.msg.watch.info_watch_list_empty"
 	BotMsgWatchInfoWatchListFilterPrefix                  Key = "bot.msg.watch.info_watch_list_filter_prefix"
 	BotMsgWatchInfoWatchListHeader                        Key = "bot.msg.watch.info_watch_list_header"
@@ -0,0 +1,261 @@
+// Package metrics exposes runtime statistics in the Prometheus text format.
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "saveany"
+
+// Task results used as the status label of TasksTotal.
+const (
+	TaskStatusSucceeded = "succeeded"
+	TaskStatusFailed    = "failed"
+	TaskStatusCanceled  = "canceled"
+)
+
+var (
+	TasksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_total",
+		Help:      "Number of finished tasks by type and status.",
+	}, []string{"type", "status"})
+
+	WorkersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers_busy",
+		Help:      "Number of workers currently executing a task.",
+	})
+
+	Workers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers",
+		Help:      "Number of configured workers.",
+	})
+
+	StorageUploadedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_uploaded_bytes_total",
+		Help:      "Bytes written to each storage.",
+	}, []string{"storage"})
+
+	StorageDownloadedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_downloaded_bytes_total",
+		Help:      "Bytes read from each storage.",
+	}, []string{"storage"})
+
+	StorageSaveDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_save_duration_seconds",
+		Help:      "Time spent saving a file to a storage.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 15, 30, 60, 180, 600, 1800},
+	}, []string{"storage", "result"})
+
+	FloodWaits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_flood_waits_total",
+		Help:      "Number of FLOOD_WAIT errors returned by Telegram.",
+	})
+
+	FloodWaitSeconds = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_flood_wait_seconds_total",
+		Help:      "Total wait time requested by FLOOD_WAIT errors.",
+	})
+
+	WebhookFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_failures_total",
+		Help:      "Number of webhook deliveries that failed after all retries.",
+	})
+
+	APIRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_rate_limited_total",
+		Help:      "Number of API requests rejected by a rate limit.",
+	}, []string{"limit"})
+)
+
+var queueLength atomic.Pointer[func() int]
+
+var _ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "queue_length",
+	Help:      "Number of tasks queued or running.",
+}, func() float64 {
+	if f := queueLength.Load(); f != nil {
+		return float64((*f)())
+	}
+	return 0
+})
+
+// SetQueueLength exposes the task queue length. The queue lives in core,
+// which depends on this package, so it hands over a getter instead.
+func SetQueueLength(length func() int) {
+	queueLength.Store(&length)
+}
+
+// PoolAccount is the rate accounting of a download pool account.
+type PoolAccount struct {
+	Name       string
+	Inflight   int64
+	Requests   int64
+	Bytes      int64
+	FloodWaits int64
+	FloodUntil time.Time
+}
+
+var poolStats atomic.Pointer[func() []PoolAccount]
+
+// SetPoolStats exposes the accounts of the download pool, which lives in
+// tdler and is handed over as a getter like the queue length.
+func SetPoolStats(stats func() []PoolAccount) {
+	poolStats.Store(&stats)
+}
+
+var (
+	poolInflightDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "pool", "inflight"),
+		"Number of download requests in flight on a pool account.", []string{"account"}, nil)
+	poolRequestsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "pool", "requests_total"),
+		"Number of download requests sent by a pool account.", []string{"account"}, nil)
+	poolBytesDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "pool", "downloaded_bytes_total"),
+		"Bytes downloaded by a pool account.", []string{"account"}, nil)
+	poolFloodWaitsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "pool", "flood_waits_total"),
+		"Number of FLOOD_WAIT errors returned to a pool account.", []string{"account"}, nil)
+	poolFloodUntilDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "pool", "flood_wait_until_seconds"),
+		"Unix time the flood wait of a pool account ends, 0 if it is not waiting.", []string{"account"}, nil)
+)
+
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolInflightDesc
+	ch <- poolRequestsDesc
+	ch <- poolBytesDesc
+	ch <- poolFloodWaitsDesc
+	ch <- poolFloodUntilDesc
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	f := poolStats.Load()
+	if f == nil {
+		return
+	}
+	now := time.Now()
+	for _, account := range (*f)() {
+		var floodUntil float64
+		if account.FloodUntil.After(now) {
+			floodUntil = float64(account.FloodUntil.Unix())
+		}
+		ch <- prometheus.MustNewConstMetric(poolInflightDesc, prometheus.GaugeValue, float64(account.Inflight), account.Name)
+		ch <- prometheus.MustNewConstMetric(poolRequestsDesc, prometheus.CounterValue, float64(account.Requests), account.Name)
+		ch <- prometheus.MustNewConstMetric(poolBytesDesc, prometheus.CounterValue, float64(account.Bytes), account.Name)
+		ch <- prometheus.MustNewConstMetric(poolFloodWaitsDesc, prometheus.CounterValue, float64(account.FloodWaits), account.Name)
+		ch <- prometheus.MustNewConstMetric(poolFloodUntilDesc, prometheus.GaugeValue, floodUntil, account.Name)
+	}
+}
+
+func init() {
+	prometheus.MustRegister(poolCollector{})
+}
+
+// ObserveSave records the outcome of a storage save.
+func ObserveSave(storage string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	StorageSaveDuration.WithLabelValues(storage, result).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the collected metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Start serves metrics on a standalone listener when metrics.listen is set.
+func Start(ctx context.Context) {
+	cfg := config.C().Metrics
+	if !cfg.Enable {
+		return
+	}
+	logger := log.FromContext(ctx).With("module", "metrics")
+	if cfg.Listen == "" {
+		if !config.C().API.Enable {
+			logger.Warn("Metrics are enabled without a listen address, but the API server is disabled; /metrics will not be served")
+		}
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logger.Infof("Serving metrics on %s/metrics", cfg.Listen)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("Metrics server error: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+}
+
+// CountingReader counts the bytes read through it into counter. It keeps the
+// io.Seeker of the wrapped reader so storages that rewind still work.
+func CountingReader(r io.Reader, counter prometheus.Counter) io.Reader {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		return &countingReadSeeker{countingReader{r: r, counter: counter}, rs}
+	}
+	return &countingReader{r: r, counter: counter}
+}
+
+type countingReader struct {
+	r       io.Reader
+	counter prometheus.Counter
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.counter.Add(float64(n))
+	}
+	return n, err
+}
+
+type countingReadSeeker struct {
+	countingReader
+	seeker io.Seeker
+}
+
+func (c *countingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return c.seeker.Seek(offset, whence)
+}
+
+type countingReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// CountingReadCloser is CountingReader for readers that must be closed.
+func CountingReadCloser(rc io.ReadCloser, counter prometheus.Counter) io.ReadCloser {
+	return countingReadCloser{Reader: CountingReader(rc, counter), Closer: rc}
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCountingReader(t *testing.T) {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_bytes"})
+
+	r := CountingReader(strings.NewReader("hello world"), counter)
+	if _, ok := r.(io.Seeker); !ok {
+		t.Fatal("expected counting reader to keep io.Seeker")
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if got := testutil.ToFloat64(counter); got != 11 {
+		t.Errorf("counted %v bytes, want 11", got)
+	}
+
+	// Rewinding and reading again counts the bytes again.
+	if _, err := r.(io.Seeker).Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if got := testutil.ToFloat64(counter); got != 16 {
+		t.Errorf("counted %v bytes, want 16", got)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("abc"))
+		pw.Close()
+	}()
+	r = CountingReader(pr, counter)
+	if _, ok := r.(io.Seeker); ok {
+		t.Fatal("pipe reader must not become seekable")
+	}
+	io.ReadAll(r)
+	if got := testutil.ToFloat64(counter); got != 19 {
+		t.Errorf("counted %v bytes, want 19", got)
+	}
+}
+
+func TestQueueLength(t *testing.T) {
+	SetQueueLength(func() int { return 7 })
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() == namespace+"_queue_length" {
+			if got := f.GetMetric()[0].GetGauge().GetValue(); got != 7 {
+				t.Errorf("queue length = %v, want 7", got)
+			}
+			return
+		}
+	}
+	t.Fatal("queue length metric not registered")
+}
+
+func TestPoolStats(t *testing.T) {
+	until := time.Now().Add(time.Minute)
+	SetPoolStats(func() []PoolAccount {
+		return []PoolAccount{
+			{Name: "bot", Inflight: 2, Requests: 5, Bytes: 1024},
+			{Name: "helper", Requests: 1, FloodWaits: 1, FloodUntil: until},
+			{Name: "expired", FloodWaits: 1, FloodUntil: time.Now().Add(-time.Minute)},
+		}
+	})
+	defer SetPoolStats(func() []PoolAccount { return nil })
+
+	expected := fmt.Sprintf(`
+# HELP saveany_pool_inflight Number of download requests in flight on a pool account.
+# TYPE saveany_pool_inflight gauge
+saveany_pool_inflight{account="bot"} 2
+saveany_pool_inflight{account="expired"} 0
+saveany_pool_inflight{account="helper"} 0
+# HELP saveany_pool_flood_wait_until_seconds Unix time the flood wait of a pool account ends, 0 if it is not waiting.
+# TYPE saveany_pool_flood_wait_until_seconds gauge
+saveany_pool_flood_wait_until_seconds{account="bot"} 0
+saveany_pool_flood_wait_until_seconds{account="expired"} 0
+saveany_pool_flood_wait_until_seconds{account="helper"} %d
+`, until.Unix())
+	if err := testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(expected),
+		"saveany_pool_inflight", "saveany_pool_flood_wait_until_seconds"); err != nil {
+		t.Error(err)
+	}
+}
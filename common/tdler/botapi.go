@@ -0,0 +1,129 @@
+package tdler
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/botapi"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+var botAPI *botapi.Client
+
+// UseBotAPI makes files of messages be downloaded through the Bot API server of client.
+// Files the server fails to get, e.g. those of chats only the userbot is in, are still downloaded by MTProto.
+func UseBotAPI(client *botapi.Client) {
+	botAPI = client
+}
+
+// botAPIFileID returns the Bot API file_id of a file of a message, false if it has none.
+func botAPIFileID(file tfile.TGFile) (string, bool) {
+	msg := tfile.MessageOf(file)
+	if msg == nil {
+		return "", false
+	}
+	media := msg.Media
+	if inner, ok := tfile.WebPageMedia(media); ok {
+		media = inner
+	}
+	var (
+		fileType botapi.FileType
+		dcID     int
+	)
+	switch m := media.(type) {
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.AsNotEmpty()
+		if !ok {
+			return "", false
+		}
+		fileType, dcID = botapi.DocumentFileType(doc), doc.DCID
+	case *tg.MessageMediaPhoto:
+		photo, ok := m.Photo.AsNotEmpty()
+		if !ok {
+			return "", false
+		}
+		fileType, dcID = botapi.FileTypePhoto, photo.DCID
+	default:
+		return "", false
+	}
+	fileID, err := botapi.EncodeFileID(file.Location(), fileType, dcID)
+	return fileID, err == nil
+}
+
+// botAPIClient downloads the file through the Bot API server, and by the wrapped client if the server cannot get it.
+type botAPIClient struct {
+	downloader.Client
+	api    *botapi.Client
+	fileID string
+	name   string
+
+	mu       sync.Mutex
+	file     *botapi.File
+	fallback bool
+}
+
+func newBotAPIClient(file tfile.TGFile, client downloader.Client) downloader.Client {
+	if botAPI == nil || client == nil {
+		return client
+	}
+	fileID, ok := botAPIFileID(file)
+	if !ok {
+		return client
+	}
+	return &botAPIClient{Client: client, api: botAPI, fileID: fileID, name: file.Name()}
+}
+
+func (c *botAPIClient) UploadGetFile(ctx context.Context, request *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	file, err := c.getFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return c.Client.UploadGetFile(ctx, request)
+	}
+	buf := make([]byte, request.Limit)
+	n, err := c.api.ReadAt(ctx, file, buf, request.Offset)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.Join(ctx.Err(), err)
+		}
+		c.fallBack(ctx, err)
+		return c.Client.UploadGetFile(ctx, request)
+	}
+	return &tg.UploadFile{Type: &tg.StorageFileUnknown{}, Bytes: buf[:n]}, nil
+}
+
+// fallBack makes the rest of the file be downloaded by the wrapped client after the server failed to read it.
+func (c *botAPIClient) fallBack(ctx context.Context, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fallback {
+		return
+	}
+	log.FromContext(ctx).Warnf("Bot API server failed to read file %s, downloading it by MTProto: %s", c.name, err)
+	c.file, c.fallback = nil, true
+}
+
+// getFile returns the file on the server, nil if the file is to be downloaded by the wrapped client.
+func (c *botAPIClient) getFile(ctx context.Context) (*botapi.File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file != nil || c.fallback {
+		return c.file, nil
+	}
+	file, err := c.api.GetFile(ctx, c.fileID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.Join(ctx.Err(), err)
+		}
+		log.FromContext(ctx).Warnf("Bot API server failed to get file %s, downloading it by MTProto: %s", c.name, err)
+		c.fallback = true
+		return nil, nil
+	}
+	c.file = file
+	return file, nil
+}
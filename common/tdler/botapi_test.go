@@ -0,0 +1,126 @@
+package tdler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/botapi"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// withBotAPI uses a stand-in of telegram-bot-api serving data for any file_id, or failing getFile if data is nil.
+func withBotAPI(t *testing.T, data []byte) {
+	withBotAPIFiles(t, data, func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file_0.bin", time.Time{}, bytes.NewReader(data))
+	})
+}
+
+// withBotAPIFiles is like withBotAPI, but downloads of the file are served by serveFile.
+func withBotAPIFiles(t *testing.T, data []byte, serveFile http.HandlerFunc) {
+	const token = "123:abc"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bot"+token+"/getFile", func(w http.ResponseWriter, r *http.Request) {
+		if data == nil {
+			fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: wrong file_id or the file is temporarily unavailable"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":{"file_id":%q,"file_size":%d,"file_path":"documents/file_0.bin"}}`, r.URL.Query().Get("file_id"), len(data))
+	})
+	mux.HandleFunc("/file/bot"+token+"/documents/file_0.bin", serveFile)
+	server := httptest.NewServer(mux)
+	client, err := botapi.NewClient(server.URL, token, "", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseBotAPI(client)
+	t.Cleanup(func() {
+		UseBotAPI(nil)
+		server.Close()
+	})
+}
+
+func newBotAPIFile(client *fakeClient) tfile.TGFile {
+	doc := &tg.Document{ID: 1, AccessHash: 2, FileReference: []byte("ref"), DCID: 2, Size: int64(len(client.data))}
+	return tfile.NewTGFile(doc.AsInputDocumentFileLocation(), client, doc.Size, "test.bin",
+		tfile.WithMessage(&tg.Message{ID: 1, PeerID: &tg.PeerUser{UserID: 1}, Media: &tg.MessageMediaDocument{Document: doc}}))
+}
+
+func TestDownloadThroughBotAPI(t *testing.T) {
+	data := make([]byte, 4096*3+10)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	withBotAPI(t, data)
+	client := &fakeClient{data: data}
+	localPath := filepath.Join(t.TempDir(), "cache")
+	if err := downloadParts(context.Background(), newBotAPIFile(client), localPath, 4096, 2, func(int64) {}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("downloaded file differs from the file on the server")
+	}
+	if len(client.fetched) != 0 {
+		t.Errorf("MTProto client fetched %d parts, want 0", len(client.fetched))
+	}
+}
+
+func TestBotAPIFallback(t *testing.T) {
+	withBotAPI(t, nil)
+	data := []byte("fallback data")
+	client := &fakeClient{data: data}
+	localPath := filepath.Join(t.TempDir(), "cache")
+	if err := downloadParts(context.Background(), newBotAPIFile(client), localPath, 4096, 1, func(int64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(localPath); !bytes.Equal(got, data) {
+		t.Fatalf("downloaded %q", got)
+	}
+	if len(client.fetched) != 1 {
+		t.Errorf("MTProto client fetched %d parts, want 1", len(client.fetched))
+	}
+}
+
+func TestBotAPIReadFallback(t *testing.T) {
+	data := make([]byte, 4096*3+10)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	var reads atomic.Int32
+	withBotAPIFiles(t, data, func(w http.ResponseWriter, r *http.Request) {
+		reads.Add(1)
+		http.Error(w, "file is too big", http.StatusBadRequest)
+	})
+	client := &fakeClient{data: data}
+	localPath := filepath.Join(t.TempDir(), "cache")
+	if err := downloadParts(context.Background(), newBotAPIFile(client), localPath, 4096, 1, func(int64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(localPath); !bytes.Equal(got, data) {
+		t.Fatal("downloaded file differs from the file on the server")
+	}
+	if len(client.fetched) != 4 || reads.Load() != 1 {
+		t.Errorf("MTProto client fetched %d parts after %d failed reads, want 4 after 1", len(client.fetched), reads.Load())
+	}
+}
+
+func TestBotAPISkipsFilesWithoutMessage(t *testing.T) {
+	withBotAPI(t, []byte("data"))
+	client := &fakeClient{data: []byte("data")}
+	file := tfile.NewTGFile(&tg.InputDocumentFileLocation{ID: 1}, client, 4, "test.bin")
+	if got := Dler(file); got != file.Dler() {
+		t.Errorf("Dler() = %T, want the client of the file", got)
+	}
+}
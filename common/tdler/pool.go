@@ -0,0 +1,314 @@
+package tdler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/krau/SaveAny-Bot/common/metrics"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// Locator gets the location of the file of msg as seen by an account, it fails if the account cannot access the chat.
+type Locator func(ctx context.Context, msg *tg.Message) (tg.InputFileLocationClass, error)
+
+// Account is an account of the download pool.
+type Account struct {
+	Name   string
+	client downloader.Client
+	locate Locator
+
+	inflight   atomic.Int64
+	requests   atomic.Int64
+	bytes      atomic.Int64
+	floodWaits atomic.Int64
+
+	mu         sync.Mutex
+	floodUntil time.Time
+}
+
+func NewAccount(name string, client downloader.Client, locate Locator) *Account {
+	return &Account{
+		Name:   name,
+		client: client,
+		locate: locate,
+	}
+}
+
+// AccountStats is a snapshot of the rate accounting of a pool account.
+type AccountStats struct {
+	Name       string
+	Inflight   int64
+	Requests   int64
+	Bytes      int64
+	FloodWaits int64
+	FloodUntil time.Time
+}
+
+func (a *Account) Stats() AccountStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return AccountStats{
+		Name:       a.Name,
+		Inflight:   a.inflight.Load(),
+		Requests:   a.requests.Load(),
+		Bytes:      a.bytes.Load(),
+		FloodWaits: a.floodWaits.Load(),
+		FloodUntil: a.floodUntil,
+	}
+}
+
+// floodedUntil returns the end of the flood wait of the account, a zero time if it is not waiting.
+func (a *Account) floodedUntil(now time.Time) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.floodUntil.After(now) {
+		return a.floodUntil
+	}
+	return time.Time{}
+}
+
+func (a *Account) setFloodWait(d time.Duration) {
+	a.floodWaits.Add(1)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if until := time.Now().Add(d); until.After(a.floodUntil) {
+		a.floodUntil = until
+	}
+}
+
+// uploadGetFile sends the request with client, a wrapper of the client of the account, counting it to the account.
+func (a *Account) uploadGetFile(ctx context.Context, client downloader.Client, req *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	a.inflight.Add(1)
+	defer a.inflight.Add(-1)
+	a.requests.Add(1)
+	res, err := client.UploadGetFile(ctx, req)
+	if file, ok := res.(*tg.UploadFile); ok {
+		a.bytes.Add(int64(len(file.Bytes)))
+	}
+	return res, err
+}
+
+var (
+	poolMu    sync.RWMutex
+	accounts  []*Account
+	primaries []*Account
+)
+
+// AddAccount adds an account to the download pool.
+// Downloads of files from a message are then spread across the accounts able to access the chat of the message.
+func AddAccount(account *Account) {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	accounts = append(accounts, account)
+}
+
+// AddPrimaryAccount registers the account of a client files are got with, e.g. the bot or the userbot.
+// All downloads of files of the client then share its flood waits and requests in flight.
+func AddPrimaryAccount(account *Account) {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	primaries = append(primaries, account)
+}
+
+func poolAccounts() []*Account {
+	poolMu.RLock()
+	defer poolMu.RUnlock()
+	return slices.Clone(accounts)
+}
+
+// primaryAccount returns the registered account of the client, or a new account if the client is not registered.
+// Wrappers of the client, e.g. the one refreshing expired file references, are unwrapped to find the account.
+func primaryAccount(client downloader.Client) *Account {
+	raw := client
+	for {
+		wrapper, ok := raw.(interface{ Unwrap() downloader.Client })
+		if !ok {
+			break
+		}
+		raw = wrapper.Unwrap()
+	}
+	poolMu.RLock()
+	defer poolMu.RUnlock()
+	for _, account := range primaries {
+		if account.client == raw {
+			return account
+		}
+	}
+	return NewAccount("", raw, nil)
+}
+
+// PoolStats returns the rate accounting of the primary accounts and the accounts of the download pool.
+func PoolStats() []AccountStats {
+	poolMu.RLock()
+	pool := slices.Concat(primaries, accounts)
+	poolMu.RUnlock()
+	stats := make([]AccountStats, 0, len(pool))
+	for _, account := range pool {
+		stats = append(stats, account.Stats())
+	}
+	return stats
+}
+
+func init() {
+	metrics.SetPoolStats(func() []metrics.PoolAccount {
+		stats := PoolStats()
+		accounts := make([]metrics.PoolAccount, 0, len(stats))
+		for _, s := range stats {
+			accounts = append(accounts, metrics.PoolAccount(s))
+		}
+		return accounts
+	})
+}
+
+// Dler returns the client to download the file with, which gets the file through the Bot API server if one is used
+// and spreads the requests across the download pool if there is one.
+func Dler(file tfile.TGFile) downloader.Client {
+	return newBotAPIClient(file, poolDler(file))
+}
+
+func poolDler(file tfile.TGFile) downloader.Client {
+	pool := poolAccounts()
+	msgFile, ok := file.(tfile.TGFileMessage)
+	if !ok || msgFile.Message() == nil || file.Dler() == nil || len(pool) == 0 {
+		return file.Dler()
+	}
+	return &poolClient{
+		Client:    file.Dler(),
+		file:      msgFile,
+		primary:   primaryAccount(file.Dler()),
+		helpers:   pool,
+		locations: make(map[*Account]tg.InputFileLocationClass),
+		excluded:  make(map[*Account]bool),
+	}
+}
+
+// poolClient sends every request of a file to the least busy account that can access the file and is not waiting out a flood wait.
+// The client of the file itself is always a candidate, the pool accounts are dropped for the file once they fail to get it.
+type poolClient struct {
+	downloader.Client
+	file    tfile.TGFileMessage
+	primary *Account
+	helpers []*Account
+
+	mu        sync.Mutex
+	excluded  map[*Account]bool
+	locateMu  sync.Mutex
+	locations map[*Account]tg.InputFileLocationClass
+}
+
+func (c *poolClient) UploadGetFile(ctx context.Context, request *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	logger := log.FromContext(ctx)
+	for {
+		account, wait := c.pick()
+		if account == nil {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		req := *request
+		if account != c.primary {
+			location, err := c.location(ctx, account)
+			if err != nil {
+				logger.Debugf("Pool account %s cannot access file %s: %s", account.Name, c.file.Name(), err)
+				c.exclude(account)
+				continue
+			}
+			req.Location = location
+		}
+		client := account.client
+		if account == c.primary {
+			// 主账号的请求经过文件自身的客户端, 以便刷新过期的文件引用
+			client = c.Client
+		}
+		res, err := account.uploadGetFile(ctx, client, &req)
+		if err == nil {
+			return res, nil
+		}
+		if d, ok := tgerr.AsFloodWait(err); ok {
+			logger.Debugf("Pool account %s got flood wait of %s", account.Name, d)
+			account.setFloodWait(d)
+			continue
+		}
+		if account == c.primary || ctx.Err() != nil {
+			return nil, err
+		}
+		logger.Debugf("Pool account %s failed to get file %s: %s", account.Name, c.file.Name(), err)
+		c.exclude(account)
+	}
+}
+
+// pick returns the account with the fewest requests in flight, or how long to wait if all accounts are waiting out flood waits.
+func (c *poolClient) pick() (*Account, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	var best *Account
+	var earliest time.Time
+	for _, account := range append([]*Account{c.primary}, c.helpers...) {
+		if c.excluded[account] {
+			continue
+		}
+		if until := account.floodedUntil(now); !until.IsZero() {
+			if earliest.IsZero() || until.Before(earliest) {
+				earliest = until
+			}
+			continue
+		}
+		if best == nil || account.inflight.Load() < best.inflight.Load() {
+			best = account
+		}
+	}
+	if best != nil {
+		return best, 0
+	}
+	return nil, earliest.Sub(now)
+}
+
+func (c *poolClient) exclude(account *Account) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.excluded[account] = true
+}
+
+// location returns the location of the file for the account, which has its own access hash and file reference.
+func (c *poolClient) location(ctx context.Context, account *Account) (tg.InputFileLocationClass, error) {
+	c.locateMu.Lock()
+	defer c.locateMu.Unlock()
+	if location, ok := c.locations[account]; ok {
+		return location, nil
+	}
+	location, err := account.locate(ctx, c.file.Message())
+	if err != nil {
+		return nil, err
+	}
+	// 私聊和普通群组中的消息 ID 是每个账号独立的, 同一 ID 可能是另一条消息
+	if !sameFile(location, c.file.Location()) {
+		return nil, errors.New("the message of the account has another file")
+	}
+	c.locations[account] = location
+	return location, nil
+}
+
+func sameFile(a, b tg.InputFileLocationClass) bool {
+	switch a := a.(type) {
+	case *tg.InputDocumentFileLocation:
+		b, ok := b.(*tg.InputDocumentFileLocation)
+		return ok && a.ID == b.ID && a.ThumbSize == b.ThumbSize
+	case *tg.InputPhotoFileLocation:
+		b, ok := b.(*tg.InputPhotoFileLocation)
+		return ok && a.ID == b.ID && a.ThumbSize == b.ThumbSize
+	default:
+		return false
+	}
+}
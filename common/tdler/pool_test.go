@@ -0,0 +1,205 @@
+package tdler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/krau/SaveAny-Bot/client/middleware"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// accountClient serves the file to requests with its own file reference and fails the first floods requests with FLOOD_WAIT.
+type accountClient struct {
+	downloader.Client
+	reference string
+	floods    atomic.Int32
+	served    atomic.Int32
+	delay     time.Duration
+}
+
+func (c *accountClient) UploadGetFile(ctx context.Context, req *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	if ref := string(req.Location.(*tg.InputDocumentFileLocation).FileReference); ref != c.reference {
+		return nil, tgerr.New(400, "FILE_REFERENCE_INVALID")
+	}
+	if c.floods.Add(-1) >= 0 {
+		return nil, tgerr.New(420, "FLOOD_WAIT_60")
+	}
+	time.Sleep(c.delay)
+	c.served.Add(1)
+	return &tg.UploadFile{Bytes: []byte("data")}, nil
+}
+
+func locateAs(reference string, docID int64) Locator {
+	return func(ctx context.Context, msg *tg.Message) (tg.InputFileLocationClass, error) {
+		return &tg.InputDocumentFileLocation{ID: docID, FileReference: []byte(reference)}, nil
+	}
+}
+
+func withPool(t *testing.T, pool ...*Account) {
+	poolMu.Lock()
+	accounts = pool
+	poolMu.Unlock()
+	t.Cleanup(func() {
+		poolMu.Lock()
+		accounts = nil
+		poolMu.Unlock()
+	})
+}
+
+func newPoolFile(client downloader.Client, opts ...tfile.TGFileOption) tfile.TGFile {
+	opts = append(opts, tfile.WithMessage(&tg.Message{ID: 1, PeerID: &tg.PeerChannel{ChannelID: 1}}))
+	return tfile.NewTGFile(&tg.InputDocumentFileLocation{ID: 1, FileReference: []byte("primary")}, client, 4, "test.bin", opts...)
+}
+
+func getParts(t *testing.T, client downloader.Client, file tfile.TGFile, parts int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make(chan error, parts)
+	for i := range parts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.UploadGetFile(context.Background(), &tg.UploadGetFileRequest{Location: file.Location(), Offset: int64(i) * 4, Limit: 4})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("download failed: %v", err)
+		}
+	}
+}
+
+func TestPoolSpreadsParts(t *testing.T) {
+	primary := &accountClient{reference: "primary", delay: 20 * time.Millisecond}
+	helper := &accountClient{reference: "helper", delay: 20 * time.Millisecond}
+	account := NewAccount("helper", helper, locateAs("helper", 1))
+	withPool(t, account)
+	file := newPoolFile(primary)
+
+	getParts(t, Dler(file), file, 8)
+	if primary.served.Load() == 0 || helper.served.Load() == 0 {
+		t.Errorf("parts were not spread, primary served %d and helper %d", primary.served.Load(), helper.served.Load())
+	}
+	if stats := PoolStats(); stats[0].Requests != int64(helper.served.Load()) || stats[0].Bytes != 4*int64(helper.served.Load()) {
+		t.Errorf("unexpected stats %+v", stats[0])
+	}
+}
+
+func TestPoolFloodWaitFailover(t *testing.T) {
+	// 主账号忙时请求会先交给协助账号
+	primary := &accountClient{reference: "primary", delay: 50 * time.Millisecond}
+	helper := &accountClient{reference: "helper"}
+	helper.floods.Store(1)
+	account := NewAccount("helper", helper, locateAs("helper", 1))
+	withPool(t, account)
+	file := newPoolFile(primary)
+	getParts(t, Dler(file), file, 4)
+	stats := account.Stats()
+	if stats.FloodWaits != 1 || time.Until(stats.FloodUntil) < 50*time.Second {
+		t.Errorf("flood wait was not recorded, stats %+v", stats)
+	}
+	if helper.served.Load() != 0 || primary.served.Load() != 4 {
+		t.Errorf("flood waiting account was used, primary served %d and helper %d", primary.served.Load(), helper.served.Load())
+	}
+}
+
+func TestPoolAccountMiddlewaresFloodWait(t *testing.T) {
+	// 协助账号通过自身的中间件收到 FLOOD_WAIT 后由主账号接手, 不在中间件中等待
+	primary := &accountClient{reference: "primary", delay: 50 * time.Millisecond}
+	helper := &accountClient{reference: "helper"}
+	helper.floods.Store(1)
+	var invoker tg.Invoker = telegram.InvokeFunc(func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+		file, err := helper.UploadGetFile(ctx, input.(*tg.UploadGetFileRequest))
+		if err != nil {
+			return err
+		}
+		output.(*tg.UploadFileBox).File = file
+		return nil
+	})
+	mws := middleware.NewPoolMiddlewares(context.Background(), time.Second)
+	for i := len(mws) - 1; i >= 0; i-- {
+		invoker = mws[i].Handle(invoker)
+	}
+	account := NewAccount("helper", tg.NewClient(invoker), locateAs("helper", 1))
+	withPool(t, account)
+	file := newPoolFile(primary)
+	start := time.Now()
+	getParts(t, Dler(file), file, 4)
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("download waited out the flood wait of the helper account")
+	}
+	if stats := account.Stats(); stats.FloodWaits != 1 || time.Until(stats.FloodUntil) < 50*time.Second {
+		t.Errorf("flood wait was not recorded, stats %+v", stats)
+	}
+	if helper.served.Load() != 0 || primary.served.Load() != 4 {
+		t.Errorf("flood waiting account was used, primary served %d and helper %d", primary.served.Load(), helper.served.Load())
+	}
+}
+
+func TestPoolSkipsInaccessibleAccounts(t *testing.T) {
+	primary := &accountClient{reference: "primary", delay: 20 * time.Millisecond}
+	located := 0
+	noAccess := NewAccount("no-access", &accountClient{reference: "no-access"}, func(ctx context.Context, msg *tg.Message) (tg.InputFileLocationClass, error) {
+		located++
+		return nil, errors.New("CHANNEL_PRIVATE")
+	})
+	otherFile := &accountClient{reference: "other"}
+	withPool(t, noAccess, NewAccount("other", otherFile, locateAs("other", 2)))
+	file := newPoolFile(primary)
+	getParts(t, Dler(file), file, 4)
+	if primary.served.Load() != 4 || otherFile.served.Load() != 0 {
+		t.Errorf("primary served %d parts, account with another file served %d", primary.served.Load(), otherFile.served.Load())
+	}
+	if located != 1 {
+		t.Errorf("inaccessible account was located %d times, expected once", located)
+	}
+}
+
+func TestPoolSharesPrimaryAccount(t *testing.T) {
+	primary := &accountClient{reference: "primary"}
+	account := NewAccount("bot", primary, nil)
+	poolMu.Lock()
+	primaries = []*Account{account}
+	poolMu.Unlock()
+	t.Cleanup(func() {
+		poolMu.Lock()
+		primaries = nil
+		poolMu.Unlock()
+	})
+	helper := NewAccount("helper", &accountClient{reference: "other"}, locateAs("other", 2))
+	withPool(t, helper)
+
+	refresher := tfile.WithRefresher(func(ctx context.Context) (tg.InputFileLocationClass, error) {
+		return &tg.InputDocumentFileLocation{ID: 1, FileReference: []byte("primary")}, nil
+	})
+	for _, file := range []tfile.TGFile{newPoolFile(primary), newPoolFile(primary, refresher)} {
+		getParts(t, Dler(file), file, 2)
+	}
+	if got := account.Stats().Requests; got != 4 {
+		t.Errorf("primary account got %d requests of both files, expected 4", got)
+	}
+	stats := PoolStats()
+	if len(stats) != 2 || stats[0].Name != "bot" || stats[1].Name != "helper" {
+		t.Errorf("unexpected pool stats %+v", stats)
+	}
+}
+
+func TestDlerWithoutPool(t *testing.T) {
+	primary := &accountClient{reference: "primary"}
+	file := newPoolFile(primary)
+	if Dler(file) != downloader.Client(primary) {
+		t.Error("expected the client of the file without a pool")
+	}
+}
@@ -0,0 +1,295 @@
+package tdler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/utils/dlutil"
+	"github.com/krau/SaveAny-Bot/common/utils/ioutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/consts/tglimit"
+	"github.com/krau/SaveAny-Bot/pkg/queue"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// CheckpointSuffix 是记录已完成分块的旁路文件的后缀
+	CheckpointSuffix = ".parts"
+	// CheckpointTTL 是未完成的下载在缓存目录中保留的时间, 超过后启动时清理缓存会将其删除
+	CheckpointTTL = 7 * 24 * time.Hour
+
+	cachePrefix = "tgdl_"
+)
+
+// Resumable reports whether the download of the file can be checkpointed, that is a document of known size.
+func Resumable(file tfile.TGFile) bool {
+	_, ok := file.Location().(*tg.InputDocumentFileLocation)
+	return ok && file.Size() > 0
+}
+
+// KeepPartial reports whether the partial download of the file is kept after the task failed with err,
+// so that retrying the task only fetches the missing parts. Downloads of cancelled tasks are removed,
+// but not those interrupted by a shutdown, which are resumed after the restart.
+func KeepPartial(ctx context.Context, file tfile.TGFile, err error) bool {
+	if err == nil || !Resumable(file) {
+		return false
+	}
+	return !errors.Is(context.Cause(ctx), queue.ErrCancelled)
+}
+
+// CachePath returns the cache file for downloading the file. Resumable files get a path derived from the document,
+// so a later task for the same file continues the download left by an earlier one; fallback is used for other files.
+func CachePath(file tfile.TGFile, fallback string) (string, error) {
+	if !Resumable(file) {
+		return filepath.Abs(fallback)
+	}
+	doc := file.Location().(*tg.InputDocumentFileLocation)
+	return filepath.Abs(filepath.Join(config.C().Temp.BasePath, fmt.Sprintf("%s%d_%d", cachePrefix, doc.ID, file.Size())))
+}
+
+// KeepOnCleanup reports whether an entry of the cache directory is an unfinished download that may still be resumed.
+func KeepOnCleanup(entry os.DirEntry) bool {
+	if !strings.HasPrefix(entry.Name(), cachePrefix) {
+		return false
+	}
+	info, err := entry.Info()
+	return err == nil && time.Since(info.ModTime()) < CheckpointTTL
+}
+
+// cacheLock 缓存文件的锁, refs 为持有或等待该锁的任务数, 为 0 时从 cacheLocks 中删除
+type cacheLock struct {
+	sync.Mutex
+	refs int
+}
+
+var (
+	cacheLocksMu sync.Mutex
+	cacheLocks   = make(map[string]*cacheLock) // cache path -> lock
+)
+
+// LockCache locks the cache file until the returned function is called,
+// tasks of the same file share the cache file and must not use it at the same time.
+func LockCache(localPath string) func() {
+	cacheLocksMu.Lock()
+	l, ok := cacheLocks[localPath]
+	if !ok {
+		l = &cacheLock{}
+		cacheLocks[localPath] = l
+	}
+	l.refs++
+	cacheLocksMu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		cacheLocksMu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(cacheLocks, localPath)
+		}
+		cacheLocksMu.Unlock()
+	}
+}
+
+// RemoveCache removes the cache file and its checkpoint.
+func RemoveCache(localPath string) error {
+	err := os.Remove(localPath)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if rmErr := os.Remove(localPath + CheckpointSuffix); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+		err = errors.Join(err, rmErr)
+	}
+	return err
+}
+
+// Download downloads the file to localPath.
+// Resumable files are fetched part by part and every completed part is recorded in a checkpoint next to the file,
+// so downloading the file again, e.g. by a retry, a new task or after a restart, only fetches the missing parts.
+// onProgress is called with the downloaded size of the file, including the parts done by earlier downloads.
+func Download(ctx context.Context, file tfile.TGFile, localPath string, onProgress func(downloaded int64)) error {
+	if err := os.MkdirAll(filepath.Dir(localPath), os.ModePerm); err != nil {
+		return err
+	}
+	if !Resumable(file) {
+		f, err := os.Create(localPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		var downloaded atomic.Int64
+		_, err = NewDownloader(file).Parallel(ctx, ioutil.NewProgressWriterAt(f, func(n int) {
+			onProgress(downloaded.Add(int64(n)))
+		}))
+		return err
+	}
+	return downloadParts(ctx, file, localPath, tglimit.MaxPartSize, dlutil.BestThreads(file.Size(), config.C().Threads), onProgress)
+}
+
+func downloadParts(ctx context.Context, file tfile.TGFile, localPath string, partSize int, threads int, onProgress func(downloaded int64)) error {
+	// 缓存文件不存在时检查点没有意义
+	_, statErr := os.Stat(localPath)
+	f, err := os.OpenFile(localPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cp, err := openCheckpoint(localPath+CheckpointSuffix, file.Size(), partSize, statErr != nil)
+	if err != nil {
+		return err
+	}
+	defer cp.Close()
+	if downloaded := cp.downloaded.Load(); downloaded > 0 {
+		onProgress(downloaded)
+	}
+	if err := f.Truncate(file.Size()); err != nil {
+		return err
+	}
+
+	client := Dler(file)
+	eg, gctx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(threads, 1))
+	for part := range cp.parts {
+		if gctx.Err() != nil {
+			break
+		}
+		if cp.isDone(part) {
+			continue
+		}
+		eg.Go(func() error {
+			offset := int64(part) * int64(partSize)
+			data, err := fetchPart(gctx, client, file, offset, partSize)
+			if err != nil {
+				return fmt.Errorf("failed to download part %d: %w", part, err)
+			}
+			if want := min(int64(partSize), file.Size()-offset); int64(len(data)) != want {
+				return fmt.Errorf("part %d has %d bytes, expected %d", part, len(data), want)
+			}
+			if _, err := f.WriteAt(data, offset); err != nil {
+				return err
+			}
+			if err := cp.markDone(part, len(data)); err != nil {
+				return err
+			}
+			onProgress(cp.downloaded.Load())
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+func fetchPart(ctx context.Context, client downloader.Client, file tfile.TGFile, offset int64, limit int) ([]byte, error) {
+	res, err := client.UploadGetFile(ctx, &tg.UploadGetFileRequest{
+		Location: file.Location(),
+		Offset:   offset,
+		Limit:    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch r := res.(type) {
+	case *tg.UploadFile:
+		return r.Bytes, nil
+	default:
+		return nil, fmt.Errorf("unexpected response %T", res)
+	}
+}
+
+// checkpoint 记录已下载完成的分块, 第一行是文件大小与分块大小, 之后每行是一个完成的分块序号
+type checkpoint struct {
+	mu         sync.Mutex
+	f          *os.File
+	size       int64
+	partSize   int
+	parts      int
+	done       map[int]struct{}
+	downloaded atomic.Int64 // size of the completed parts
+}
+
+// openCheckpoint loads the checkpoint at path, a checkpoint of another size or part size is discarded, so is any if reset is true.
+func openCheckpoint(path string, size int64, partSize int, reset bool) (*checkpoint, error) {
+	cp := &checkpoint{
+		size:     size,
+		partSize: partSize,
+		parts:    int((size + int64(partSize) - 1) / int64(partSize)),
+		done:     make(map[int]struct{}),
+	}
+	header := fmt.Sprintf("%d %d", size, partSize)
+	if !reset {
+		if err := cp.load(path, header); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.WriteString(header + "\n")
+	for part := range cp.done {
+		sb.WriteString(strconv.Itoa(part) + "\n")
+	}
+	if _, err := io.WriteString(f, sb.String()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	cp.f = f
+	return cp, nil
+}
+
+func (c *checkpoint) load(path, header string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || scanner.Text() != header {
+		return nil
+	}
+	for scanner.Scan() {
+		// 中断时可能写入了不完整的一行, 解析失败的行会被忽略
+		part, err := strconv.Atoi(scanner.Text())
+		if _, ok := c.done[part]; err == nil && !ok && part >= 0 && part < c.parts {
+			c.done[part] = struct{}{}
+			c.downloaded.Add(min(int64(c.partSize), c.size-int64(part)*int64(c.partSize)))
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *checkpoint) isDone(part int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.done[part]
+	return ok
+}
+
+func (c *checkpoint) markDone(part, size int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.f.WriteString(strconv.Itoa(part) + "\n"); err != nil {
+		return err
+	}
+	c.done[part] = struct{}{}
+	c.downloaded.Add(int64(size))
+	return nil
+}
+
+func (c *checkpoint) Close() error {
+	return c.f.Close()
+}
@@ -0,0 +1,142 @@
+package tdler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/queue"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// fakeClient serves parts of data and fails the parts in failAt once.
+type fakeClient struct {
+	downloader.Client
+	data    []byte
+	mu      sync.Mutex
+	failAt  map[int64]bool
+	fetched []int64
+}
+
+func (c *fakeClient) UploadGetFile(ctx context.Context, req *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failAt[req.Offset] {
+		delete(c.failAt, req.Offset)
+		return nil, errors.New("connection lost")
+	}
+	c.fetched = append(c.fetched, req.Offset)
+	end := min(req.Offset+int64(req.Limit), int64(len(c.data)))
+	return &tg.UploadFile{Bytes: c.data[req.Offset:end]}, nil
+}
+
+func TestDownloadPartsResume(t *testing.T) {
+	const partSize = 4096
+	data := make([]byte, partSize*5+100)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	client := &fakeClient{data: data, failAt: map[int64]bool{partSize * 3: true}}
+	file := tfile.NewTGFile(&tg.InputDocumentFileLocation{ID: 1}, client, int64(len(data)), "test.bin")
+	localPath := filepath.Join(t.TempDir(), "cache")
+
+	var progress atomic.Int64
+	onProgress := func(downloaded int64) {
+		// 并发的分块可能乱序报告进度
+		for cur := progress.Load(); downloaded > cur && !progress.CompareAndSwap(cur, downloaded); cur = progress.Load() {
+		}
+	}
+	if err := downloadParts(context.Background(), file, localPath, partSize, 1, onProgress); err == nil {
+		t.Fatal("expected the first download to fail")
+	}
+	if _, err := os.Stat(localPath + CheckpointSuffix); err != nil {
+		t.Fatalf("checkpoint should be kept after a failure: %v", err)
+	}
+	firstFetched := len(client.fetched)
+
+	if err := downloadParts(context.Background(), file, localPath, partSize, 2, onProgress); err != nil {
+		t.Fatalf("resumed download failed: %v", err)
+	}
+	if got, want := len(client.fetched)-firstFetched, 6-firstFetched; got != want {
+		t.Errorf("resumed download fetched %d parts, expected only the %d missing parts", got, want)
+	}
+	if progress.Load() != int64(len(data)) {
+		t.Errorf("progress = %d, expected %d", progress.Load(), len(data))
+	}
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, data) {
+		t.Error("downloaded content does not match")
+	}
+
+	// a checkpoint without the cache file is discarded
+	if err := os.Remove(localPath); err != nil {
+		t.Fatal(err)
+	}
+	client.fetched = nil
+	if err := downloadParts(context.Background(), file, localPath, partSize, 2, onProgress); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if len(client.fetched) != 6 {
+		t.Errorf("fetched %d parts, expected all 6", len(client.fetched))
+	}
+	if err := RemoveCache(localPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(localPath + CheckpointSuffix); !os.IsNotExist(err) {
+		t.Error("checkpoint should be removed with the cache")
+	}
+}
+
+func TestKeepPartial(t *testing.T) {
+	file := tfile.NewTGFile(&tg.InputDocumentFileLocation{ID: 1}, &fakeClient{}, 10, "test.bin")
+	failed := errors.New("network error")
+
+	task := queue.NewTask(context.Background(), "keep", "", struct{}{})
+	if !KeepPartial(task.Context(), file, failed) {
+		t.Error("partial download of a failed task should be kept")
+	}
+	if KeepPartial(task.Context(), file, nil) {
+		t.Error("download of a successful task should be removed")
+	}
+
+	parent, stop := context.WithCancel(context.Background())
+	task = queue.NewTask(parent, "shutdown", "", struct{}{})
+	stop()
+	if !KeepPartial(task.Context(), file, context.Canceled) {
+		t.Error("partial download interrupted by a shutdown should be kept")
+	}
+
+	task = queue.NewTask(context.Background(), "cancel", "", struct{}{})
+	task.Cancel()
+	if KeepPartial(task.Context(), file, context.Canceled) {
+		t.Error("partial download of a cancelled task should be removed")
+	}
+}
+
+func TestLockCacheRemovesUnusedLocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache")
+	unlock := LockCache(path)
+	done := make(chan struct{})
+	go func() {
+		LockCache(path)()
+		close(done)
+	}()
+	unlock()
+	<-done
+
+	cacheLocksMu.Lock()
+	defer cacheLocksMu.Unlock()
+	if _, ok := cacheLocks[path]; ok {
+		t.Error("lock of an unused cache file was not removed")
+	}
+}
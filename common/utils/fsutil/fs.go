@@ -11,11 +11,19 @@ import (
 
 // 删除文件夹内的所有文件和子目录, 但不删除文件夹本身
 func RemoveAllInDir(dirPath string) error {
+	return RemoveAllInDirExcept(dirPath, nil)
+}
+
+// 删除文件夹内 keep 返回 false 的文件和子目录, keep 为 nil 时全部删除
+func RemoveAllInDirExcept(dirPath string, keep func(entry os.DirEntry) bool) error {
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
 		return err
 	}
 	for _, entry := range entries {
+		if keep != nil && keep(entry) {
+			continue
+		}
 		entryPath := filepath.Join(dirPath, entry.Name())
 		if err := os.RemoveAll(entryPath); err != nil {
 			return err
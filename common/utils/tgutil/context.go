@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
 )
 
 type contextKey struct{}
@@ -20,3 +21,20 @@ func ExtFromContext(ctx context.Context) *ext.Context {
 func ExtWithContext(ctx context.Context, extCtx *ext.Context) context.Context {
 	return context.WithValue(ctx, extKey, extCtx)
 }
+
+type sourceMessageKey struct{}
+
+// WithSourceMessage sets the telegram message the saved file comes from, storages may use it to describe the file.
+func WithSourceMessage(ctx context.Context, msg *tg.Message) context.Context {
+	if msg == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sourceMessageKey{}, msg)
+}
+
+func SourceMessageFromContext(ctx context.Context) *tg.Message {
+	if msg, ok := ctx.Value(sourceMessageKey{}).(*tg.Message); ok {
+		return msg
+	}
+	return nil
+}
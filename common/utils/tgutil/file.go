@@ -0,0 +1,32 @@
+package tgutil
+
+import (
+	"context"
+	"errors"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// WithFileRefresher returns a file option refreshing an expired file reference by getting msg again,
+// ctx must be the client the message was got with, the bot or the userbot.
+func WithFileRefresher(ctx *ext.Context, msg *tg.Message) tfile.TGFileOption {
+	chatID := ChatIdFromPeer(msg.GetPeerID())
+	msgID := msg.GetID()
+	return tfile.WithRefresher(func(context.Context) (tg.InputFileLocationClass, error) {
+		fresh, err := RefetchMessageByID(ctx, chatID, msgID)
+		if err != nil {
+			return nil, err
+		}
+		media, ok := fresh.GetMedia()
+		if !ok {
+			return nil, errors.New("message has no media anymore")
+		}
+		file, err := tfile.FromMedia(media, ctx.Raw)
+		if err != nil {
+			return nil, err
+		}
+		return file.Location(), nil
+	})
+}
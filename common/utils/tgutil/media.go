@@ -5,9 +5,13 @@ import (
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
 )
 
 func GetMediaFileName(media tg.MessageMediaClass) (string, error) {
+	if inner, ok := tfile.WebPageMedia(media); ok {
+		media = inner
+	}
 	switch v := media.(type) {
 	case *tg.MessageMediaPhoto:
 		f, ok := v.Photo.AsNotEmpty()
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf16"
 
@@ -20,6 +21,7 @@ import (
 	"github.com/krau/SaveAny-Bot/common/i18n"
 	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
 	"github.com/krau/SaveAny-Bot/common/utils/strutil"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
 	"github.com/rs/xid"
 )
 
@@ -28,6 +30,9 @@ import (
 // it will never return an empty string
 func GenFileNameFromMessage(message tg.Message) string {
 	ext := func(media tg.MessageMediaClass) string {
+		if inner, ok := tfile.WebPageMedia(media); ok {
+			media = inner
+		}
 		switch media := media.(type) {
 		case *tg.MessageMediaDocument:
 			doc, ok := media.Document.AsNotEmpty()
@@ -310,28 +315,67 @@ func getMessageByID(ctx *ext.Context, chatID int64, msgID int) (*tg.Message, err
 // f**k gotgproto's breaking changes
 func GetMessageByID(ctx *ext.Context, chatID int64, msgID int) (*tg.Message, error) {
 	// we don't know what the input chatID is bot api style(e.g. channel with -100 prefix) or plain tdlib style(no any prefix and every id is positive)
-	if msg, err := getMessageByID(ctx, chatID, msgID); err == nil {
-		return msg, nil
+	for _, id := range candidateChatIDs(chatID) {
+		if msg, err := getMessageByID(ctx, id, msgID); err == nil {
+			return msg, nil
+		}
 	}
-	in := constant.TDLibPeerID(chatID)
-	plain := in.ToPlain()
-	var channel constant.TDLibPeerID
-	channel.Channel(plain)
-	if msg, err := getMessageByID(ctx, int64(channel), msgID); err == nil {
-		return msg, nil
+	return nil, fmt.Errorf("failed to get message by ID: chatID=%d, msgID=%d", chatID, msgID)
+}
+
+// RefetchMessageByID is GetMessageByID skipping the cache, for messages whose cached copy is outdated, e.g. has an expired file reference.
+func RefetchMessageByID(ctx *ext.Context, chatID int64, msgID int) (*tg.Message, error) {
+	for _, id := range candidateChatIDs(chatID) {
+		cache.Delete(fmt.Sprintf("tgmsg:%d:%d:%d", ctx.Self.ID, id, msgID))
 	}
-	var chat constant.TDLibPeerID
+	return GetMessageByID(ctx, chatID, msgID)
+}
+
+// candidateChatIDs returns the chat ID as given, then as a channel, a chat and a user ID.
+func candidateChatIDs(chatID int64) []int64 {
+	plain := constant.TDLibPeerID(chatID).ToPlain()
+	var channel, chat, user constant.TDLibPeerID
+	channel.Channel(plain)
 	chat.Chat(plain)
-	if msg, err := getMessageByID(ctx, int64(chat), msgID); err == nil {
-		return msg, nil
+	user.User(plain)
+	return []int64{chatID, int64(channel), int64(chat), int64(user)}
+}
+
+// GetNthLatestMessageID returns the ID of the n-th newest message in a chat, 1 means the newest.
+// It returns 0 when the chat has fewer messages.
+func GetNthLatestMessageID(ctx *ext.Context, chatID int64, n int) (int, error) {
+	return getHistoryMessageID(ctx, chatID, 0, max(n-1, 0))
+}
+
+// GetLastMessageIDBefore returns the ID of the newest message sent before t, or 0 if there is none.
+func GetLastMessageIDBefore(ctx *ext.Context, chatID int64, t time.Time) (int, error) {
+	return getHistoryMessageID(ctx, chatID, int(t.Unix()), 0)
+}
+
+func getHistoryMessageID(ctx *ext.Context, chatID int64, offsetDate, addOffset int) (int, error) {
+	peer, err := ctx.ResolveInputPeerById(chatID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve chat %d: %w", chatID, err)
 	}
-	var userID constant.TDLibPeerID
-	userID.User(plain)
-	if msg, err := getMessageByID(ctx, int64(userID), msgID); err == nil {
-		return msg, nil
+	res, err := ctx.Raw.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+		Peer:       peer,
+		OffsetDate: offsetDate,
+		AddOffset:  addOffset,
+		Limit:      1,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get history of chat %d: %w", chatID, err)
 	}
-
-	return nil, fmt.Errorf("failed to get message by ID: chatID=%d, msgID=%d", chatID, msgID)
+	history, ok := res.AsModified()
+	if !ok {
+		return 0, fmt.Errorf("unexpected history type: %T", res)
+	}
+	for _, msg := range history.GetMessages() {
+		if _, empty := msg.(*tg.MessageEmpty); !empty {
+			return msg.GetID(), nil
+		}
+	}
+	return 0, nil
 }
 
 func GetGroupedMessages(ctx *ext.Context, chatID int64, msg *tg.Message) ([]*tg.Message, error) {
@@ -407,3 +451,77 @@ func ExtractMessageEntityUrlsText(msg *tg.Message) string {
 	}
 	return sb.String()
 }
+
+// MessagesFromUpdates returns the new messages in the updates returned by sending messages.
+func MessagesFromUpdates(updates tg.UpdatesClass) []*tg.Message {
+	var list []tg.UpdateClass
+	switch u := updates.(type) {
+	case *tg.Updates:
+		list = u.Updates
+	case *tg.UpdatesCombined:
+		list = u.Updates
+	case *tg.UpdateShort:
+		list = []tg.UpdateClass{u.Update}
+	}
+	msgs := make([]*tg.Message, 0, len(list))
+	for _, update := range list {
+		var msg tg.MessageClass
+		switch u := update.(type) {
+		case *tg.UpdateNewMessage:
+			msg = u.Message
+		case *tg.UpdateNewChannelMessage:
+			msg = u.Message
+		}
+		if m, ok := msg.(*tg.Message); ok {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// SentMessageID returns the ID of the message sent with the updates, 0 if it is not found.
+func SentMessageID(updates tg.UpdatesClass) int {
+	if sent, ok := updates.(*tg.UpdateShortSentMessage); ok {
+		return sent.ID
+	}
+	if msgs := MessagesFromUpdates(updates); len(msgs) > 0 {
+		return msgs[0].GetID()
+	}
+	return 0
+}
+
+// MessageLink returns the t.me link of a channel message, or an empty string for messages of other chats, which have no links.
+// Messages standing for stories get the link of the story.
+func MessageLink(ctx *ext.Context, msg *tg.Message) string {
+	if storyID, ok := tfile.StoryOf(msg); ok {
+		return storyLink(ctx, msg.GetPeerID(), storyID)
+	}
+	peer, ok := msg.GetPeerID().(*tg.PeerChannel)
+	if !ok {
+		return ""
+	}
+	post := strconv.Itoa(msg.GetID())
+	if topicID := MessageTopicID(msg); topicID != 0 {
+		post = fmt.Sprintf("%d/%s", topicID, post)
+	}
+	if ctx != nil {
+		for _, id := range candidateChatIDs(peer.ChannelID) {
+			if p := ctx.PeerStorage.GetPeerById(id); p != nil && p.Username != "" {
+				return fmt.Sprintf("https://t.me/%s/%s", p.Username, post)
+			}
+		}
+	}
+	return fmt.Sprintf("https://t.me/c/%d/%s", peer.ChannelID, post)
+}
+
+// MessageTopicID returns the forum topic of the message, 0 if it is not in a topic.
+func MessageTopicID(msg *tg.Message) int {
+	header, ok := msg.ReplyTo.(*tg.MessageReplyHeader)
+	if !ok || !header.ForumTopic {
+		return 0
+	}
+	if header.ReplyToTopID != 0 {
+		return header.ReplyToTopID
+	}
+	return header.ReplyToMsgID
+}
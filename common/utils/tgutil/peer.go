@@ -1,6 +1,16 @@
 package tgutil
 
-import "github.com/gotd/td/tg"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
+	"github.com/gotd/td/telegram/query/dialogs"
+	"github.com/gotd/td/tg"
+)
 
 func ChatIdFromPeer(peer tg.PeerClass) int64 {
 	switch peer := peer.(type) {
@@ -14,3 +24,83 @@ func ChatIdFromPeer(peer tg.PeerClass) int64 {
 		return 0
 	}
 }
+
+// SyncDialogPeers stores the peers of all dialogs of the account, so chats can be accessed by their ID. It returns the number of peers stored.
+func SyncDialogPeers(ctx context.Context, ectx *ext.Context) (int, error) {
+	peerStorage := ectx.PeerStorage
+	count := 0
+	err := dialogs.NewQueryBuilder(ectx.Raw).GetDialogs().BatchSize(50).ForEach(ctx, func(ctx context.Context, e dialogs.Elem) error {
+		for cid, channel := range e.Entities.Channels() {
+			peerStorage.AddPeer(cid, channel.AccessHash, storage.TypeChannel, channel.Username)
+			count++
+		}
+		for uid, user := range e.Entities.Users() {
+			peerStorage.AddPeer(uid, user.AccessHash, storage.TypeUser, user.Username)
+			count++
+		}
+		for gid := range e.Entities.Chats() {
+			peerStorage.AddPeer(gid, storage.DefaultAccessHash, storage.TypeChat, storage.DefaultUsername)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// chat titles rarely change, cache them for the lifetime of the process
+var peerTitles sync.Map // map[int64]string
+
+// GetPeerTitle returns the title of a channel or group, or the full name of a user.
+func GetPeerTitle(ctx *ext.Context, chatID int64) (string, error) {
+	if title, ok := peerTitles.Load(chatID); ok {
+		return title.(string), nil
+	}
+	title, err := getPeerTitle(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+	peerTitles.Store(chatID, title)
+	return title, nil
+}
+
+func getPeerTitle(ctx *ext.Context, chatID int64) (string, error) {
+	peer, err := ctx.ResolveInputPeerById(chatID)
+	if err != nil {
+		return "", err
+	}
+	switch p := peer.(type) {
+	case *tg.InputPeerChannel:
+		chats, err := ctx.Raw.ChannelsGetChannels(ctx, []tg.InputChannelClass{
+			&tg.InputChannel{ChannelID: p.ChannelID, AccessHash: p.AccessHash},
+		})
+		if err != nil {
+			return "", err
+		}
+		if chat, ok := chats.MapChats().First(); ok {
+			if ch, ok := chat.(*tg.Channel); ok {
+				return ch.Title, nil
+			}
+		}
+	case *tg.InputPeerChat:
+		chats, err := ctx.Raw.MessagesGetChats(ctx, []int64{p.ChatID})
+		if err != nil {
+			return "", err
+		}
+		if chat, ok := chats.MapChats().First(); ok {
+			if c, ok := chat.(*tg.Chat); ok {
+				return c.Title, nil
+			}
+		}
+	case *tg.InputPeerUser:
+		users, err := ctx.Raw.UsersGetUsers(ctx, []tg.InputUserClass{
+			&tg.InputUser{UserID: p.UserID, AccessHash: p.AccessHash},
+		})
+		if err != nil {
+			return "", err
+		}
+		if user, ok := tg.UserClassArray(users).FirstAsNotEmpty(); ok {
+			return strings.TrimSpace(user.FirstName + " " + user.LastName), nil
+		}
+	}
+	return "", fmt.Errorf("no title found for chat %d", chatID)
+}
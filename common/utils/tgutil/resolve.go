@@ -7,8 +7,10 @@ import (
 	"strings"
 
 	"github.com/celestix/gotgproto/ext"
+	"github.com/celestix/gotgproto/storage"
 	"github.com/duke-git/lancet/v2/validator"
 	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/cache"
 )
 
 func ParseChatID(ctx *ext.Context, idOrUsername string) (int64, error) {
@@ -39,81 +41,124 @@ func ParseChatID(ctx *ext.Context, idOrUsername string) (int64, error) {
 	return chatID, nil
 }
 
-// return: ChatID, MessageID, error
-func ParseMessageLink(ctx *ext.Context, link string) (int64, int, error) {
+// MessageLinkParts 是消息链接的各部分
+type MessageLinkParts struct {
+	Chat    string // 聊天 ID 或用户名
+	TopicID int    // 论坛话题 ID, 不是话题中的消息时为 0
+	MsgID   int
+	Comment int // 频道消息的评论 ID, 不是评论链接时为 0
+}
+
+// SplitMessageLink 解析消息链接的路径, 支持的格式:
+//   - https://t.me/acherkrau/123
+//   - https://t.me/c/123456789/123
+//   - https://t.me/acherkrau/111/123 , 111: topic id
+//   - https://t.me/c/123456789/111/123 , 111: topic id
+//   - https://t.me/c/123456789/123?thread=111 , 111: topic id
+//   - https://t.me/acherkrau/123?comment=2
+func SplitMessageLink(link string) (*MessageLinkParts, error) {
 	u, err := url.Parse(link)
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid URL: %w", err)
+		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
-	paths := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
-
-	if cmt := u.Query().Get("comment"); cmt != "" {
-		// 频道评论的消息链接
-		// https://t.me/acherkrau/123?comment=2
-		chid, err := ParseChatID(ctx, paths[0])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
-		}
-		chatfull, err := ctx.GetChat(chid)
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to get chat: %w", err)
-		}
-		chfull, ok := chatfull.(*tg.ChannelFull)
-		if !ok {
-			return 0, 0, fmt.Errorf("chat is not a channel: %s", chatfull.TypeName())
-		}
-		linkChatId, ok := chfull.GetLinkedChatID()
-		if !ok {
-			return 0, 0, fmt.Errorf("channel has no linked chat")
-		}
-		msgID, err := strconv.Atoi(cmt)
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse comment ID: %w", err)
-		}
-		return linkChatId, msgID, nil
+	paths := strings.Split(strings.Trim(u.Path, "/"), "/")
+	private := len(paths) > 0 && paths[0] == "c"
+	if private {
+		paths = paths[1:]
 	}
-
+	parts := &MessageLinkParts{}
+	var ids []string
 	switch len(paths) {
-	case 2: // https://t.me/acherkrau/123
-		chatID, err := ParseChatID(ctx, paths[0])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
-		}
-		msgID, err := strconv.Atoi(paths[1])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
-		}
-		return chatID, msgID, nil
+	case 2:
+		ids = []string{paths[1]}
 	case 3:
-		// https://t.me/c/123456789/123
-		// https://t.me/acherkrau/123/456 , 123: topic id
-		chatPart, msgPart := paths[1], paths[2]
-		if paths[0] != "c" {
-			chatPart = paths[0]
+		ids = []string{paths[1], paths[2]}
+	default:
+		return nil, fmt.Errorf("invalid message link format: %s", link)
+	}
+	parts.Chat = paths[0]
+	if parts.Chat == "" || (private && !validator.IsIntStr(parts.Chat)) {
+		return nil, fmt.Errorf("invalid chat in message link: %s", link)
+	}
+	for i, id := range ids {
+		n, err := strconv.Atoi(id)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid message ID in message link: %s", link)
 		}
-		chatID, err := ParseChatID(ctx, chatPart)
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
+		if i == len(ids)-1 {
+			parts.MsgID = n
+		} else {
+			parts.TopicID = n
 		}
-		msgID, err := strconv.Atoi(msgPart)
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
+	}
+	query := u.Query()
+	for _, key := range []string{"thread", "topic"} {
+		if v := query.Get(key); v != "" && parts.TopicID == 0 {
+			if parts.TopicID, err = strconv.Atoi(v); err != nil || parts.TopicID <= 0 {
+				return nil, fmt.Errorf("invalid topic ID in message link: %s", link)
+			}
 		}
-		return chatID, msgID, nil
-	case 4:
-		// https://t.me/c/123456789/111/456 111: topic id
-		if paths[0] != "c" {
-			return 0, 0, fmt.Errorf("invalid message link format: %s", link)
+	}
+	if cmt := query.Get("comment"); cmt != "" {
+		if parts.Comment, err = strconv.Atoi(cmt); err != nil || parts.Comment <= 0 {
+			return nil, fmt.Errorf("failed to parse comment ID: %s", cmt)
 		}
-		chatID, err := ParseChatID(ctx, paths[1])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
+	}
+	return parts, nil
+}
+
+// return: ChatID, MessageID, error
+func ParseMessageLink(ctx *ext.Context, link string) (int64, int, error) {
+	parts, err := SplitMessageLink(link)
+	if err != nil {
+		return 0, 0, err
+	}
+	chatID, err := ParseChatID(ctx, parts.Chat)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse chat ID: %w", err)
+	}
+	if parts.Comment == 0 {
+		return chatID, parts.MsgID, nil
+	}
+	// 频道评论的消息链接
+	// https://t.me/acherkrau/123?comment=2
+	msg, err := GetCommentMessage(ctx, chatID, parts.MsgID, parts.Comment)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ChatIdFromPeer(msg.GetPeerID()), msg.GetID(), nil
+}
+
+// GetCommentMessage returns a comment of a channel post, the message is in the discussion group of the channel.
+// The discussion group is stored to the peer storage, so the comment can be got again by its chat and ID.
+func GetCommentMessage(ctx *ext.Context, channelID int64, postID, commentID int) (*tg.Message, error) {
+	peer, err := ctx.ResolveInputPeerById(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve channel %d: %w", channelID, err)
+	}
+	res, err := ctx.Raw.MessagesGetReplies(ctx, &tg.MessagesGetRepliesRequest{
+		Peer:     peer,
+		MsgID:    postID,
+		OffsetID: commentID + 1,
+		Limit:    1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments of post %d: %w", postID, err)
+	}
+	replies, ok := res.AsModified()
+	if !ok {
+		return nil, fmt.Errorf("unexpected replies type: %T", res)
+	}
+	for _, chat := range replies.GetChats() {
+		if channel, ok := chat.(*tg.Channel); ok {
+			ctx.PeerStorage.AddPeer(channel.ID, channel.AccessHash, storage.TypeChannel, channel.Username)
 		}
-		msgID, err := strconv.Atoi(paths[3])
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to parse message ID: %w", err)
+	}
+	for _, m := range replies.GetMessages() {
+		if msg, ok := m.(*tg.Message); ok && msg.ID == commentID {
+			cache.Set(fmt.Sprintf("tgmsg:%d:%d:%d", ctx.Self.ID, ChatIdFromPeer(msg.PeerID), msg.ID), msg)
+			return msg, nil
 		}
-		return chatID, msgID, nil
 	}
-	return 0, 0, fmt.Errorf("invalid message link format: %s", link)
+	return nil, fmt.Errorf("comment %d of post %d not found", commentID, postID)
 }
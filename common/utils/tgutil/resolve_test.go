@@ -0,0 +1,84 @@
+package tgutil_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+)
+
+func TestSplitMessageLink(t *testing.T) {
+	tests := []struct {
+		link     string
+		expected *tgutil.MessageLinkParts
+	}{
+		{
+			link:     "https://t.me/acherkrau/123",
+			expected: &tgutil.MessageLinkParts{Chat: "acherkrau", MsgID: 123},
+		},
+		{
+			link:     "https://t.me/c/123456789/123",
+			expected: &tgutil.MessageLinkParts{Chat: "123456789", MsgID: 123},
+		},
+		{
+			link:     "https://t.me/acherkrau/111/123",
+			expected: &tgutil.MessageLinkParts{Chat: "acherkrau", TopicID: 111, MsgID: 123},
+		},
+		{
+			link:     "https://t.me/c/123456789/111/123",
+			expected: &tgutil.MessageLinkParts{Chat: "123456789", TopicID: 111, MsgID: 123},
+		},
+		{
+			link:     "https://t.me/c/123456789/123?thread=111",
+			expected: &tgutil.MessageLinkParts{Chat: "123456789", TopicID: 111, MsgID: 123},
+		},
+		{
+			link:     "https://t.me/c/123456789/111/123/",
+			expected: &tgutil.MessageLinkParts{Chat: "123456789", TopicID: 111, MsgID: 123},
+		},
+		{
+			link:     "https://t.me/acherkrau/123?comment=2",
+			expected: &tgutil.MessageLinkParts{Chat: "acherkrau", MsgID: 123, Comment: 2},
+		},
+	}
+	for _, test := range tests {
+		parts, err := tgutil.SplitMessageLink(test.link)
+		if err != nil {
+			t.Errorf("SplitMessageLink(%q) failed: %v", test.link, err)
+			continue
+		}
+		if !reflect.DeepEqual(parts, test.expected) {
+			t.Errorf("SplitMessageLink(%q) = %+v, expected %+v", test.link, parts, test.expected)
+		}
+	}
+
+	for _, link := range []string{
+		"https://t.me/acherkrau",
+		"https://t.me/c/123456789",
+		"https://t.me/c/acherkrau/123",
+		"https://t.me/c/123456789/topic/123",
+		"https://t.me/c/123456789/111/123/456",
+		"https://t.me/c/123456789/123?thread=abc",
+	} {
+		if _, err := tgutil.SplitMessageLink(link); err == nil {
+			t.Errorf("expected an error for %q", link)
+		}
+	}
+}
+
+func TestSplitStoryLink(t *testing.T) {
+	chat, storyID, err := tgutil.SplitStoryLink("https://t.me/acherkrau/s/12")
+	if err != nil || chat != "acherkrau" || storyID != 12 {
+		t.Errorf("SplitStoryLink = %q, %d, %v", chat, storyID, err)
+	}
+	for _, link := range []string{
+		"https://t.me/acherkrau/12",
+		"https://t.me/acherkrau/s/",
+		"https://t.me/acherkrau/s/abc",
+		"https://t.me/c/s/12",
+	} {
+		if _, _, err := tgutil.SplitStoryLink(link); err == nil {
+			t.Errorf("expected an error for %q", link)
+		}
+	}
+}
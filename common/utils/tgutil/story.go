@@ -0,0 +1,138 @@
+package tgutil
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// SplitStoryLink 解析故事链接, 返回用户名或聊天 ID 与故事 ID, 支持的格式:
+//   - https://t.me/acherkrau/s/123
+func SplitStoryLink(link string) (string, int, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid URL: %w", err)
+	}
+	paths := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(paths) != 3 || paths[0] == "" || paths[0] == "c" || paths[1] != "s" {
+		return "", 0, fmt.Errorf("invalid story link format: %s", link)
+	}
+	storyID, err := strconv.Atoi(paths[2])
+	if err != nil || storyID <= 0 {
+		return "", 0, fmt.Errorf("invalid story ID in story link: %s", link)
+	}
+	return paths[0], storyID, nil
+}
+
+// storyPeer returns the input peer and the peer of a chat posting stories, only users and channels post stories.
+func storyPeer(ctx *ext.Context, chatID int64) (tg.InputPeerClass, tg.PeerClass, error) {
+	input, err := ctx.ResolveInputPeerById(chatID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve chat %d: %w", chatID, err)
+	}
+	switch p := input.(type) {
+	case *tg.InputPeerUser:
+		return input, &tg.PeerUser{UserID: p.UserID}, nil
+	case *tg.InputPeerChannel:
+		return input, &tg.PeerChannel{ChannelID: p.ChannelID}, nil
+	case *tg.InputPeerSelf:
+		return input, &tg.PeerUser{UserID: ctx.Self.ID}, nil
+	}
+	return nil, nil, fmt.Errorf("chat %d has no stories", chatID)
+}
+
+func storyItems(items []tg.StoryItemClass) []*tg.StoryItem {
+	stories := make([]*tg.StoryItem, 0, len(items))
+	for _, item := range items {
+		if story, ok := item.(*tg.StoryItem); ok {
+			stories = append(stories, story)
+		}
+	}
+	return stories
+}
+
+// GetStory returns a story of a user or channel and the peer posting it.
+// Stories can only be got by users, ctx should be the userbot.
+func GetStory(ctx *ext.Context, chatID int64, storyID int) (*tg.StoryItem, tg.PeerClass, error) {
+	input, peer, err := storyPeer(ctx, chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := ctx.Raw.StoriesGetStoriesByID(ctx, &tg.StoriesGetStoriesByIDRequest{
+		Peer: input,
+		ID:   []int{storyID},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get story %d of chat %d: %w", storyID, chatID, err)
+	}
+	for _, story := range storyItems(res.Stories) {
+		if story.ID == storyID {
+			return story, peer, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("story %d of chat %d not found or expired", storyID, chatID)
+}
+
+// GetActiveStories returns the stories of a user or channel that have not expired yet and the peer posting them.
+func GetActiveStories(ctx *ext.Context, chatID int64) ([]*tg.StoryItem, tg.PeerClass, error) {
+	input, peer, err := storyPeer(ctx, chatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := ctx.Raw.StoriesGetPeerStories(ctx, input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get stories of chat %d: %w", chatID, err)
+	}
+	stories := storyItems(res.Stories.Stories)
+	// 列表中可能只有故事的 ID, 需要再次获取完整的故事
+	var skipped []int
+	for _, item := range res.Stories.Stories {
+		if story, ok := item.(*tg.StoryItemSkipped); ok {
+			skipped = append(skipped, story.ID)
+		}
+	}
+	if len(skipped) > 0 {
+		full, err := ctx.Raw.StoriesGetStoriesByID(ctx, &tg.StoriesGetStoriesByIDRequest{Peer: input, ID: skipped})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get stories of chat %d: %w", chatID, err)
+		}
+		stories = append(stories, storyItems(full.Stories)...)
+	}
+	return stories, peer, nil
+}
+
+// WithStoryRefresher returns a file option refreshing an expired file reference by getting the story again,
+// ctx must be the client the story was got with.
+func WithStoryRefresher(ctx *ext.Context, chatID int64, storyID int) tfile.TGFileOption {
+	return tfile.WithRefresher(func(context.Context) (tg.InputFileLocationClass, error) {
+		story, _, err := GetStory(ctx, chatID, storyID)
+		if err != nil {
+			return nil, err
+		}
+		file, err := tfile.FromMedia(story.Media, ctx.Raw)
+		if err != nil {
+			return nil, err
+		}
+		return file.Location(), nil
+	})
+}
+
+// storyLink returns the t.me link of a story, empty if the username of its peer is unknown.
+func storyLink(ctx *ext.Context, peer tg.PeerClass, storyID int) string {
+	if ctx == nil {
+		return ""
+	}
+	chatID := ChatIdFromPeer(peer)
+	for _, id := range candidateChatIDs(chatID) {
+		if p := ctx.PeerStorage.GetPeerById(id); p != nil && p.Username != "" {
+			return fmt.Sprintf("https://t.me/%s/s/%d", p.Username, storyID)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,11 @@
+package config
+
+type dashboardConfig struct {
+	Enable bool `toml:"enable" mapstructure:"enable" json:"enable"`
+	// PublicURL is the externally reachable base URL of the API server, used to build one-time login links
+	PublicURL string `toml:"public_url" mapstructure:"public_url" json:"public_url"`
+	// BotUsername enables the Telegram Login Widget on the login page when set
+	BotUsername string `toml:"bot_username" mapstructure:"bot_username" json:"bot_username"`
+	// SessionTTL is the lifetime of a dashboard login session in seconds
+	SessionTTL int `toml:"session_ttl" mapstructure:"session_ttl" json:"session_ttl"`
+}
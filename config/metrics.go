@@ -0,0 +1,8 @@
+package config
+
+type metricsConfig struct {
+	Enable bool `toml:"enable" mapstructure:"enable" json:"enable"`
+	// Listen is the address of a standalone metrics listener, e.g. "127.0.0.1:9090".
+	// When empty, /metrics is served by the API server behind its token
+	Listen string `toml:"listen" mapstructure:"listen" json:"listen"`
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"text/template"
 
 	storenum "github.com/krau/SaveAny-Bot/pkg/enums/storage"
 )
@@ -17,6 +18,14 @@ type TelegramStorageConfig struct {
 	// only effective when SkipLarge is false
 	// use zip when splitting
 	SplitSizeMB int64 `toml:"split_size_mb" mapstructure:"split_size_mb" json:"split_size_mb"`
+	// forum topic to send files to, 0 for the General topic or chats without topics
+	TopicID int `toml:"topic_id" mapstructure:"topic_id" json:"topic_id"`
+	// forum topics of directories, files saved to a directory are sent to its topic instead of TopicID
+	Topics map[string]int `toml:"topics" mapstructure:"topics" json:"topics"`
+	// create a topic named after the directory for directories not in Topics
+	AutoTopic bool `toml:"auto_topic" mapstructure:"auto_topic" json:"auto_topic"`
+	// text/template of the caption of uploaded files, leave empty to use the file name
+	CaptionTemplate string `toml:"caption_template" mapstructure:"caption_template" json:"caption_template"`
 }
 
 func (m *TelegramStorageConfig) Validate() error {
@@ -26,6 +35,19 @@ func (m *TelegramStorageConfig) Validate() error {
 	if m.RateLimit < 0 || m.RateBurst < 0 {
 		return fmt.Errorf("rate_limit and rate_burst must be greater than 0 for telegram storage")
 	}
+	if m.TopicID < 0 {
+		return fmt.Errorf("topic_id must not be negative for telegram storage")
+	}
+	for dir, topicID := range m.Topics {
+		if dir == "" || topicID <= 0 {
+			return fmt.Errorf("invalid topic %d of directory %q for telegram storage", topicID, dir)
+		}
+	}
+	if m.CaptionTemplate != "" {
+		if _, err := template.New("caption").Parse(m.CaptionTemplate); err != nil {
+			return fmt.Errorf("invalid caption_template for telegram storage: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -8,11 +8,33 @@ type telegramConfig struct {
 	RpcRetry          int           `toml:"rpc_retry" mapstructure:"rpc_retry" json:"rpc_retry"`
 	Userbot           userbotConfig `toml:"userbot" mapstructure:"userbot" json:"userbot"`
 	MediaGroupTimeout int           `toml:"media_group_timeout" mapstructure:"media_group_timeout" json:"media_group_timeout"`
+	Pool              []poolAccount `toml:"pool" mapstructure:"pool" json:"pool"`
+	BotAPI            botAPIConfig  `toml:"bot_api" mapstructure:"bot_api" json:"bot_api"`
 }
 
 type userbotConfig struct {
-	Enable  bool   `toml:"enable" mapstructure:"enable"`
-	Session string `toml:"session" mapstructure:"session"`
+	Enable         bool   `toml:"enable" mapstructure:"enable"`
+	Session        string `toml:"session" mapstructure:"session"`
+	DigestInterval int    `toml:"digest_interval" mapstructure:"digest_interval" json:"digest_interval"` // seconds between subscription digests
+	StoryInterval  int    `toml:"story_interval" mapstructure:"story_interval" json:"story_interval"`    // seconds between checks for new stories of watched chats
+}
+
+// poolAccount 是协助下载文件的账号, 配置了 token 时为 bot, 否则为 userbot
+type poolAccount struct {
+	Name    string `toml:"name" mapstructure:"name" json:"name"`
+	Token   string `toml:"token" mapstructure:"token" json:"token"`
+	Session string `toml:"session" mapstructure:"session" json:"session"`
+}
+
+func (a poolAccount) IsBot() bool {
+	return a.Token != ""
+}
+
+// botAPIConfig 是下载文件使用的 Bot API 服务器, 通常是自建的 telegram-bot-api
+type botAPIConfig struct {
+	Enable bool   `toml:"enable" mapstructure:"enable" json:"enable"`
+	URL    string `toml:"url" mapstructure:"url" json:"url"`
+	Dir    string `toml:"dir" mapstructure:"dir" json:"dir"` // 以 --local 模式运行的服务器的工作目录在本机的挂载路径
 }
 
 type tgProxyConfig struct {
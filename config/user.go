@@ -8,9 +8,11 @@ type userConfig struct {
 	ID        int64    `toml:"id" mapstructure:"id" json:"id"`                      // telegram user id
 	Storages  []string `toml:"storages" mapstructure:"storages" json:"storages"`    // storage names
 	Blacklist bool     `toml:"blacklist" mapstructure:"blacklist" json:"blacklist"` // 黑名单模式, storage names 中的存储将不会被使用, 默认为白名单模式
+	Admin     bool     `toml:"admin" mapstructure:"admin" json:"admin"`             // 管理员可以在面板中查看和管理所有用户的任务
 }
 
 var userIDs []int64
+var adminIDs []int64
 var storages []string
 var userStorages = make(map[int64][]string)
 
@@ -26,6 +28,11 @@ func (c Config) GetUsersID() []int64 {
 	return userIDs
 }
 
+// IsAdmin reports whether the user manages the tasks of all users
+func (c Config) IsAdmin(userID int64) bool {
+	return slice.Contain(adminIDs, userID)
+}
+
 func (c Config) HasStorage(userID int64, storageName string) bool {
 	us, ok := userStorages[userID]
 	if !ok {
@@ -16,16 +16,17 @@ import (
 )
 
 type Config struct {
-	Lang         string      `toml:"lang" mapstructure:"lang" json:"lang"`
-	Workers      int         `toml:"workers" mapstructure:"workers"`
-	Retry        int         `toml:"retry" mapstructure:"retry"`
-	NoCleanCache bool        `toml:"no_clean_cache" mapstructure:"no_clean_cache" json:"no_clean_cache"`
-	Threads      int         `toml:"threads" mapstructure:"threads" json:"threads"`
-	Stream       bool        `toml:"stream" mapstructure:"stream" json:"stream"`
-	Proxy        string      `toml:"proxy" mapstructure:"proxy" json:"proxy"`
-	Log          logConfig   `toml:"log" mapstructure:"log" json:"log"`
-	Aria2        aria2Config `toml:"aria2" mapstructure:"aria2" json:"aria2"`
-	API          apiConfig   `toml:"api" mapstructure:"api" json:"api"`
+	Lang         string        `toml:"lang" mapstructure:"lang" json:"lang"`
+	Workers      int           `toml:"workers" mapstructure:"workers"`
+	Retry        int           `toml:"retry" mapstructure:"retry"`
+	NoCleanCache bool          `toml:"no_clean_cache" mapstructure:"no_clean_cache" json:"no_clean_cache"`
+	Threads      int           `toml:"threads" mapstructure:"threads" json:"threads"`
+	Stream       bool          `toml:"stream" mapstructure:"stream" json:"stream"`
+	Proxy        string        `toml:"proxy" mapstructure:"proxy" json:"proxy"`
+	Log          logConfig     `toml:"log" mapstructure:"log" json:"log"`
+	Aria2        aria2Config   `toml:"aria2" mapstructure:"aria2" json:"aria2"`
+	API          apiConfig     `toml:"api" mapstructure:"api" json:"api"`
+	Metrics      metricsConfig `toml:"metrics" mapstructure:"metrics" json:"metrics"`
 
 	Cache    cacheConfig             `toml:"cache" mapstructure:"cache" json:"cache"`
 	Users    []userConfig            `toml:"users" mapstructure:"users" json:"users"`
@@ -50,6 +51,31 @@ type apiConfig struct {
 	Host   string `toml:"host" mapstructure:"host" json:"host"`
 	Port   int    `toml:"port" mapstructure:"port" json:"port"`
 	Token  string `toml:"token" mapstructure:"token" json:"token"`
+	// IdempotencyTTL is how long (in seconds) an Idempotency-Key is remembered
+	IdempotencyTTL int `toml:"idempotency_ttl" mapstructure:"idempotency_ttl" json:"idempotency_ttl"`
+	// MaxBatchSize limits the number of tasks accepted by one batch request
+	MaxBatchSize int `toml:"max_batch_size" mapstructure:"max_batch_size" json:"max_batch_size"`
+
+	Dashboard dashboardConfig `toml:"dashboard" mapstructure:"dashboard" json:"dashboard"`
+	GRPC      grpcConfig      `toml:"grpc" mapstructure:"grpc" json:"grpc"`
+	Limit     apiLimitConfig  `toml:"limit" mapstructure:"limit" json:"limit"`
+}
+
+type apiLimitConfig struct {
+	// RequestsPerMinute limits API requests per token, 0 means unlimited
+	RequestsPerMinute int `toml:"requests_per_minute" mapstructure:"requests_per_minute" json:"requests_per_minute"`
+	// TasksPerMinute limits task creation per token, 0 means unlimited
+	TasksPerMinute int `toml:"tasks_per_minute" mapstructure:"tasks_per_minute" json:"tasks_per_minute"`
+	// MaxBodySize is the maximum request body size in bytes
+	MaxBodySize int64 `toml:"max_body_size" mapstructure:"max_body_size" json:"max_body_size"`
+	// MaxURLs is the maximum number of URLs or message links in one task, 0 means unlimited
+	MaxURLs int `toml:"max_urls" mapstructure:"max_urls" json:"max_urls"`
+}
+
+type grpcConfig struct {
+	Enable bool `toml:"enable" mapstructure:"enable" json:"enable"`
+	// Port is the gRPC listen port, it binds to the same host as the HTTP API
+	Port int `toml:"port" mapstructure:"port" json:"port"`
 }
 
 var cfg = &Config{}
@@ -114,11 +140,14 @@ func Init(ctx context.Context, configFile ...string) error {
 		"cache.max_cost":     1e6,
 
 		// Telegram
-		"telegram.app_id":          1025907,
-		"telegram.app_hash":        "452b0359b988148995f22ff0f4229750",
-		"telegram.rpc_retry":       5,
-		"telegram.userbot.enable":  false,
-		"telegram.userbot.session": "data/usersession.db",
+		"telegram.app_id":                  1025907,
+		"telegram.app_hash":                "452b0359b988148995f22ff0f4229750",
+		"telegram.rpc_retry":               5,
+		"telegram.userbot.enable":          false,
+		"telegram.userbot.session":         "data/usersession.db",
+		"telegram.userbot.digest_interval": 600,
+		"telegram.userbot.story_interval":  600,
+		"telegram.bot_api.url":             "http://localhost:8081",
 
 		// 临时目录
 		"temp.base_path": "cache/",
@@ -133,6 +162,24 @@ func Init(ctx context.Context, configFile ...string) error {
 		"api.port":   8080,
 		"api.token":  "",
 
+		"api.idempotency_ttl": 86400,
+		"api.max_batch_size":  100,
+
+		"api.dashboard.enable":      false,
+		"api.dashboard.session_ttl": 604800,
+
+		"api.grpc.enable": false,
+		"api.grpc.port":   9090,
+
+		"api.limit.requests_per_minute": 120,
+		"api.limit.tasks_per_minute":    30,
+		"api.limit.max_body_size":       1 << 20,
+		"api.limit.max_urls":            100,
+
+		// Metrics
+		"metrics.enable": false,
+		"metrics.listen": "",
+
 		// yt-dlp
 		"ytdlp.recode": "mp4",
 	}
@@ -165,6 +212,20 @@ func Init(ctx context.Context, configFile ...string) error {
 		storageNames[storage.GetName()] = struct{}{}
 	}
 
+	poolNames := make(map[string]struct{})
+	for i, account := range cfg.Telegram.Pool {
+		if account.Name == "" {
+			return fmt.Errorf("telegram pool account %d has no name", i)
+		}
+		if _, ok := poolNames[account.Name]; ok {
+			return fmt.Errorf("duplicate telegram pool account name: %s", account.Name)
+		}
+		poolNames[account.Name] = struct{}{}
+		if account.Session == "" {
+			cfg.Telegram.Pool[i].Session = fmt.Sprintf("data/pool_%s.db", account.Name)
+		}
+	}
+
 	if cfg.Workers < 1 {
 		cfg.Workers = 1
 	}
@@ -180,6 +241,9 @@ func Init(ctx context.Context, configFile ...string) error {
 	}
 	for _, user := range cfg.Users {
 		userIDs = append(userIDs, user.ID)
+		if user.Admin {
+			adminIDs = append(adminIDs, user.ID)
+		}
 		if user.Blacklist {
 			userStorages[user.ID] = slice.Compact(slice.Difference(storages, user.Storages))
 		} else {
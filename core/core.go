@@ -5,6 +5,7 @@ import (
 	"errors"
 
 	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/common/metrics"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
 	"github.com/krau/SaveAny-Bot/pkg/queue"
@@ -33,18 +34,23 @@ func worker(ctx context.Context, qe *queue.TaskQueue[Executable], semaphore chan
 		exe := qtask.Data
 		taskCtx := qtask.Context()
 		logger.Infof("Processing task: %s", exe.TaskID())
+		metrics.WorkersBusy.Inc()
 		taskevent.Emit(taskCtx, taskevent.Event{TaskID: exe.TaskID(), Phase: taskevent.PhaseStart})
 		if err := ExecCommandString(taskCtx, execHooks.TaskBeforeStart); err != nil {
 			logger.Errorf("Failed to execute before start hook for task %s: %v", exe.TaskID(), err)
 		}
 		err = exe.Execute(taskCtx)
+		metrics.WorkersBusy.Dec()
+		status := metrics.TaskStatusSucceeded
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
+				status = metrics.TaskStatusCanceled
 				logger.Infof("Task %s was canceled", exe.TaskID())
 				if err := ExecCommandString(ctx, execHooks.TaskCancel); err != nil {
 					logger.Errorf("Failed to execute cancel hook for task %s: %v", exe.TaskID(), err)
 				}
 			} else {
+				status = metrics.TaskStatusFailed
 				logger.Errorf("Failed to execute task %s: %v", exe.TaskID(), err)
 				if err := ExecCommandString(ctx, execHooks.TaskFail); err != nil {
 					logger.Errorf("Failed to execute fail hook for task %s: %v", exe.TaskID(), err)
@@ -56,6 +62,7 @@ func worker(ctx context.Context, qe *queue.TaskQueue[Executable], semaphore chan
 				logger.Errorf("Failed to execute success hook for task %s: %v", exe.TaskID(), err)
 			}
 		}
+		metrics.TasksTotal.WithLabelValues(string(exe.Type()), status).Inc()
 		taskevent.Emit(taskCtx, taskevent.Event{TaskID: exe.TaskID(), Phase: taskevent.PhaseDone, Err: err})
 		qe.Done(qtask.ID)
 		<-semaphore
@@ -68,14 +75,36 @@ func Run(ctx context.Context) {
 	if queueInstance == nil {
 		queueInstance = queue.NewTaskQueue[Executable]()
 	}
+	metrics.Workers.Set(float64(config.C().Workers))
+	metrics.SetQueueLength(queueInstance.ActiveLength)
 	for range config.C().Workers {
 		go worker(ctx, queueInstance, semaphore)
 	}
 
 }
 
+type ownerKey struct{}
+
+// WithOwner marks the tasks added with the context as tasks of the user of chatID.
+func WithOwner(ctx context.Context, chatID int64) context.Context {
+	return context.WithValue(ctx, ownerKey{}, chatID)
+}
+
+func newTask(ctx context.Context, task Executable) *queue.Task[Executable] {
+	t := queue.NewTask(ctx, task.TaskID(), task.Title(), task)
+	t.Owner, _ = ctx.Value(ownerKey{}).(int64)
+	return t
+}
+
 func AddTask(ctx context.Context, task Executable) error {
-	return queueInstance.Add(queue.NewTask(ctx, task.TaskID(), task.Title(), task))
+	return queueInstance.Add(newTask(ctx, task))
+}
+
+// AddLowPriorityTask adds a task that only runs after all normal tasks queued before it.
+func AddLowPriorityTask(ctx context.Context, task Executable) error {
+	t := newTask(ctx, task)
+	t.LowPriority = true
+	return queueInstance.Add(t)
 }
 
 func CancelTask(ctx context.Context, id string) error {
@@ -88,9 +117,15 @@ func GetLength(ctx context.Context) int {
 }
 
 func GetRunningTasks(ctx context.Context) []queue.TaskInfo {
+	if queueInstance == nil {
+		return nil
+	}
 	return queueInstance.RunningTasks()
 }
 
 func GetQueuedTasks(ctx context.Context) []queue.TaskInfo {
+	if queueInstance == nil {
+		return nil
+	}
 	return queueInstance.QueuedTasks()
 }
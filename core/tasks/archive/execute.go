@@ -0,0 +1,245 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/duke-git/lancet/v2/retry"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/tdler"
+	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/pkg/taskevent"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/pkg/tgarchive"
+	"github.com/krau/SaveAny-Bot/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+func (t *Task) Execute(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithPrefix(fmt.Sprintf("archive[%s]", t.ID))
+	logger.Infof("Starting archive of chat %d from message %d", t.ChatID, t.state.LastMessageID+1)
+	t.title = strconv.FormatInt(t.ChatID, 10)
+	if title, err := tgutil.GetPeerTitle(t.client, t.ChatID); err != nil {
+		logger.Warnf("Failed to get title of chat %d: %s", t.ChatID, err)
+	} else if title != "" {
+		t.title = title
+	}
+	t.progress.OnStart(ctx, t)
+	err := t.archive(ctx)
+	if err != nil {
+		logger.Errorf("Archive of chat %d failed: %v", t.ChatID, err)
+	} else {
+		logger.Infof("Archive of chat %d completed, %d messages and %d files", t.ChatID, t.messages.Load(), t.files.Load())
+	}
+	t.progress.OnDone(ctx, t, err)
+	return err
+}
+
+// archivedPage 是已写入 JSON 和 Markdown, 但还不知道下一页而未渲染 HTML 的页
+type archivedPage struct {
+	page int
+	msgs []tgarchive.Message
+}
+
+func (t *Task) archive(ctx context.Context) error {
+	latest, err := tgutil.GetNthLatestMessageID(t.client, t.ChatID, 1)
+	if err != nil {
+		return err
+	}
+	t.latestID.Store(int64(latest))
+	chat := tgarchive.Chat{ID: t.ChatID, Title: t.title}
+	state := t.state
+	// The page of the last archived message is archived again, it may have new messages and its viewer page has no next link yet
+	first := tgarchive.PageOf(state.LastMessageID)
+	prev := -1
+	for _, page := range state.Pages {
+		if page < first {
+			prev = page
+		}
+	}
+
+	var pending *archivedPage
+	flush := func(next int) error {
+		if pending == nil {
+			return nil
+		}
+		var buf bytes.Buffer
+		if err := tgarchive.RenderPageHTML(&buf, chat, pending.page, prev, next, pending.msgs); err != nil {
+			return err
+		}
+		if err := t.writeFile(ctx, tgarchive.PageHTMLPath(pending.page), buf.Bytes()); err != nil {
+			return err
+		}
+		prev = pending.page
+		pending = nil
+		return nil
+	}
+
+	for page := first; page <= tgarchive.PageOf(latest); page++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		msgs, err := t.archivePage(ctx, page, latest)
+		if err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+		if err := flush(page); err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(msgs); err != nil {
+			return err
+		}
+		if err := t.writeFile(ctx, tgarchive.PageJSONPath(page), buf.Bytes()); err != nil {
+			return err
+		}
+		buf.Reset()
+		if err := tgarchive.RenderMarkdown(&buf, chat, page, msgs); err != nil {
+			return err
+		}
+		if err := t.writeFile(ctx, tgarchive.PageMarkdownPath(page), buf.Bytes()); err != nil {
+			return err
+		}
+		pending = &archivedPage{page: page, msgs: msgs}
+		if !slices.Contains(state.Pages, page) {
+			state.Pages = append(state.Pages, page)
+		}
+		state.LastMessageID = msgs[len(msgs)-1].ID
+		if err := t.store.SaveState(ctx, state); err != nil {
+			return fmt.Errorf("failed to save archive state: %w", err)
+		}
+		t.progress.OnProgress(ctx, t)
+		taskevent.Emit(ctx, taskevent.Event{
+			TaskID:          t.ID,
+			Phase:           taskevent.PhaseProgress,
+			DownloadedFiles: int(t.files.Load()),
+		})
+	}
+	if err := flush(-1); err != nil {
+		return err
+	}
+
+	chat.LastMessageID = max(state.LastMessageID, latest)
+	chat.ArchivedAt = time.Now()
+	content, err := json.MarshalIndent(chat, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := t.writeFile(ctx, tgarchive.ChatFile, content); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	slices.Sort(state.Pages)
+	if err := tgarchive.RenderIndexHTML(&buf, chat, state.Pages); err != nil {
+		return err
+	}
+	if err := t.writeFile(ctx, tgarchive.IndexFile, buf.Bytes()); err != nil {
+		return err
+	}
+	state.LastMessageID = chat.LastMessageID
+	return t.store.SaveState(ctx, state)
+}
+
+// archivePage fetches the messages of a page and saves their files, files that already exist are not downloaded again.
+func (t *Task) archivePage(ctx context.Context, page, latest int) ([]tgarchive.Message, error) {
+	logger := log.FromContext(ctx)
+	start, end := tgarchive.PageRange(page)
+	msgs, err := tgutil.GetMessagesRange(t.client, t.ChatID, start, min(end, latest))
+	if err != nil {
+		return nil, err
+	}
+	msgs = slices.DeleteFunc(msgs, func(msg *tg.Message) bool { return msg == nil })
+	slices.SortFunc(msgs, func(a, b *tg.Message) int { return a.GetID() - b.GetID() })
+
+	result := make([]tgarchive.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		t.currentID.Store(int64(msg.GetID()))
+		m := tgarchive.FromMessage(msg)
+		m.Links = tgutil.ExtractMessageEntityUrls(msg)
+		if media, ok := msg.GetMedia(); ok {
+			file, err := tfile.FromMediaMessage(media, t.client.Raw, msg, tfile.WithNameIfEmpty(tgutil.GenFileNameFromMessage(*msg)), tgutil.WithFileRefresher(t.client, msg))
+			if err == nil {
+				m.Media.Name = file.Name()
+				m.Media.File = tgarchive.MediaPath(msg.GetID(), fsutil.NormalizePathname(file.Name()))
+				if err := t.saveMedia(ctx, file, m.Media.File); err != nil {
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					logger.Warnf("Failed to save file of message %d: %s", msg.GetID(), err)
+					m.Media.File, m.Media.Error = "", err.Error()
+				} else {
+					t.files.Add(1)
+				}
+			}
+		}
+		result = append(result, m)
+		t.messages.Add(1)
+	}
+	return result, nil
+}
+
+func (t *Task) writeFile(ctx context.Context, rel string, content []byte) error {
+	vctx := context.WithValue(storage.WithOverwrite(ctx), ctxkey.ContentLength, int64(len(content)))
+	return retry.Retry(func() error {
+		return storage.Save(vctx, t.Stor, bytes.NewReader(content), path.Join(t.StorPath, rel))
+	}, retry.Context(ctx), retry.RetryTimes(uint(config.C().Retry)))
+}
+
+func (t *Task) saveMedia(ctx context.Context, file tfile.TGFile, rel string) error {
+	storPath := path.Join(t.StorPath, rel)
+	if t.Stor.Exists(ctx, storPath) {
+		return nil
+	}
+	return retry.Retry(func() error {
+		if config.C().Stream && !t.cannotStream {
+			pr, pw := io.Pipe()
+			defer pr.Close()
+			eg, gctx := errgroup.WithContext(ctx)
+			eg.Go(func() error {
+				return storage.Save(gctx, t.Stor, pr, storPath)
+			})
+			eg.Go(func() error {
+				_, err := tdler.NewDownloader(file).Stream(gctx, pw)
+				pw.CloseWithError(err)
+				return err
+			})
+			return eg.Wait()
+		}
+		localFile, err := fsutil.CreateFile(filepath.Join(config.C().Temp.BasePath, fmt.Sprintf("archive_%s_%s", t.ID, path.Base(rel))))
+		if err != nil {
+			return fmt.Errorf("failed to create cache file: %w", err)
+		}
+		defer func() {
+			if err := localFile.CloseAndRemove(); err != nil {
+				log.FromContext(ctx).Errorf("Failed to remove cache file: %v", err)
+			}
+		}()
+		if _, err := tdler.NewDownloader(file).Parallel(ctx, localFile); err != nil {
+			return fmt.Errorf("failed to download file: %w", err)
+		}
+		var stat os.FileInfo
+		if stat, err = localFile.Stat(); err != nil {
+			return err
+		}
+		if _, err := localFile.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return storage.Save(context.WithValue(ctx, ctxkey.ContentLength, stat.Size()), t.Stor, localFile, storPath)
+	}, retry.Context(ctx), retry.RetryTimes(uint(config.C().Retry)))
+}
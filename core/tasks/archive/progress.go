@@ -0,0 +1,117 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/message/entity"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/i18n"
+	"github.com/krau/SaveAny-Bot/common/i18n/i18nk"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+)
+
+type ProgressTracker interface {
+	OnStart(ctx context.Context, info TaskInfo)
+	OnProgress(ctx context.Context, info TaskInfo)
+	OnDone(ctx context.Context, info TaskInfo, err error)
+}
+
+type Progress struct {
+	MessageID int
+	ChatID    int64
+}
+
+func (p *Progress) OnStart(ctx context.Context, info TaskInfo) {
+	log.FromContext(ctx).Debugf("Archive task progress tracking started for message %d in chat %d", p.MessageID, p.ChatID)
+	p.edit(ctx, info, i18n.T(i18nk.BotMsgProgressArchiveStart, map[string]any{
+		"Chat": info.ChatTitle(),
+	}), true)
+}
+
+func (p *Progress) OnProgress(ctx context.Context, info TaskInfo) {
+	log.FromContext(ctx).Debugf("Progress update: %s, %d/%d", info.TaskID(), info.CurrentMessageID(), info.LatestMessageID())
+	p.edit(ctx, info, i18n.T(i18nk.BotMsgProgressArchiveProgress, map[string]any{
+		"Chat":     info.ChatTitle(),
+		"Current":  info.CurrentMessageID(),
+		"Latest":   info.LatestMessageID(),
+		"Messages": info.ArchivedMessages(),
+		"Files":    info.SavedFiles(),
+	}), true)
+}
+
+func (p *Progress) OnDone(ctx context.Context, info TaskInfo, err error) {
+	logger := log.FromContext(ctx)
+	ext := tgutil.ExtFromContext(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			logger.Infof("Archive task %s was canceled", info.TaskID())
+			if ext != nil {
+				ext.EditMessage(p.ChatID, &tg.MessagesEditMessageRequest{
+					ID: p.MessageID,
+					Message: i18n.T(i18nk.BotMsgProgressTaskCanceledWithId, map[string]any{
+						"TaskID": info.TaskID(),
+					}),
+				})
+			}
+			return
+		}
+		logger.Errorf("Archive task %s failed: %s", info.TaskID(), err)
+		if ext != nil {
+			ext.EditMessage(p.ChatID, &tg.MessagesEditMessageRequest{
+				ID: p.MessageID,
+				Message: i18n.T(i18nk.BotMsgProgressTaskFailedWithError, map[string]any{
+					"Error": err.Error(),
+				}),
+			})
+		}
+		return
+	}
+	p.edit(ctx, info, i18n.T(i18nk.BotMsgProgressArchiveDone, map[string]any{
+		"Chat":     info.ChatTitle(),
+		"Messages": info.ArchivedMessages(),
+		"Files":    info.SavedFiles(),
+	}), false)
+}
+
+func (p *Progress) edit(ctx context.Context, info TaskInfo, text string, cancelable bool) {
+	entityBuilder := entity.Builder{}
+	if err := styling.Perform(&entityBuilder,
+		styling.Plain(text),
+		styling.Plain(i18n.T(i18nk.BotMsgProgressSavePathPrefix, nil)),
+		styling.Code(fmt.Sprintf("[%s]:%s", info.StorageName(), info.StoragePath())),
+	); err != nil {
+		log.FromContext(ctx).Errorf("Failed to build entities: %s", err)
+		return
+	}
+	text, entities := entityBuilder.Complete()
+	req := &tg.MessagesEditMessageRequest{
+		ID: p.MessageID,
+	}
+	req.SetMessage(text)
+	req.SetEntities(entities)
+	if cancelable {
+		req.SetReplyMarkup(&tg.ReplyInlineMarkup{
+			Rows: []tg.KeyboardButtonRow{
+				{
+					Buttons: []tg.KeyboardButtonClass{
+						tgutil.BuildCancelButton(info.TaskID()),
+					},
+				},
+			}},
+		)
+	}
+	if ext := tgutil.ExtFromContext(ctx); ext != nil {
+		ext.EditMessage(p.ChatID, req)
+	}
+}
+
+func NewProgress(messageID int, chatID int64) *Progress {
+	return &Progress{
+		MessageID: messageID,
+		ChatID:    chatID,
+	}
+}
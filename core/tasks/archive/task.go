@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/krau/SaveAny-Bot/core"
+	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+var _ core.Executable = (*Task)(nil)
+
+// State 是归档的进度, 再次归档时从 LastMessageID 所在的页继续
+type State struct {
+	LastMessageID int
+	Pages         []int // pages that have messages
+}
+
+// StateStore persists the state after every page, so an interrupted archive can be resumed.
+type StateStore interface {
+	SaveState(ctx context.Context, state State) error
+}
+
+type Task struct {
+	ID       string
+	Ctx      context.Context
+	ChatID   int64
+	Stor     storage.Storage
+	StorPath string // archive root
+	client   *ext.Context
+	state    State
+	store    StateStore
+	progress ProgressTracker
+
+	cannotStream bool
+	title        string
+	latestID     atomic.Int64
+	currentID    atomic.Int64
+	messages     atomic.Int64
+	files        atomic.Int64
+}
+
+// Title implements core.Exectable.
+func (t *Task) Title() string {
+	return fmt.Sprintf("[%s](%d->%s:%s)", t.Type(), t.ChatID, t.Stor.Name(), t.StorPath)
+}
+
+func (t *Task) Type() tasktype.TaskType {
+	return tasktype.TaskTypeArchive
+}
+
+// NewTask creates a task archiving a chat, client must be the userbot since bots cannot read the history.
+func NewTask(
+	id string,
+	ctx context.Context,
+	chatID int64,
+	stor storage.Storage,
+	storPath string,
+	client *ext.Context,
+	state State,
+	store StateStore,
+	progress ProgressTracker,
+) *Task {
+	_, cannotStream := stor.(storage.StorageCannotStream)
+	return &Task{
+		ID:           id,
+		Ctx:          ctx,
+		ChatID:       chatID,
+		Stor:         stor,
+		StorPath:     storPath,
+		client:       client,
+		state:        state,
+		store:        store,
+		progress:     progress,
+		cannotStream: cannotStream,
+	}
+}
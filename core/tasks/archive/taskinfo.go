@@ -0,0 +1,44 @@
+package archive
+
+type TaskInfo interface {
+	TaskID() string
+	ChatTitle() string
+	CurrentMessageID() int
+	LatestMessageID() int
+	ArchivedMessages() int64
+	SavedFiles() int64
+	StorageName() string
+	StoragePath() string
+}
+
+func (t *Task) TaskID() string {
+	return t.ID
+}
+
+func (t *Task) ChatTitle() string {
+	return t.title
+}
+
+func (t *Task) CurrentMessageID() int {
+	return int(t.currentID.Load())
+}
+
+func (t *Task) LatestMessageID() int {
+	return int(t.latestID.Load())
+}
+
+func (t *Task) ArchivedMessages() int64 {
+	return t.messages.Load()
+}
+
+func (t *Task) SavedFiles() int64 {
+	return t.files.Load()
+}
+
+func (t *Task) StorageName() string {
+	return t.Stor.Name()
+}
+
+func (t *Task) StoragePath() string {
+	return t.StorPath
+}
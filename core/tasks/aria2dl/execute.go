@@ -14,6 +14,7 @@ import (
 	"github.com/krau/SaveAny-Bot/pkg/aria2"
 	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
 	"github.com/krau/SaveAny-Bot/pkg/taskevent"
+	"github.com/krau/SaveAny-Bot/storage"
 )
 
 // Execute implements core.Executable.
@@ -213,7 +214,7 @@ func (t *Task) transferFile(ctx context.Context, filePath string) error {
 
 	logger.Infof("Transferring file %s to %s:%s", fileName, t.Storage.Name(), destPath)
 
-	if err := t.Storage.Save(ctx, f, destPath); err != nil {
+	if err := storage.Save(ctx, t.Storage, f, destPath); err != nil {
 		return fmt.Errorf("failed to save file %s to storage: %w", fileName, err)
 	}
 
@@ -0,0 +1,119 @@
+package batchtfile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/log"
+	"github.com/duke-git/lancet/v2/retry"
+	"github.com/krau/SaveAny-Bot/common/tdler"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/storagetypes"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
+)
+
+// album 是要作为相册保存到同一存储的媒体组文件
+type album struct {
+	groupedID int64
+	storage   storage.StorageAlbum
+	elems     []TaskElement
+}
+
+// groupAlbums 将要保存到支持相册的存储的媒体组文件分组, 返回其余的文件和各个相册
+func (t *Task) groupAlbums() ([]TaskElement, []album) {
+	singles := make([]TaskElement, 0, len(t.elems))
+	albums := make([]album, 0)
+	index := make(map[string]int)
+	for _, elem := range t.elems {
+		stor, ok := elem.Storage.(storage.StorageAlbum)
+		msg := tfile.MessageOf(elem.File)
+		if !ok || elem.stream || msg == nil || msg.GroupedID == 0 {
+			singles = append(singles, elem)
+			continue
+		}
+		key := fmt.Sprintf("%s:%d:%t", stor.Name(), msg.GroupedID, elem.Overwrite)
+		if i, ok := index[key]; ok {
+			albums[i].elems = append(albums[i].elems, elem)
+			continue
+		}
+		index[key] = len(albums)
+		albums = append(albums, album{groupedID: msg.GroupedID, storage: stor, elems: []TaskElement{elem}})
+	}
+	grouped := albums[:0]
+	for _, a := range albums {
+		// 只有一个文件的媒体组按普通文件保存
+		if len(a.elems) == 1 {
+			singles = append(singles, a.elems[0])
+			continue
+		}
+		slices.SortStableFunc(a.elems, func(x, y TaskElement) int {
+			return tfile.MessageOf(x.File).GetID() - tfile.MessageOf(y.File).GetID()
+		})
+		grouped = append(grouped, a)
+	}
+	return singles, grouped
+}
+
+// processAlbum 下载媒体组的所有文件后将其作为相册保存
+func (t *Task) processAlbum(ctx context.Context, a album) error {
+	logger := log.FromContext(ctx).WithPrefix(fmt.Sprintf("album[%d]", a.groupedID))
+	if a.elems[0].Overwrite {
+		ctx = storage.WithOverwrite(ctx)
+	}
+	t.processingMu.Lock()
+	for i := range a.elems {
+		t.processing[a.elems[i].ID] = &a.elems[i]
+	}
+	t.processingMu.Unlock()
+	defer func() {
+		t.processingMu.Lock()
+		for _, elem := range a.elems {
+			delete(t.processing, elem.ID)
+		}
+		t.processingMu.Unlock()
+	}()
+
+	var err error
+	for i := range a.elems {
+		elem := &a.elems[i]
+		unlock := tdler.LockCache(elem.localPath)
+		defer unlock()
+		defer func() {
+			t.removeCache(ctx, logger, elem, err)
+		}()
+		logger.Infof("Starting download of %s", elem.FileName())
+		if err = t.download(ctx, elem); err != nil {
+			return err
+		}
+	}
+	logger.Infof("Album of %d files downloaded successfully", len(a.elems))
+	err = retry.Retry(func() error {
+		files := make([]storagetypes.AlbumFile, 0, len(a.elems))
+		for _, elem := range a.elems {
+			file, err := os.Open(elem.localPath)
+			if err != nil {
+				return fmt.Errorf("failed to open cache file: %w", err)
+			}
+			defer file.Close()
+			stat, err := file.Stat()
+			if err != nil {
+				return fmt.Errorf("failed to get file stat: %w", err)
+			}
+			files = append(files, storagetypes.AlbumFile{
+				Reader:  file,
+				Path:    elem.Path,
+				Size:    stat.Size(),
+				Message: tfile.MessageOf(elem.File),
+			})
+		}
+		if err := storage.SaveAlbum(ctx, a.storage, files); err != nil {
+			logger.Errorf("Failed to save album: %s, retrying...", err)
+			return err
+		}
+		return nil
+	}, retry.Context(ctx), retry.RetryTimes(uint(config.C().Retry)))
+	return err
+}
@@ -6,26 +6,33 @@ import (
 	"io"
 	"os"
 	"path"
+	"sync/atomic"
 
 	"github.com/charmbracelet/log"
 	"github.com/duke-git/lancet/v2/retry"
 	"github.com/krau/SaveAny-Bot/common/tdler"
 	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
 	"github.com/krau/SaveAny-Bot/common/utils/ioutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
 	"github.com/krau/SaveAny-Bot/pkg/taskevent"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
 	"golang.org/x/sync/errgroup"
 )
 
 func (t *Task) Execute(ctx context.Context) error {
 	logger := log.FromContext(ctx).WithPrefix(fmt.Sprintf("batch_file[%s]", t.ID))
 	logger.Info("Starting batch file task")
-	t.Progress.OnStart(ctx, t)
+	if t.Progress != nil {
+		t.Progress.OnStart(ctx, t)
+	}
 	workers := config.C().Workers
 	eg, gctx := errgroup.WithContext(ctx)
 	eg.SetLimit(workers)
-	for _, elem := range t.elems {
+	singles, albums := t.groupAlbums()
+	for _, elem := range singles {
 		eg.Go(func() error {
 			t.processingMu.RLock()
 			if t.processing[elem.ID] != nil {
@@ -43,28 +50,41 @@ func (t *Task) Execute(ctx context.Context) error {
 			return t.processElement(gctx, elem)
 		})
 	}
+	for _, album := range albums {
+		eg.Go(func() error {
+			return t.processAlbum(gctx, album)
+		})
+	}
 	err := eg.Wait()
 	if err != nil {
 		logger.Errorf("Error during batch file processing: %v", err)
 	} else {
 		logger.Info("Batch file task completed successfully")
 	}
-	t.Progress.OnDone(ctx, t, err)
+	if t.Progress != nil {
+		t.Progress.OnDone(ctx, t, err)
+	}
 	return err
 }
 
 func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 	logger := log.FromContext(ctx).WithPrefix(fmt.Sprintf("file[%s]", elem.File.Name()))
+	if elem.Overwrite {
+		ctx = storage.WithOverwrite(ctx)
+	}
+	ctx = tgutil.WithSourceMessage(ctx, tfile.MessageOf(elem.File))
 	if elem.stream {
 		pr, pw := io.Pipe()
 		defer pr.Close()
 		errg, uploadCtx := errgroup.WithContext(ctx)
 		errg.Go(func() error {
-			return elem.Storage.Save(uploadCtx, pr, elem.Path)
+			return storage.Save(uploadCtx, elem.Storage, pr, elem.Path)
 		})
 		wr := ioutil.NewProgressWriter(pw, func(n int) {
 			downloaded := t.downloaded.Add(int64(n))
-			t.Progress.OnProgress(ctx, t)
+			if t.Progress != nil {
+				t.Progress.OnProgress(ctx, t)
+			}
 			taskevent.Emit(ctx, taskevent.Event{
 				TaskID:          t.ID,
 				Phase:           taskevent.PhaseProgress,
@@ -89,36 +109,16 @@ func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 		return nil
 	}
 	logger.Info("Starting file download")
-	localFile, err := fsutil.CreateFile(elem.localPath)
-	if err != nil {
-		return fmt.Errorf("failed to create local file: %w", err)
-	}
+	unlock := tdler.LockCache(elem.localPath)
+	defer unlock()
+	var err error
 	defer func() {
-		if err := localFile.CloseAndRemove(); err != nil {
-			logger.Errorf("Failed to close local file: %v", err)
-		}
+		t.removeCache(ctx, logger, &elem, err)
 	}()
-	wrAt := ioutil.NewProgressWriterAt(localFile, func(n int) {
-		downloaded := t.downloaded.Add(int64(n))
-		t.Progress.OnProgress(ctx, t)
-		taskevent.Emit(ctx, taskevent.Event{
-			TaskID:          t.ID,
-			Phase:           taskevent.PhaseProgress,
-			TotalBytes:      t.totalSize,
-			DownloadedBytes: downloaded,
-		})
-	})
-	_, err = tdler.NewDownloader(elem.File).Parallel(ctx, wrAt)
-	if err != nil {
-		return fmt.Errorf("failed to download file: %w", err)
+	if err = t.download(ctx, &elem); err != nil {
+		return err
 	}
 	logger.Info("File downloaded successfully")
-	if path.Ext(elem.FileName()) == "" {
-		ext := fsutil.DetectFileExt(elem.localPath)
-		if ext != "" {
-			elem.Path = elem.Path + ext
-		}
-	}
 	var fileStat os.FileInfo
 	fileStat, err = os.Stat(elem.localPath)
 	if err != nil {
@@ -132,7 +132,7 @@ func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 			return fmt.Errorf("failed to open cache file: %w", err)
 		}
 		defer file.Close()
-		if err = elem.Storage.Save(vctx, file, elem.Path); err != nil {
+		if err = storage.Save(vctx, elem.Storage, file, elem.Path); err != nil {
 			logger.Errorf("Failed to save file: %s, retrying...", err)
 			return err
 		}
@@ -140,3 +140,45 @@ func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 	}, retry.Context(vctx), retry.RetryTimes(uint(config.C().Retry)))
 	return err
 }
+
+// download 下载文件到缓存, 文件名没有扩展名时根据内容补全保存路径的扩展名
+func (t *Task) download(ctx context.Context, elem *TaskElement) error {
+	// 重试时已完成的部分会再次上报, 只累加与上次上报的差值
+	var reported atomic.Int64
+	onProgress := func(elemDownloaded int64) {
+		downloaded := t.downloaded.Add(elemDownloaded - reported.Swap(elemDownloaded))
+		if t.Progress != nil {
+			t.Progress.OnProgress(ctx, t)
+		}
+		taskevent.Emit(ctx, taskevent.Event{
+			TaskID:          t.ID,
+			Phase:           taskevent.PhaseProgress,
+			TotalBytes:      t.totalSize,
+			DownloadedBytes: downloaded,
+		})
+	}
+	err := retry.Retry(func() error {
+		return tdler.Download(ctx, elem.File, elem.localPath, onProgress)
+	}, retry.Context(ctx), retry.RetryTimes(uint(config.C().Retry)))
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	if path.Ext(elem.FileName()) == "" {
+		ext := fsutil.DetectFileExt(elem.localPath)
+		if ext != "" {
+			elem.Path = elem.Path + ext
+		}
+	}
+	return nil
+}
+
+func (t *Task) removeCache(ctx context.Context, logger *log.Logger, elem *TaskElement, err error) {
+	// 可续传的文件失败时保留缓存, 再次下载时只获取缺少的分块, 任务被取消时删除
+	if tdler.KeepPartial(ctx, elem.File, err) {
+		logger.Infof("Keeping the partial download for resuming")
+		return
+	}
+	if err := tdler.RemoveCache(elem.localPath); err != nil {
+		logger.Errorf("Failed to remove cache file: %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/krau/SaveAny-Bot/common/tdler"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/core"
 	"github.com/krau/SaveAny-Bot/pkg/enums/tasktype"
@@ -22,6 +23,7 @@ type TaskElement struct {
 	Storage   storage.Storage
 	Path      string
 	File      tfile.TGFile
+	Overwrite bool // overwrite the existing file in the storage
 	localPath string
 	stream    bool
 }
@@ -56,7 +58,7 @@ func NewTaskElement(
 	id := xid.New().String()
 	_, ok := stor.(storage.StorageCannotStream)
 	if !config.C().Stream || ok {
-		cachePath, err := filepath.Abs(filepath.Join(config.C().Temp.BasePath, fmt.Sprintf("%s_%s", id, file.Name())))
+		cachePath, err := tdler.CachePath(file, filepath.Join(config.C().Temp.BasePath, fmt.Sprintf("%s_%s", id, file.Name())))
 		if err != nil {
 			return nil, fmt.Errorf("failed to get absolute path for cache: %w", err)
 		}
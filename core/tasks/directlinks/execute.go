@@ -16,6 +16,7 @@ import (
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
 	"github.com/krau/SaveAny-Bot/pkg/taskevent"
+	"github.com/krau/SaveAny-Bot/storage"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -131,7 +132,7 @@ func (t *Task) processLink(ctx context.Context, file *File) error {
 		}
 		ctx = context.WithValue(ctx, ctxkey.ContentLength, file.Size)
 		if t.stream {
-			return t.Storage.Save(ctx, resp.Body, filepath.Join(t.StorPath, file.Name))
+			return storage.Save(ctx, t.Storage, resp.Body, filepath.Join(t.StorPath, file.Name))
 		}
 		cacheFile, err := fsutil.CreateFile(filepath.Join(config.C().Temp.BasePath,
 			fmt.Sprintf("direct_%s_%s", t.ID, file.Name)))
@@ -173,7 +174,7 @@ func (t *Task) processLink(ctx context.Context, file *File) error {
 		if err != nil {
 			return fmt.Errorf("failed to seek cache file for resource %s: %w", file.URL, err)
 		}
-		return t.Storage.Save(ctx, cacheFile, filepath.Join(t.StorPath, file.Name))
+		return storage.Save(ctx, t.Storage, cacheFile, filepath.Join(t.StorPath, file.Name))
 	}, retry.RetryTimes(uint(config.C().Retry)), retry.Context(ctx))
 	if ctx.Err() != nil {
 		return ctx.Err()
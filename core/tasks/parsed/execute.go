@@ -17,6 +17,7 @@ import (
 	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
 	"github.com/krau/SaveAny-Bot/pkg/parser"
 	"github.com/krau/SaveAny-Bot/pkg/taskevent"
+	"github.com/krau/SaveAny-Bot/storage"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -95,7 +96,7 @@ func (t *Task) processResource(ctx context.Context, resource parser.Resource) er
 			return resp.ContentLength
 		}())
 		if t.stream {
-			return t.Stor.Save(ctx, resp.Body, path.Join(t.StorPath, resource.Filename))
+			return storage.Save(ctx, t.Stor, resp.Body, path.Join(t.StorPath, resource.Filename))
 		}
 		cacheFile, err := fsutil.CreateFile(filepath.Join(config.C().Temp.BasePath,
 			fmt.Sprintf("resource_%s_%s", t.ID, resource.Filename)))
@@ -137,7 +138,7 @@ func (t *Task) processResource(ctx context.Context, resource parser.Resource) er
 		if err != nil {
 			return fmt.Errorf("failed to seek cache file for resource %s: %w", resource.URL, err)
 		}
-		return t.Stor.Save(ctx, cacheFile, path.Join(t.StorPath, resource.Filename))
+		return storage.Save(ctx, t.Stor, cacheFile, path.Join(t.StorPath, resource.Filename))
 	}, retry.Context(ctx), retry.RetryTimes(uint(config.C().Retry)))
 	if ctx.Err() != nil {
 		return ctx.Err()
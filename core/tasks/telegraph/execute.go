@@ -12,6 +12,7 @@ import (
 	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/pkg/taskevent"
+	"github.com/krau/SaveAny-Bot/storage"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -82,12 +83,12 @@ func (t *Task) processPic(ctx context.Context, picUrl string, index int) error {
 			if err != nil {
 				return fmt.Errorf("failed to seek cache file for picture %s: %w", filename, err)
 			}
-			err = t.Stor.Save(ctx, cacheFile, path.Join(t.StorPath, filename))
+			err = storage.Save(ctx, t.Stor, cacheFile, path.Join(t.StorPath, filename))
 			if err != nil {
 				return fmt.Errorf("failed to save picture %s: %w", filename, err)
 			}
 		} else {
-			err = t.Stor.Save(ctx, body, path.Join(t.StorPath, filename))
+			err = storage.Save(ctx, t.Stor, body, path.Join(t.StorPath, filename))
 		}
 
 		if err != nil {
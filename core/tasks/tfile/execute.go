@@ -10,8 +10,11 @@ import (
 	"github.com/duke-git/lancet/v2/retry"
 	"github.com/krau/SaveAny-Bot/common/tdler"
 	"github.com/krau/SaveAny-Bot/common/utils/fsutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+	"github.com/krau/SaveAny-Bot/storage"
 )
 
 func (t *Task) Execute(ctx context.Context) error {
@@ -19,28 +22,35 @@ func (t *Task) Execute(ctx context.Context) error {
 	if t.Progress != nil {
 		t.Progress.OnStart(ctx, t)
 	}
+	ctx = tgutil.WithSourceMessage(ctx, tfile.MessageOf(t.File))
 	if t.stream {
 		return executeStream(ctx, t)
 	}
 
 	logger.Info("Starting file download")
-	localFile, err := fsutil.CreateFile(t.localPath)
-	if err != nil {
-		return fmt.Errorf("failed to create local file: %w", err)
-	}
+	unlock := tdler.LockCache(t.localPath)
+	defer unlock()
+	var err error
 	defer func() {
-		if err := localFile.CloseAndRemove(); err != nil {
-			logger.Errorf("Failed to close local file: %v", err)
+		// 可续传的文件失败时保留缓存, 再次下载时只获取缺少的分块, 任务被取消时删除
+		if tdler.KeepPartial(ctx, t.File, err) {
+			logger.Infof("Keeping the partial download for resuming")
+			return
+		}
+		if err := tdler.RemoveCache(t.localPath); err != nil {
+			logger.Errorf("Failed to remove cache file: %v", err)
 		}
 	}()
-	wrAt := newWriterAt(ctx, localFile, t.Progress, t)
 
 	defer func() {
 		if t.Progress != nil {
 			t.Progress.OnDone(ctx, t, err)
 		}
 	}()
-	_, err = tdler.NewDownloader(t.File).Parallel(ctx, wrAt)
+	reporter := newProgressReporter(ctx, t.Progress, t)
+	err = retry.Retry(func() error {
+		return tdler.Download(ctx, t.File, t.localPath, reporter.onProgress)
+	}, retry.Context(ctx), retry.RetryTimes(uint(config.C().Retry)))
 	if err != nil {
 		return fmt.Errorf("failed to download file: %w", err)
 	}
@@ -63,7 +73,7 @@ func (t *Task) Execute(ctx context.Context) error {
 			return fmt.Errorf("failed to open cache file: %w", err)
 		}
 		defer file.Close()
-		if err = t.Storage.Save(vctx, file, t.Path); err != nil {
+		if err = storage.Save(vctx, t.Storage, file, t.Path); err != nil {
 			return fmt.Errorf("failed to save file: %w", err)
 		}
 		return nil
@@ -7,6 +7,7 @@ import (
 
 	"github.com/charmbracelet/log"
 	"github.com/krau/SaveAny-Bot/common/tdler"
+	"github.com/krau/SaveAny-Bot/storage"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -17,7 +18,7 @@ func executeStream(ctx context.Context, task *Task) error {
 	defer pr.Close()
 	errg, uploadCtx := errgroup.WithContext(ctx)
 	errg.Go(func() error {
-		return task.Storage.Save(uploadCtx, pr, task.Path)
+		return storage.Save(uploadCtx, task.Storage, pr, task.Path)
 	})
 	wr := newWriter(ctx, pw, task.Progress, task)
 	errg.Go(func() error {
@@ -8,45 +8,38 @@ import (
 	"github.com/krau/SaveAny-Bot/pkg/taskevent"
 )
 
-type ProgressWriterAt struct {
+// progressReporter reports the progress of a download to the cache file
+type progressReporter struct {
 	ctx        context.Context
-	wrAt       io.WriterAt
 	progress   ProgressTracker
 	downloaded *atomic.Int64
 	total      int64
 	info       TaskInfo
 }
 
-func (w *ProgressWriterAt) WriteAt(p []byte, off int64) (int, error) {
-	at, err := w.wrAt.WriteAt(p, off)
-	if err != nil {
-		return 0, err
-	}
-	downloaded := w.downloaded.Add(int64(at))
-	if w.progress != nil {
-		w.progress.OnProgress(w.ctx, w.info, downloaded, w.total)
+func (r *progressReporter) onProgress(downloaded int64) {
+	r.downloaded.Store(downloaded)
+	if r.progress != nil {
+		r.progress.OnProgress(r.ctx, r.info, downloaded, r.total)
 	}
-	taskevent.Emit(w.ctx, taskevent.Event{
-		TaskID:          w.info.TaskID(),
+	taskevent.Emit(r.ctx, taskevent.Event{
+		TaskID:          r.info.TaskID(),
 		Phase:           taskevent.PhaseProgress,
-		TotalBytes:      w.total,
+		TotalBytes:      r.total,
 		DownloadedBytes: downloaded,
 	})
-	return at, nil
 }
 
-func newWriterAt(
+func newProgressReporter(
 	ctx context.Context,
-	wrAt io.WriterAt,
 	progress ProgressTracker,
 	taskInfo TaskInfo,
-) *ProgressWriterAt {
-	return &ProgressWriterAt{
+) *progressReporter {
+	return &progressReporter{
 		ctx:        ctx,
 		progress:   progress,
 		downloaded: &atomic.Int64{},
 		total:      taskInfo.FileSize(),
-		wrAt:       wrAt,
 		info:       taskInfo,
 	}
 }
@@ -80,7 +80,7 @@ func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 	}
 
 	logger.Info("Opening file from source storage")
-	reader, size, err := readableStorage.OpenFile(ctx, elem.SourcePath)
+	reader, size, err := storage.OpenFile(ctx, readableStorage, elem.SourcePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
@@ -93,7 +93,7 @@ func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 	ctx = context.WithValue(ctx, ctxkey.ContentLength, size)
 
 	if config.C().Stream {
-		if err := elem.TargetStorage.Save(ctx, reader, storagePath); err != nil {
+		if err := storage.Save(ctx, elem.TargetStorage, reader, storagePath); err != nil {
 			return fmt.Errorf("failed to upload file to storage: %w", err)
 		}
 	} else {
@@ -110,7 +110,7 @@ func (t *Task) processElement(ctx context.Context, elem TaskElement) error {
 		}
 
 		logger.Infof("Uploading file to storage (size: %d bytes)", size)
-		if err := elem.TargetStorage.Save(ctx, tempFile, storagePath); err != nil {
+		if err := storage.Save(ctx, elem.TargetStorage, tempFile, storagePath); err != nil {
 			return fmt.Errorf("failed to upload file to storage: %w", err)
 		}
 	}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/krau/SaveAny-Bot/config"
 	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/storage"
 )
 
 // Execute implements core.Executable.
@@ -169,7 +170,7 @@ func (t *Task) transferFile(ctx context.Context, filePath string) error {
 
 	logger.Infof("Transferring file %s to %s:%s", fileName, t.Storage.Name(), destPath)
 
-	if err := t.Storage.Save(ctx, f, destPath); err != nil {
+	if err := storage.Save(ctx, t.Storage, f, destPath); err != nil {
 		return fmt.Errorf("failed to save file %s to storage: %w", fileName, err)
 	}
 
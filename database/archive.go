@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// GetArchive returns the archive of a chat, or nil if the user has not archived it yet.
+func (user *User) GetArchive(ctx context.Context, chatID int64) (*Archive, error) {
+	var archive Archive
+	err := db.WithContext(ctx).Where("user_id = ? AND chat_id = ?", user.ID, chatID).First(&archive).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+func GetArchiveByID(ctx context.Context, id uint) (*Archive, error) {
+	var archive Archive
+	err := db.WithContext(ctx).First(&archive, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+func CreateArchive(ctx context.Context, archive *Archive) error {
+	return db.WithContext(ctx).Create(archive).Error
+}
+
+// UpdateArchiveProgress saves how far an archive got.
+func UpdateArchiveProgress(ctx context.Context, id uint, lastMessageID int, pages string) error {
+	return db.WithContext(ctx).Model(&Archive{}).Where("id = ?", id).
+		Updates(map[string]any{"last_message_id": lastMessageID, "pages": pages}).Error
+}
+
+func DeleteArchive(ctx context.Context, id uint) error {
+	return db.WithContext(ctx).Unscoped().Delete(&Archive{}, id).Error
+}
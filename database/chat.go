@@ -1,6 +1,10 @@
 package database
 
-import "context"
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
 
 func (user *User) WatchChat(ctx context.Context, chat WatchChat) error {
 	if len(user.WatchChats) == 0 {
@@ -17,7 +21,69 @@ func (user *User) UnwatchChat(ctx context.Context, chatID int64) error {
 	if err != nil {
 		return err
 	}
-	return db.WithContext(ctx).Unscoped().Delete(&watchChat).Error
+	var ruleIDs []uint
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if ruleIDs, err = deleteRules(tx, "watch_chat_id = ?", watchChat.ID); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&watchChat).Error
+	})
+	if err != nil {
+		return err
+	}
+	notifyRulesDeleted(ruleIDs)
+	return nil
+}
+
+// GetWatchChat returns the watch of a chat with its rules.
+func (user *User) GetWatchChat(ctx context.Context, chatID int64) (*WatchChat, error) {
+	var watchChat WatchChat
+	err := db.WithContext(ctx).Preload("Rules").Where("chat_id = ? AND user_id = ?", chatID, user.ID).First(&watchChat).Error
+	if err != nil {
+		return nil, err
+	}
+	return &watchChat, nil
+}
+
+// UpdateWatchChat saves the settings of a watch, the progress fields are updated by their own functions.
+func UpdateWatchChat(ctx context.Context, watchChat *WatchChat) error {
+	return db.WithContext(ctx).Omit("Rules", "LastMessageID", "BackfillRanges").Save(watchChat).Error
+}
+
+func GetWatchChatByID(ctx context.Context, id uint) (*WatchChat, error) {
+	var watchChat WatchChat
+	err := db.WithContext(ctx).Preload("Rules").First(&watchChat, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &watchChat, nil
+}
+
+func GetAllWatchChats(ctx context.Context) ([]*WatchChat, error) {
+	var watchChats []*WatchChat
+	err := db.WithContext(ctx).Preload("Rules").Find(&watchChats).Error
+	if err != nil {
+		return nil, err
+	}
+	return watchChats, nil
+}
+
+// UpdateWatchChatLastMessageID raises the last handled message ID of a watch, it never moves backwards.
+func UpdateWatchChatLastMessageID(ctx context.Context, id uint, messageID int) error {
+	return db.WithContext(ctx).Model(&WatchChat{}).
+		Where("id = ? AND last_message_id < ?", id, messageID).
+		Update("last_message_id", messageID).Error
+}
+
+// UpdateWatchChatLastStoryID raises the last saved story ID of a watch, it never moves backwards.
+func UpdateWatchChatLastStoryID(ctx context.Context, id uint, storyID int) error {
+	return db.WithContext(ctx).Model(&WatchChat{}).
+		Where("id = ? AND last_story_id < ?", id, storyID).
+		Update("last_story_id", storyID).Error
+}
+
+func UpdateWatchChatBackfillRanges(ctx context.Context, id uint, ranges string) error {
+	return db.WithContext(ctx).Model(&WatchChat{}).Where("id = ?", id).Update("backfill_ranges", ranges).Error
 }
 
 func (user *User) WatchingChat(ctx context.Context, chatID int64) (bool, error) {
@@ -31,7 +97,7 @@ func (user *User) WatchingChat(ctx context.Context, chatID int64) (bool, error)
 
 func GetWatchChatsByChatID(ctx context.Context, chatID int64) ([]*WatchChat, error) {
 	var watchChats []*WatchChat
-	err := db.WithContext(ctx).Where("chat_id = ?", chatID).Find(&watchChats).Error
+	err := db.WithContext(ctx).Preload("Rules").Where("chat_id = ?", chatID).Find(&watchChats).Error
 	if err != nil {
 		return nil, err
 	}
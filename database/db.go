@@ -35,7 +35,13 @@ func Init(ctx context.Context) {
 		logger.Fatal("Failed to open database: ", err)
 	}
 	logger.Debug("Database connected")
-	if err := db.AutoMigrate(&User{}, &Dir{}, &Rule{}, &WatchChat{}); err != nil {
+	if err := migrateTelegramFiles(); err != nil {
+		logger.Fatal("Failed to migrate telegram files: ", err)
+	}
+	if err := migrateRules(); err != nil {
+		logger.Fatal("Failed to migrate rules: ", err)
+	}
+	if err := db.AutoMigrate(&User{}, &Dir{}, &Rule{}, &WatchChat{}, &Subscription{}, &Archive{}, &TelegramFile{}, &TelegramTopic{}, &IdempotencyKey{}); err != nil {
 		logger.Fatal("Database migration failed; if upgrading from an old version, try deleting the database file and retrying", "error", err)
 	}
 	if err := syncUsers(ctx); err != nil {
@@ -45,6 +51,34 @@ func Init(ctx context.Context) {
 	logger.Info("Database initialized")
 }
 
+// migrateTelegramFiles drops the unique index of telegram files created before files were indexed by topic directory,
+// AutoMigrate recreates it with the directory.
+func migrateTelegramFiles() error {
+	m := db.Migrator()
+	if !m.HasTable(&TelegramFile{}) || m.HasColumn(&TelegramFile{}, "Dir") || !m.HasIndex(&TelegramFile{}, "idx_telegram_file_path") {
+		return nil
+	}
+	return m.DropIndex(&TelegramFile{}, "idx_telegram_file_path")
+}
+
+// migrateRules drops the foreign keys of rules to users and watches,
+// a rule belongs to only one of them and the ID of the other is zero, which fails the constraint.
+func migrateRules() error {
+	m := db.Migrator()
+	if !m.HasTable(&Rule{}) {
+		return nil
+	}
+	for _, name := range []string{"fk_users_rules", "fk_watch_chats_rules"} {
+		if !m.HasConstraint(&Rule{}, name) {
+			continue
+		}
+		if err := m.DropConstraint(&Rule{}, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func syncUsers(ctx context.Context) error {
 	logger := log.FromContext(ctx)
 	dbUsers, err := GetAllUsers(ctx)
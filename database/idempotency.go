@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKeys stores the idempotency keys of the API.
+type IdempotencyKeys struct{}
+
+// GetIdempotencyKey returns the record of a key created after since, or nil if there is none.
+func (IdempotencyKeys) GetIdempotencyKey(ctx context.Context, tokenHash, key string, since time.Time) (*IdempotencyKey, error) {
+	var record IdempotencyKey
+	err := db.WithContext(ctx).Where("token_hash = ? AND idempotency_key = ? AND created_at > ?", tokenHash, key, since).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// SaveIdempotencyKey saves the record, replacing an expired record of the same key.
+func (IdempotencyKeys) SaveIdempotencyKey(ctx context.Context, record *IdempotencyKey) error {
+	return db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token_hash"}, {Name: "idempotency_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"body_hash", "response", "created_at", "updated_at"}),
+	}).Create(record).Error
+}
+
+// DeleteIdempotencyKeys deletes the records created before the time.
+func (IdempotencyKeys) DeleteIdempotencyKeys(ctx context.Context, before time.Time) error {
+	return db.WithContext(ctx).Unscoped().Where("created_at < ?", before).Delete(&IdempotencyKey{}).Error
+}
@@ -12,8 +12,9 @@ type User struct {
 	DefaultDir       uint // Dir.ID
 	Dirs             []Dir
 	ApplyRule        bool
-	Rules            []Rule
+	Rules            []Rule `gorm:"constraint:-"` // a rule belongs to either a user or a watch, the other ID is zero
 	WatchChats       []WatchChat
+	Subscriptions    []Subscription
 	FilenameStrategy string
 	FilenameTemplate string
 	ConflictStrategy string
@@ -21,9 +22,36 @@ type User struct {
 
 type WatchChat struct {
 	gorm.Model
-	UserID uint // User's database ID (not chat ID)
-	ChatID int64
-	Filter string
+	UserID           uint // User's database ID (not chat ID)
+	ChatID           int64
+	Filter           string // message filter, "msgre:<regex>"
+	MediaTypes       string // comma separated media kinds or MIME globs
+	SizeRange        string // e.g. "10MB-2GB"
+	Senders          string // comma separated sender IDs or usernames
+	Hashtags         string // comma separated hashtags, any of them must be present
+	StorageName      string // empty means the user's default storage
+	DirPath          string // empty means the user's default dir
+	FilenameTemplate string // empty means the user's filename strategy
+	Rules            []Rule `gorm:"constraint:-"` // rules of this watch, used instead of the user's rules
+	LastMessageID    int    // newest handled message, older messages received again are skipped
+	BackfillRanges   string // message ID ranges still to be backfilled, e.g. "1-500,900-950"
+	LastStoryID      int    // newest saved story of the chat, for users and channels
+}
+
+// Subscription 不限定聊天, userbot 所在的任意聊天中符合过滤条件的文件都会被保存
+type Subscription struct {
+	gorm.Model
+	UserID           uint   `gorm:"uniqueIndex:idx_subscription_user_name"`
+	Name             string `gorm:"uniqueIndex:idx_subscription_user_name"`
+	Filter           string // message filter, "msgre:<regex>"
+	MediaTypes       string // comma separated media kinds or MIME globs
+	SizeRange        string // e.g. "10MB-2GB"
+	Senders          string // comma separated sender IDs or usernames
+	Hashtags         string // comma separated hashtags, any of them must be present
+	StorageName      string // empty means the user's default storage
+	DirPath          string // empty means the user's default dir
+	FilenameTemplate string // empty means the user's filename strategy
+	MaxPerHour       int    // files saved per hour at most, zero means no limit
 }
 
 type Dir struct {
@@ -35,9 +63,55 @@ type Dir struct {
 
 type Rule struct {
 	gorm.Model
-	UserID      uint
+	UserID      uint // zero for rules of a watch
+	WatchChatID uint
 	Type        string
 	Data        string
 	StorageName string
 	DirPath     string
+	Priority    int    // higher priority rules are evaluated first and win over lower ones
+	Stop        bool   // stop evaluating further rules once this one matches
+	Action      string // actions to take when the rule matches, see rule.ParseActions
+}
+
+// Archive 是一个聊天的完整归档, 记录归档的位置与进度, 再次归档时只获取新的消息
+type Archive struct {
+	gorm.Model
+	UserID        uint  `gorm:"uniqueIndex:idx_archive_user_chat"`
+	ChatID        int64 `gorm:"uniqueIndex:idx_archive_user_chat"`
+	StorageName   string
+	Path          string // archive root in the storage
+	LastMessageID int    // newest archived message
+	Pages         string // comma separated pages that have messages, see tgarchive.PageOf
+}
+
+// TelegramFile indexes a file uploaded by a telegram storage, see storage/telegram.Index
+type TelegramFile struct {
+	gorm.Model
+	StorageName string `gorm:"uniqueIndex:idx_telegram_file_path"`
+	ChatID      int64  `gorm:"uniqueIndex:idx_telegram_file_path"`
+	Dir         string `gorm:"uniqueIndex:idx_telegram_file_path"` // topic directory, empty outside directory topics
+	Name        string `gorm:"uniqueIndex:idx_telegram_file_path"`
+	Size        int64
+	Hash        string
+	MessageIDs  string // comma separated
+	Split       bool
+}
+
+// TelegramTopic records a forum topic created by a telegram storage for a directory
+type TelegramTopic struct {
+	gorm.Model
+	StorageName string `gorm:"uniqueIndex:idx_telegram_topic"`
+	ChatID      int64  `gorm:"uniqueIndex:idx_telegram_topic"`
+	Title       string `gorm:"uniqueIndex:idx_telegram_topic"`
+	TopicID     int
+}
+
+// IdempotencyKey records a task created through the API with an Idempotency-Key, so retries after a restart are replayed too
+type IdempotencyKey struct {
+	gorm.Model
+	TokenHash string `gorm:"uniqueIndex:idx_idempotency_key"` // keys of different tokens never collide
+	Key       string `gorm:"column:idempotency_key;uniqueIndex:idx_idempotency_key"`
+	BodyHash  string // hash of the request, a reused key must come with the same request
+	Response  string // JSON of the response
 }
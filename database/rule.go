@@ -1,13 +1,48 @@
 package database
 
-import "context"
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// rulesDeleted is called with the IDs of deleted rules, see OnRulesDeleted.
+var rulesDeleted func(ids []uint)
+
+// OnRulesDeleted sets the function called with the IDs of deleted rules, so caches of compiled rules can drop them.
+func OnRulesDeleted(f func(ids []uint)) {
+	rulesDeleted = f
+}
+
+func notifyRulesDeleted(ids []uint) {
+	if rulesDeleted != nil && len(ids) > 0 {
+		rulesDeleted(ids)
+	}
+}
+
+// deleteRules deletes the rules matched by the query and returns their IDs.
+func deleteRules(tx *gorm.DB, query any, args ...any) ([]uint, error) {
+	var ids []uint
+	if err := tx.Unscoped().Model(&Rule{}).Where(query, args...).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return ids, tx.Unscoped().Delete(&Rule{}, ids).Error
+}
 
 func CreateRule(ctx context.Context, rule *Rule) error {
 	return db.WithContext(ctx).Create(rule).Error
 }
 
 func DeleteRule(ctx context.Context, ruleID uint) error {
-	return db.WithContext(ctx).Unscoped().Delete(&Rule{}, ruleID).Error
+	ids, err := deleteRules(db.WithContext(ctx), "id = ?", ruleID)
+	if err != nil {
+		return err
+	}
+	notifyRulesDeleted(ids)
+	return nil
 }
 
 func UpdateUserApplyRule(ctx context.Context, chatID int64, applyRule bool) error {
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// GetSubscription returns the subscription with the name, or nil if the user has no such subscription.
+func (user *User) GetSubscription(ctx context.Context, name string) (*Subscription, error) {
+	var sub Subscription
+	err := db.WithContext(ctx).Where("user_id = ? AND name = ?", user.ID, name).First(&sub).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// SaveSubscription creates the subscription or updates its settings.
+func SaveSubscription(ctx context.Context, sub *Subscription) error {
+	defer subscriptions.invalidate()
+	return db.WithContext(ctx).Save(sub).Error
+}
+
+func (user *User) DeleteSubscription(ctx context.Context, name string) error {
+	defer subscriptions.invalidate()
+	sub, err := user.GetSubscription(ctx, name)
+	if err != nil {
+		return err
+	}
+	if sub == nil {
+		return gorm.ErrRecordNotFound
+	}
+	if err := db.WithContext(ctx).Unscoped().Delete(sub).Error; err != nil {
+		return err
+	}
+	notifySubscriptionsDeleted([]uint{sub.ID})
+	return nil
+}
+
+// subscriptionsDeleted is called with the IDs of deleted subscriptions, see OnSubscriptionsDeleted.
+var subscriptionsDeleted func(ids []uint)
+
+// OnSubscriptionsDeleted sets the function called with the IDs of deleted subscriptions, so caches of their filters can drop them.
+func OnSubscriptionsDeleted(f func(ids []uint)) {
+	subscriptionsDeleted = f
+}
+
+func notifySubscriptionsDeleted(ids []uint) {
+	if subscriptionsDeleted != nil && len(ids) > 0 {
+		subscriptionsDeleted(ids)
+	}
+}
+
+// GetAllSubscriptions returns the subscriptions of all users. They are cached until a subscription is saved or deleted,
+// so every media message does not query the database, and must not be modified.
+func GetAllSubscriptions(ctx context.Context) ([]*Subscription, error) {
+	return subscriptions.get(ctx)
+}
+
+// HasSubscriptions reports whether any user has a subscription, messages of unwatched chats are ignored otherwise.
+func HasSubscriptions(ctx context.Context) (bool, error) {
+	subs, err := subscriptions.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	return len(subs) > 0, nil
+}
+
+// subscriptionCache 缓存所有订阅, 订阅变更时失效
+type subscriptionCache struct {
+	mu     sync.Mutex
+	subs   []*Subscription
+	loaded bool
+	gen    uint64 // 每次失效时递增, 避免保存失效前查询到的旧数据
+}
+
+var subscriptions = &subscriptionCache{}
+
+func (c *subscriptionCache) get(ctx context.Context) ([]*Subscription, error) {
+	c.mu.Lock()
+	if c.loaded {
+		subs := c.subs
+		c.mu.Unlock()
+		return subs, nil
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	var subs []*Subscription
+	if err := db.WithContext(ctx).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.gen == gen {
+		c.subs, c.loaded = subs, true
+	}
+	c.mu.Unlock()
+	return subs, nil
+}
+
+func (c *subscriptionCache) invalidate() {
+	c.mu.Lock()
+	c.subs, c.loaded = nil, false
+	c.gen++
+	c.mu.Unlock()
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/krau/SaveAny-Bot/pkg/storagetypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TelegramIndex is the index of the telegram storages.
+type TelegramIndex struct{}
+
+// SaveTelegramFile indexes a file, replacing the file of the same name in the directory of the chat.
+func (TelegramIndex) SaveTelegramFile(ctx context.Context, storageName string, file storagetypes.TelegramFile) error {
+	ids := make([]string, 0, len(file.MessageIDs))
+	for _, id := range file.MessageIDs {
+		ids = append(ids, strconv.Itoa(id))
+	}
+	record := TelegramFile{
+		StorageName: storageName,
+		ChatID:      file.ChatID,
+		Dir:         file.Dir,
+		Name:        file.Name,
+		Size:        file.Size,
+		Hash:        file.Hash,
+		MessageIDs:  strings.Join(ids, ","),
+		Split:       file.Split,
+	}
+	return db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "storage_name"}, {Name: "chat_id"}, {Name: "dir"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "hash", "message_ids", "split", "created_at", "updated_at", "deleted_at"}),
+	}).Create(&record).Error
+}
+
+func (TelegramIndex) GetTelegramFiles(ctx context.Context, storageName string, chatID int64, dir string) ([]storagetypes.TelegramFile, error) {
+	var records []TelegramFile
+	err := db.WithContext(ctx).Where("storage_name = ? AND chat_id = ? AND dir = ?", storageName, chatID, dir).Order("name").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	files := make([]storagetypes.TelegramFile, 0, len(records))
+	for _, record := range records {
+		files = append(files, record.toStorageType())
+	}
+	return files, nil
+}
+
+// GetTelegramFile returns the indexed file, or nil if there is none.
+func (TelegramIndex) GetTelegramFile(ctx context.Context, storageName string, chatID int64, dir, name string) (*storagetypes.TelegramFile, error) {
+	var record TelegramFile
+	err := db.WithContext(ctx).Where("storage_name = ? AND chat_id = ? AND dir = ? AND name = ?", storageName, chatID, dir, name).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	file := record.toStorageType()
+	return &file, nil
+}
+
+func (f TelegramFile) toStorageType() storagetypes.TelegramFile {
+	ids := make([]int, 0)
+	for s := range strings.SplitSeq(f.MessageIDs, ",") {
+		if id, err := strconv.Atoi(s); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return storagetypes.TelegramFile{
+		ChatID:     f.ChatID,
+		Dir:        f.Dir,
+		Name:       f.Name,
+		Size:       f.Size,
+		Hash:       f.Hash,
+		MessageIDs: ids,
+		Split:      f.Split,
+		CreatedAt:  f.CreatedAt,
+	}
+}
+
+// GetTelegramTopic returns the topic created for the title, or 0 if there is none.
+func (TelegramIndex) GetTelegramTopic(ctx context.Context, storageName string, chatID int64, title string) (int, error) {
+	var record TelegramTopic
+	err := db.WithContext(ctx).Where("storage_name = ? AND chat_id = ? AND title = ?", storageName, chatID, title).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return record.TopicID, nil
+}
+
+func (TelegramIndex) SaveTelegramTopic(ctx context.Context, storageName string, chatID int64, title string, topicID int) error {
+	record := TelegramTopic{
+		StorageName: storageName,
+		ChatID:      chatID,
+		Title:       title,
+		TopicID:     topicID,
+	}
+	return db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "storage_name"}, {Name: "chat_id"}, {Name: "title"}},
+		DoUpdates: clause.AssignmentColumns([]string{"topic_id", "updated_at", "deleted_at"}),
+	}).Create(&record).Error
+}
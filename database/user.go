@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -25,6 +26,7 @@ func GetUserByChatID(ctx context.Context, chatID int64) (*User, error) {
 	var user User
 	err := db.WithContext(ctx).
 		Preload(clause.Associations).
+		Preload("WatchChats.Rules").
 		Where("chat_id = ?", chatID).First(&user).Error
 	return &user, err
 }
@@ -37,16 +39,41 @@ func UpdateUser(ctx context.Context, user *User) error {
 }
 
 func DeleteUser(ctx context.Context, user *User) error {
-	return db.WithContext(ctx).
-		Unscoped().
-		Select(clause.Associations).
-		Delete(user).Error
+	defer subscriptions.invalidate()
+	var ruleIDs, subIDs []uint
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		watchRules, err := deleteRules(tx, "watch_chat_id IN (?)", tx.Model(&WatchChat{}).Select("id").Where("user_id = ?", user.ID))
+		if err != nil {
+			return err
+		}
+		userRules, err := deleteRules(tx, "user_id = ?", user.ID)
+		if err != nil {
+			return err
+		}
+		ruleIDs = append(watchRules, userRules...)
+		if err := tx.Unscoped().Model(&Subscription{}).Where("user_id = ?", user.ID).Pluck("id", &subIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&Archive{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().
+			Select(clause.Associations).
+			Delete(user).Error
+	})
+	if err != nil {
+		return err
+	}
+	notifyRulesDeleted(ruleIDs)
+	notifySubscriptionsDeleted(subIDs)
+	return nil
 }
 
 func GetUserByID(ctx context.Context, id uint) (*User, error) {
 	var user User
 	err := db.WithContext(ctx).
 		Preload(clause.Associations).
+		Preload("WatchChats.Rules").
 		Where("id = ?", id).First(&user).Error
 	return &user, err
 }
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/krau/SaveAny-Bot/pkg/userdata"
+	"gorm.io/gorm"
+)
+
+// ExportUserData collects the rules, dirs, watched chats and settings of a user.
+func ExportUserData(ctx context.Context, chatID int64) (*userdata.Data, error) {
+	user, err := GetUserByChatID(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	data := &userdata.Data{
+		Version:    userdata.Version,
+		ExportedAt: time.Now(),
+		Settings: userdata.Settings{
+			ApplyRule:        &user.ApplyRule,
+			Silent:           &user.Silent,
+			DefaultStorage:   user.DefaultStorage,
+			FilenameStrategy: user.FilenameStrategy,
+			FilenameTemplate: user.FilenameTemplate,
+			ConflictStrategy: user.ConflictStrategy,
+		},
+	}
+	data.Rules = exportRules(user.Rules)
+	for _, dir := range user.Dirs {
+		data.Dirs = append(data.Dirs, userdata.Dir{StorageName: dir.StorageName, Path: dir.Path})
+	}
+	for _, wc := range user.WatchChats {
+		data.WatchChats = append(data.WatchChats, userdata.WatchChat{
+			ChatID:           wc.ChatID,
+			Filter:           wc.Filter,
+			MediaTypes:       wc.MediaTypes,
+			SizeRange:        wc.SizeRange,
+			Senders:          wc.Senders,
+			Hashtags:         wc.Hashtags,
+			StorageName:      wc.StorageName,
+			DirPath:          wc.DirPath,
+			FilenameTemplate: wc.FilenameTemplate,
+			Rules:            exportRules(wc.Rules),
+		})
+	}
+	return data, nil
+}
+
+// ImportStats counts the records created or updated by an import.
+type ImportStats struct {
+	Rules      int
+	Dirs       int
+	WatchChats int
+}
+
+// ImportUserData applies exported data to a user in a single transaction, the data should be validated first.
+// In merge mode, records identical to existing ones are skipped and the settings and rules of an already watched chat are replaced.
+func ImportUserData(ctx context.Context, chatID int64, data *userdata.Data, mode userdata.Mode) (*ImportStats, error) {
+	user, err := GetUserByChatID(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	stats := &ImportStats{}
+	var deletedRules []uint
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		replace := mode == userdata.ModeReplace
+		if replace {
+			watchIDs := tx.Model(&WatchChat{}).Select("id").Where("user_id = ?", user.ID)
+			watchRules, err := deleteRules(tx, "watch_chat_id IN (?)", watchIDs)
+			if err != nil {
+				return err
+			}
+			userRules, err := deleteRules(tx, "user_id = ?", user.ID)
+			if err != nil {
+				return err
+			}
+			deletedRules = append(watchRules, userRules...)
+			for _, model := range []any{&Dir{}, &WatchChat{}} {
+				if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			user.Rules, user.Dirs, user.WatchChats = nil, nil, nil
+		}
+
+		for _, ru := range data.Rules {
+			if slices.Contains(exportRules(user.Rules), ru) {
+				continue
+			}
+			rd := importRule(ru)
+			rd.UserID = user.ID
+			if err := tx.Create(&rd).Error; err != nil {
+				return err
+			}
+			user.Rules = append(user.Rules, rd)
+			stats.Rules++
+		}
+
+		for _, dir := range data.Dirs {
+			exists := false
+			for _, existing := range user.Dirs {
+				if existing.StorageName == dir.StorageName && existing.Path == dir.Path {
+					exists = true
+					break
+				}
+			}
+			if exists {
+				continue
+			}
+			d := Dir{UserID: user.ID, StorageName: dir.StorageName, Path: dir.Path}
+			if err := tx.Create(&d).Error; err != nil {
+				return err
+			}
+			user.Dirs = append(user.Dirs, d)
+			stats.Dirs++
+		}
+
+		for _, wc := range data.WatchChats {
+			var existing *WatchChat
+			for i := range user.WatchChats {
+				if user.WatchChats[i].ChatID == wc.ChatID {
+					existing = &user.WatchChats[i]
+					break
+				}
+			}
+			if existing != nil && existing.sameSettings(wc) && slices.Equal(exportRules(existing.Rules), wc.Rules) {
+				continue
+			}
+			if existing == nil {
+				user.WatchChats = append(user.WatchChats, WatchChat{UserID: user.ID, ChatID: wc.ChatID})
+				existing = &user.WatchChats[len(user.WatchChats)-1]
+			} else {
+				ids, err := deleteRules(tx, "watch_chat_id = ?", existing.ID)
+				if err != nil {
+					return err
+				}
+				deletedRules = append(deletedRules, ids...)
+			}
+			existing.Filter = wc.Filter
+			existing.MediaTypes = wc.MediaTypes
+			existing.SizeRange = wc.SizeRange
+			existing.Senders = wc.Senders
+			existing.Hashtags = wc.Hashtags
+			existing.StorageName = wc.StorageName
+			existing.DirPath = wc.DirPath
+			existing.FilenameTemplate = wc.FilenameTemplate
+			existing.Rules = nil
+			if err := tx.Omit("Rules").Save(existing).Error; err != nil {
+				return err
+			}
+			for _, ru := range wc.Rules {
+				rd := importRule(ru)
+				rd.WatchChatID = existing.ID
+				if err := tx.Create(&rd).Error; err != nil {
+					return err
+				}
+				existing.Rules = append(existing.Rules, rd)
+			}
+			stats.WatchChats++
+		}
+
+		s := data.Settings
+		updates := map[string]any{}
+		setString := func(column, value string) {
+			if value != "" || replace {
+				updates[column] = value
+			}
+		}
+		setString("default_storage", s.DefaultStorage)
+		setString("filename_strategy", s.FilenameStrategy)
+		setString("filename_template", s.FilenameTemplate)
+		setString("conflict_strategy", s.ConflictStrategy)
+		setBool := func(column string, value *bool) {
+			if value != nil {
+				updates[column] = *value
+			} else if replace {
+				updates[column] = false
+			}
+		}
+		setBool("apply_rule", s.ApplyRule)
+		setBool("silent", s.Silent)
+		if replace {
+			// the default dir was one of the deleted dirs
+			updates["default_dir"] = 0
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&User{}).Where("id = ?", user.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	notifyRulesDeleted(deletedRules)
+	return stats, nil
+}
+
+func exportRules(rules []Rule) []userdata.Rule {
+	var result []userdata.Rule
+	for _, ru := range rules {
+		result = append(result, userdata.Rule{
+			Type:        ru.Type,
+			Data:        ru.Data,
+			StorageName: ru.StorageName,
+			DirPath:     ru.DirPath,
+			Priority:    ru.Priority,
+			Stop:        ru.Stop,
+			Action:      ru.Action,
+		})
+	}
+	return result
+}
+
+func importRule(ru userdata.Rule) Rule {
+	return Rule{
+		Type:        ru.Type,
+		Data:        ru.Data,
+		StorageName: ru.StorageName,
+		DirPath:     ru.DirPath,
+		Priority:    ru.Priority,
+		Stop:        ru.Stop,
+		Action:      ru.Action,
+	}
+}
+
+func (w *WatchChat) sameSettings(wc userdata.WatchChat) bool {
+	return w.Filter == wc.Filter && w.MediaTypes == wc.MediaTypes && w.SizeRange == wc.SizeRange &&
+		w.Senders == wc.Senders && w.Hashtags == wc.Hashtags && w.StorageName == wc.StorageName &&
+		w.DirPath == wc.DirPath && w.FilenameTemplate == wc.FilenameTemplate
+}
@@ -0,0 +1,170 @@
+// Package botapi downloads Telegram files through a Bot API server, usually a self-hosted telegram-bot-api
+// which has no limit on the size of the files.
+package botapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrInvalidURL   = errors.New("botapi: invalid URL")
+	ErrInvalidToken = errors.New("botapi: invalid bot token")
+)
+
+// Client is a client of a Bot API server for a bot
+type Client struct {
+	url    string
+	token  string
+	dir    string
+	client *http.Client
+}
+
+// File is a file ready to be downloaded from the server
+type File struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileSize     int64  `json:"file_size"`
+	// FilePath is the path to download the file from the server,
+	// or the absolute path of the file on the disk of the server running in --local mode.
+	FilePath string `json:"file_path"`
+}
+
+type response struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+// Error is an error returned by the server
+type Error struct {
+	Code        int
+	Description string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("botapi: error %d: %s", e.Code, e.Description)
+}
+
+// NewClient creates a client of the server at serverURL, e.g. "http://localhost:8081", for the bot of token.
+// dir is where the working directory of a server running in --local mode is mounted on this machine,
+// files are read from the paths returned by the server if it is empty.
+func NewClient(serverURL, token, dir string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	if token == "" || strings.Contains(token, "/") {
+		return nil, ErrInvalidToken
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Client{
+		url:    strings.TrimRight(serverURL, "/"),
+		token:  token,
+		dir:    dir,
+		client: httpClient,
+	}, nil
+}
+
+// GetFile prepares the file of fileID to be downloaded.
+// A server running in --local mode downloads the whole file to its disk before returning.
+func (c *Client) GetFile(ctx context.Context, fileID string) (*File, error) {
+	query := url.Values{"file_id": {fileID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/bot%s/getFile?%s", c.url, c.token, query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("botapi: failed to get file: %w", err)
+	}
+	defer resp.Body.Close()
+	var res response
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("botapi: invalid response with status %s: %w", resp.Status, err)
+	}
+	if !res.OK {
+		return nil, &Error{Code: res.ErrorCode, Description: res.Description}
+	}
+	var file File
+	if err := json.Unmarshal(res.Result, &file); err != nil {
+		return nil, fmt.Errorf("botapi: invalid file: %w", err)
+	}
+	if file.FilePath == "" {
+		return nil, errors.New("botapi: the file cannot be downloaded")
+	}
+	return &file, nil
+}
+
+// ReadAt reads up to len(p) bytes of the file starting at offset, fewer bytes are read only at the end of the file.
+func (c *Client) ReadAt(ctx context.Context, file *File, p []byte, offset int64) (int, error) {
+	if strings.HasPrefix(file.FilePath, "/") {
+		return c.readLocal(file, p, offset)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/file/bot%s/%s", c.url, c.token, file.FilePath), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+int64(len(p))-1))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("botapi: failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the server ignores the range and sends the whole file
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			return 0, readErr(err)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("botapi: failed to download file: %s", resp.Status)
+	}
+	n, err := io.ReadFull(resp.Body, p)
+	return n, readErr(err)
+}
+
+func (c *Client) readLocal(file *File, p []byte, offset int64) (int, error) {
+	f, err := os.Open(c.localPath(file.FilePath))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := f.ReadAt(p, offset)
+	return n, readErr(err)
+}
+
+// localPath maps the path of a file on the server to the mounted working directory of the server,
+// the files of a bot are in "<working directory>/<token>/" on the server.
+func (c *Client) localPath(path string) string {
+	if c.dir == "" {
+		return path
+	}
+	_, rel, ok := strings.Cut(filepath.ToSlash(path), "/"+c.token+"/")
+	if !ok {
+		return path
+	}
+	return filepath.Join(c.dir, c.token, filepath.FromSlash(rel))
+}
+
+// readErr drops the errors of reading past the end of the file.
+func readErr(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package botapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testToken = "123:abc"
+
+// newTestServer starts a stand-in of telegram-bot-api serving content as the file "documents/file_0.bin",
+// or as an absolute path on the disk in --local mode.
+func newTestServer(t *testing.T, content []byte, localPath string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bot"+testToken+"/getFile", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("file_id") != "known" {
+			fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: invalid file_id"}`)
+			return
+		}
+		path := "documents/file_0.bin"
+		if localPath != "" {
+			path = localPath
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":{"file_id":"known","file_unique_id":"u","file_size":%d,"file_path":%q}}`, len(content), path)
+	})
+	mux.HandleFunc("/file/bot"+testToken+"/documents/file_0.bin", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file_0.bin", time.Time{}, bytes.NewReader(content))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewClient(t *testing.T) {
+	if _, err := NewClient("", testToken, "", nil); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("NewClient() with empty url error = %v", err)
+	}
+	if _, err := NewClient("http://localhost:8081", "", "", nil); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("NewClient() with empty token error = %v", err)
+	}
+	if _, err := NewClient("http://localhost:8081/", testToken, "", nil); err != nil {
+		t.Errorf("NewClient() error = %v", err)
+	}
+}
+
+func TestGetFileError(t *testing.T) {
+	server := newTestServer(t, nil, "")
+	client, _ := NewClient(server.URL, testToken, "", server.Client())
+	_, err := client.GetFile(context.Background(), "unknown")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 400 {
+		t.Fatalf("GetFile() error = %v", err)
+	}
+}
+
+func TestReadAtRemote(t *testing.T) {
+	content := []byte("0123456789")
+	server := newTestServer(t, content, "")
+	client, _ := NewClient(server.URL, testToken, "", server.Client())
+	file, err := client.GetFile(context.Background(), "known")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.FileSize != int64(len(content)) {
+		t.Errorf("file size = %d", file.FileSize)
+	}
+	tests := []struct {
+		offset int64
+		limit  int
+		want   string
+	}{
+		{0, 4, "0123"},
+		{4, 4, "4567"},
+		{8, 4, "89"},
+		{12, 4, ""},
+	}
+	for _, tt := range tests {
+		buf := make([]byte, tt.limit)
+		n, err := client.ReadAt(context.Background(), file, buf, tt.offset)
+		if err != nil {
+			t.Fatalf("ReadAt(%d) error = %v", tt.offset, err)
+		}
+		if got := string(buf[:n]); got != tt.want {
+			t.Errorf("ReadAt(%d) = %q, want %q", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestReadAtLocal(t *testing.T) {
+	// the server runs in --local mode and its working directory /var/lib/telegram-bot-api is mounted at dir
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, testToken, "videos"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, testToken, "videos", "file_1.mp4"), []byte("local video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t, nil, "/var/lib/telegram-bot-api/"+testToken+"/videos/file_1.mp4")
+	client, _ := NewClient(server.URL, testToken, dir, server.Client())
+	file, err := client.GetFile(context.Background(), "known")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8)
+	n, err := client.ReadAt(context.Background(), file, buf, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "video" {
+		t.Errorf("ReadAt() = %q", got)
+	}
+
+	client, _ = NewClient(server.URL, testToken, "", server.Client())
+	if _, err := client.ReadAt(context.Background(), file, buf, 0); err == nil || !strings.Contains(err.Error(), "file_1.mp4") {
+		t.Errorf("ReadAt() without the mounted directory error = %v", err)
+	}
+}
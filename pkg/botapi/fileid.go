@@ -0,0 +1,141 @@
+package botapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+
+	"github.com/gotd/td/tg"
+)
+
+// FileType is the type of a file in a Bot API file_id
+type FileType int32
+
+const (
+	FileTypePhoto     FileType = 2
+	FileTypeVoice     FileType = 3
+	FileTypeVideo     FileType = 4
+	FileTypeDocument  FileType = 5
+	FileTypeSticker   FileType = 8
+	FileTypeAudio     FileType = 9
+	FileTypeAnimation FileType = 10
+	FileTypeVideoNote FileType = 13
+)
+
+const (
+	fileReferenceFlag = 1 << 25
+	thumbnailSource   = 1 // PhotoSizeSource::Thumbnail
+
+	// version of the file_id format, photo size sources are written without volume and local IDs since minor version 32
+	versionMinor = 34
+	versionMajor = 4
+)
+
+// DocumentFileType returns the file type of a document as the Bot API sees it, by its attributes.
+func DocumentFileType(doc *tg.Document) FileType {
+	fileType := FileTypeDocument
+	for _, attr := range doc.Attributes {
+		switch a := attr.(type) {
+		case *tg.DocumentAttributeVideo:
+			if a.RoundMessage {
+				return FileTypeVideoNote
+			}
+			if fileType == FileTypeDocument {
+				fileType = FileTypeVideo
+			}
+		case *tg.DocumentAttributeAudio:
+			if a.Voice {
+				return FileTypeVoice
+			}
+			fileType = FileTypeAudio
+		case *tg.DocumentAttributeAnimated:
+			return FileTypeAnimation
+		case *tg.DocumentAttributeSticker:
+			return FileTypeSticker
+		}
+	}
+	return fileType
+}
+
+// EncodeFileID returns the Bot API file_id of a document or photo location stored in the data center dcID.
+func EncodeFileID(location tg.InputFileLocationClass, fileType FileType, dcID int) (string, error) {
+	var (
+		id, accessHash int64
+		reference      []byte
+		thumbSize      string
+	)
+	switch loc := location.(type) {
+	case *tg.InputDocumentFileLocation:
+		if loc.ThumbSize != "" {
+			return "", errors.New("botapi: thumbnails of documents are not supported")
+		}
+		id, accessHash, reference = loc.ID, loc.AccessHash, loc.FileReference
+	case *tg.InputPhotoFileLocation:
+		if len(loc.ThumbSize) != 1 {
+			return "", errors.New("botapi: invalid photo size")
+		}
+		id, accessHash, reference, thumbSize = loc.ID, loc.AccessHash, loc.FileReference, loc.ThumbSize
+		fileType = FileTypePhoto
+	default:
+		return "", errors.New("botapi: unsupported file location")
+	}
+	if dcID <= 0 {
+		return "", errors.New("botapi: invalid DC ID")
+	}
+
+	var buf bytes.Buffer
+	header := int32(fileType)
+	if len(reference) > 0 {
+		header |= fileReferenceFlag
+	}
+	binary.Write(&buf, binary.LittleEndian, [2]int32{header, int32(dcID)})
+	if len(reference) > 0 {
+		writeTLBytes(&buf, reference)
+	}
+	binary.Write(&buf, binary.LittleEndian, [2]int64{id, accessHash})
+	if fileType == FileTypePhoto {
+		binary.Write(&buf, binary.LittleEndian, [3]int32{thumbnailSource, int32(FileTypePhoto), int32(thumbSize[0])})
+	}
+	buf.Write([]byte{versionMinor, versionMajor})
+	return base64.RawURLEncoding.EncodeToString(rleEncode(buf.Bytes())), nil
+}
+
+// writeTLBytes writes b as TL bytes, the length prefixed data padded to 4 bytes.
+func writeTLBytes(buf *bytes.Buffer, b []byte) {
+	n := len(b)
+	if n < 254 {
+		buf.WriteByte(byte(n))
+		n++
+	} else {
+		buf.Write([]byte{254, byte(len(b)), byte(len(b) >> 8), byte(len(b) >> 16)})
+		n += 4
+	}
+	buf.Write(b)
+	buf.Write(make([]byte, (4-n%4)%4))
+}
+
+// rleEncode replaces each run of zero bytes with a zero byte followed by the length of the run.
+func rleEncode(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if c == 0 && zeros < 250 {
+			zeros++
+			continue
+		}
+		if zeros > 0 {
+			out = append(out, 0, byte(zeros))
+			zeros = 0
+		}
+		if c == 0 {
+			zeros++
+			continue
+		}
+		out = append(out, c)
+	}
+	if zeros > 0 {
+		out = append(out, 0, byte(zeros))
+	}
+	return out
+}
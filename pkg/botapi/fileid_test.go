@@ -0,0 +1,183 @@
+package botapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func rleDecode(b []byte) []byte {
+	var out []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == 0 && i+1 < len(b) {
+			out = append(out, make([]byte, b[i+1])...)
+			i++
+			continue
+		}
+		out = append(out, b[i])
+	}
+	return out
+}
+
+func decodeFileID(t *testing.T, fileID string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(fileID)
+	if err != nil {
+		t.Fatalf("file_id is not base64: %v", err)
+	}
+	return rleDecode(b)
+}
+
+func TestRLEEncode(t *testing.T) {
+	data := append([]byte{1, 0, 0, 2}, make([]byte, 300)...)
+	data = append(data, 3)
+	encoded := rleEncode(data)
+	if !bytes.Equal(rleDecode(encoded), data) {
+		t.Fatalf("rleEncode() does not round trip: %v", encoded)
+	}
+	if !bytes.HasPrefix(encoded, []byte{1, 0, 2, 2, 0, 250, 0, 50, 3}) {
+		t.Fatalf("rleEncode() = %v", encoded)
+	}
+}
+
+func TestEncodeDocumentFileID(t *testing.T) {
+	fileID, err := EncodeFileID(&tg.InputDocumentFileLocation{ID: 42, AccessHash: -7, FileReference: []byte("ref")}, FileTypeVideo, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := decodeFileID(t, fileID)
+
+	var header [2]int32
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, &header)
+	if header[0] != int32(FileTypeVideo)|fileReferenceFlag || header[1] != 4 {
+		t.Fatalf("header = %v", header)
+	}
+	// TL bytes: length, "ref"
+	if !bytes.Equal(data[8:12], []byte{3, 'r', 'e', 'f'}) {
+		t.Fatalf("file reference = %v", data[8:12])
+	}
+	var ids [2]int64
+	binary.Read(bytes.NewReader(data[12:]), binary.LittleEndian, &ids)
+	if ids != [2]int64{42, -7} {
+		t.Fatalf("id and access hash = %v", ids)
+	}
+	if !bytes.Equal(data[28:], []byte{versionMinor, versionMajor}) {
+		t.Fatalf("version = %v", data[28:])
+	}
+}
+
+func TestEncodePhotoFileID(t *testing.T) {
+	fileID, err := EncodeFileID(&tg.InputPhotoFileLocation{ID: 1, AccessHash: 2, ThumbSize: "y"}, FileTypeDocument, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := decodeFileID(t, fileID)
+	var fields [2]int32
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, &fields)
+	if fields != [2]int32{int32(FileTypePhoto), 2} {
+		t.Fatalf("header = %v", fields)
+	}
+	var thumb [3]int32
+	binary.Read(bytes.NewReader(data[24:]), binary.LittleEndian, &thumb)
+	if thumb != [3]int32{thumbnailSource, int32(FileTypePhoto), 'y'} {
+		t.Fatalf("thumbnail = %v", thumb)
+	}
+}
+
+// TestEncodeRealFileIDs encodes files sent by a Bot API server, the vectors of the fileid tests of gotd/td.
+func TestEncodeRealFileIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		location tg.InputFileLocationClass
+		fileType FileType
+		want     string
+	}{
+		{
+			"video",
+			&tg.InputDocumentFileLocation{
+				ID:            5233570104335143242,
+				AccessHash:    4819682371444353606,
+				FileReference: []byte("\x01\x00\x00\x00@a\x9c\xe3J@\x95c\xb4\xed\xae\x9d\xa5\xf7g\x82C6\x18\xc5Q"),
+			},
+			FileTypeVideo,
+			"BAACAgIAAxkBAANAYZzjSkCVY7Ttrp2l92eCQzYYxVEAAkoRAAJIYKFIRionwJTz4kIiBA",
+		},
+		{
+			"audio",
+			&tg.InputDocumentFileLocation{
+				ID:            5366039677566452464,
+				AccessHash:    2905629019683770424,
+				FileReference: []byte("\x01\x00\x00\x00Da\x9c\xed\xde\xb0\xc0\xc3\x90\xa4\x1d%<E\x90<\x034\xd3\xb3#"),
+			},
+			FileTypeAudio,
+			"CQACAgIAAxkBAANEYZzt3rDAw5CkHSU8RZA8AzTTsyMAAvACAAKoAAF4SjhQUd8y3lIoIgQ",
+		},
+		{
+			"photo",
+			&tg.InputPhotoFileLocation{
+				ID:            5249364129762884486,
+				AccessHash:    5280454898771269252,
+				FileReference: []byte("\x01\x00\x00\x00=a\x9a\x97\x1b\xe0tXq/\xeeQeC\x13\x90\x0e\xce\xa3\xacd"),
+				ThumbSize:     "x",
+			},
+			FileTypePhoto,
+			"AgACAgIAAxkBAAM9YZqXG-B0WHEv7lFlQxOQDs6jrGQAAoa7MRvdfNlIhJa73cDxR0kBAAMCAAN4AAMiBA",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncodeFileID(tt.location, tt.fileType, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("EncodeFileID() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeFileIDErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		location tg.InputFileLocationClass
+		dcID     int
+	}{
+		{"document thumbnail", &tg.InputDocumentFileLocation{ID: 1, ThumbSize: "m"}, 1},
+		{"photo without size", &tg.InputPhotoFileLocation{ID: 1}, 1},
+		{"no DC", &tg.InputDocumentFileLocation{ID: 1}, 0},
+		{"unsupported location", &tg.InputPeerPhotoFileLocation{}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := EncodeFileID(tt.location, FileTypeDocument, tt.dcID); err == nil {
+				t.Fatal("EncodeFileID() expected an error")
+			}
+		})
+	}
+}
+
+func TestDocumentFileType(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs []tg.DocumentAttributeClass
+		want  FileType
+	}{
+		{"document", []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: "a.zip"}}, FileTypeDocument},
+		{"video", []tg.DocumentAttributeClass{&tg.DocumentAttributeVideo{}}, FileTypeVideo},
+		{"video note", []tg.DocumentAttributeClass{&tg.DocumentAttributeVideo{RoundMessage: true}}, FileTypeVideoNote},
+		{"animation", []tg.DocumentAttributeClass{&tg.DocumentAttributeVideo{}, &tg.DocumentAttributeAnimated{}}, FileTypeAnimation},
+		{"audio", []tg.DocumentAttributeClass{&tg.DocumentAttributeAudio{}}, FileTypeAudio},
+		{"voice", []tg.DocumentAttributeClass{&tg.DocumentAttributeAudio{Voice: true}}, FileTypeVoice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DocumentFileType(&tg.Document{Attributes: tt.attrs}); got != tt.want {
+				t.Errorf("DocumentFileType() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
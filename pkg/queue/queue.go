@@ -42,7 +42,12 @@ func (tq *TaskQueue[T]) Add(task *Task[T]) error {
 		return fmt.Errorf("task %s has been cancelled", task.ID)
 	}
 
-	element := tq.tasks.PushBack(task)
+	var element *list.Element
+	if first := tq.firstLowPriority(); first != nil && !task.LowPriority {
+		element = tq.tasks.InsertBefore(task, first)
+	} else {
+		element = tq.tasks.PushBack(task)
+	}
 	task.element = element
 	tq.taskMap[task.ID] = task
 
@@ -50,6 +55,16 @@ func (tq *TaskQueue[T]) Add(task *Task[T]) error {
 	return nil
 }
 
+// firstLowPriority returns the first low priority task in the queue, or nil.
+func (tq *TaskQueue[T]) firstLowPriority() *list.Element {
+	for element := tq.tasks.Front(); element != nil; element = element.Next() {
+		if element.Value.(*Task[T]).LowPriority {
+			return element
+		}
+	}
+	return nil
+}
+
 // Get retrieves and removes the next non-cancelled task from the queue, adding it to the running tasks.
 // Blocks until a task is available or the queue is closed.
 func (tq *TaskQueue[T]) Get() (*Task[T], error) {
@@ -128,6 +143,7 @@ func (tq *TaskQueue[T]) RunningTasks() []TaskInfo {
 			Title:     task.Title,
 			Created:   task.created,
 			Cancelled: task.Cancelled(),
+			Owner:     task.Owner,
 		})
 	}
 	return tasks
@@ -148,6 +164,7 @@ func (tq *TaskQueue[T]) QueuedTasks() []TaskInfo {
 				Title:     task.Title,
 				Created:   task.created,
 				Cancelled: task.Cancelled(),
+				Owner:     task.Owner,
 			})
 		}
 	}
@@ -100,3 +100,26 @@ func TestConcurrencySafety(t *testing.T) {
 	})
 	wg.Wait()
 }
+
+func TestLowPriorityOrder(t *testing.T) {
+	q := queue.NewTaskQueue[int]()
+	low1 := newTask("low1")
+	low1.LowPriority = true
+	low2 := newTask("low2")
+	low2.LowPriority = true
+	for _, task := range []*queue.Task[int]{newTask("n1"), low1, newTask("n2"), low2, newTask("n3")} {
+		if err := q.Add(task); err != nil {
+			t.Fatalf("unexpected error on Add: %v", err)
+		}
+	}
+	want := []string{"n1", "n2", "n3", "low1", "low2"}
+	for _, id := range want {
+		task, err := q.Get()
+		if err != nil {
+			t.Fatalf("unexpected error on Get: %v", err)
+		}
+		if task.ID != id {
+			t.Fatalf("expected %s, got %s", id, task.ID)
+		}
+	}
+}
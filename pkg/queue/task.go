@@ -3,17 +3,23 @@ package queue
 import (
 	"container/list"
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrCancelled is the cause of the context of a cancelled task, telling it from contexts cancelled by their parent, e.g. on shutdown.
+var ErrCancelled = errors.New("task cancelled")
+
 type Task[T any] struct {
-	ID      string
-	Title   string
-	Data    T
-	ctx     context.Context
-	cancel  context.CancelFunc
-	created time.Time
-	element *list.Element
+	ID          string
+	Title       string
+	Data        T
+	LowPriority bool  // low priority tasks are queued after all normal tasks
+	Owner       int64 // chat ID of the user the task belongs to, zero if unknown
+	ctx         context.Context
+	cancel      context.CancelCauseFunc
+	created     time.Time
+	element     *list.Element
 }
 
 // Read-only info about a task
@@ -22,10 +28,11 @@ type TaskInfo struct {
 	Created   time.Time
 	Cancelled bool
 	Title     string
+	Owner     int64
 }
 
 func NewTask[T any](ctx context.Context, id string, title string, data T) *Task[T] {
-	cancelCtx, cancel := context.WithCancel(ctx)
+	cancelCtx, cancel := context.WithCancelCause(ctx)
 	return &Task[T]{
 		ID:      id,
 		Title:   title,
@@ -46,7 +53,7 @@ func (t *Task[T]) Cancelled() bool {
 }
 
 func (t *Task[T]) Cancel() {
-	t.cancel()
+	t.cancel(ErrCancelled)
 }
 
 func (t *Task[T]) Context() context.Context {
@@ -0,0 +1,124 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+)
+
+// Actions stored in Rule.Action, multiple actions are separated by ';' followed by an action,
+// so that a filename template may contain other ';', e.g.
+//
+//	skip
+//	rename={{.msgid}}_{{.origname}};conflict=overwrite;low-priority
+const (
+	ActionSkip        = "skip"         // do not save the file
+	ActionRename      = "rename"       // rename=<filename template>, same syntax as the user filename template
+	ActionConflict    = "conflict"     // conflict=<rename|ask|overwrite|skip>
+	ActionLowPriority = "low-priority" // queue the task after normal tasks
+)
+
+// RuleKeep as the storage name or dir path of a rule keeps what was chosen before the rule,
+// so that a rule can only act without routing.
+const RuleKeep = "-"
+
+type Actions struct {
+	Skip             bool
+	FilenameTemplate string
+	ConflictStrategy string
+	LowPriority      bool
+}
+
+func (a Actions) IsZero() bool {
+	return a == Actions{}
+}
+
+// Merge overrides a with the actions set in b.
+func (a *Actions) Merge(b Actions) {
+	a.Skip = a.Skip || b.Skip
+	a.LowPriority = a.LowPriority || b.LowPriority
+	if b.FilenameTemplate != "" {
+		a.FilenameTemplate = b.FilenameTemplate
+	}
+	if b.ConflictStrategy != "" {
+		a.ConflictStrategy = b.ConflictStrategy
+	}
+}
+
+func (a Actions) String() string {
+	var parts []string
+	if a.Skip {
+		parts = append(parts, ActionSkip)
+	}
+	if a.FilenameTemplate != "" {
+		parts = append(parts, ActionRename+"="+a.FilenameTemplate)
+	}
+	if a.ConflictStrategy != "" {
+		parts = append(parts, ActionConflict+"="+a.ConflictStrategy)
+	}
+	if a.LowPriority {
+		parts = append(parts, ActionLowPriority)
+	}
+	return strings.Join(parts, ";")
+}
+
+func ParseActions(s string) (Actions, error) {
+	var a Actions
+	for _, part := range splitActions(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(part, "=")
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case ActionSkip:
+			a.Skip = true
+		case ActionLowPriority:
+			a.LowPriority = true
+		case ActionRename:
+			if value == "" {
+				return a, fmt.Errorf("empty filename template in %q", part)
+			}
+			if _, err := template.New("filename").Parse(value); err != nil {
+				return a, fmt.Errorf("invalid filename template: %w", err)
+			}
+			a.FilenameTemplate = value
+		case ActionConflict:
+			value = strings.ToLower(strings.TrimSpace(value))
+			if !tcbdata.IsConflictStrategy(value) {
+				return a, fmt.Errorf("invalid conflict strategy %q, available: %s", value, strings.Join(tcbdata.ConflictStrategyValues(), ", "))
+			}
+			a.ConflictStrategy = value
+		default:
+			return a, fmt.Errorf("unknown action %q", key)
+		}
+	}
+	return a, nil
+}
+
+// splitActions splits s at each ';' followed by an action.
+func splitActions(s string) []string {
+	var parts []string
+	start := 0
+	for i := range len(s) {
+		if s[i] == ';' && startsWithAction(s[i+1:]) {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func startsWithAction(s string) bool {
+	key := s
+	if i := strings.IndexAny(s, "=;"); i >= 0 {
+		key = s[:i]
+	}
+	switch strings.ToLower(strings.TrimSpace(key)) {
+	case "", ActionSkip, ActionRename, ActionConflict, ActionLowPriority:
+		return true
+	}
+	return false
+}
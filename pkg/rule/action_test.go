@@ -0,0 +1,40 @@
+package rule
+
+import "testing"
+
+func TestParseActions(t *testing.T) {
+	a, err := ParseActions("skip; rename={{.msgid}}_{{.origname}} ;conflict=Overwrite;low-priority")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Actions{Skip: true, FilenameTemplate: "{{.msgid}}_{{.origname}}", ConflictStrategy: "overwrite", LowPriority: true}
+	if a != want {
+		t.Errorf("got %+v, want %+v", a, want)
+	}
+	back, err := ParseActions(a.String())
+	if err != nil || back != a {
+		t.Errorf("round trip failed: %+v, %v", back, err)
+	}
+
+	for _, s := range []string{"delete", "conflict=never", "rename=", "rename={{.msgid"} {
+		if _, err := ParseActions(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+	a, err = ParseActions("rename={{.msgid}};{{.origname}}; Conflict=skip;")
+	if err != nil || a.FilenameTemplate != "{{.msgid}};{{.origname}}" || a.ConflictStrategy != "skip" {
+		t.Errorf("template with ';': %+v, %v", a, err)
+	}
+	if a, err := ParseActions(""); err != nil || !a.IsZero() {
+		t.Errorf("empty actions: %+v, %v", a, err)
+	}
+}
+
+func TestActionsMerge(t *testing.T) {
+	a := Actions{ConflictStrategy: "skip", FilenameTemplate: "a"}
+	a.Merge(Actions{LowPriority: true, FilenameTemplate: "b"})
+	want := Actions{ConflictStrategy: "skip", FilenameTemplate: "b", LowPriority: true}
+	if a != want {
+		t.Errorf("got %+v, want %+v", a, want)
+	}
+}
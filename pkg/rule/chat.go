@@ -0,0 +1,133 @@
+package rule
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// peerList matches a peer against comma separated IDs and usernames, "*" matches any peer.
+type peerList struct {
+	any       bool
+	ids       []float64
+	usernames []string
+}
+
+func parsePeerList(data string) (peerList, error) {
+	var l peerList
+	for item := range strings.SplitSeq(data, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+			continue
+		case item == "*":
+			l.any = true
+		default:
+			if id, err := strconv.ParseInt(item, 10, 64); err == nil {
+				l.ids = append(l.ids, float64(id))
+				continue
+			}
+			l.usernames = append(l.usernames, strings.TrimPrefix(item, "@"))
+		}
+	}
+	if !l.any && len(l.ids) == 0 && len(l.usernames) == 0 {
+		return l, errors.New("empty chat list")
+	}
+	return l, nil
+}
+
+func (l peerList) match(p Peer) bool {
+	if p.ID == 0 && p.Username == "" && p.Title == "" {
+		return false
+	}
+	if l.any {
+		return true
+	}
+	for _, id := range l.ids {
+		if p.matchID(id) {
+			return true
+		}
+	}
+	for _, name := range l.usernames {
+		if p.matchName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleSourceChat matches files by the chat the message is in.
+type RuleSourceChat struct {
+	storInfo
+	peers peerList
+}
+
+var _ RuleClass[Peer] = (*RuleSourceChat)(nil)
+
+func (r RuleSourceChat) Type() RuleType {
+	return SourceChat
+}
+
+func (r RuleSourceChat) Match(input Peer) (bool, error) {
+	return r.peers.match(input), nil
+}
+
+func (r RuleSourceChat) StorageName() string {
+	return r.storName
+}
+
+func (r RuleSourceChat) StoragePath() string {
+	return r.storPath
+}
+
+func NewRuleSourceChat(storName, storPath, chats string) (*RuleSourceChat, error) {
+	peers, err := parsePeerList(chats)
+	if err != nil {
+		return nil, err
+	}
+	return &RuleSourceChat{
+		storInfo: storInfo{
+			storName: storName,
+			storPath: storPath,
+		},
+		peers: peers,
+	}, nil
+}
+
+// RuleForwardFrom matches forwarded files by the origin in their forward header.
+type RuleForwardFrom struct {
+	storInfo
+	peers peerList
+}
+
+var _ RuleClass[Peer] = (*RuleForwardFrom)(nil)
+
+func (r RuleForwardFrom) Type() RuleType {
+	return ForwardFrom
+}
+
+func (r RuleForwardFrom) Match(input Peer) (bool, error) {
+	return r.peers.match(input), nil
+}
+
+func (r RuleForwardFrom) StorageName() string {
+	return r.storName
+}
+
+func (r RuleForwardFrom) StoragePath() string {
+	return r.storPath
+}
+
+func NewRuleForwardFrom(storName, storPath, chats string) (*RuleForwardFrom, error) {
+	peers, err := parsePeerList(chats)
+	if err != nil {
+		return nil, err
+	}
+	return &RuleForwardFrom{
+		storInfo: storInfo{
+			storName: storName,
+			storPath: storPath,
+		},
+		peers: peers,
+	}, nil
+}
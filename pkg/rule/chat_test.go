@@ -0,0 +1,45 @@
+package rule
+
+import "testing"
+
+func TestRuleSourceChat(t *testing.T) {
+	chat := Peer{ID: 1234567890, Username: "MyChannel"}
+	cases := map[string]bool{
+		"-1001234567890":     true,
+		"1234567890":         true,
+		"@mychannel":         true,
+		"other, MyChannel":   true,
+		"@other,-1009999999": false,
+		"*":                  true,
+	}
+	for data, want := range cases {
+		ru, err := NewRuleSourceChat("local", "/", data)
+		if err != nil {
+			t.Errorf("%q: %v", data, err)
+			continue
+		}
+		if got, _ := ru.Match(chat); got != want {
+			t.Errorf("%q: got %v, want %v", data, got, want)
+		}
+	}
+	if _, err := NewRuleSourceChat("local", "/", " , "); err == nil {
+		t.Error("expected error for empty chat list")
+	}
+}
+
+func TestRuleForwardFrom(t *testing.T) {
+	ru, err := NewRuleForwardFrom("local", "/{{chat_title}}", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := ru.Match(Peer{}); ok {
+		t.Error("non-forwarded message must not match *")
+	}
+	if ok, _ := ru.Match(Peer{Title: "Hidden Sender"}); !ok {
+		t.Error("forward with only a sender name should match *")
+	}
+	ru, _ = NewRuleForwardFrom("local", "/", "42")
+	if ok, _ := ru.Match(Peer{ID: 42}); !ok {
+		t.Error("expected forward from user 42 to match")
+	}
+}
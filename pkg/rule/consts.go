@@ -4,3 +4,10 @@ const (
 	RuleStorNameChosen     = "CHOSEN"
 	RuleDirPathNewForAlbum = "NEW-FOR-ALBUM" // create a new directory for album files
 )
+
+// placeholders in rule dir paths, replaced with the chat of the matched message
+const (
+	DirPlaceholderChatID       = "{{chat_id}}"
+	DirPlaceholderChatTitle    = "{{chat_title}}"
+	DirPlaceholderChatUsername = "{{chat_username}}"
+)
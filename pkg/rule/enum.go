@@ -6,6 +6,12 @@ const (
 	FileNameRegex RuleType = "FILENAME-REGEX"
 	MessageRegex  RuleType = "MESSAGE-REGEX"
 	IsAlbum       RuleType = "IS-ALBUM"
+	FileSize      RuleType = "FILE-SIZE"
+	MimeType      RuleType = "MIME-TYPE"
+	MediaDuration RuleType = "MEDIA-DURATION"
+	SourceChat    RuleType = "SOURCE-CHAT"
+	ForwardFrom   RuleType = "FORWARD-FROM"
+	Expr          RuleType = "EXPR"
 )
 
 func (r RuleType) String() string {
@@ -13,5 +19,5 @@ func (r RuleType) String() string {
 }
 
 func Values() []RuleType {
-	return []RuleType{FileNameRegex, MessageRegex, IsAlbum}
+	return []RuleType{FileNameRegex, MessageRegex, IsAlbum, FileSize, MimeType, MediaDuration, SourceChat, ForwardFrom, Expr}
 }
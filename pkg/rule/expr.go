@@ -0,0 +1,651 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Peer is a chat or user an EXPR rule can compare against. It equals a number
+// when the number is its ID (bare or in the -100 channel form) and a string
+// when the string is its username, with or without the leading @.
+type Peer struct {
+	ID       int64
+	Username string
+	Title    string
+}
+
+func (p Peer) matchID(n float64) bool {
+	if p.ID == 0 {
+		return false
+	}
+	id := float64(p.ID)
+	return n == id || n == -id || n == -(1e12+id)
+}
+
+func (p Peer) matchName(s string) bool {
+	s = strings.TrimPrefix(s, "@")
+	return p.Username != "" && strings.EqualFold(p.Username, s)
+}
+
+// ExprEnv holds the attributes an EXPR rule can refer to.
+type ExprEnv struct {
+	Name     string
+	Size     int64
+	Mime     string
+	Duration float64 // seconds
+	Chat     Peer
+	Sender   Peer
+	Forward  Peer // zero when the message is not forwarded
+	Caption  string
+	Date     time.Time
+	Album    bool
+}
+
+// exprIdents lists the identifiers available in EXPR rules.
+var exprIdents = map[string]func(env *ExprEnv) value{
+	"name":     func(env *ExprEnv) value { return strValue(env.Name) },
+	"size":     func(env *ExprEnv) value { return numValue(float64(env.Size)) },
+	"mime":     func(env *ExprEnv) value { return strValue(env.Mime) },
+	"duration": func(env *ExprEnv) value { return numValue(env.Duration) },
+	"chat":     func(env *ExprEnv) value { return peerValue(env.Chat) },
+	"sender":   func(env *ExprEnv) value { return peerValue(env.Sender) },
+	"forward":  func(env *ExprEnv) value { return peerValue(env.Forward) },
+	"caption":  func(env *ExprEnv) value { return strValue(env.Caption) },
+	"date":     func(env *ExprEnv) value { return timeValue(env.Date) },
+	"album":    func(env *ExprEnv) value { return boolValue(env.Album) },
+}
+
+var _ RuleClass[*ExprEnv] = (*RuleExpr)(nil)
+
+// RuleExpr matches files with a boolean expression over ExprEnv, e.g.
+//
+//	size > 500MB && (chat == @foo || forward == -1001234567890) && !album
+type RuleExpr struct {
+	storInfo
+	root node
+}
+
+func (r RuleExpr) Type() RuleType {
+	return Expr
+}
+
+func (r RuleExpr) Match(input *ExprEnv) (bool, error) {
+	v, err := r.root.eval(input)
+	if err != nil {
+		return false, err
+	}
+	return v.truthy(), nil
+}
+
+func (r RuleExpr) StorageName() string {
+	return r.storName
+}
+
+func (r RuleExpr) StoragePath() string {
+	return r.storPath
+}
+
+func NewRuleExpr(storName, storPath, exprStr string) (*RuleExpr, error) {
+	root, err := compileExpr(exprStr)
+	if err != nil {
+		return nil, err
+	}
+	return &RuleExpr{
+		storInfo: storInfo{
+			storName: storName,
+			storPath: storPath,
+		},
+		root: root,
+	}, nil
+}
+
+// compileExpr parses an EXPR rule and checks that every identifier exists.
+func compileExpr(s string) (node, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+	return n, nil
+}
+
+// ---- values ----
+
+type valueKind int
+
+const (
+	kindNum valueKind = iota
+	kindStr
+	kindBool
+	kindPeer
+	kindTime
+	kindUser // @username literal
+)
+
+type value struct {
+	kind valueKind
+	num  float64
+	str  string
+	b    bool
+	peer Peer
+	t    time.Time
+}
+
+func numValue(n float64) value    { return value{kind: kindNum, num: n} }
+func strValue(s string) value     { return value{kind: kindStr, str: s} }
+func boolValue(b bool) value      { return value{kind: kindBool, b: b} }
+func peerValue(p Peer) value      { return value{kind: kindPeer, peer: p} }
+func timeValue(t time.Time) value { return value{kind: kindTime, t: t} }
+
+func (v value) truthy() bool {
+	switch v.kind {
+	case kindBool:
+		return v.b
+	case kindNum:
+		return v.num != 0
+	case kindStr:
+		return v.str != ""
+	case kindPeer:
+		return v.peer.ID != 0 || v.peer.Username != "" || v.peer.Title != ""
+	case kindTime:
+		return !v.t.IsZero()
+	}
+	return false
+}
+
+// ---- lexer ----
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNum
+	tokStr
+	tokUser
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokKind
+	text string
+	num  float64
+	pos  int
+}
+
+var sizeUnits = map[string]float64{
+	"B": 1, "KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30, "TB": 1 << 40,
+	"KIB": 1 << 10, "MIB": 1 << 20, "GIB": 1 << 30, "TIB": 1 << 40,
+}
+
+var durationUnits = map[string]float64{
+	"s": 1, "sec": 1, "min": 60, "h": 3600,
+}
+
+// parseUnit returns the multiplier for a number suffix. Size units are case
+// insensitive, duration units are lower case so that "m" is never ambiguous.
+func parseUnit(u string) (float64, bool) {
+	if u == "" {
+		return 1, true
+	}
+	if m, ok := durationUnits[u]; ok {
+		return m, true
+	}
+	m, ok := sizeUnits[strings.ToUpper(u)]
+	return m, ok
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			quote := c
+			var sb strings.Builder
+			i++
+			for i < len(rs) && rs[i] != quote {
+				// only the quote can be escaped, so regexes like \d stay intact
+				if rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == quote {
+					i++
+				}
+				sb.WriteRune(rs[i])
+				i++
+			}
+			if i >= len(rs) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			toks = append(toks, token{kind: tokStr, text: sb.String(), pos: start})
+		case c == '@':
+			start := i
+			i++
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			if i == start+1 {
+				return nil, fmt.Errorf("empty username at position %d", start)
+			}
+			toks = append(toks, token{kind: tokUser, text: string(rs[start+1 : i]), pos: start})
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			start := i
+			i++
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			numEnd := i
+			for i < len(rs) && unicode.IsLetter(rs[i]) {
+				i++
+			}
+			n, err := strconv.ParseFloat(string(rs[start:numEnd]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", string(rs[start:i]), start)
+			}
+			unit := string(rs[numEnd:i])
+			m, ok := parseUnit(unit)
+			if !ok {
+				return nil, fmt.Errorf("unknown unit %q at position %d", unit, numEnd)
+			}
+			toks = append(toks, token{kind: tokNum, text: string(rs[start:i]), num: n * m, pos: start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			word := string(rs[start:i])
+			switch strings.ToUpper(word) {
+			case "AND":
+				toks = append(toks, token{kind: tokOp, text: "&&", pos: start})
+			case "OR":
+				toks = append(toks, token{kind: tokOp, text: "||", pos: start})
+			case "NOT":
+				toks = append(toks, token{kind: tokOp, text: "!", pos: start})
+			default:
+				toks = append(toks, token{kind: tokIdent, text: word, pos: start})
+			}
+		default:
+			op, width := "", 2
+			if i+1 < len(rs) {
+				switch two := string(rs[i : i+2]); two {
+				case "&&", "||", "==", "!=", ">=", "<=", "=~", "!~":
+					op = two
+				}
+			}
+			if op == "" {
+				width = 1
+				switch c {
+				case '!', '>', '<':
+					op = string(c)
+				case '=':
+					op = "=="
+				default:
+					return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+				}
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += width
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, text: "end of expression", pos: len(rs)})
+	return toks, nil
+}
+
+// ---- parser ----
+
+type node interface {
+	eval(env *ExprEnv) (value, error)
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == op
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	return p.parseComparison()
+}
+
+var comparisonOps = map[string]bool{
+	"==": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true, "=~": true, "!~": true,
+}
+
+func (p *parser) parseComparison() (node, error) {
+	if p.peek().kind == tokLParen {
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, fmt.Errorf("expected ) at position %d", p.peek().pos)
+		}
+		p.next()
+		return n, nil
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOp || !comparisonOps[t.text] {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	cmp := &cmpNode{op: t.text, left: left, right: right}
+	if t.text == "=~" || t.text == "!~" {
+		lit, ok := right.(litNode)
+		if !ok || lit.v.kind != kindStr {
+			return nil, fmt.Errorf("right side of %s must be a string at position %d", t.text, t.pos)
+		}
+		re, err := regexp.Compile(lit.v.str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex at position %d: %w", t.pos, err)
+		}
+		cmp.re = re
+	}
+	return cmp, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNum:
+		return litNode{numValue(t.num)}, nil
+	case tokStr:
+		return litNode{strValue(t.text)}, nil
+	case tokUser:
+		return litNode{value{kind: kindUser, str: t.text}}, nil
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return litNode{boolValue(true)}, nil
+		case "false":
+			return litNode{boolValue(false)}, nil
+		}
+		get, ok := exprIdents[strings.ToLower(t.text)]
+		if !ok {
+			return nil, fmt.Errorf("unknown identifier %q at position %d", t.text, t.pos)
+		}
+		return identNode{name: t.text, get: get}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+// ---- evaluation ----
+
+type litNode struct{ v value }
+
+func (n litNode) eval(*ExprEnv) (value, error) { return n.v, nil }
+
+type identNode struct {
+	name string
+	get  func(env *ExprEnv) value
+}
+
+func (n identNode) eval(env *ExprEnv) (value, error) { return n.get(env), nil }
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(env *ExprEnv) (value, error) {
+	l, err := n.left.eval(env)
+	if err != nil || !l.truthy() {
+		return boolValue(false), err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return boolValue(false), err
+	}
+	return boolValue(r.truthy()), nil
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(env *ExprEnv) (value, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return boolValue(false), err
+	}
+	if l.truthy() {
+		return boolValue(true), nil
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return boolValue(false), err
+	}
+	return boolValue(r.truthy()), nil
+}
+
+type notNode struct{ n node }
+
+func (n notNode) eval(env *ExprEnv) (value, error) {
+	v, err := n.n.eval(env)
+	if err != nil {
+		return boolValue(false), err
+	}
+	return boolValue(!v.truthy()), nil
+}
+
+type cmpNode struct {
+	op          string
+	left, right node
+	re          *regexp.Regexp
+}
+
+var errTypeMismatch = errors.New("type mismatch")
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func (n *cmpNode) eval(env *ExprEnv) (value, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+	if n.re != nil {
+		var ok bool
+		switch l.kind {
+		case kindStr:
+			ok = n.re.MatchString(l.str)
+		case kindPeer:
+			ok = (l.peer.Username != "" && n.re.MatchString(l.peer.Username)) || (l.peer.Title != "" && n.re.MatchString(l.peer.Title))
+		default:
+			return value{}, fmt.Errorf("%s needs a string or chat on the left: %w", n.op, errTypeMismatch)
+		}
+		return boolValue(ok == (n.op == "=~")), nil
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+	// Put the peer or time on the left so the cases below stay symmetric.
+	if r.kind == kindPeer || r.kind == kindTime {
+		l, r = r, l
+		n = &cmpNode{op: flipOp(n.op)}
+	}
+	switch l.kind {
+	case kindPeer:
+		var eq bool
+		switch r.kind {
+		case kindNum:
+			eq = l.peer.matchID(r.num)
+		case kindStr, kindUser:
+			eq = l.peer.matchName(r.str)
+		case kindPeer:
+			eq = l.peer.ID != 0 && l.peer.ID == r.peer.ID
+		default:
+			return value{}, fmt.Errorf("cannot compare chat: %w", errTypeMismatch)
+		}
+		return equality(n.op, eq)
+	case kindTime:
+		var rt time.Time
+		switch r.kind {
+		case kindStr:
+			rt, err = parseDate(r.str)
+			if err != nil {
+				return value{}, err
+			}
+		case kindTime:
+			rt = r.t
+		default:
+			return value{}, fmt.Errorf("cannot compare date: %w", errTypeMismatch)
+		}
+		return ordered(n.op, float64(l.t.Unix()), float64(rt.Unix()))
+	case kindNum:
+		if r.kind != kindNum {
+			return value{}, fmt.Errorf("cannot compare number with non-number: %w", errTypeMismatch)
+		}
+		return ordered(n.op, l.num, r.num)
+	case kindStr, kindUser:
+		if r.kind != kindStr && r.kind != kindUser {
+			return value{}, fmt.Errorf("cannot compare string with non-string: %w", errTypeMismatch)
+		}
+		// A pattern with wildcards is matched as a glob, e.g. mime == "video/*".
+		eq := l.str == r.str
+		if !eq && strings.ContainsAny(r.str, "*?[") {
+			eq, _ = path.Match(r.str, l.str)
+		}
+		return equality(n.op, eq)
+	case kindBool:
+		if r.kind != kindBool {
+			return value{}, fmt.Errorf("cannot compare bool with non-bool: %w", errTypeMismatch)
+		}
+		return equality(n.op, l.b == r.b)
+	}
+	return value{}, errTypeMismatch
+}
+
+func flipOp(op string) string {
+	switch op {
+	case ">":
+		return "<"
+	case "<":
+		return ">"
+	case ">=":
+		return "<="
+	case "<=":
+		return ">="
+	}
+	return op
+}
+
+func equality(op string, eq bool) (value, error) {
+	switch op {
+	case "==":
+		return boolValue(eq), nil
+	case "!=":
+		return boolValue(!eq), nil
+	}
+	return value{}, fmt.Errorf("operator %s is not supported here: %w", op, errTypeMismatch)
+}
+
+func ordered(op string, l, r float64) (value, error) {
+	switch op {
+	case "==":
+		return boolValue(l == r), nil
+	case "!=":
+		return boolValue(l != r), nil
+	case ">":
+		return boolValue(l > r), nil
+	case ">=":
+		return boolValue(l >= r), nil
+	case "<":
+		return boolValue(l < r), nil
+	case "<=":
+		return boolValue(l <= r), nil
+	}
+	return value{}, fmt.Errorf("operator %s is not supported here: %w", op, errTypeMismatch)
+}
@@ -0,0 +1,90 @@
+package rule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuleExprMatch(t *testing.T) {
+	env := &ExprEnv{
+		Name:     "movie.mkv",
+		Size:     600 << 20,
+		Mime:     "video/x-matroska",
+		Duration: 5400,
+		Chat:     Peer{ID: 1234567890, Username: "Foo"},
+		Sender:   Peer{ID: 42},
+		Caption:  "#movie new release",
+		Date:     time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local),
+	}
+
+	cases := map[string]bool{
+		`size > 500MB && chat == @foo`:                true,
+		`size > 500MB AND chat == @bar`:               false,
+		`size > 1GB || name =~ "\.mkv$"`:              true,
+		`NOT album`:                                   true,
+		`!forward`:                                    true,
+		`mime == "video/*" && duration >= 1h`:         true,
+		`duration < 30min`:                            false,
+		`chat == -1001234567890`:                      true,
+		`chat == 1234567890 && sender == 42`:          true,
+		`caption !~ "#movie"`:                         false,
+		`date >= "2024-01-01" && date < "2025-01-01"`: true,
+		`(size < 1MB || chat != @foo) && true`:        false,
+		`name == 'movie.mkv'`:                         true,
+		`name =~ "^\w+\.mkv$"`:                        true,
+	}
+	for src, want := range cases {
+		ru, err := NewRuleExpr("local", "/", src)
+		if err != nil {
+			t.Errorf("%q: compile failed: %v", src, err)
+			continue
+		}
+		got, err := ru.Match(env)
+		if err != nil {
+			t.Errorf("%q: match failed: %v", src, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%q: got %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestRuleExprForwardFromName(t *testing.T) {
+	ru, err := NewRuleExpr("local", "/", `forward =~ "^Alice"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := ru.Match(&ExprEnv{Forward: Peer{Title: "Alice Smith"}})
+	if err != nil || !ok {
+		t.Errorf("expected forward title to match, got %v, %v", ok, err)
+	}
+}
+
+func TestRuleExprCompileErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`size >`,
+		`unknown == 1`,
+		`size > 5XB`,
+		`(size > 1`,
+		`name =~ "["`,
+		`name =~ size`,
+		`caption == "unterminated`,
+		`size > 1 size`,
+	} {
+		if _, err := NewRuleExpr("local", "/", src); err == nil {
+			t.Errorf("%q: expected compile error", src)
+		}
+	}
+}
+
+func TestRuleExprTypeMismatch(t *testing.T) {
+	ru, err := NewRuleExpr("local", "/", `size == "big"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ru.Match(&ExprEnv{}); err == nil {
+		t.Error("expected type mismatch error")
+	}
+}
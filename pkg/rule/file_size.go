@@ -0,0 +1,47 @@
+package rule
+
+import (
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// RuleFileSize matches files whose size is within a range, e.g. "100MB-2GB" or ">500MB".
+type RuleFileSize struct {
+	storInfo
+	size numRange
+}
+
+var _ RuleClass[tfile.TGFileMessage] = (*RuleFileSize)(nil)
+
+func (r RuleFileSize) Type() RuleType {
+	return FileSize
+}
+
+func (r RuleFileSize) Match(input tfile.TGFileMessage) (bool, error) {
+	// photo sizes are unknown before downloading
+	if input.Size() <= 0 {
+		return false, nil
+	}
+	return r.size.contains(float64(input.Size())), nil
+}
+
+func (r RuleFileSize) StorageName() string {
+	return r.storName
+}
+
+func (r RuleFileSize) StoragePath() string {
+	return r.storPath
+}
+
+func NewRuleFileSize(storName, storPath, sizeRange string) (*RuleFileSize, error) {
+	size, err := parseRange(sizeRange, parseSize)
+	if err != nil {
+		return nil, err
+	}
+	return &RuleFileSize{
+		storInfo: storInfo{
+			storName: storName,
+			storPath: storPath,
+		},
+		size: size,
+	}, nil
+}
@@ -0,0 +1,148 @@
+package rule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// FilterOptions are the conditions of a Filter in their text form, empty options are not checked.
+type FilterOptions struct {
+	Message  string // "msgre:<regex>", the format used by /watch
+	Media    string // comma separated media kinds (photo, video, audio, document) or MIME globs
+	Size     string // size range, same syntax as FILE-SIZE rules
+	Senders  string // comma separated IDs or usernames, same syntax as SOURCE-CHAT rules
+	Hashtags string // comma separated hashtags, the leading # is optional
+}
+
+func (o FilterOptions) IsZero() bool {
+	return o == FilterOptions{}
+}
+
+// Filter decides whether a file should be saved at all, every set condition must match.
+type Filter struct {
+	message  *regexp.Regexp
+	media    *RuleMimeType
+	size     *RuleFileSize
+	senders  *peerList
+	hashtags []string
+}
+
+// mediaKinds maps media kinds to MIME globs
+var mediaKinds = map[string]string{
+	"photo":    "image/*",
+	"image":    "image/*",
+	"video":    "video/*",
+	"audio":    "audio/*",
+	"document": "application/*,text/*",
+}
+
+func NewFilter(opts FilterOptions) (*Filter, error) {
+	f := &Filter{}
+	if opts.Message != "" {
+		parts := strings.Split(opts.Message, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected <type>:<data>", opts.Message)
+		}
+		switch parts[0] {
+		case "msgre":
+			re, err := regexp.Compile(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			f.message = re
+		default:
+			return nil, fmt.Errorf("unsupported filter type %q", parts[0])
+		}
+	}
+	if opts.Media != "" {
+		var globs []string
+		for item := range strings.SplitSeq(opts.Media, ",") {
+			item = strings.ToLower(strings.TrimSpace(item))
+			if glob, ok := mediaKinds[item]; ok {
+				item = glob
+			} else if item != "" && !strings.Contains(item, "/") {
+				return nil, fmt.Errorf("unknown media type %q", item)
+			}
+			globs = append(globs, item)
+		}
+		media, err := NewRuleMimeType("", "", strings.Join(globs, ","))
+		if err != nil {
+			return nil, err
+		}
+		f.media = media
+	}
+	if opts.Size != "" {
+		size, err := NewRuleFileSize("", "", opts.Size)
+		if err != nil {
+			return nil, err
+		}
+		f.size = size
+	}
+	if opts.Senders != "" {
+		senders, err := parsePeerList(opts.Senders)
+		if err != nil {
+			return nil, err
+		}
+		f.senders = &senders
+	}
+	for tag := range strings.SplitSeq(opts.Hashtags, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+		if tag == "" {
+			continue
+		}
+		if hashtagRe.FindString("#"+tag) != "#"+tag {
+			return nil, fmt.Errorf("invalid hashtag %q", tag)
+		}
+		f.hashtags = append(f.hashtags, tag)
+	}
+	return f, nil
+}
+
+// NeedsEnv reports whether Match needs the message attributes in ExprEnv.
+func (f *Filter) NeedsEnv() bool {
+	return f.senders != nil
+}
+
+// Match reports whether the file passes the filter, env may be nil when NeedsEnv is false.
+func (f *Filter) Match(file tfile.TGFileMessage, env *ExprEnv) bool {
+	var caption string
+	if msg := file.Message(); msg != nil {
+		caption = msg.GetMessage()
+	}
+	if f.message != nil && !f.message.MatchString(caption) {
+		return false
+	}
+	if f.media != nil {
+		if ok, _ := f.media.Match(file); !ok {
+			return false
+		}
+	}
+	if f.size != nil {
+		if ok, _ := f.size.Match(file); !ok {
+			return false
+		}
+	}
+	if f.senders != nil && (env == nil || !f.senders.match(env.Sender)) {
+		return false
+	}
+	if len(f.hashtags) > 0 && !hasAnyHashtag(caption, f.hashtags) {
+		return false
+	}
+	return true
+}
+
+var hashtagRe = regexp.MustCompile(`#[\p{L}\p{N}_]+`)
+
+func hasAnyHashtag(text string, tags []string) bool {
+	for _, found := range hashtagRe.FindAllString(text, -1) {
+		for _, tag := range tags {
+			if strings.EqualFold(found[1:], tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
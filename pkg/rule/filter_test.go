@@ -0,0 +1,77 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+func newCaptionFile(t *testing.T, size int64, mime, caption string) tfile.TGFileMessage {
+	t.Helper()
+	file := newDocumentFile(t, size, mime)
+	file.Message().Message = caption
+	return file
+}
+
+func TestFilterMatch(t *testing.T) {
+	file := newCaptionFile(t, 50<<20, "video/mp4", "new episode #Anime #S01")
+	env := &ExprEnv{Sender: Peer{ID: 42, Username: "uploader"}}
+	cases := []struct {
+		opts FilterOptions
+		want bool
+	}{
+		{FilterOptions{}, true},
+		{FilterOptions{Message: "msgre:episode"}, true},
+		{FilterOptions{Message: "msgre:^movie"}, false},
+		{FilterOptions{Media: "photo, video"}, true},
+		{FilterOptions{Media: "audio"}, false},
+		{FilterOptions{Media: "video/mp4"}, true},
+		{FilterOptions{Size: "10MB-100MB"}, true},
+		{FilterOptions{Size: "1GB-"}, false},
+		{FilterOptions{Senders: "@uploader"}, true},
+		{FilterOptions{Senders: "1,2"}, false},
+		{FilterOptions{Hashtags: "anime"}, true},
+		{FilterOptions{Hashtags: "#movie, #s01"}, true},
+		{FilterOptions{Hashtags: "ani"}, false},
+		{FilterOptions{Media: "video", Hashtags: "movie"}, false},
+	}
+	for _, c := range cases {
+		f, err := NewFilter(c.opts)
+		if err != nil {
+			t.Errorf("%+v: %v", c.opts, err)
+			continue
+		}
+		if got := f.Match(file, env); got != c.want {
+			t.Errorf("%+v: got %v, want %v", c.opts, got, c.want)
+		}
+	}
+}
+
+func TestFilterSenderNeedsEnv(t *testing.T) {
+	f, err := NewFilter(FilterOptions{Senders: "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.NeedsEnv() {
+		t.Error("sender filter should need env")
+	}
+	if f.Match(newCaptionFile(t, 1, "image/png", ""), nil) {
+		t.Error("sender filter should not match without env")
+	}
+}
+
+func TestNewFilterErrors(t *testing.T) {
+	for _, opts := range []FilterOptions{
+		{Message: "msgre"},
+		{Message: "kw:foo"},
+		{Message: "msgre:("},
+		{Media: "sticker"},
+		{Size: "big"},
+		{Senders: " , "},
+		{Hashtags: "not a tag"},
+	} {
+		if _, err := NewFilter(opts); err == nil {
+			t.Errorf("%+v: expected error", opts)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package rule
+
+import (
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// RuleMediaDuration matches videos and audios whose duration is within a range, e.g. "10min-1h" or ">30s".
+type RuleMediaDuration struct {
+	storInfo
+	duration numRange
+}
+
+var _ RuleClass[tfile.TGFileMessage] = (*RuleMediaDuration)(nil)
+
+func (r RuleMediaDuration) Type() RuleType {
+	return MediaDuration
+}
+
+func (r RuleMediaDuration) Match(input tfile.TGFileMessage) (bool, error) {
+	msg := input.Message()
+	if msg == nil {
+		return false, nil
+	}
+	duration, ok := tfile.MediaDuration(msg.Media)
+	if !ok {
+		return false, nil
+	}
+	return r.duration.contains(duration), nil
+}
+
+func (r RuleMediaDuration) StorageName() string {
+	return r.storName
+}
+
+func (r RuleMediaDuration) StoragePath() string {
+	return r.storPath
+}
+
+func NewRuleMediaDuration(storName, storPath, durationRange string) (*RuleMediaDuration, error) {
+	duration, err := parseRange(durationRange, parseDurationSeconds)
+	if err != nil {
+		return nil, err
+	}
+	return &RuleMediaDuration{
+		storInfo: storInfo{
+			storName: storName,
+			storPath: storPath,
+		},
+		duration: duration,
+	}, nil
+}
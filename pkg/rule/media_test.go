@@ -0,0 +1,138 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+func newDocumentFile(t *testing.T, size int64, mime string, attrs ...tg.DocumentAttributeClass) tfile.TGFileMessage {
+	t.Helper()
+	media := &tg.MessageMediaDocument{
+		Document: &tg.Document{
+			Size:       size,
+			MimeType:   mime,
+			Attributes: append(attrs, &tg.DocumentAttributeFilename{FileName: "file"}),
+		},
+	}
+	file, err := tfile.FromMediaMessage(media, nil, &tg.Message{Media: media})
+	if err != nil {
+		t.Fatalf("failed to build file: %v", err)
+	}
+	return file
+}
+
+func TestRuleFileSize(t *testing.T) {
+	file := newDocumentFile(t, 600<<20, "video/mp4")
+	cases := map[string]bool{
+		"500MB-1GB":   true,
+		"500mb-":      true,
+		"-500MB":      false,
+		">600MB":      false,
+		">=600MB":     true,
+		"<1GB":        true,
+		"1GB-2GB":     false,
+		"0.5GB-0.6GB": true,
+	}
+	for data, want := range cases {
+		ru, err := NewRuleFileSize("local", "/", data)
+		if err != nil {
+			t.Errorf("%q: %v", data, err)
+			continue
+		}
+		if got, _ := ru.Match(file); got != want {
+			t.Errorf("%q: got %v, want %v", data, got, want)
+		}
+	}
+
+	for _, data := range []string{"", "500MB", "2GB-1GB", "-", "10XB-", "abc-1GB"} {
+		if _, err := NewRuleFileSize("local", "/", data); err == nil {
+			t.Errorf("%q: expected error", data)
+		}
+	}
+}
+
+func TestRuleMimeType(t *testing.T) {
+	video := newDocumentFile(t, 1, "video/MP4")
+	cases := map[string]bool{
+		"video/*":            true,
+		"video/mp4":          true,
+		"audio/*":            false,
+		"audio/*, video/mp4": true,
+		"*/*":                true,
+	}
+	for data, want := range cases {
+		ru, err := NewRuleMimeType("local", "/", data)
+		if err != nil {
+			t.Errorf("%q: %v", data, err)
+			continue
+		}
+		if got, _ := ru.Match(video); got != want {
+			t.Errorf("%q: got %v, want %v", data, got, want)
+		}
+	}
+
+	photo, err := tfile.FromMediaMessage(&tg.MessageMediaPhoto{Photo: &tg.Photo{Sizes: []tg.PhotoSizeClass{&tg.PhotoSize{Type: "x"}}}}, nil, &tg.Message{
+		Media: &tg.MessageMediaPhoto{Photo: &tg.Photo{}},
+	})
+	if err != nil {
+		t.Fatalf("failed to build photo: %v", err)
+	}
+	ru, _ := NewRuleMimeType("local", "/", "image/*")
+	if ok, _ := ru.Match(photo); !ok {
+		t.Error("expected photo to match image/*")
+	}
+
+	for _, data := range []string{"", " , ", "video/["} {
+		if _, err := NewRuleMimeType("local", "/", data); err == nil {
+			t.Errorf("%q: expected error", data)
+		}
+	}
+}
+
+func TestRuleMediaDuration(t *testing.T) {
+	video := newDocumentFile(t, 1, "video/mp4", &tg.DocumentAttributeVideo{Duration: 1800})
+	audio := newDocumentFile(t, 1, "audio/mpeg", &tg.DocumentAttributeAudio{Duration: 200})
+	doc := newDocumentFile(t, 1, "application/pdf")
+
+	cases := []struct {
+		data string
+		file tfile.TGFileMessage
+		want bool
+	}{
+		{"10min-1h", video, true},
+		{">30min", video, false},
+		{">=1800", video, true},
+		{"1h30m-", video, false},
+		{"<5min", audio, true},
+		{"3min-4min", audio, true},
+		{"-1h", doc, false},
+	}
+	for _, c := range cases {
+		ru, err := NewRuleMediaDuration("local", "/", c.data)
+		if err != nil {
+			t.Errorf("%q: %v", c.data, err)
+			continue
+		}
+		if got, _ := ru.Match(c.file); got != c.want {
+			t.Errorf("%q on %s: got %v, want %v", c.data, c.file.Name(), got, c.want)
+		}
+	}
+
+	if _, err := NewRuleMediaDuration("local", "/", "10 parsecs-"); err == nil {
+		t.Error("expected error for invalid duration")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(FileNameRegex, "("); err == nil {
+		t.Error("expected invalid regex error")
+	}
+	if err := Validate(FileSize, ">1GB"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Validate(IsAlbum, "anything"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package rule
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// RuleMimeType matches files by MIME type with comma separated globs, e.g. "video/*,audio/flac".
+type RuleMimeType struct {
+	storInfo
+	patterns []string
+}
+
+var _ RuleClass[tfile.TGFileMessage] = (*RuleMimeType)(nil)
+
+func (r RuleMimeType) Type() RuleType {
+	return MimeType
+}
+
+func (r RuleMimeType) Match(input tfile.TGFileMessage) (bool, error) {
+	msg := input.Message()
+	if msg == nil {
+		return false, nil
+	}
+	mime := strings.ToLower(tfile.MediaMimeType(msg.Media))
+	if mime == "" {
+		return false, nil
+	}
+	for _, pattern := range r.patterns {
+		if ok, _ := path.Match(pattern, mime); ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r RuleMimeType) StorageName() string {
+	return r.storName
+}
+
+func (r RuleMimeType) StoragePath() string {
+	return r.storPath
+}
+
+func NewRuleMimeType(storName, storPath, patterns string) (*RuleMimeType, error) {
+	var ps []string
+	for p := range strings.SplitSeq(patterns, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+	if len(ps) == 0 {
+		return nil, errors.New("empty MIME type pattern")
+	}
+	return &RuleMimeType{
+		storInfo: storInfo{
+			storName: storName,
+			storPath: storPath,
+		},
+		patterns: ps,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package rule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// numRange is a numeric interval parsed from rule data such as
+// "100MB-2GB", "500MB-", "-10MB", ">1h" or "<=30s".
+type numRange struct {
+	min, max         float64
+	hasMin, hasMax   bool
+	minExcl, maxExcl bool
+}
+
+func (r numRange) contains(v float64) bool {
+	if r.hasMin && (v < r.min || (r.minExcl && v == r.min)) {
+		return false
+	}
+	if r.hasMax && (v > r.max || (r.maxExcl && v == r.max)) {
+		return false
+	}
+	return true
+}
+
+func parseRange(s string, parse func(string) (float64, error)) (numRange, error) {
+	s = strings.TrimSpace(s)
+	var r numRange
+	var err error
+	switch {
+	case strings.HasPrefix(s, ">="):
+		r.hasMin = true
+		r.min, err = parse(s[2:])
+	case strings.HasPrefix(s, ">"):
+		r.hasMin, r.minExcl = true, true
+		r.min, err = parse(s[1:])
+	case strings.HasPrefix(s, "<="):
+		r.hasMax = true
+		r.max, err = parse(s[2:])
+	case strings.HasPrefix(s, "<"):
+		r.hasMax, r.maxExcl = true, true
+		r.max, err = parse(s[1:])
+	case strings.Contains(s, "-"):
+		lo, hi, _ := strings.Cut(s, "-")
+		if lo = strings.TrimSpace(lo); lo != "" {
+			r.hasMin = true
+			if r.min, err = parse(lo); err != nil {
+				return r, err
+			}
+		}
+		if hi = strings.TrimSpace(hi); hi != "" {
+			r.hasMax = true
+			r.max, err = parse(hi)
+		}
+		if !r.hasMin && !r.hasMax {
+			return r, fmt.Errorf("empty range %q", s)
+		}
+	default:
+		return r, fmt.Errorf("invalid range %q, expected e.g. 100MB-2GB, >500MB or <10min", s)
+	}
+	if err != nil {
+		return r, err
+	}
+	if r.hasMin && r.hasMax && r.min > r.max {
+		return r, fmt.Errorf("invalid range %q: lower bound is greater than upper bound", s)
+	}
+	return r, nil
+}
+
+// splitQuantity splits "1.5GB" into 1.5 and "GB".
+func splitQuantity(s string) (float64, string, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid number %q", s)
+	}
+	return n, strings.TrimSpace(s[i:]), nil
+}
+
+// parseSize parses a size like "500MB" into bytes, units are 1024-based and case insensitive.
+func parseSize(s string) (float64, error) {
+	n, unit, err := splitQuantity(s)
+	if err != nil {
+		return 0, err
+	}
+	if unit == "" {
+		return n, nil
+	}
+	m, ok := sizeUnits[strings.ToUpper(unit)]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q", unit)
+	}
+	return n * m, nil
+}
+
+// parseDurationSeconds parses "90", "30s", "10min", "2h" or a Go duration like "1h30m" into seconds.
+func parseDurationSeconds(s string) (float64, error) {
+	n, unit, err := splitQuantity(s)
+	if err == nil {
+		if unit == "" {
+			return n, nil
+		}
+		if m, ok := durationUnits[unit]; ok {
+			return n * m, nil
+		}
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d.Seconds(), nil
+}
@@ -1,5 +1,7 @@
 package rule
 
+import "regexp"
+
 type RuleClass[InputType any] interface {
 	Type() RuleType
 	Match(input InputType) (bool, error)
@@ -7,6 +9,31 @@ type RuleClass[InputType any] interface {
 	StoragePath() string
 }
 
+// Validate checks that data can be used with the given rule type.
+func Validate(t RuleType, data string) error {
+	switch t {
+	case FileNameRegex, MessageRegex:
+		_, err := regexp.Compile(data)
+		return err
+	case FileSize:
+		_, err := NewRuleFileSize("", "", data)
+		return err
+	case MimeType:
+		_, err := NewRuleMimeType("", "", data)
+		return err
+	case MediaDuration:
+		_, err := NewRuleMediaDuration("", "", data)
+		return err
+	case SourceChat, ForwardFrom:
+		_, err := parsePeerList(data)
+		return err
+	case Expr:
+		_, err := compileExpr(data)
+		return err
+	}
+	return nil
+}
+
 type storInfo struct {
 	storName string
 	storPath string
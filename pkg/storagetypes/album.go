@@ -0,0 +1,15 @@
+package storagetypes
+
+import (
+	"io"
+
+	"github.com/gotd/td/tg"
+)
+
+// AlbumFile is a file of a media group saved as an album
+type AlbumFile struct {
+	Reader  io.Reader
+	Path    string
+	Size    int64
+	Message *tg.Message // the message the file comes from
+}
@@ -0,0 +1,15 @@
+package storagetypes
+
+import "time"
+
+// TelegramFile is a file uploaded to a chat by a telegram storage
+type TelegramFile struct {
+	ChatID     int64
+	Dir        string // topic directory of the file, empty for files not sent to a directory topic
+	Name       string
+	Size       int64
+	Hash       string // hex encoded sha256 of the content
+	MessageIDs []int  // the message of the file, or the messages of the zip parts of a split file in order
+	Split      bool
+	CreatedAt  time.Time
+}
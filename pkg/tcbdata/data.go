@@ -12,6 +12,7 @@ const (
 	TypeSetDefault = "setdefault"
 	TypeConfig     = "config"
 	TypeCancel     = "cancel"
+	TypeSaveRange  = "saverange"
 )
 
 const (
@@ -81,6 +82,8 @@ type Add struct {
 	TransferSourceStorName string
 	TransferSourcePath     string
 	TransferFiles          []string // file paths relative to source storage
+	// archive
+	ArchiveChatID int64
 }
 
 type SetDefaultStorage struct {
@@ -0,0 +1,70 @@
+package tfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// ErrFileReferenceExpired is the RPC error of a download whose file reference expired
+const ErrFileReferenceExpired = "FILE_REFERENCE_EXPIRED"
+
+// Refresher fetches a fresh location of a file whose file reference expired, usually by getting its message again.
+type Refresher func(ctx context.Context) (tg.InputFileLocationClass, error)
+
+// WithRefresher lets the file refresh its file reference when a download fails with FILE_REFERENCE_EXPIRED.
+func WithRefresher(refresher Refresher) TGFileOption {
+	return func(f *tgFile) {
+		f.refresher = refresher
+	}
+}
+
+// refresh replaces the expired location with a fresh one,
+// parts of a download failing at the same time refresh the location only once.
+func (f *tgFile) refresh(ctx context.Context, expired tg.InputFileLocationClass) error {
+	f.refreshMu.Lock()
+	defer f.refreshMu.Unlock()
+	if f.Location() != expired {
+		return nil
+	}
+	location, err := f.refresher(ctx)
+	if err != nil {
+		return err
+	}
+	if location == nil {
+		return errors.New("refreshed location is empty")
+	}
+	f.mu.Lock()
+	f.location = location
+	f.mu.Unlock()
+	return nil
+}
+
+// refreshingClient sends the requests of a file with its latest location and refreshes the location once it expired.
+type refreshingClient struct {
+	downloader.Client
+	file *tgFile
+}
+
+// Unwrap returns the client the requests are sent with.
+func (c *refreshingClient) Unwrap() downloader.Client {
+	return c.Client
+}
+
+func (c *refreshingClient) UploadGetFile(ctx context.Context, request *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	req := *request
+	req.Location = c.file.Location()
+	res, err := c.Client.UploadGetFile(ctx, &req)
+	if err == nil || !tgerr.Is(err, ErrFileReferenceExpired) {
+		return res, err
+	}
+	if err := c.file.refresh(ctx, req.Location); err != nil {
+		return nil, fmt.Errorf("failed to refresh expired file reference: %w", err)
+	}
+	req.Location = c.file.Location()
+	return c.Client.UploadGetFile(ctx, &req)
+}
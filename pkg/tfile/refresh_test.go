@@ -0,0 +1,112 @@
+package tfile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// fakeClient serves the file only for locations with the valid file reference.
+type fakeClient struct {
+	downloader.Client
+	mu        sync.Mutex
+	reference []byte
+	requests  []*tg.InputDocumentFileLocation
+}
+
+func (c *fakeClient) UploadGetFile(ctx context.Context, req *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	location := req.Location.(*tg.InputDocumentFileLocation)
+	c.requests = append(c.requests, location)
+	if !bytes.Equal(location.FileReference, c.reference) {
+		return nil, tgerr.New(400, ErrFileReferenceExpired)
+	}
+	return &tg.UploadFile{Bytes: []byte("data")}, nil
+}
+
+func newExpiredFile(client *fakeClient, refreshes *atomic.Int32) TGFile {
+	return NewTGFile(&tg.InputDocumentFileLocation{ID: 1, FileReference: []byte("old")}, client, 4, "test.bin",
+		WithRefresher(func(ctx context.Context) (tg.InputFileLocationClass, error) {
+			refreshes.Add(1)
+			return &tg.InputDocumentFileLocation{ID: 1, FileReference: []byte("new")}, nil
+		}))
+}
+
+func TestRefreshExpiredFileReference(t *testing.T) {
+	client := &fakeClient{reference: []byte("new")}
+	var refreshes atomic.Int32
+	file := newExpiredFile(client, &refreshes)
+
+	res, err := file.Dler().UploadGetFile(context.Background(), &tg.UploadGetFileRequest{Location: file.Location(), Limit: 4})
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if got := string(res.(*tg.UploadFile).Bytes); got != "data" {
+		t.Errorf("got %q, expected %q", got, "data")
+	}
+	if refreshes.Load() != 1 {
+		t.Errorf("refreshed %d times, expected once", refreshes.Load())
+	}
+	if ref := file.Location().(*tg.InputDocumentFileLocation).FileReference; string(ref) != "new" {
+		t.Errorf("file keeps reference %q after refresh", ref)
+	}
+	if len(client.requests) != 2 || string(client.requests[1].FileReference) != "new" {
+		t.Errorf("expected a retry with the fresh reference, got %d requests", len(client.requests))
+	}
+}
+
+func TestRefreshOnceForConcurrentParts(t *testing.T) {
+	client := &fakeClient{reference: []byte("new")}
+	var refreshes atomic.Int32
+	file := newExpiredFile(client, &refreshes)
+	dler := file.Dler()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := dler.UploadGetFile(context.Background(), &tg.UploadGetFileRequest{Location: file.Location(), Offset: int64(i) * 4, Limit: 4})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("download failed: %v", err)
+		}
+	}
+	if refreshes.Load() != 1 {
+		t.Errorf("refreshed %d times, expected once", refreshes.Load())
+	}
+}
+
+func TestRefreshFailure(t *testing.T) {
+	client := &fakeClient{reference: []byte("new")}
+	refreshErr := errors.New("message deleted")
+	file := NewTGFile(&tg.InputDocumentFileLocation{ID: 1, FileReference: []byte("old")}, client, 4, "test.bin",
+		WithRefresher(func(ctx context.Context) (tg.InputFileLocationClass, error) {
+			return nil, refreshErr
+		}))
+	_, err := file.Dler().UploadGetFile(context.Background(), &tg.UploadGetFileRequest{Location: file.Location(), Limit: 4})
+	if !errors.Is(err, refreshErr) {
+		t.Errorf("got error %v, expected the refresh error", err)
+	}
+
+	// 没有 Refresher 的文件原样返回错误
+	file = NewTGFile(&tg.InputDocumentFileLocation{ID: 1, FileReference: []byte("old")}, client, 4, "test.bin")
+	_, err = file.Dler().UploadGetFile(context.Background(), &tg.UploadGetFileRequest{Location: file.Location(), Limit: 4})
+	if !tgerr.Is(err, ErrFileReferenceExpired) {
+		t.Errorf("got error %v, expected %s", err, ErrFileReferenceExpired)
+	}
+}
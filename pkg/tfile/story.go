@@ -0,0 +1,42 @@
+package tfile
+
+import (
+	"errors"
+
+	"github.com/gotd/td/telegram/downloader"
+	"github.com/gotd/td/tg"
+)
+
+// StoryMessage returns a message standing for the story of peer, so stories are named, filtered and captioned like messages.
+// The message has the ID, date, caption and media of the story and replies to the story itself.
+func StoryMessage(peer tg.PeerClass, story *tg.StoryItem) *tg.Message {
+	return &tg.Message{
+		ID:       story.ID,
+		PeerID:   peer,
+		Date:     story.Date,
+		Message:  story.Caption,
+		Entities: story.Entities,
+		Media:    story.Media,
+		ReplyTo:  &tg.MessageReplyStoryHeader{Peer: peer, StoryID: story.ID},
+	}
+}
+
+// StoryOf returns the ID of the story a message made by StoryMessage stands for, false for other messages.
+func StoryOf(msg *tg.Message) (int, bool) {
+	if msg == nil {
+		return 0, false
+	}
+	header, ok := msg.ReplyTo.(*tg.MessageReplyStoryHeader)
+	if !ok || header.StoryID != msg.ID {
+		return 0, false
+	}
+	return header.StoryID, true
+}
+
+// FromStory returns the file of a photo or video story of peer, with a message made by StoryMessage.
+func FromStory(story *tg.StoryItem, peer tg.PeerClass, client downloader.Client, opts ...TGFileOption) (TGFileMessage, error) {
+	if story.Media == nil {
+		return nil, errors.New("story has no media")
+	}
+	return FromMediaMessage(story.Media, client, StoryMessage(peer, story), opts...)
+}
@@ -0,0 +1,61 @@
+package tfile
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestFromStory(t *testing.T) {
+	peer := &tg.PeerUser{UserID: 42}
+	story := &tg.StoryItem{
+		ID:      7,
+		Date:    1700000000,
+		Caption: "sunset #travel",
+		Media: &tg.MessageMediaDocument{Document: &tg.Document{
+			ID:         1,
+			Size:       1 << 20,
+			MimeType:   "video/mp4",
+			Attributes: []tg.DocumentAttributeClass{&tg.DocumentAttributeFilename{FileName: "sunset.mp4"}},
+		}},
+	}
+	file, err := FromStory(story, peer, nil)
+	if err != nil {
+		t.Fatalf("FromStory failed: %v", err)
+	}
+	if file.Name() != "sunset.mp4" || file.Size() != 1<<20 {
+		t.Errorf("got file %q of %d bytes", file.Name(), file.Size())
+	}
+	msg := file.Message()
+	if msg.ID != 7 || msg.Message != story.Caption || msg.PeerID != peer {
+		t.Errorf("story message has ID %d, text %q and peer %v", msg.ID, msg.Message, msg.PeerID)
+	}
+	if id, ok := StoryOf(msg); !ok || id != 7 {
+		t.Errorf("StoryOf = %d, %t, expected story 7", id, ok)
+	}
+	if _, ok := StoryOf(&tg.Message{ID: 7, PeerID: peer}); ok {
+		t.Error("expected a plain message not to be a story")
+	}
+	if _, err := FromStory(&tg.StoryItem{ID: 8}, peer, nil); err == nil {
+		t.Error("expected an error for a story without media")
+	}
+}
+
+func TestWebPageMedia(t *testing.T) {
+	document := &tg.Document{ID: 1, MimeType: "image/gif"}
+	media := &tg.MessageMediaWebPage{Webpage: &tg.WebPage{URL: "https://example.com"}}
+	media.Webpage.(*tg.WebPage).SetDocument(document)
+	inner, ok := WebPageMedia(media)
+	if !ok {
+		t.Fatal("expected the document of the web page")
+	}
+	if m, ok := inner.(*tg.MessageMediaDocument); !ok || m.Document != document {
+		t.Errorf("got media %#v", inner)
+	}
+	if MediaMimeType(media) != "image/gif" {
+		t.Errorf("got MIME type %q", MediaMimeType(media))
+	}
+	if _, ok := WebPageMedia(&tg.MessageMediaWebPage{Webpage: &tg.WebPage{URL: "https://example.com"}}); ok {
+		t.Error("expected no media for a web page without photo or document")
+	}
+}
@@ -3,6 +3,7 @@ package tfile
 import (
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/celestix/gotgproto/functions"
 	"github.com/gotd/td/telegram/downloader"
@@ -23,11 +24,14 @@ type TGFileMessage interface {
 }
 
 type tgFile struct {
-	location tg.InputFileLocationClass
-	size     int64
-	name     string
-	message  *tg.Message
-	dler     downloader.Client
+	mu        sync.RWMutex
+	location  tg.InputFileLocationClass
+	size      int64
+	name      string
+	message   *tg.Message
+	dler      downloader.Client
+	refresher Refresher
+	refreshMu sync.Mutex
 }
 
 func (f *tgFile) SetName(name string) {
@@ -35,6 +39,8 @@ func (f *tgFile) SetName(name string) {
 }
 
 func (f *tgFile) Location() tg.InputFileLocationClass {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	return f.location
 }
 
@@ -51,6 +57,9 @@ func (f *tgFile) Message() *tg.Message {
 }
 
 func (f *tgFile) Dler() downloader.Client {
+	if f.refresher != nil && f.dler != nil {
+		return &refreshingClient{Client: f.dler, file: f}
+	}
 	return f.dler
 }
 
@@ -75,6 +84,12 @@ func NewTGFile(
 
 func FromMedia(media tg.MessageMediaClass, client downloader.Client, opts ...TGFileOption) (TGFile, error) {
 	switch m := media.(type) {
+	case *tg.MessageMediaWebPage:
+		inner, ok := WebPageMedia(m)
+		if !ok {
+			return nil, errors.New("web page has no photo or document")
+		}
+		return FromMedia(inner, client, opts...)
 	case *tg.MessageMediaDocument:
 		document, ok := m.Document.AsNotEmpty()
 		if !ok {
@@ -135,11 +150,95 @@ func FromMediaMessage(media tg.MessageMediaClass, client downloader.Client, msg
 	if err != nil {
 		return nil, err
 	}
+	f := file.(*tgFile)
+	f.message = msg
+	return f, nil
+}
+
+// WebPageMedia returns the photo or document of a link preview, e.g. a result sent via an inline bot, as a message media.
+func WebPageMedia(media tg.MessageMediaClass) (tg.MessageMediaClass, bool) {
+	m, ok := media.(*tg.MessageMediaWebPage)
+	if !ok {
+		return nil, false
+	}
+	page, ok := m.Webpage.(*tg.WebPage)
+	if !ok {
+		return nil, false
+	}
+	if document, ok := page.GetDocument(); ok {
+		return &tg.MessageMediaDocument{Document: document}, true
+	}
+	if photo, ok := page.GetPhoto(); ok {
+		return &tg.MessageMediaPhoto{Photo: photo}, true
+	}
+	return nil, false
+}
+
+// MessageOf returns the message the file comes from, nil if it is unknown.
+func MessageOf(file TGFile) *tg.Message {
+	if f, ok := file.(TGFileMessage); ok {
+		return f.Message()
+	}
+	return nil
+}
+
+// Copy returns a copy of the file that can be renamed independently.
+func Copy(file TGFileMessage) TGFileMessage {
+	if f, ok := file.(*tgFile); ok {
+		return &tgFile{
+			location:  f.Location(),
+			dler:      f.dler,
+			size:      f.size,
+			name:      f.name,
+			message:   f.message,
+			refresher: f.refresher,
+		}
+	}
 	return &tgFile{
 		location: file.Location(),
 		dler:     file.Dler(),
 		size:     file.Size(),
 		name:     file.Name(),
-		message:  msg,
-	}, nil
+		message:  file.Message(),
+	}
+}
+
+// MediaMimeType returns the MIME type of a message media, photos are always image/jpeg.
+func MediaMimeType(media tg.MessageMediaClass) string {
+	if inner, ok := WebPageMedia(media); ok {
+		media = inner
+	}
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		return "image/jpeg"
+	case *tg.MessageMediaDocument:
+		if document, ok := m.Document.AsNotEmpty(); ok {
+			return document.MimeType
+		}
+	}
+	return ""
+}
+
+// MediaDuration returns the duration in seconds of a video or audio document.
+func MediaDuration(media tg.MessageMediaClass) (float64, bool) {
+	if inner, ok := WebPageMedia(media); ok {
+		media = inner
+	}
+	m, ok := media.(*tg.MessageMediaDocument)
+	if !ok {
+		return 0, false
+	}
+	document, ok := m.Document.AsNotEmpty()
+	if !ok {
+		return 0, false
+	}
+	for _, attribute := range document.Attributes {
+		switch attr := attribute.(type) {
+		case *tg.DocumentAttributeVideo:
+			return attr.Duration, true
+		case *tg.DocumentAttributeAudio:
+			return float64(attr.Duration), true
+		}
+	}
+	return 0, false
 }
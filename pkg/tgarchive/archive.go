@@ -0,0 +1,254 @@
+// Package tgarchive converts Telegram messages to the archive format written by /archive and renders it as JSON, Markdown and HTML.
+//
+// An archive root looks like:
+//
+//	chat.json             metadata of the chat
+//	index.html            offline viewer, links to every page
+//	messages/000000.json  messages of a page
+//	messages/000000.md
+//	html/000000.html
+//	media/<id>_<name>     files of the messages
+package tgarchive
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// PageSize 每页包含的消息 ID 数量. 页按消息 ID 划分, 因此同一条消息总是在同一页中
+const PageSize = 100
+
+type Chat struct {
+	ID            int64     `json:"id"`
+	Title         string    `json:"title,omitempty"`
+	LastMessageID int       `json:"last_message_id"`
+	ArchivedAt    time.Time `json:"archived_at"`
+}
+
+type Message struct {
+	ID         int        `json:"id"`
+	Date       time.Time  `json:"date"`
+	EditDate   *time.Time `json:"edit_date,omitempty"`
+	FromID     int64      `json:"from_id,omitempty"`
+	PostAuthor string     `json:"post_author,omitempty"`
+	Text       string     `json:"text,omitempty"`
+	Links      []string   `json:"links,omitempty"`
+	ReplyTo    int        `json:"reply_to,omitempty"`
+	TopicID    int        `json:"topic_id,omitempty"`
+	Forward    *Forward   `json:"forward,omitempty"`
+	GroupedID  int64      `json:"grouped_id,omitempty"`
+	Views      int        `json:"views,omitempty"`
+	Forwards   int        `json:"forwards,omitempty"`
+	Pinned     bool       `json:"pinned,omitempty"`
+	Media      *Media     `json:"media,omitempty"`
+}
+
+type Forward struct {
+	FromID     int64     `json:"from_id,omitempty"`
+	FromName   string    `json:"from_name,omitempty"`
+	Date       time.Time `json:"date"`
+	PostAuthor string    `json:"post_author,omitempty"`
+}
+
+type Media struct {
+	Type     string   `json:"type"`
+	File     string   `json:"file,omitempty"` // path relative to the archive root, empty if the file was not saved
+	Name     string   `json:"name,omitempty"`
+	Size     int64    `json:"size,omitempty"`
+	MimeType string   `json:"mime_type,omitempty"`
+	URL      string   `json:"url,omitempty"`     // webpage previews
+	Title    string   `json:"title,omitempty"`   // webpage title or poll question
+	Options  []string `json:"options,omitempty"` // poll answers
+	Error    string   `json:"error,omitempty"`   // why the file was not saved
+}
+
+// IsImage reports whether the saved file can be shown inline by the viewer.
+func (m *Media) IsImage() bool {
+	return m.File != "" && strings.HasPrefix(m.MimeType, "image/")
+}
+
+func (m *Media) IsVideo() bool {
+	return m.File != "" && strings.HasPrefix(m.MimeType, "video/")
+}
+
+func (m *Media) IsAudio() bool {
+	return m.File != "" && strings.HasPrefix(m.MimeType, "audio/")
+}
+
+// FromMessage converts a message, Links and Media.File are left to the caller.
+func FromMessage(msg *tg.Message) Message {
+	m := Message{
+		ID:         msg.ID,
+		Date:       time.Unix(int64(msg.Date), 0),
+		PostAuthor: msg.PostAuthor,
+		Text:       msg.Message,
+		Views:      msg.Views,
+		Forwards:   msg.Forwards,
+		Pinned:     msg.Pinned,
+	}
+	if editDate, ok := msg.GetEditDate(); ok {
+		t := time.Unix(int64(editDate), 0)
+		m.EditDate = &t
+	}
+	if from, ok := msg.GetFromID(); ok {
+		m.FromID = peerID(from)
+	}
+	if reply, ok := msg.ReplyTo.(*tg.MessageReplyHeader); ok {
+		m.ReplyTo = reply.ReplyToMsgID
+		if reply.ForumTopic {
+			m.TopicID = reply.ReplyToTopID
+			if m.TopicID == 0 {
+				// a message in a topic without replying to another message
+				m.TopicID, m.ReplyTo = reply.ReplyToMsgID, 0
+			}
+		}
+	}
+	if fwd, ok := msg.GetFwdFrom(); ok {
+		m.Forward = &Forward{
+			FromName:   fwd.FromName,
+			Date:       time.Unix(int64(fwd.Date), 0),
+			PostAuthor: fwd.PostAuthor,
+		}
+		if from, ok := fwd.GetFromID(); ok {
+			m.Forward.FromID = peerID(from)
+		}
+	}
+	if groupedID, ok := msg.GetGroupedID(); ok {
+		m.GroupedID = groupedID
+	}
+	if media, ok := msg.GetMedia(); ok {
+		m.Media = fromMedia(media)
+	}
+	return m
+}
+
+func fromMedia(media tg.MessageMediaClass) *Media {
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		return &Media{Type: "photo", MimeType: "image/jpeg"}
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.AsNotEmpty()
+		if !ok {
+			return &Media{Type: "document"}
+		}
+		result := &Media{Type: "document", MimeType: doc.MimeType, Size: doc.Size}
+		for _, attr := range doc.Attributes {
+			switch a := attr.(type) {
+			case *tg.DocumentAttributeFilename:
+				result.Name = a.FileName
+			case *tg.DocumentAttributeVideo:
+				result.Type = "video"
+				if a.RoundMessage {
+					result.Type = "video_note"
+				}
+			case *tg.DocumentAttributeAudio:
+				result.Type = "audio"
+				if a.Voice {
+					result.Type = "voice"
+				}
+			case *tg.DocumentAttributeSticker:
+				result.Type = "sticker"
+			case *tg.DocumentAttributeAnimated:
+				result.Type = "animation"
+			}
+		}
+		return result
+	case *tg.MessageMediaWebPage:
+		result := &Media{Type: "webpage"}
+		if page, ok := m.Webpage.(*tg.WebPage); ok {
+			result.URL, result.Title = page.URL, page.Title
+		}
+		return result
+	case *tg.MessageMediaPoll:
+		result := &Media{Type: "poll", Title: m.Poll.Question.Text}
+		for _, answer := range m.Poll.Answers {
+			if a, ok := answer.(*tg.PollAnswer); ok {
+				result.Options = append(result.Options, a.Text.Text)
+			}
+		}
+		return result
+	case *tg.MessageMediaGeo, *tg.MessageMediaGeoLive, *tg.MessageMediaVenue:
+		return &Media{Type: "location"}
+	case *tg.MessageMediaContact:
+		return &Media{Type: "contact", Title: strings.TrimSpace(m.FirstName + " " + m.LastName)}
+	}
+	// e.g. *tg.MessageMediaDice -> dice
+	return &Media{Type: strings.ToLower(strings.TrimPrefix(reflect.TypeOf(media).Elem().Name(), "MessageMedia"))}
+}
+
+func peerID(peer tg.PeerClass) int64 {
+	switch p := peer.(type) {
+	case *tg.PeerUser:
+		return p.UserID
+	case *tg.PeerChat:
+		return p.ChatID
+	case *tg.PeerChannel:
+		return p.ChannelID
+	}
+	return 0
+}
+
+// PageOf returns the page a message belongs to.
+func PageOf(messageID int) int {
+	return (max(messageID, 1) - 1) / PageSize
+}
+
+// PageRange returns the first and last message ID of a page.
+func PageRange(page int) (start, end int) {
+	return page*PageSize + 1, (page + 1) * PageSize
+}
+
+const (
+	ChatFile  = "chat.json"
+	IndexFile = "index.html"
+)
+
+func PageJSONPath(page int) string {
+	return path.Join("messages", fmt.Sprintf("%06d.json", page))
+}
+
+func PageMarkdownPath(page int) string {
+	return path.Join("messages", fmt.Sprintf("%06d.md", page))
+}
+
+func PageHTMLPath(page int) string {
+	return path.Join("html", fmt.Sprintf("%06d.html", page))
+}
+
+// MediaPath returns where the file of a message is saved.
+func MediaPath(messageID int, name string) string {
+	return path.Join("media", fmt.Sprintf("%d_%s", messageID, name))
+}
+
+// ParsePages parses the page list stored by FormatPages.
+func ParsePages(s string) ([]int, error) {
+	var pages []int
+	for part := range strings.SplitSeq(s, ",") {
+		if part == "" {
+			continue
+		}
+		page, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page %q: %w", part, err)
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// FormatPages returns the sorted and deduplicated page list.
+func FormatPages(pages []int) string {
+	pages = slices.Compact(slices.Sorted(slices.Values(pages)))
+	parts := make([]string, 0, len(pages))
+	for _, page := range pages {
+		parts = append(parts, strconv.Itoa(page))
+	}
+	return strings.Join(parts, ",")
+}
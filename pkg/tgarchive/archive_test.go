@@ -0,0 +1,126 @@
+package tgarchive
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestPageOf(t *testing.T) {
+	tests := []struct {
+		id   int
+		page int
+	}{
+		{1, 0},
+		{100, 0},
+		{101, 1},
+		{250, 2},
+	}
+	for _, tt := range tests {
+		if got := PageOf(tt.id); got != tt.page {
+			t.Errorf("PageOf(%d) = %d, want %d", tt.id, got, tt.page)
+		}
+		start, end := PageRange(tt.page)
+		if tt.id < start || tt.id > end {
+			t.Errorf("message %d is not in PageRange(%d) = %d-%d", tt.id, tt.page, start, end)
+		}
+	}
+}
+
+func TestPages(t *testing.T) {
+	pages, err := ParsePages(FormatPages([]int{3, 0, 3, 1}))
+	if err != nil {
+		t.Fatalf("ParsePages error: %v", err)
+	}
+	if !slices.Equal(pages, []int{0, 1, 3}) {
+		t.Errorf("pages = %v, want [0 1 3]", pages)
+	}
+	if _, err := ParsePages("1,x"); err == nil {
+		t.Error("ParsePages(\"1,x\") expected error")
+	}
+}
+
+func TestFromMessage(t *testing.T) {
+	msg := &tg.Message{
+		ID:      42,
+		Date:    1700000000,
+		Message: "hello",
+		Views:   10,
+	}
+	msg.SetFromID(&tg.PeerUser{UserID: 7})
+	msg.SetReplyTo(&tg.MessageReplyHeader{ReplyToMsgID: 40})
+	fwd := tg.MessageFwdHeader{FromName: "someone", Date: 1600000000}
+	msg.SetFwdFrom(fwd)
+	msg.SetMedia(&tg.MessageMediaDocument{Document: &tg.Document{
+		MimeType: "audio/ogg",
+		Size:     1024,
+		Attributes: []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeFilename{FileName: "voice.ogg"},
+			&tg.DocumentAttributeAudio{Voice: true},
+		},
+	}})
+
+	m := FromMessage(msg)
+	if m.ID != 42 || m.Text != "hello" || m.FromID != 7 || m.ReplyTo != 40 || m.Views != 10 {
+		t.Errorf("unexpected message: %+v", m)
+	}
+	if m.Forward == nil || m.Forward.FromName != "someone" {
+		t.Errorf("unexpected forward: %+v", m.Forward)
+	}
+	if m.Media == nil || m.Media.Type != "voice" || m.Media.Name != "voice.ogg" || m.Media.Size != 1024 {
+		t.Errorf("unexpected media: %+v", m.Media)
+	}
+}
+
+func TestFromMessageTopic(t *testing.T) {
+	msg := &tg.Message{ID: 10}
+	msg.SetReplyTo(&tg.MessageReplyHeader{ForumTopic: true, ReplyToMsgID: 5})
+	if m := FromMessage(msg); m.TopicID != 5 || m.ReplyTo != 0 {
+		t.Errorf("topic message: topic = %d, reply = %d, want 5, 0", m.TopicID, m.ReplyTo)
+	}
+	reply := &tg.MessageReplyHeader{ForumTopic: true, ReplyToMsgID: 8}
+	reply.SetReplyToTopID(5)
+	msg.SetReplyTo(reply)
+	if m := FromMessage(msg); m.TopicID != 5 || m.ReplyTo != 8 {
+		t.Errorf("reply in topic: topic = %d, reply = %d, want 5, 8", m.TopicID, m.ReplyTo)
+	}
+}
+
+func TestRender(t *testing.T) {
+	chat := Chat{ID: 1, Title: "chat"}
+	msgs := []Message{
+		{ID: 101, Text: "<b>hi</b>", Media: &Media{Type: "photo", MimeType: "image/jpeg", File: MediaPath(101, "a.jpg"), Name: "a.jpg"}},
+		{ID: 102, ReplyTo: 5, Media: &Media{Type: "poll", Title: "q", Options: []string{"x", "y"}}},
+	}
+
+	var md bytes.Buffer
+	if err := RenderMarkdown(&md, chat, 1, msgs); err != nil {
+		t.Fatalf("RenderMarkdown error: %v", err)
+	}
+	for _, want := range []string{"# chat 101-200", "![a.jpg](../media/101_a.jpg)", "> Reply to #5", "- y"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown does not contain %q:\n%s", want, md.String())
+		}
+	}
+
+	var page bytes.Buffer
+	if err := RenderPageHTML(&page, chat, 1, 0, -1, msgs); err != nil {
+		t.Fatalf("RenderPageHTML error: %v", err)
+	}
+	for _, want := range []string{`src="../media/101_a.jpg"`, "&lt;b&gt;hi&lt;/b&gt;", `href="000000.html#msg5"`, `href="000000.html"`} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("page does not contain %q:\n%s", want, page.String())
+		}
+	}
+
+	var index bytes.Buffer
+	if err := RenderIndexHTML(&index, chat, []int{0, 1}); err != nil {
+		t.Fatalf("RenderIndexHTML error: %v", err)
+	}
+	if !strings.Contains(index.String(), `href="html/000001.html"`) {
+		t.Errorf("index does not link page 1:\n%s", index.String())
+	}
+}
@@ -0,0 +1,110 @@
+package tgarchive
+
+import (
+	"cmp"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+const timeLayout = "2006-01-02 15:04:05"
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"formatTime": func(t time.Time) string { return t.Format(timeLayout) },
+	"pageFile":   func(page int) string { return path.Base(PageHTMLPath(page)) },
+	"pageStart":  func(page int) int { start, _ := PageRange(page); return start },
+	"pageEnd":    func(page int) int { _, end := PageRange(page); return end },
+	"messageLink": func(id int) string {
+		return fmt.Sprintf("%s#msg%d", path.Base(PageHTMLPath(PageOf(id))), id)
+	},
+}).ParseFS(templateFS, "templates/*.html"))
+
+// RenderPageHTML renders a page of the offline viewer, prev and next are -1 when there is no such page.
+func RenderPageHTML(w io.Writer, chat Chat, page, prev, next int, msgs []Message) error {
+	start, end := PageRange(page)
+	return templates.ExecuteTemplate(w, "page", map[string]any{
+		"Chat":     chat,
+		"Start":    start,
+		"End":      end,
+		"Prev":     prev,
+		"Next":     next,
+		"Messages": msgs,
+	})
+}
+
+// RenderIndexHTML renders the entry of the offline viewer.
+func RenderIndexHTML(w io.Writer, chat Chat, pages []int) error {
+	return templates.ExecuteTemplate(w, "index", map[string]any{
+		"Chat":  chat,
+		"Pages": pages,
+	})
+}
+
+// RenderMarkdown renders a page as Markdown, file links are relative to the messages directory.
+func RenderMarkdown(w io.Writer, chat Chat, page int, msgs []Message) error {
+	var sb strings.Builder
+	start, end := PageRange(page)
+	fmt.Fprintf(&sb, "# %s %d-%d\n", chat.Title, start, end)
+	for _, msg := range msgs {
+		fmt.Fprintf(&sb, "\n## #%d %s\n\n", msg.ID, msg.Date.Format(timeLayout))
+		var meta []string
+		if msg.FromID != 0 {
+			meta = append(meta, fmt.Sprintf("from %d", msg.FromID))
+		}
+		if msg.PostAuthor != "" {
+			meta = append(meta, msg.PostAuthor)
+		}
+		if msg.Pinned {
+			meta = append(meta, "pinned")
+		}
+		if msg.EditDate != nil {
+			meta = append(meta, "edited "+msg.EditDate.Format(timeLayout))
+		}
+		if len(meta) > 0 {
+			sb.WriteString("*" + strings.Join(meta, ", ") + "*\n\n")
+		}
+		if fwd := msg.Forward; fwd != nil {
+			from := fwd.FromName
+			if from == "" {
+				from = fmt.Sprint(fwd.FromID)
+			}
+			fmt.Fprintf(&sb, "> Forwarded from %s, %s\n\n", from, fwd.Date.Format(timeLayout))
+		}
+		if msg.ReplyTo != 0 {
+			fmt.Fprintf(&sb, "> Reply to #%d\n\n", msg.ReplyTo)
+		}
+		if m := msg.Media; m != nil {
+			switch {
+			case m.IsImage():
+				fmt.Fprintf(&sb, "![%s](../%s)\n\n", m.Name, m.File)
+			case m.File != "":
+				fmt.Fprintf(&sb, "[%s](../%s)\n\n", m.Name, m.File)
+			case m.URL != "":
+				fmt.Fprintf(&sb, "[%s](%s)\n\n", cmp.Or(m.Title, m.URL), m.URL)
+			default:
+				fmt.Fprintf(&sb, "[%s] %s\n\n", m.Type, m.Title)
+				for _, option := range m.Options {
+					fmt.Fprintf(&sb, "- %s\n", option)
+				}
+				if len(m.Options) > 0 {
+					sb.WriteString("\n")
+				}
+			}
+			if m.Error != "" {
+				fmt.Fprintf(&sb, "*file not saved: %s*\n\n", m.Error)
+			}
+		}
+		if msg.Text != "" {
+			sb.WriteString(msg.Text + "\n")
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,248 @@
+// Package userdata defines the file format used to export and import a user's rules, dirs, watched chats and settings.
+package userdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/krau/SaveAny-Bot/pkg/enums/fnamest"
+	"github.com/krau/SaveAny-Bot/pkg/rule"
+	"github.com/krau/SaveAny-Bot/pkg/tcbdata"
+)
+
+// Version is the current version of the export format.
+const Version = 1
+
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses a format name, an empty name means YAML.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "", "yaml", "yml":
+		return FormatYAML, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected yaml or json", s)
+}
+
+// Ext returns the file extension of the format, including the dot.
+func (f Format) Ext() string {
+	if f == FormatJSON {
+		return ".json"
+	}
+	return ".yaml"
+}
+
+type Mode string
+
+const (
+	// ModeMerge keeps existing records, adds new ones and only overrides settings that are set in the file.
+	ModeMerge Mode = "merge"
+	// ModeReplace deletes existing rules, dirs and watched chats and replaces all settings.
+	ModeReplace Mode = "replace"
+)
+
+// ParseMode parses an import mode, an empty name means ModeMerge.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(strings.ToLower(s)) {
+	case "", ModeMerge:
+		return ModeMerge, nil
+	case ModeReplace:
+		return ModeReplace, nil
+	}
+	return "", fmt.Errorf("unknown mode %q, expected merge or replace", s)
+}
+
+type Data struct {
+	Version    int         `json:"version" yaml:"version"`
+	ExportedAt time.Time   `json:"exported_at,omitzero" yaml:"exported_at,omitempty"`
+	Settings   Settings    `json:"settings" yaml:"settings"`
+	Rules      []Rule      `json:"rules,omitempty" yaml:"rules,omitempty"`
+	Dirs       []Dir       `json:"dirs,omitempty" yaml:"dirs,omitempty"`
+	WatchChats []WatchChat `json:"watch_chats,omitempty" yaml:"watch_chats,omitempty"`
+}
+
+// Settings are the user settings, empty values are left unchanged when merging.
+type Settings struct {
+	ApplyRule        *bool  `json:"apply_rule,omitempty" yaml:"apply_rule,omitempty"`
+	Silent           *bool  `json:"silent,omitempty" yaml:"silent,omitempty"`
+	DefaultStorage   string `json:"default_storage,omitempty" yaml:"default_storage,omitempty"`
+	FilenameStrategy string `json:"filename_strategy,omitempty" yaml:"filename_strategy,omitempty"`
+	FilenameTemplate string `json:"filename_template,omitempty" yaml:"filename_template,omitempty"`
+	ConflictStrategy string `json:"conflict_strategy,omitempty" yaml:"conflict_strategy,omitempty"`
+}
+
+type Rule struct {
+	Type        string `json:"type" yaml:"type"`
+	Data        string `json:"data" yaml:"data"`
+	StorageName string `json:"storage_name" yaml:"storage_name"`
+	DirPath     string `json:"dir_path" yaml:"dir_path"`
+	Priority    int    `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Stop        bool   `json:"stop,omitempty" yaml:"stop,omitempty"`
+	Action      string `json:"action,omitempty" yaml:"action,omitempty"`
+}
+
+type Dir struct {
+	StorageName string `json:"storage_name" yaml:"storage_name"`
+	Path        string `json:"path" yaml:"path"`
+}
+
+type WatchChat struct {
+	ChatID           int64  `json:"chat_id" yaml:"chat_id"`
+	Filter           string `json:"filter,omitempty" yaml:"filter,omitempty"`
+	MediaTypes       string `json:"media_types,omitempty" yaml:"media_types,omitempty"`
+	SizeRange        string `json:"size_range,omitempty" yaml:"size_range,omitempty"`
+	Senders          string `json:"senders,omitempty" yaml:"senders,omitempty"`
+	Hashtags         string `json:"hashtags,omitempty" yaml:"hashtags,omitempty"`
+	StorageName      string `json:"storage_name,omitempty" yaml:"storage_name,omitempty"`
+	DirPath          string `json:"dir_path,omitempty" yaml:"dir_path,omitempty"`
+	FilenameTemplate string `json:"filename_template,omitempty" yaml:"filename_template,omitempty"`
+	// Rules are used instead of the user's rules for files from this chat
+	Rules []Rule `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+// Marshal encodes data in the given format.
+func Marshal(data *Data, format Format) ([]byte, error) {
+	if format == FormatJSON {
+		return json.MarshalIndent(data, "", "  ")
+	}
+	return yaml.Marshal(data)
+}
+
+// Unmarshal decodes data in either format, unknown fields are rejected to catch typos.
+func Unmarshal(b []byte) (*Data, error) {
+	var data Data
+	var err error
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&data)
+	} else {
+		err = yaml.UnmarshalWithOptions(b, &data, yaml.Strict())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+	if data.Version == 0 {
+		return nil, errors.New("missing version, is this an exported file?")
+	}
+	if data.Version > Version {
+		return nil, fmt.Errorf("unsupported version %d, please upgrade", data.Version)
+	}
+	return &data, nil
+}
+
+// Validate checks every record and returns all problems found. hasStorage reports whether the user can use a storage.
+func (d *Data) Validate(hasStorage func(name string) bool) error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	s := d.Settings
+	if s.DefaultStorage != "" && !hasStorage(s.DefaultStorage) {
+		add("settings: storage not available: %s", s.DefaultStorage)
+	}
+	if s.FilenameStrategy != "" && !fnamest.FnameST(s.FilenameStrategy).IsValid() {
+		add("settings: invalid filename strategy: %s", s.FilenameStrategy)
+	}
+	if s.FilenameTemplate != "" {
+		if _, err := template.New("filename").Parse(s.FilenameTemplate); err != nil {
+			add("settings: invalid filename template: %s", err)
+		}
+	}
+	if s.ConflictStrategy != "" && !tcbdata.IsConflictStrategy(s.ConflictStrategy) {
+		add("settings: invalid conflict strategy: %s", s.ConflictStrategy)
+	}
+
+	for i, ru := range d.Rules {
+		validateRule(fmt.Sprintf("rule %d", i+1), ru, hasStorage, add)
+	}
+
+	for i, dir := range d.Dirs {
+		if !hasStorage(dir.StorageName) {
+			add("dir %d: storage not available: %s", i+1, dir.StorageName)
+		}
+		if dir.Path == "" {
+			add("dir %d: path is required", i+1)
+		}
+	}
+
+	for i, wc := range d.WatchChats {
+		if wc.ChatID == 0 {
+			add("watch chat %d: chat id is required", i+1)
+		}
+		_, err := rule.NewFilter(rule.FilterOptions{
+			Message:  wc.Filter,
+			Media:    wc.MediaTypes,
+			Size:     wc.SizeRange,
+			Senders:  wc.Senders,
+			Hashtags: wc.Hashtags,
+		})
+		if err != nil {
+			add("watch chat %d: invalid filter: %s", i+1, err)
+		}
+		if wc.StorageName != "" && !hasStorage(wc.StorageName) {
+			add("watch chat %d: storage not available: %s", i+1, wc.StorageName)
+		}
+		if wc.FilenameTemplate != "" {
+			if _, err := template.New("filename").Parse(wc.FilenameTemplate); err != nil {
+				add("watch chat %d: invalid filename template: %s", i+1, err)
+			}
+		}
+		for j, ru := range wc.Rules {
+			validateRule(fmt.Sprintf("watch chat %d rule %d", i+1, j+1), ru, hasStorage, add)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateRule(name string, ru Rule, hasStorage func(name string) bool, add func(format string, args ...any)) {
+	ruleType := rule.RuleType(strings.ToUpper(ru.Type))
+	if !slices.Contains(rule.Values(), ruleType) {
+		add("%s: invalid type: %s", name, ru.Type)
+		return
+	}
+	if err := rule.Validate(ruleType, ru.Data); err != nil {
+		add("%s: invalid data: %s", name, err)
+	}
+	if ru.StorageName != rule.RuleStorNameChosen && ru.StorageName != rule.RuleKeep && !hasStorage(ru.StorageName) {
+		add("%s: storage not available: %s", name, ru.StorageName)
+	}
+	if ru.DirPath == "" {
+		add("%s: dir path is required", name)
+	}
+	if _, err := rule.ParseActions(ru.Action); err != nil {
+		add("%s: invalid action: %s", name, err)
+	}
+}
+
+// Normalize upper-cases rule types and normalizes actions, call it after Validate.
+func (d *Data) Normalize() {
+	normalizeRules(d.Rules)
+	for i := range d.WatchChats {
+		normalizeRules(d.WatchChats[i].Rules)
+	}
+}
+
+func normalizeRules(rules []Rule) {
+	for i := range rules {
+		rules[i].Type = strings.ToUpper(rules[i].Type)
+		if actions, err := rule.ParseActions(rules[i].Action); err == nil {
+			rules[i].Action = actions.String()
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package userdata
+
+import (
+	"strings"
+	"testing"
+)
+
+func testData() *Data {
+	applyRule := true
+	return &Data{
+		Version: Version,
+		Settings: Settings{
+			ApplyRule:        &applyRule,
+			DefaultStorage:   "local",
+			FilenameStrategy: "template",
+			FilenameTemplate: "{{.msgid}}_{{.origname}}",
+			ConflictStrategy: "skip",
+		},
+		Rules: []Rule{
+			{Type: "filename-regex", Data: `\.mp4$`, StorageName: "local", DirPath: "/videos", Priority: 1},
+			{Type: "FILE-SIZE", Data: "<1MB", StorageName: "-", DirPath: "-", Action: "skip"},
+		},
+		Dirs:       []Dir{{StorageName: "local", Path: "/downloads"}},
+		WatchChats: []WatchChat{{ChatID: -1001234567890, Filter: "msgre:#movie"}},
+	}
+}
+
+func hasLocal(name string) bool { return name == "local" }
+
+func TestMarshalRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatYAML, FormatJSON} {
+		b, err := Marshal(testData(), format)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", format, err)
+		}
+		data, err := Unmarshal(b)
+		if err != nil {
+			t.Fatalf("%s: unmarshal: %v\n%s", format, err, b)
+		}
+		if len(data.Rules) != 2 || data.Rules[0].Data != `\.mp4$` || data.Rules[1].Action != "skip" {
+			t.Errorf("%s: rules not preserved: %+v", format, data.Rules)
+		}
+		if data.Settings.ApplyRule == nil || !*data.Settings.ApplyRule || data.Settings.Silent != nil {
+			t.Errorf("%s: settings not preserved: %+v", format, data.Settings)
+		}
+		if len(data.WatchChats) != 1 || data.WatchChats[0].ChatID != -1001234567890 {
+			t.Errorf("%s: watch chats not preserved: %+v", format, data.WatchChats)
+		}
+		if err := data.Validate(hasLocal); err != nil {
+			t.Errorf("%s: unexpected validation error: %v", format, err)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, src := range []string{
+		"rules: []",
+		"version: 99",
+		"version: 1\nrulez: []",
+		`{"version": 1, "unknown": true}`,
+		"version: [",
+	} {
+		if _, err := Unmarshal([]byte(src)); err == nil {
+			t.Errorf("%q: expected error", src)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	data := testData()
+	data.Settings.ConflictStrategy = "explode"
+	data.Rules = append(data.Rules,
+		Rule{Type: "NOPE", Data: "x", StorageName: "local", DirPath: "/"},
+		Rule{Type: "FILENAME-REGEX", Data: "(", StorageName: "remote", DirPath: "/"},
+	)
+	data.Dirs = append(data.Dirs, Dir{StorageName: "local"})
+	data.WatchChats = append(data.WatchChats,
+		WatchChat{ChatID: 1, Filter: "kw:foo"},
+		WatchChat{ChatID: 2, MediaTypes: "sticker", StorageName: "remote", Rules: []Rule{{Type: "FILENAME-REGEX", Data: ".*"}}},
+	)
+
+	err := data.Validate(hasLocal)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		"invalid conflict strategy",
+		"rule 3: invalid type",
+		"rule 4: invalid data",
+		"rule 4: storage not available: remote",
+		"dir 2: path is required",
+		"watch chat 2: invalid filter: unsupported filter type",
+		"watch chat 3: invalid filter: unknown media type",
+		"watch chat 3: storage not available: remote",
+		"watch chat 3 rule 1: storage not available",
+		"watch chat 3 rule 1: dir path is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	data := testData()
+	data.Rules[1].Action = " skip ; low-priority"
+	data.Normalize()
+	if data.Rules[0].Type != "FILENAME-REGEX" {
+		t.Errorf("type not upper-cased: %s", data.Rules[0].Type)
+	}
+	if data.Rules[1].Action != "skip;low-priority" {
+		t.Errorf("action not normalized: %q", data.Rules[1].Action)
+	}
+}
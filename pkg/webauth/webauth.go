@@ -0,0 +1,179 @@
+// Package webauth issues the short-lived credentials used by the web
+// dashboard: one-time login codes handed out by the bot, browser sessions, and
+// verification of Telegram Login Widget payloads. It only knows about Telegram
+// chat IDs, so both the bot handlers and the API server can depend on it.
+package webauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// LoginCodeTTL is how long a one-time login code stays valid.
+	LoginCodeTTL = 5 * time.Minute
+	// LoginPath is the dashboard route that redeems a login code.
+	LoginPath = "/dashboard/login"
+)
+
+var (
+	ErrInvalidWidgetHash = errors.New("invalid login widget hash")
+	ErrWidgetAuthExpired = errors.New("login widget data is expired")
+)
+
+type grant struct {
+	chatID    int64
+	expiresAt time.Time
+}
+
+type store struct {
+	mu       sync.Mutex
+	codes    map[string]grant
+	sessions map[string]grant
+}
+
+var s = &store{
+	codes:    make(map[string]grant),
+	sessions: make(map[string]grant),
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("webauth: failed to read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// IssueLoginCode returns a one-time code that logs the given chat in.
+func IssueLoginCode(chatID int64) string {
+	code := randomToken()
+	s.mu.Lock()
+	s.codes[code] = grant{chatID: chatID, expiresAt: time.Now().Add(LoginCodeTTL)}
+	s.mu.Unlock()
+	return code
+}
+
+// LoginURL builds the link that logs in with code, relative to the
+// dashboard's public base URL.
+func LoginURL(baseURL, code string) string {
+	return strings.TrimRight(baseURL, "/") + LoginPath + "?code=" + url.QueryEscape(code)
+}
+
+// RedeemLoginCode consumes a login code. A code can be redeemed only once.
+func RedeemLoginCode(code string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.codes[code]
+	if !ok {
+		return 0, false
+	}
+	delete(s.codes, code)
+	if time.Now().After(g.expiresAt) {
+		return 0, false
+	}
+	return g.chatID, true
+}
+
+// NewSession creates a browser session for chatID valid for ttl.
+func NewSession(chatID int64, ttl time.Duration) (token string, expiresAt time.Time) {
+	token = randomToken()
+	expiresAt = time.Now().Add(ttl)
+	s.mu.Lock()
+	s.sessions[token] = grant{chatID: chatID, expiresAt: expiresAt}
+	s.mu.Unlock()
+	return token, expiresAt
+}
+
+// LookupSession returns the chat ID owning the session token.
+func LookupSession(token string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.sessions[token]
+	if !ok {
+		return 0, false
+	}
+	if time.Now().After(g.expiresAt) {
+		delete(s.sessions, token)
+		return 0, false
+	}
+	return g.chatID, true
+}
+
+// DeleteSession logs a session out.
+func DeleteSession(token string) {
+	s.mu.Lock()
+	delete(s.sessions, token)
+	s.mu.Unlock()
+}
+
+// Cleanup drops expired login codes and sessions.
+func Cleanup() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, g := range s.codes {
+		if now.After(g.expiresAt) {
+			delete(s.codes, k)
+		}
+	}
+	for k, g := range s.sessions {
+		if now.After(g.expiresAt) {
+			delete(s.sessions, k)
+		}
+	}
+}
+
+// VerifyLoginWidget checks the payload sent by the Telegram Login Widget
+// against the bot token and returns the authenticated user ID.
+// See https://core.telegram.org/widgets/login#checking-authorization
+func VerifyLoginWidget(botToken string, data url.Values, maxAge time.Duration) (int64, error) {
+	hash := data.Get("hash")
+	if hash == "" || botToken == "" {
+		return 0, ErrInvalidWidgetHash
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		if k == "hash" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+"="+data.Get(k))
+	}
+
+	secret := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(hash)) {
+		return 0, ErrInvalidWidgetHash
+	}
+
+	authDate, err := strconv.ParseInt(data.Get("auth_date"), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidWidgetHash
+	}
+	if maxAge > 0 && time.Since(time.Unix(authDate, 0)) > maxAge {
+		return 0, ErrWidgetAuthExpired
+	}
+
+	id, err := strconv.ParseInt(data.Get("id"), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidWidgetHash
+	}
+	return id, nil
+}
@@ -0,0 +1,96 @@
+package webauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginCodeIsOneTime(t *testing.T) {
+	code := IssueLoginCode(42)
+	id, ok := RedeemLoginCode(code)
+	if !ok || id != 42 {
+		t.Fatalf("expected to redeem code for 42, got %d %v", id, ok)
+	}
+	if _, ok := RedeemLoginCode(code); ok {
+		t.Error("code must not be redeemable twice")
+	}
+	if _, ok := RedeemLoginCode("unknown"); ok {
+		t.Error("unknown code must not be redeemable")
+	}
+}
+
+func TestSession(t *testing.T) {
+	token, _ := NewSession(7, time.Hour)
+	if id, ok := LookupSession(token); !ok || id != 7 {
+		t.Fatalf("expected session for 7, got %d %v", id, ok)
+	}
+	DeleteSession(token)
+	if _, ok := LookupSession(token); ok {
+		t.Error("deleted session must not be valid")
+	}
+
+	expired, _ := NewSession(7, -time.Second)
+	if _, ok := LookupSession(expired); ok {
+		t.Error("expired session must not be valid")
+	}
+}
+
+func signWidget(botToken string, data url.Values) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, k+"="+data.Get(k))
+	}
+	secret := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	data.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestVerifyLoginWidget(t *testing.T) {
+	const botToken = "123456:ABC"
+	data := url.Values{
+		"id":         {"114514"},
+		"first_name": {"Test"},
+		"auth_date":  {strconv.FormatInt(time.Now().Unix(), 10)},
+	}
+	signWidget(botToken, data)
+
+	id, err := VerifyLoginWidget(botToken, data, time.Hour)
+	if err != nil || id != 114514 {
+		t.Fatalf("expected valid payload for 114514, got %d %v", id, err)
+	}
+
+	if _, err := VerifyLoginWidget("other:token", data, time.Hour); err != ErrInvalidWidgetHash {
+		t.Errorf("expected hash error with wrong token, got %v", err)
+	}
+
+	tampered := url.Values{}
+	for k, v := range data {
+		tampered[k] = v
+	}
+	tampered.Set("id", "1")
+	if _, err := VerifyLoginWidget(botToken, tampered, time.Hour); err != ErrInvalidWidgetHash {
+		t.Errorf("expected hash error for tampered payload, got %v", err)
+	}
+
+	old := url.Values{
+		"id":        {"114514"},
+		"auth_date": {strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)},
+	}
+	signWidget(botToken, old)
+	if _, err := VerifyLoginWidget(botToken, old, time.Hour); err != ErrWidgetAuthExpired {
+		t.Errorf("expected expired error, got %v", err)
+	}
+}
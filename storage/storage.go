@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
+	"github.com/krau/SaveAny-Bot/common/metrics"
 	storcfg "github.com/krau/SaveAny-Bot/config/storage"
 	storenum "github.com/krau/SaveAny-Bot/pkg/enums/storage"
 	"github.com/krau/SaveAny-Bot/pkg/storagetypes"
@@ -43,6 +45,12 @@ type StorageReadable interface {
 	OpenFile(ctx context.Context, filePath string) (io.ReadCloser, int64, error)
 }
 
+// StorageAlbum 表示支持将媒体组的文件作为相册保存的存储
+type StorageAlbum interface {
+	Storage
+	SaveAlbum(ctx context.Context, files []storagetypes.AlbumFile) error
+}
+
 var Storages = make(map[string]Storage)
 
 type StorageConstructor func() Storage
@@ -71,3 +79,34 @@ func NewStorage(ctx context.Context, cfg storcfg.StorageConfig) (Storage, error)
 
 	return storage, nil
 }
+
+// Save writes reader to stor, recording the uploaded bytes and save latency.
+// Tasks should save through it rather than calling stor.Save directly.
+func Save(ctx context.Context, stor Storage, reader io.Reader, storagePath string) error {
+	start := time.Now()
+	err := stor.Save(ctx, metrics.CountingReader(reader, metrics.StorageUploadedBytes.WithLabelValues(stor.Name())), storagePath)
+	metrics.ObserveSave(stor.Name(), start, err)
+	return err
+}
+
+// SaveAlbum saves the files of a media group to stor as an album, recording them like Save.
+func SaveAlbum(ctx context.Context, stor StorageAlbum, files []storagetypes.AlbumFile) error {
+	start := time.Now()
+	counted := make([]storagetypes.AlbumFile, 0, len(files))
+	for _, file := range files {
+		file.Reader = metrics.CountingReader(file.Reader, metrics.StorageUploadedBytes.WithLabelValues(stor.Name()))
+		counted = append(counted, file)
+	}
+	err := stor.SaveAlbum(ctx, counted)
+	metrics.ObserveSave(stor.Name(), start, err)
+	return err
+}
+
+// OpenFile opens filePath on stor, recording the bytes read from it.
+func OpenFile(ctx context.Context, stor StorageReadable, filePath string) (io.ReadCloser, int64, error) {
+	rc, size, err := stor.OpenFile(ctx, filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	return metrics.CountingReadCloser(rc, metrics.StorageDownloadedBytes.WithLabelValues(stor.Name())), size, nil
+}
@@ -0,0 +1,190 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/krau/SaveAny-Bot/pkg/storagetypes"
+)
+
+// albumMaxSize 是一个相册中媒体数量的上限
+const albumMaxSize = 10
+
+type albumItem struct {
+	file    *pendingFile
+	media   tg.InputMediaClass
+	caption string
+}
+
+// SaveAlbum 将媒体组的文件作为相册发送, 需要分卷的文件和要保存到其他聊天或话题的文件单独保存
+func (t *Telegram) SaveAlbum(ctx context.Context, files []storagetypes.AlbumFile) error {
+	tctx := tgutil.ExtFromContext(ctx)
+	if tctx == nil {
+		return fmt.Errorf("failed to get telegram context")
+	}
+	if err := t.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limit failed: %w", err)
+	}
+	splitSize := t.splitSize()
+	items := make([]albumItem, 0, len(files))
+	for _, f := range files {
+		fctx := context.WithValue(tgutil.WithSourceMessage(ctx, f.Message), ctxkey.ContentLength, f.Size)
+		if f.Size > splitSize || (t.config.SkipLarge && f.Size > MaxUploadFileSize) {
+			if err := t.Save(fctx, f.Reader, f.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		file, err := t.prepareFile(fctx, tctx, f.Reader, f.Path, f.Size)
+		if err != nil {
+			return err
+		}
+		if len(items) > 0 && (file.chatID != items[0].file.chatID || file.topicID != items[0].file.topicID) {
+			if err := t.Save(fctx, f.Reader, f.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		uploaded, err := t.uploadMedia(fctx, file)
+		if err != nil {
+			return err
+		}
+		// 相册中的媒体需要先上传到服务器
+		media, err := tctx.Raw.MessagesUploadMedia(ctx, &tg.MessagesUploadMediaRequest{
+			Peer:  file.peer,
+			Media: uploaded,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload media of %s: %w", file.filename, err)
+		}
+		input, err := inputMediaFromMessageMedia(media, sourceMediaOf(f.Message).spoiler)
+		if err != nil {
+			return fmt.Errorf("failed to upload media of %s: %w", file.filename, err)
+		}
+		items = append(items, albumItem{
+			file:    file,
+			media:   input,
+			caption: t.caption(fctx, tctx, file.filename, file.hasher.n),
+		})
+	}
+	for i := 0; i < len(items); i += albumMaxSize {
+		if err := t.sendAlbum(ctx, tctx, items[i:min(i+albumMaxSize, len(items))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Telegram) sendAlbum(ctx context.Context, tctx *ext.Context, items []albumItem) error {
+	file := items[0].file
+	if len(items) > 1 {
+		multiMedia := make([]tg.InputSingleMedia, 0, len(items))
+		for _, item := range items {
+			randomID, err := randomMessageID()
+			if err != nil {
+				return err
+			}
+			multiMedia = append(multiMedia, tg.InputSingleMedia{
+				Media:    item.media,
+				RandomID: randomID,
+				Message:  item.caption,
+			})
+		}
+		updates, err := tctx.Raw.MessagesSendMultiMedia(ctx, &tg.MessagesSendMultiMediaRequest{
+			Peer:       file.peer,
+			ReplyTo:    file.replyTo(),
+			MultiMedia: multiMedia,
+		})
+		if err == nil {
+			ids := sentMessageIDs(updates)
+			for i, item := range items {
+				t.indexAlbumItem(ctx, item, ids[multiMedia[i].RandomID])
+			}
+			return nil
+		}
+		// 不同类型的媒体不能放在同一相册中, 例如因过大而以文件发送的图片
+		log.FromContext(ctx).Warnf("Failed to send album, sending the files one by one: %s", err)
+	}
+	for _, item := range items {
+		sent, err := file.to(tctx).Media(ctx, message.Media(item.media, styling.Plain(item.caption)))
+		if err != nil {
+			return err
+		}
+		t.indexAlbumItem(ctx, item, tgutil.SentMessageID(sent))
+	}
+	return nil
+}
+
+func (t *Telegram) indexAlbumItem(ctx context.Context, item albumItem, msgID int) {
+	if msgID == 0 {
+		return
+	}
+	t.indexFile(ctx, storagetypes.TelegramFile{
+		ChatID:     item.file.chatID,
+		Dir:        item.file.dir,
+		Name:       item.file.filename,
+		Size:       item.file.hasher.n,
+		Hash:       item.file.hasher.Sum(),
+		MessageIDs: []int{msgID},
+	})
+}
+
+// inputMediaFromMessageMedia 返回已上传到服务器的媒体
+func inputMediaFromMessageMedia(media tg.MessageMediaClass, spoiler bool) (tg.InputMediaClass, error) {
+	switch m := media.(type) {
+	case *tg.MessageMediaPhoto:
+		photo, ok := m.Photo.AsNotEmpty()
+		if !ok {
+			return nil, errors.New("photo is empty")
+		}
+		return &tg.InputMediaPhoto{ID: photo.AsInput(), Spoiler: spoiler}, nil
+	case *tg.MessageMediaDocument:
+		document, ok := m.Document.AsNotEmpty()
+		if !ok {
+			return nil, errors.New("document is empty")
+		}
+		return &tg.InputMediaDocument{ID: document.AsInput(), Spoiler: spoiler}, nil
+	}
+	return nil, fmt.Errorf("unsupported media type: %T", media)
+}
+
+// sentMessageIDs 返回发送的消息的随机 ID 与消息 ID 的对应关系
+func sentMessageIDs(updates tg.UpdatesClass) map[int64]int {
+	ids := make(map[int64]int)
+	for _, update := range updateList(updates) {
+		if u, ok := update.(*tg.UpdateMessageID); ok {
+			ids[u.RandomID] = u.ID
+		}
+	}
+	return ids
+}
+
+func updateList(updates tg.UpdatesClass) []tg.UpdateClass {
+	switch u := updates.(type) {
+	case *tg.Updates:
+		return u.Updates
+	case *tg.UpdatesCombined:
+		return u.Updates
+	case *tg.UpdateShort:
+		return []tg.UpdateClass{u.Update}
+	}
+	return nil
+}
+
+func randomMessageID() (int64, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return 0, fmt.Errorf("failed to generate random ID: %w", err)
+	}
+	return int64(binary.LittleEndian.Uint64(b)), nil
+}
@@ -0,0 +1,80 @@
+package telegram
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/utils/dlutil"
+	"github.com/krau/SaveAny-Bot/common/utils/strutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+)
+
+// captionMaxLength 是媒体说明文字的长度上限, 以 UTF-16 码元计
+const captionMaxLength = 1024
+
+// caption 返回上传的文件的说明文字, 未配置模板时为文件名
+func (t *Telegram) caption(ctx context.Context, tctx *ext.Context, filename string, size int64) string {
+	if t.captionTmpl == nil {
+		return filename
+	}
+	var sb strings.Builder
+	data := buildCaptionData(tctx, tgutil.SourceMessageFromContext(ctx), filename, size)
+	if err := t.captionTmpl.Execute(&sb, data); err != nil {
+		log.FromContext(ctx).Errorf("Failed to execute caption template: %s", err)
+		return filename
+	}
+	return truncateCaption(strings.TrimSpace(sb.String()))
+}
+
+// buildCaptionData 返回说明文字模板的数据, 没有来源消息时消息相关的字段为空
+func buildCaptionData(tctx *ext.Context, msg *tg.Message, filename string, size int64) map[string]string {
+	data := map[string]string{
+		"filename": filename,
+		"size":     dlutil.FormatSize(size),
+		"msgid":    "",
+		"msgraw":   "",
+		"msgtags":  "",
+		"msglink":  "",
+		"msgdate":  "",
+		"chatid":   "",
+	}
+	if msg == nil {
+		return data
+	}
+	data["msgid"] = strconv.Itoa(msg.GetID())
+	data["msgraw"] = msg.GetMessage()
+	tags := strutil.ExtractTagsFromText(msg.GetMessage())
+	for i, tag := range tags {
+		tags[i] = "#" + tag
+	}
+	data["msgtags"] = strings.Join(tags, " ")
+	data["msglink"] = tgutil.MessageLink(tctx, msg)
+	if date := msg.GetDate(); date != 0 {
+		data["msgdate"] = time.Unix(int64(date), 0).Format("2006-01-02 15:04:05")
+	}
+	if chatID := tgutil.ChatIdFromPeer(msg.GetPeerID()); chatID != 0 {
+		data["chatid"] = strconv.FormatInt(chatID, 10)
+	}
+	return data
+}
+
+func truncateCaption(caption string) string {
+	if len(utf16.Encode([]rune(caption))) <= captionMaxLength {
+		return caption
+	}
+	runes := []rune(caption)
+	length := 1 // 省略号
+	for i, r := range runes {
+		length += utf16.RuneLen(r)
+		if length > captionMaxLength {
+			return string(runes[:i]) + "…"
+		}
+	}
+	return caption
+}
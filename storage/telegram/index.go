@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"path"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/krau/SaveAny-Bot/pkg/storagetypes"
+	"github.com/rs/xid"
+)
+
+// Index 记录 telegram 存储上传的所有文件, 使聊天可以像文件系统一样被列举和读取
+type Index interface {
+	SaveTelegramFile(ctx context.Context, storageName string, file storagetypes.TelegramFile) error
+	// GetTelegramFiles returns the files of a topic directory of the chat, files outside directory topics for an empty dir
+	GetTelegramFiles(ctx context.Context, storageName string, chatID int64, dir string) ([]storagetypes.TelegramFile, error)
+	// GetTelegramFile returns nil if the file is not indexed
+	GetTelegramFile(ctx context.Context, storageName string, chatID int64, dir, name string) (*storagetypes.TelegramFile, error)
+	// GetTelegramTopic returns 0 if no topic was created for the title
+	GetTelegramTopic(ctx context.Context, storageName string, chatID int64, title string) (int, error)
+	SaveTelegramTopic(ctx context.Context, storageName string, chatID int64, title string, topicID int) error
+}
+
+var index Index
+
+// SetIndex sets the index of the telegram storages, without it only split files can be found by their manifests.
+func SetIndex(idx Index) {
+	index = idx
+}
+
+func (t *Telegram) indexFile(ctx context.Context, file storagetypes.TelegramFile) {
+	if index == nil || file.Name == "" {
+		return
+	}
+	if err := index.SaveTelegramFile(ctx, t.Name(), file); err != nil {
+		log.FromContext(ctx).Warnf("Failed to index file %s: %s", file.Name, err)
+	}
+}
+
+func (t *Telegram) indexedFile(ctx context.Context, chatID int64, dir, name string) *storagetypes.TelegramFile {
+	if index == nil {
+		return nil
+	}
+	file, err := index.GetTelegramFile(ctx, t.Name(), chatID, dir, name)
+	if err != nil {
+		log.FromContext(ctx).Warnf("Failed to get indexed file %s: %s", name, err)
+		return nil
+	}
+	return file
+}
+
+// uniqueName returns a name not indexed in the topic directory of the chat yet, like the other storages do for existing files.
+func (t *Telegram) uniqueName(ctx context.Context, chatID int64, dir, name string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 1; t.indexedFile(ctx, chatID, dir, candidate) != nil; i++ {
+		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+		if i > 100 {
+			log.FromContext(ctx).Errorf("Too many attempts to find a unique filename for %s", name)
+			return fmt.Sprintf("%s_%s%s", base, xid.New().String(), ext)
+		}
+	}
+	return candidate
+}
+
+// contentHasher hashes the content read by the uploader.
+type contentHasher struct {
+	hash hash.Hash
+	n    int64
+}
+
+func newContentHasher() *contentHasher {
+	return &contentHasher{hash: sha256.New()}
+}
+
+func (h *contentHasher) Write(p []byte) (int, error) {
+	h.n += int64(len(p))
+	return h.hash.Write(p)
+}
+
+func (h *contentHasher) Sum() string {
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
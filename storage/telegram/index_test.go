@@ -0,0 +1,85 @@
+package telegram
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+	"strings"
+	"testing"
+
+	storconfig "github.com/krau/SaveAny-Bot/config/storage"
+	"github.com/krau/SaveAny-Bot/pkg/storagetypes"
+)
+
+type fakeIndex struct {
+	files  map[string]storagetypes.TelegramFile
+	topics map[string]int
+}
+
+func (f *fakeIndex) SaveTelegramFile(ctx context.Context, storageName string, file storagetypes.TelegramFile) error {
+	f.files[path.Join("/", file.Dir, file.Name)] = file
+	return nil
+}
+
+func (f *fakeIndex) GetTelegramFiles(ctx context.Context, storageName string, chatID int64, dir string) ([]storagetypes.TelegramFile, error) {
+	files := make([]storagetypes.TelegramFile, 0, len(f.files))
+	for _, file := range f.files {
+		if file.ChatID == chatID && file.Dir == dir {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+func (f *fakeIndex) GetTelegramFile(ctx context.Context, storageName string, chatID int64, dir, name string) (*storagetypes.TelegramFile, error) {
+	if file, ok := f.files[path.Join("/", dir, name)]; ok && file.ChatID == chatID {
+		return &file, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeIndex) GetTelegramTopic(ctx context.Context, storageName string, chatID int64, title string) (int, error) {
+	return f.topics[title], nil
+}
+
+func (f *fakeIndex) SaveTelegramTopic(ctx context.Context, storageName string, chatID int64, title string, topicID int) error {
+	f.topics[title] = topicID
+	return nil
+}
+
+func TestUniqueName(t *testing.T) {
+	idx := &fakeIndex{files: make(map[string]storagetypes.TelegramFile)}
+	SetIndex(idx)
+	t.Cleanup(func() { SetIndex(nil) })
+	stor := &Telegram{config: storconfig.TelegramStorageConfig{ChatID: 1}}
+	ctx := context.Background()
+
+	if got := stor.uniqueName(ctx, 1, "", "video.mp4"); got != "video.mp4" {
+		t.Errorf("got %s for a new file, expected video.mp4", got)
+	}
+	stor.indexFile(ctx, storagetypes.TelegramFile{ChatID: 1, Name: "video.mp4", MessageIDs: []int{10}})
+	stor.indexFile(ctx, storagetypes.TelegramFile{ChatID: 1, Name: "video_1.mp4", MessageIDs: []int{11}})
+	if got := stor.uniqueName(ctx, 1, "", "video.mp4"); got != "video_2.mp4" {
+		t.Errorf("got %s for an indexed file, expected video_2.mp4", got)
+	}
+	if got := stor.uniqueName(ctx, 2, "", "video.mp4"); got != "video.mp4" {
+		t.Errorf("got %s for a file of another chat, expected video.mp4", got)
+	}
+	if got := stor.uniqueName(ctx, 1, "videos", "video.mp4"); got != "video.mp4" {
+		t.Errorf("got %s for a file of a topic directory, expected video.mp4", got)
+	}
+}
+
+func TestContentHasher(t *testing.T) {
+	content := strings.Repeat("SaveAny", 1000)
+	hasher := newContentHasher()
+	if _, err := io.Copy(io.Discard, io.TeeReader(strings.NewReader(content), hasher)); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	if hasher.Sum() != hex.EncodeToString(sum[:]) || hasher.n != int64(len(content)) {
+		t.Errorf("got hash %s of %d bytes", hasher.Sum(), hasher.n)
+	}
+}
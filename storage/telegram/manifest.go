@@ -0,0 +1,185 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+)
+
+// manifestTag 标记分卷上传的清单消息
+const manifestTag = "#SaveAnySplit"
+
+// manifest 描述一个分卷上传的原始文件, 每个清单记录上一个清单的消息 ID, 最新的清单被置顶, 从而可以找到聊天中所有的分卷文件
+type manifest struct {
+	Dir   string // topic directory of the file, see TelegramFile.Dir
+	Name  string
+	Size  int64
+	Parts []int // message IDs of the parts in order
+	Prev  int   // message ID of the previous manifest, 0 if it is the first
+	Date  time.Time
+}
+
+func (m manifest) String() string {
+	parts := make([]string, 0, len(m.Parts))
+	for _, id := range m.Parts {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	text := fmt.Sprintf("%s\nname: %s\nsize: %d\nparts: %s\nprev: %d", manifestTag, m.Name, m.Size, strings.Join(parts, ","), m.Prev)
+	if m.Dir != "" {
+		text += "\ndir: " + m.Dir
+	}
+	return text
+}
+
+var errNotManifest = errors.New("not a split manifest")
+
+func parseManifest(text string) (*manifest, error) {
+	lines := strings.Split(text, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != manifestTag {
+		return nil, errNotManifest
+	}
+	m := &manifest{}
+	for _, line := range lines[1:] {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		var err error
+		switch key {
+		case "dir":
+			m.Dir = value
+		case "name":
+			m.Name = value
+		case "size":
+			m.Size, err = strconv.ParseInt(value, 10, 64)
+		case "prev":
+			m.Prev, err = strconv.Atoi(value)
+		case "parts":
+			for id := range strings.SplitSeq(value, ",") {
+				var part int
+				if part, err = strconv.Atoi(id); err != nil {
+					break
+				}
+				m.Parts = append(m.Parts, part)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s of split manifest: %w", key, err)
+		}
+	}
+	if m.Name == "" || m.Size <= 0 || len(m.Parts) == 0 {
+		return nil, fmt.Errorf("incomplete split manifest")
+	}
+	return m, nil
+}
+
+// writeManifest sends the manifest of the parts sent by updates and pins it as the latest manifest of the chat.
+// It returns the message IDs of the parts, which are found even if sending the manifest fails.
+func writeManifest(ctx *ext.Context, peer tg.InputPeerClass, dir, name string, size int64, updates []tg.UpdatesClass) ([]int, error) {
+	parts := make([]*tg.Message, 0)
+	for _, upd := range updates {
+		parts = append(parts, tgutil.MessagesFromUpdates(upd)...)
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("no parts found in the sent messages")
+	}
+	slices.SortFunc(parts, func(a, b *tg.Message) int { return a.GetID() - b.GetID() })
+	m := manifest{Dir: dir, Name: name, Size: size}
+	for _, part := range parts {
+		m.Parts = append(m.Parts, part.GetID())
+	}
+	prev, err := getPinnedMessageID(ctx, peer)
+	if err != nil {
+		return m.Parts, fmt.Errorf("failed to get the latest manifest: %w", err)
+	}
+	m.Prev = prev
+	sent, err := ctx.Sender.To(peer).Text(ctx, m.String())
+	if err != nil {
+		return m.Parts, fmt.Errorf("failed to send manifest: %w", err)
+	}
+	id := tgutil.SentMessageID(sent)
+	if id == 0 {
+		return m.Parts, errors.New("failed to get the ID of the sent manifest")
+	}
+	_, err = ctx.Raw.MessagesUpdatePinnedMessage(ctx, &tg.MessagesUpdatePinnedMessageRequest{
+		Silent: true,
+		Peer:   peer,
+		ID:     id,
+	})
+	if err != nil {
+		return m.Parts, fmt.Errorf("failed to pin manifest: %w", err)
+	}
+	return m.Parts, nil
+}
+
+// readManifests returns the manifests of the chat from the latest one.
+func readManifests(ctx *ext.Context, chatID int64, peer tg.InputPeerClass) ([]*manifest, error) {
+	id, err := getPinnedMessageID(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	manifests := make([]*manifest, 0)
+	seen := make(map[int]bool)
+	for id != 0 && !seen[id] {
+		seen[id] = true
+		msg, err := tgutil.GetMessageByID(ctx, chatID, id)
+		if err != nil && len(manifests) > 0 {
+			log.FromContext(ctx).Warnf("Failed to get manifest %d, older split files are not found: %s", id, err)
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get manifest %d: %w", id, err)
+		}
+		m, err := parseManifest(msg.GetMessage())
+		if errors.Is(err, errNotManifest) {
+			// 置顶了其他消息, 之前的清单无法再找到
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest %d: %w", id, err)
+		}
+		m.Date = time.Unix(int64(msg.GetDate()), 0)
+		manifests = append(manifests, m)
+		id = m.Prev
+	}
+	return manifests, nil
+}
+
+func getPinnedMessageID(ctx *ext.Context, peer tg.InputPeerClass) (int, error) {
+	switch p := peer.(type) {
+	case *tg.InputPeerChannel:
+		full, err := ctx.Raw.ChannelsGetFullChannel(ctx, &tg.InputChannel{ChannelID: p.ChannelID, AccessHash: p.AccessHash})
+		if err != nil {
+			return 0, err
+		}
+		if channel, ok := full.FullChat.(*tg.ChannelFull); ok {
+			id, _ := channel.GetPinnedMsgID()
+			return id, nil
+		}
+	case *tg.InputPeerChat:
+		full, err := ctx.Raw.MessagesGetFullChat(ctx, p.ChatID)
+		if err != nil {
+			return 0, err
+		}
+		if chat, ok := full.FullChat.(*tg.ChatFull); ok {
+			id, _ := chat.GetPinnedMsgID()
+			return id, nil
+		}
+	case *tg.InputPeerUser:
+		full, err := ctx.Raw.UsersGetFullUser(ctx, &tg.InputUser{UserID: p.UserID, AccessHash: p.AccessHash})
+		if err != nil {
+			return 0, err
+		}
+		id, _ := full.FullUser.GetPinnedMsgID()
+		return id, nil
+	}
+	return 0, fmt.Errorf("unsupported peer %T", peer)
+}
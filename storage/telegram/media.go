@@ -0,0 +1,238 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/utils/dlutil"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/config"
+	"github.com/krau/SaveAny-Bot/pkg/consts/tglimit"
+	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
+	"github.com/rs/xid"
+)
+
+// pendingFile 是准备上传到聊天的文件
+type pendingFile struct {
+	chatID   int64
+	peer     tg.InputPeerClass
+	topicID  int    // 0 表示不发送到话题
+	dir      string // 索引中的话题目录
+	filename string
+	size     int64
+	reader   io.Reader     // 读取时同时计算哈希
+	rs       io.ReadSeeker // 不可 seek 时为 nil
+	mtype    *mimetype.MIME
+	hasher   *contentHasher
+	upler    *uploader.Uploader
+}
+
+func (t *Telegram) prepareFile(ctx context.Context, tctx *ext.Context, r io.Reader, storagePath string, size int64) (*pendingFile, error) {
+	chatID, dir, filename := t.splitPath(ctx, tctx, path.Clean(storagePath))
+	if overwrite, _ := ctx.Value(ctxkey.OverwriteExisting).(bool); !overwrite && filename != "" {
+		filename = t.uniqueName(ctx, chatID, t.topicDir(dir), filename)
+	}
+	peer := tryGetInputPeer(tctx, chatID)
+	if peer == nil || peer.Zero() {
+		return nil, fmt.Errorf("failed to get input peer for chat ID %d", chatID)
+	}
+	topicID, err := t.topicID(ctx, tctx, chatID, peer, dir)
+	if err != nil {
+		return nil, err
+	}
+	file := &pendingFile{
+		chatID:   chatID,
+		peer:     peer,
+		topicID:  topicID,
+		dir:      t.topicDir(dir),
+		filename: filename,
+		size:     size,
+		hasher:   newContentHasher(),
+		upler: uploader.NewUploader(tctx.Raw).
+			WithPartSize(tglimit.MaxUploadPartSize).
+			WithThreads(dlutil.BestThreads(size, config.C().Threads)),
+	}
+	if rs, ok := r.(io.ReadSeeker); ok {
+		mtype, err := mimetype.DetectReader(rs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect mimetype: %w", err)
+		}
+		if file.filename == "" {
+			file.filename = xid.New().String() + mtype.Extension()
+		}
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek reader: %w", err)
+		}
+		file.rs = rs
+		file.mtype = mtype
+	}
+	file.reader = io.TeeReader(r, file.hasher)
+	return file, nil
+}
+
+// to 返回发送消息到文件的聊天和话题的构造器
+func (f *pendingFile) to(tctx *ext.Context) *message.Builder {
+	b := &tctx.Sender.WithUploader(f.upler).To(f.peer).Builder
+	if f.topicID != 0 {
+		b = b.Reply(f.topicID)
+	}
+	return b
+}
+
+// replyTo 返回发送到文件的话题的回复设置, 不发送到话题时为 nil
+func (f *pendingFile) replyTo() tg.InputReplyToClass {
+	if f.topicID == 0 {
+		return nil
+	}
+	return &tg.InputReplyToMessage{ReplyToMsgID: f.topicID}
+}
+
+// uploadMedia 上传文件并返回其消息媒体
+func (t *Telegram) uploadMedia(ctx context.Context, file *pendingFile) (tg.InputMediaClass, error) {
+	var input tg.InputFileClass
+	var err error
+	if file.size <= 0 {
+		input, err = file.upler.FromReader(ctx, file.filename, file.reader)
+	} else {
+		input, err = file.upler.Upload(ctx, uploader.NewUpload(file.filename, file.reader, file.size))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file to telegram: %w", err)
+	}
+	return t.buildMedia(ctx, file, input), nil
+}
+
+// sourceMedia 是文件来源消息的媒体类型
+type sourceMedia struct {
+	photo    bool
+	document bool
+	spoiler  bool
+	video    *tg.DocumentAttributeVideo
+	audio    *tg.DocumentAttributeAudio
+	animated bool
+}
+
+func sourceMediaOf(msg *tg.Message) sourceMedia {
+	var src sourceMedia
+	if msg == nil {
+		return src
+	}
+	switch m := msg.Media.(type) {
+	case *tg.MessageMediaPhoto:
+		src.photo = true
+		src.spoiler = m.Spoiler
+	case *tg.MessageMediaDocument:
+		src.spoiler = m.Spoiler
+		document, ok := m.Document.AsNotEmpty()
+		if !ok {
+			break
+		}
+		src.document = true
+		for _, attribute := range document.Attributes {
+			switch attr := attribute.(type) {
+			case *tg.DocumentAttributeVideo:
+				src.video = attr
+			case *tg.DocumentAttributeAudio:
+				src.audio = attr
+			case *tg.DocumentAttributeAnimated:
+				src.animated = true
+			}
+		}
+	}
+	return src
+}
+
+// buildMedia 返回上传的文件作为其来源消息的媒体类型发送时的消息媒体, 没有来源消息时根据 MIME 类型判断
+func (t *Telegram) buildMedia(ctx context.Context, file *pendingFile, input tg.InputFileClass) tg.InputMediaClass {
+	src := sourceMediaOf(tgutil.SourceMessageFromContext(ctx))
+	mtype := ""
+	if file.mtype != nil {
+		mtype = file.mtype.String()
+	}
+	doc := &tg.InputMediaUploadedDocument{
+		File:      input,
+		MimeType:  mtype,
+		ForceFile: t.config.ForceFile,
+		Spoiler:   src.spoiler,
+		Attributes: []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeFilename{FileName: file.filename},
+		},
+	}
+	if doc.ForceFile {
+		return doc
+	}
+	isImage := strings.HasPrefix(mtype, "image/") && !strings.HasSuffix(mtype, "webp")
+	switch {
+	case src.photo || (!src.document && isImage):
+		if isImage && file.hasher.n < tglimit.MaxPhotoSize {
+			return &tg.InputMediaUploadedPhoto{File: input, Spoiler: src.spoiler}
+		}
+		doc.ForceFile = true
+	case src.audio != nil:
+		audio := *src.audio
+		doc.Attributes = append(doc.Attributes, &audio)
+	case src.video != nil || (!src.document && strings.HasPrefix(mtype, "video/")):
+		t.setVideoAttributes(ctx, doc, file, src.video)
+		if src.animated {
+			doc.Attributes = append(doc.Attributes, &tg.DocumentAttributeAnimated{})
+		}
+	case !src.document && strings.HasPrefix(mtype, "audio/"):
+		doc.Attributes = append(doc.Attributes, &tg.DocumentAttributeAudio{Title: file.filename})
+	case src.document:
+		// 来源消息以文件形式发送
+		doc.ForceFile = true
+	}
+	return doc
+}
+
+// setVideoAttributes 设置视频的缩略图和属性, 有来源消息的视频属性时使用其时长和分辨率
+func (t *Telegram) setVideoAttributes(ctx context.Context, doc *tg.InputMediaUploadedDocument, file *pendingFile, srcAttr *tg.DocumentAttributeVideo) {
+	attr := &tg.DocumentAttributeVideo{}
+	if srcAttr != nil {
+		*attr = *srcAttr
+	}
+	attr.SupportsStreaming = true
+	defer func() {
+		doc.Attributes = append(doc.Attributes, attr)
+	}()
+	rs := file.rs
+	if rs == nil {
+		return
+	}
+	defer rs.Seek(0, io.SeekStart)
+	rs.Seek(0, io.SeekStart)
+	if thumb, err := extractThumbFrame(rs); err == nil {
+		if thumbFile, err := file.upler.FromBytes(ctx, "thumb.jpg", thumb); err == nil {
+			doc.Thumb = thumbFile
+		}
+	}
+	if srcAttr != nil {
+		return
+	}
+	rs.Seek(0, io.SeekStart)
+	var info *VideoMetadata
+	var err error
+	if file.mtype.String() == "video/mp4" {
+		info, err = getMP4Meta(rs)
+		if err != nil {
+			// Fallback to ffprobe if gomedia fails (e.g., malformed MP4)
+			rs.Seek(0, io.SeekStart)
+			info, err = getVideoMetadata(rs)
+		}
+	} else {
+		info, err = getVideoMetadata(rs)
+	}
+	if err == nil {
+		attr.Duration = float64(info.Duration)
+		attr.W = info.Width
+		attr.H = info.Height
+	}
+}
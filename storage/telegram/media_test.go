@@ -0,0 +1,101 @@
+package telegram
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+	"unicode/utf16"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gotd/td/tg"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+)
+
+func TestCaptionTemplate(t *testing.T) {
+	stor := &Telegram{captionTmpl: template.Must(template.New("caption").Parse("{{.filename}} ({{.size}})\n{{.msgraw}}\n{{.msgtags}}\n{{.msglink}}"))}
+	msg := &tg.Message{
+		ID:      42,
+		PeerID:  &tg.PeerChannel{ChannelID: 1234},
+		Message: "new wallpaper #nature #4k",
+	}
+	ctx := tgutil.WithSourceMessage(context.Background(), msg)
+	got := stor.caption(ctx, nil, "forest.jpg", 3<<20)
+	want := "forest.jpg (3.00 MB)\nnew wallpaper #nature #4k\n#nature #4k\nhttps://t.me/c/1234/42"
+	if got != want {
+		t.Errorf("got caption %q, expected %q", got, want)
+	}
+	if got := stor.caption(context.Background(), nil, "forest.jpg", 3<<20); got != "forest.jpg (3.00 MB)" {
+		t.Errorf("got caption %q without a source message", got)
+	}
+	if got := (&Telegram{}).caption(ctx, nil, "forest.jpg", 3<<20); got != "forest.jpg" {
+		t.Errorf("got caption %q without a template, expected the file name", got)
+	}
+}
+
+func TestTruncateCaption(t *testing.T) {
+	long := strings.Repeat("猫", 600) + strings.Repeat("😺", 300)
+	got := truncateCaption(long)
+	if n := len(utf16.Encode([]rune(got))); n > captionMaxLength {
+		t.Errorf("truncated caption has %d UTF-16 code units", n)
+	}
+	if !strings.HasSuffix(got, "…") {
+		t.Errorf("truncated caption %q has no ellipsis", got)
+	}
+	if got := truncateCaption("short"); got != "short" {
+		t.Errorf("got %q for a short caption", got)
+	}
+}
+
+func TestBuildMediaKeepsSourceType(t *testing.T) {
+	stor := &Telegram{}
+	newFile := func(mtype string, size int64) *pendingFile {
+		file := &pendingFile{filename: "file", mtype: mimetype.Lookup(mtype), hasher: newContentHasher()}
+		file.hasher.n = size
+		return file
+	}
+	input := &tg.InputFile{ID: 1}
+	source := func(media tg.MessageMediaClass) context.Context {
+		return tgutil.WithSourceMessage(context.Background(), &tg.Message{Media: media})
+	}
+
+	photo, ok := stor.buildMedia(source(&tg.MessageMediaPhoto{Spoiler: true}), newFile("image/jpeg", 1<<20), input).(*tg.InputMediaUploadedPhoto)
+	if !ok || !photo.Spoiler {
+		t.Errorf("expected a photo with spoiler, got %#v", photo)
+	}
+
+	voice := &tg.DocumentAttributeAudio{Voice: true, Duration: 12}
+	doc, ok := stor.buildMedia(source(&tg.MessageMediaDocument{
+		Document: &tg.Document{Attributes: []tg.DocumentAttributeClass{voice}},
+	}), newFile("audio/ogg", 1<<10), input).(*tg.InputMediaUploadedDocument)
+	if !ok || doc.ForceFile || !hasAttribute(doc, voice) {
+		t.Errorf("expected a voice note, got %#v", doc)
+	}
+
+	music := &tg.DocumentAttributeAudio{Title: "Song", Performer: "Band", Duration: 180}
+	doc, ok = stor.buildMedia(source(&tg.MessageMediaDocument{
+		Document: &tg.Document{Attributes: []tg.DocumentAttributeClass{music}},
+	}), newFile("audio/mpeg", 1<<20), input).(*tg.InputMediaUploadedDocument)
+	if !ok || !hasAttribute(doc, music) {
+		t.Errorf("expected an audio with its title and performer, got %#v", doc)
+	}
+
+	doc, ok = stor.buildMedia(source(&tg.MessageMediaDocument{Document: &tg.Document{}}), newFile("image/jpeg", 1<<20), input).(*tg.InputMediaUploadedDocument)
+	if !ok || !doc.ForceFile {
+		t.Errorf("expected an image sent as a file to stay a file, got %#v", doc)
+	}
+
+	if _, ok := stor.buildMedia(context.Background(), newFile("image/png", 1<<20), input).(*tg.InputMediaUploadedPhoto); !ok {
+		t.Error("expected an image without a source message to be sent as a photo")
+	}
+}
+
+func hasAttribute(doc *tg.InputMediaUploadedDocument, want *tg.DocumentAttributeAudio) bool {
+	for _, attr := range doc.Attributes {
+		if audio, ok := attr.(*tg.DocumentAttributeAudio); ok && reflect.DeepEqual(audio, want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package telegram
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/duke-git/lancet/v2/validator"
+	"github.com/krau/SaveAny-Bot/common/tdler"
+	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
+	"github.com/krau/SaveAny-Bot/pkg/storagetypes"
+	"github.com/krau/SaveAny-Bot/pkg/tfile"
+)
+
+// ListFiles lists the files uploaded to the chat and topic directory of dirPath, laid out like the paths files are saved to:
+// empty for the configured chat, the chat ID, a topic directory, or the chat ID followed by a topic directory.
+// Files are listed from the index, and split files uploaded before the index existed from their manifests.
+func (t *Telegram) ListFiles(ctx context.Context, dirPath string) ([]storagetypes.FileInfo, error) {
+	tctx := extContext(ctx)
+	if tctx == nil {
+		return nil, fmt.Errorf("failed to get telegram context")
+	}
+	chatID, dir, err := t.dirLocation(tctx, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := t.chatFiles(ctx, tctx, chatID, dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]storagetypes.FileInfo, 0, len(files))
+	for _, file := range files {
+		infos = append(infos, storagetypes.FileInfo{
+			Name:    file.Name,
+			Path:    path.Join(dirPath, file.Name),
+			Size:    file.Size,
+			ModTime: file.CreatedAt,
+		})
+	}
+	return infos, nil
+}
+
+// OpenFile streams a file of the chat, split files are reassembled from their parts.
+func (t *Telegram) OpenFile(ctx context.Context, filePath string) (io.ReadCloser, int64, error) {
+	tctx := extContext(ctx)
+	if tctx == nil {
+		return nil, 0, fmt.Errorf("failed to get telegram context")
+	}
+	dir, name := path.Split(path.Clean("/" + filePath))
+	chatID, topicDir, err := t.dirLocation(tctx, dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	found := t.indexedFile(ctx, chatID, topicDir, name)
+	if found == nil {
+		files, err := t.chatFiles(ctx, tctx, chatID, topicDir)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, file := range files {
+			if file.Name == name {
+				found = &file
+				break
+			}
+		}
+	}
+	if found == nil || len(found.MessageIDs) == 0 {
+		return nil, 0, fmt.Errorf("failed to open file %s: %w", filePath, os.ErrNotExist)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(downloadMessages(ctx, tctx, chatID, found.MessageIDs, pw))
+	}()
+	if !found.Split {
+		return pr, found.Size, nil
+	}
+	r, err := unzipStored(pr, found.Size)
+	if err != nil {
+		pr.CloseWithError(err)
+		return nil, 0, fmt.Errorf("failed to read split file %s: %w", filePath, err)
+	}
+	return &readCloser{Reader: r, Closer: pr}, found.Size, nil
+}
+
+// chatFiles returns the indexed files of a topic directory of the chat and the split files found by their manifests,
+// newer files hide older ones of the same name.
+func (t *Telegram) chatFiles(ctx context.Context, tctx *ext.Context, chatID int64, dir string) ([]storagetypes.TelegramFile, error) {
+	files := make([]storagetypes.TelegramFile, 0)
+	seen := make(map[string]bool)
+	if index != nil {
+		indexed, err := index.GetTelegramFiles(ctx, t.Name(), chatID, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get indexed files of chat %d: %w", chatID, err)
+		}
+		for _, file := range indexed {
+			seen[file.Name] = true
+			files = append(files, file)
+		}
+	}
+	manifests, err := t.readManifests(tctx, chatID)
+	if err != nil {
+		if index == nil {
+			return nil, err
+		}
+		log.FromContext(ctx).Warnf("Failed to read split manifests, listing indexed files only: %s", err)
+	}
+	for _, m := range manifests {
+		if m.Dir != dir || seen[m.Name] {
+			continue
+		}
+		seen[m.Name] = true
+		files = append(files, storagetypes.TelegramFile{
+			ChatID:     chatID,
+			Dir:        m.Dir,
+			Name:       m.Name,
+			Size:       m.Size,
+			MessageIDs: m.Parts,
+			Split:      true,
+			CreatedAt:  m.Date,
+		})
+	}
+	return files, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// extContext returns the client to read the storage with, the telegram context of ctx or ctx itself.
+func extContext(ctx context.Context) *ext.Context {
+	if tctx, ok := ctx.(*ext.Context); ok {
+		return tctx
+	}
+	return tgutil.ExtFromContext(ctx)
+}
+
+// dirLocation returns the chat and topic directory of a directory, recognising topic directories the way splitPath does.
+func (t *Telegram) dirLocation(tctx *ext.Context, dirPath string) (int64, string, error) {
+	parts := slice.Compact(strings.Split(strings.Trim(path.Clean("/"+dirPath), "/"), "/"))
+	chatID := t.config.ChatID
+	if len(parts) >= 1 && validator.IsAlphaNumeric(parts[0]) && !t.isTopicDir(parts[0]) {
+		cid, err := tgutil.ParseChatID(tctx, parts[0])
+		if err != nil {
+			return 0, "", err
+		}
+		chatID = cid
+		parts = parts[1:]
+	}
+	switch len(parts) {
+	case 0:
+		return chatID, "", nil
+	case 1:
+		if dir := t.topicDir(parts[0]); dir != "" {
+			return chatID, dir, nil
+		}
+	}
+	return 0, "", fmt.Errorf("directory %s not found: %w", dirPath, os.ErrNotExist)
+}
+
+func (t *Telegram) readManifests(tctx *ext.Context, chatID int64) ([]*manifest, error) {
+	peer := tryGetInputPeer(tctx, chatID)
+	if peer == nil || peer.Zero() {
+		return nil, fmt.Errorf("failed to get input peer for chat ID %d", chatID)
+	}
+	manifests, err := readManifests(tctx, chatID, peer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read split manifests of chat %d: %w", chatID, err)
+	}
+	return manifests, nil
+}
+
+// downloadMessages writes the files of the messages to w in order, the zip parts of a split file or the message of a file.
+func downloadMessages(ctx context.Context, tctx *ext.Context, chatID int64, parts []int, w io.Writer) error {
+	for i, id := range parts {
+		msg, err := tgutil.GetMessageByID(tctx, chatID, id)
+		if err != nil {
+			return fmt.Errorf("failed to get part %d: %w", i+1, err)
+		}
+		media, ok := msg.GetMedia()
+		if !ok {
+			return fmt.Errorf("part %d has no file", i+1)
+		}
+		file, err := tfile.FromMediaMessage(media, tctx.Raw, msg, tgutil.WithFileRefresher(tctx, msg))
+		if err != nil {
+			return fmt.Errorf("failed to get file of part %d: %w", i+1, err)
+		}
+		if _, err := tdler.NewDownloader(file).Stream(ctx, w); err != nil {
+			return fmt.Errorf("failed to download part %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+const zipLocalHeaderSignature = 0x04034b50
+
+// unzipStored returns the content of the first entry of a zip stream written by CreateSplitZip, which is stored without compression.
+// The size of the entry must be given, as its local header is written before the size is known.
+func unzipStored(r io.Reader, size int64) (io.Reader, error) {
+	header := make([]byte, 30)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read zip header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(header) != zipLocalHeaderSignature {
+		return nil, errors.New("not a zip file")
+	}
+	if method := binary.LittleEndian.Uint16(header[8:]); method != zip.Store {
+		return nil, fmt.Errorf("unsupported zip method %d", method)
+	}
+	nameLen := binary.LittleEndian.Uint16(header[26:])
+	extraLen := binary.LittleEndian.Uint16(header[28:])
+	if _, err := io.CopyN(io.Discard, r, int64(nameLen)+int64(extraLen)); err != nil {
+		return nil, fmt.Errorf("failed to read zip header: %w", err)
+	}
+	return io.LimitReader(r, size), nil
+}
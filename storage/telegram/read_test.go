@@ -0,0 +1,71 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	m := manifest{Name: "movie: part one.mkv", Size: 5 << 30, Parts: []int{101, 102, 103}, Prev: 95}
+	parsed, err := parseManifest(m.String())
+	if err != nil {
+		t.Fatalf("failed to parse manifest: %v", err)
+	}
+	if !reflect.DeepEqual(*parsed, m) {
+		t.Errorf("parsed %+v, expected %+v", *parsed, m)
+	}
+	m.Dir = "movies"
+	if parsed, err := parseManifest(m.String()); err != nil || !reflect.DeepEqual(*parsed, m) {
+		t.Errorf("parsed %+v (%v), expected %+v", parsed, err, m)
+	}
+	if _, err := parseManifest("a pinned announcement"); err != errNotManifest {
+		t.Errorf("got error %v for another message, expected %v", err, errNotManifest)
+	}
+	if _, err := parseManifest(manifestTag + "\nname: a.bin\nsize: 10"); err == nil {
+		t.Error("expected an error for a manifest without parts")
+	}
+}
+
+func TestUnzipStoredSplitParts(t *testing.T) {
+	data := make([]byte, 3<<20+123)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	outputBase := filepath.Join(t.TempDir(), "video")
+	if err := CreateSplitZip(context.Background(), bytes.NewReader(data), int64(len(data)), "video.mp4", outputBase, 1<<20); err != nil {
+		t.Fatalf("failed to create split zip: %v", err)
+	}
+	parts, err := filepath.Glob(outputBase + ".z*")
+	if err != nil || len(parts) != 4 {
+		t.Fatalf("expected 4 parts, got %v: %v", parts, err)
+	}
+	readers := make([]io.Reader, 0, len(parts))
+	for _, part := range parts {
+		content, err := os.ReadFile(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		readers = append(readers, bytes.NewReader(content))
+	}
+
+	r, err := unzipStored(io.MultiReader(readers...), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to unzip: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("reassembled %d bytes differ from the original %d bytes", len(got), len(data))
+	}
+
+	if _, err := unzipStored(bytes.NewReader(data), 10); err == nil {
+		t.Error("expected an error for data that is not a zip file")
+	}
+}
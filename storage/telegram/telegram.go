@@ -5,27 +5,25 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/celestix/gotgproto/ext"
 	"github.com/charmbracelet/log"
 	"github.com/duke-git/lancet/v2/slice"
 	"github.com/duke-git/lancet/v2/validator"
-	"github.com/gabriel-vasile/mimetype"
 	"github.com/gotd/td/telegram/message"
 	"github.com/gotd/td/telegram/message/styling"
 	"github.com/gotd/td/telegram/uploader"
 	"github.com/gotd/td/tg"
-	"github.com/krau/SaveAny-Bot/common/utils/dlutil"
 	"github.com/krau/SaveAny-Bot/common/utils/tgutil"
 	"github.com/krau/SaveAny-Bot/config"
 	storconfig "github.com/krau/SaveAny-Bot/config/storage"
-	"github.com/krau/SaveAny-Bot/pkg/consts/tglimit"
 	"github.com/krau/SaveAny-Bot/pkg/enums/ctxkey"
 	storenum "github.com/krau/SaveAny-Bot/pkg/enums/storage"
+	"github.com/krau/SaveAny-Bot/pkg/storagetypes"
 	"github.com/rs/xid"
 	"golang.org/x/time/rate"
 )
@@ -37,8 +35,10 @@ const (
 )
 
 type Telegram struct {
-	config  storconfig.TelegramStorageConfig
-	limiter *rate.Limiter
+	config      storconfig.TelegramStorageConfig
+	limiter     *rate.Limiter
+	captionTmpl *template.Template
+	topics      topicCache
 }
 
 func (t *Telegram) Init(ctx context.Context, cfg storconfig.StorageConfig) error {
@@ -55,6 +55,13 @@ func (t *Telegram) Init(ctx context.Context, cfg storconfig.StorageConfig) error
 		t.config.RateBurst = 1
 	}
 	t.limiter = rate.NewLimiter(rate.Every(time.Duration(t.config.RateLimit)*time.Second), t.config.RateBurst)
+	if t.config.CaptionTemplate != "" {
+		tmpl, err := template.New("caption").Parse(t.config.CaptionTemplate)
+		if err != nil {
+			return fmt.Errorf("failed to parse caption template: %w", err)
+		}
+		t.captionTmpl = tmpl
+	}
 	return nil
 }
 
@@ -67,11 +74,15 @@ func (t *Telegram) Name() string {
 }
 
 func (t *Telegram) Exists(ctx context.Context, storagePath string) bool {
-	return false
+	tctx := extContext(ctx)
+	if tctx == nil {
+		return false
+	}
+	chatID, dir, filename := t.splitPath(ctx, tctx, storagePath)
+	return filename != "" && t.indexedFile(ctx, chatID, t.topicDir(dir), filename) != nil
 }
 
 func (t *Telegram) Save(ctx context.Context, r io.Reader, storagePath string) error {
-	storagePath = path.Clean(storagePath)
 	tctx := tgutil.ExtFromContext(ctx)
 	if tctx == nil {
 		return fmt.Errorf("failed to get telegram context")
@@ -88,27 +99,60 @@ func (t *Telegram) Save(ctx context.Context, r io.Reader, storagePath string) er
 		log.FromContext(ctx).Warnf("Skipping file larger than Telegram limit (%d bytes): %d bytes", MaxUploadFileSize, size)
 		return nil
 	}
-	rs, seekable := r.(io.ReadSeeker)
-	splitSize := t.config.SplitSizeMB * 1024 * 1024
-	if splitSize <= 0 {
-		splitSize = DefaultSplitSize
-	}
 
 	if err := t.limiter.Wait(ctx); err != nil {
 		return fmt.Errorf("rate limit failed: %w", err)
 	}
 
-	// 去除前导斜杠并分隔路径, 当 len(parts):
-	// ==0, 存储到配置文件中的 chat_id, 随机文件名
-	// ==1, 视作只有文件名, 存储到配置文件中的 chat_id
-	// ==2, parts[0]: 视作要存储到的 chat_id, parts[1]: filename
+	file, err := t.prepareFile(ctx, tctx, r, storagePath, size)
+	if err != nil {
+		return err
+	}
+	if splitSize := t.splitSize(); size > splitSize {
+		// large file, use split uploader
+		return t.splitUpload(tctx, file, splitSize)
+	}
+
+	media, err := t.uploadMedia(ctx, file)
+	if err != nil {
+		return err
+	}
+	sent, err := file.to(tctx).Media(ctx, message.Media(media, styling.Plain(t.caption(ctx, tctx, file.filename, file.hasher.n))))
+	if err != nil {
+		return err
+	}
+	t.indexFile(ctx, storagetypes.TelegramFile{
+		ChatID:     file.chatID,
+		Dir:        file.dir,
+		Name:       file.filename,
+		Size:       file.hasher.n,
+		Hash:       file.hasher.Sum(),
+		MessageIDs: []int{tgutil.SentMessageID(sent)},
+	})
+	return nil
+}
+
+func (t *Telegram) splitSize() int64 {
+	splitSize := t.config.SplitSizeMB * 1024 * 1024
+	if splitSize <= 0 {
+		splitSize = DefaultSplitSize
+	}
+	return splitSize
+}
+
+// splitPath 去除前导斜杠并分隔路径, 返回要存储到的聊天, 话题目录和文件名, 当 len(parts):
+// ==0, 存储到配置文件中的 chat_id, 随机文件名
+// ==1, 视作只有文件名, 存储到配置文件中的 chat_id
+// >=2, parts[0]: 不是话题目录时视作要存储到的 chat_id, 之后的第一个目录视作话题目录, 最后一部分为文件名
+func (t *Telegram) splitPath(ctx context.Context, tctx *ext.Context, storagePath string) (int64, string, string) {
 	parts := slice.Compact(strings.Split(strings.TrimPrefix(storagePath, "/"), "/"))
 	filename := ""
 	chatID := t.config.ChatID
 	if len(parts) >= 1 {
 		filename = parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
 	}
-	if len(parts) >= 2 && validator.IsAlphaNumeric(parts[0]) {
+	if len(parts) >= 1 && validator.IsAlphaNumeric(parts[0]) && !t.isTopicDir(parts[0]) {
 		cid, err := tgutil.ParseChatID(tctx, parts[0])
 		if err != nil {
 			// id不合法时使用配置文件中的 chat_id
@@ -116,108 +160,21 @@ func (t *Telegram) Save(ctx context.Context, r io.Reader, storagePath string) er
 			cid = chatID
 		}
 		chatID = cid
+		parts = parts[1:]
 	}
-	upler := uploader.NewUploader(tctx.Raw).
-		WithPartSize(tglimit.MaxUploadPartSize).
-		WithThreads(dlutil.BestThreads(size, config.C().Threads))
-	peer := tryGetInputPeer(tctx, chatID)
-	if peer == nil || peer.Zero() {
-		return fmt.Errorf("failed to get input peer for chat ID %d", chatID)
-	}
-	var mtype *mimetype.MIME
-	if seekable {
-		var err error
-		mtype, err = mimetype.DetectReader(rs)
-		if err != nil {
-			return fmt.Errorf("failed to detect mimetype: %w", err)
-		}
-		if filename == "" {
-			filename = xid.New().String() + mtype.Extension()
-		}
-
-		if _, err := rs.Seek(0, io.SeekStart); err != nil {
-			return fmt.Errorf("failed to seek reader: %w", err)
-		}
-	}
-	if size > splitSize {
-		// large file, use split uploader
-		return t.splitUpload(tctx, r, filename, upler, peer, size, splitSize)
-	}
-
-	var file tg.InputFileClass
-	var err error
-	if size <= 0 {
-		file, err = upler.FromReader(ctx, filename, r)
-	} else {
-		file, err = upler.Upload(ctx, uploader.NewUpload(filename, r, size))
-	}
-	if err != nil {
-		return fmt.Errorf("failed to upload file to telegram: %w", err)
-	}
-	caption := styling.Plain(filename)
-	forceFile := t.config.ForceFile
-
-	if mtype != nil && strings.HasPrefix(mtype.String(), "image/") && size >= tglimit.MaxPhotoSize {
-		forceFile = true
-	}
-	doc := message.UploadedDocument(file, caption).
-		Filename(filename).
-		ForceFile(forceFile)
-	if mtype != nil {
-		doc = doc.MIME(mtype.String())
-	}
-	var media message.MediaOption = doc
-	if mtype != nil && rs != nil {
-		switch mtypeStr := mtype.String(); {
-		case strings.HasPrefix(mtypeStr, "video/"):
-			media = doc.Video().SupportsStreaming()
-			thumb, err := extractThumbFrame(rs)
-			if err == nil {
-				thumb, err := upler.FromBytes(ctx, "thumb.jpg", thumb)
-				if err == nil {
-					doc = doc.Thumb(thumb)
-				}
-			}
-			rs.Seek(0, io.SeekStart)
-			switch mtypeStr {
-			case "video/mp4":
-				info, err := getMP4Meta(rs)
-				if err != nil {
-					// Fallback to ffprobe if gomedia fails (e.g., malformed MP4)
-					rs.Seek(0, io.SeekStart)
-					info, err = getVideoMetadata(rs)
-				}
-				if err == nil {
-					media = doc.Video().
-						Duration(time.Duration(info.Duration)*time.Second).
-						Resolution(info.Width, info.Height).
-						SupportsStreaming()
-				}
-			default:
-				info, err := getVideoMetadata(rs)
-				if err == nil {
-					media = doc.Video().
-						Duration(time.Duration(info.Duration)*time.Second).
-						Resolution(info.Width, info.Height).
-						SupportsStreaming()
-				}
-			}
-		case strings.HasPrefix(mtypeStr, "audio/"):
-			media = doc.Audio().Title(filename)
-		case strings.HasPrefix(mtypeStr, "image/") && !strings.HasSuffix(mtypeStr, "webp"):
-			media = message.UploadedPhoto(file, caption)
-		}
+	dir := ""
+	if len(parts) >= 1 {
+		dir = parts[0]
 	}
-	sender := tctx.Sender
-	_, err = sender.WithUploader(upler).To(peer).Media(ctx, media)
-	return err
+	return chatID, dir, filename
 }
 
 func (t *Telegram) CannotStream() string {
 	return "Telegram storage must use a ReaderSeeker"
 }
 
-func (t *Telegram) splitUpload(ctx *ext.Context, r io.Reader, filename string, upler *uploader.Uploader, peer tg.InputPeerClass, fileSize, splitSize int64) error {
+func (t *Telegram) splitUpload(ctx *ext.Context, file *pendingFile, splitSize int64) (err error) {
+	filename, fileSize, upler := file.filename, file.size, file.upler
 	tempId := xid.New().String()
 	outputBase := filepath.Join(config.C().Temp.BasePath, tempId, strings.Split(filename, ".")[0])
 	defer func() {
@@ -226,7 +183,7 @@ func (t *Telegram) splitUpload(ctx *ext.Context, r io.Reader, filename string, u
 			log.FromContext(ctx).Warnf("Failed to cleanup temp split files: %s", err)
 		}
 	}()
-	if err := CreateSplitZip(ctx, r, fileSize, filename, outputBase, splitSize); err != nil {
+	if err := CreateSplitZip(ctx, file.reader, fileSize, filename, outputBase, splitSize); err != nil {
 		return fmt.Errorf("failed to create split zip: %w", err)
 	}
 	matched, err := filepath.Glob(outputBase + ".z*")
@@ -259,6 +216,28 @@ func (t *Telegram) splitUpload(ctx *ext.Context, r io.Reader, filename string, u
 			return fmt.Errorf("failed to upload split part %s: %w", partPath, err)
 		}
 	}
+	sent := make([]tg.UpdatesClass, 0, (len(inputFiles)+9)/10)
+	defer func() {
+		if err != nil || len(sent) == 0 {
+			return
+		}
+		// 上传已经完成, 清单仅用于之后读取, 失败时不视为保存失败
+		parts, err := writeManifest(ctx, file.peer, file.dir, filename, fileSize, sent)
+		if err != nil {
+			log.FromContext(ctx).Warnf("Failed to write split manifest of %s: %s", filename, err)
+		}
+		if len(parts) > 0 {
+			t.indexFile(ctx, storagetypes.TelegramFile{
+				ChatID:     file.chatID,
+				Dir:        file.dir,
+				Name:       filename,
+				Size:       fileSize,
+				Hash:       file.hasher.Sum(),
+				MessageIDs: parts,
+				Split:      true,
+			})
+		}
+	}()
 	if len(inputFiles) == 1 {
 		// only one part, send as normal file
 		// shoud not happen as we already check fileSize > splitSize
@@ -266,10 +245,9 @@ func (t *Telegram) splitUpload(ctx *ext.Context, r io.Reader, filename string, u
 			Filename(filepath.Base(matched[0])).
 			ForceFile(true).
 			MIME("application/zip")
-		_, err = ctx.Sender.
-			WithUploader(upler).
-			To(peer).
-			Media(ctx, doc)
+		var upd tg.UpdatesClass
+		upd, err = file.to(ctx).Media(ctx, doc)
+		sent = append(sent, upd)
 		return err
 	}
 
@@ -281,12 +259,10 @@ func (t *Telegram) splitUpload(ctx *ext.Context, r io.Reader, filename string, u
 		multiMedia = append(multiMedia, doc)
 	}
 
-	sender := ctx.Sender
-
 	if len(multiMedia) <= 10 {
-		_, err = sender.WithUploader(upler).
-			To(peer).
-			Album(ctx, multiMedia[0], multiMedia[1:]...)
+		var upd tg.UpdatesClass
+		upd, err = file.to(ctx).Album(ctx, multiMedia[0], multiMedia[1:]...)
+		sent = append(sent, upd)
 		return err
 	}
 
@@ -294,12 +270,12 @@ func (t *Telegram) splitUpload(ctx *ext.Context, r io.Reader, filename string, u
 	for i := 0; i < len(multiMedia); i += 10 {
 		end := min(i+10, len(multiMedia))
 		batch := multiMedia[i:end]
-		_, err = sender.WithUploader(upler).
-			To(peer).
-			Album(ctx, batch[0], batch[1:]...)
+		var upd tg.UpdatesClass
+		upd, err = file.to(ctx).Album(ctx, batch[0], batch[1:]...)
 		if err != nil {
 			return fmt.Errorf("failed to send album batch: %w", err)
 		}
+		sent = append(sent, upd)
 	}
 	return nil
 
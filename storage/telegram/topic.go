@@ -0,0 +1,160 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/celestix/gotgproto/ext"
+	"github.com/charmbracelet/log"
+	"github.com/duke-git/lancet/v2/validator"
+	"github.com/gotd/td/tg"
+)
+
+// topicCache 记录自动创建或找到的话题, 避免并发保存时重复创建
+type topicCache struct {
+	mu     sync.Mutex
+	topics map[string]int // chatID:title -> topic ID
+}
+
+// isTopicDir 判断路径的第一部分是否为话题目录而不是聊天
+func (t *Telegram) isTopicDir(name string) bool {
+	if _, ok := t.configuredTopic(name); ok {
+		return true
+	}
+	return t.config.AutoTopic && !validator.IsIntStr(name)
+}
+
+// topicDir 返回文件在索引中的话题目录, 目录不对应单独的话题时为空
+func (t *Telegram) topicDir(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	if _, ok := t.config.Topics[dir]; ok {
+		return dir
+	}
+	for name := range t.config.Topics {
+		if strings.EqualFold(name, dir) {
+			return name
+		}
+	}
+	if t.config.AutoTopic {
+		return dir
+	}
+	return ""
+}
+
+// configuredTopic 返回配置中目录对应的话题, 目录名不区分大小写
+func (t *Telegram) configuredTopic(dir string) (int, bool) {
+	if topicID, ok := t.config.Topics[dir]; ok {
+		return topicID, true
+	}
+	for name, topicID := range t.config.Topics {
+		if strings.EqualFold(name, dir) {
+			return topicID, true
+		}
+	}
+	return 0, false
+}
+
+// topicID 返回要将目录中的文件发送到的话题, 0 表示不发送到话题
+func (t *Telegram) topicID(ctx context.Context, tctx *ext.Context, chatID int64, peer tg.InputPeerClass, dir string) (int, error) {
+	if dir == "" {
+		return t.config.TopicID, nil
+	}
+	if topicID, ok := t.configuredTopic(dir); ok {
+		return topicID, nil
+	}
+	if !t.config.AutoTopic {
+		return t.config.TopicID, nil
+	}
+
+	t.topics.mu.Lock()
+	defer t.topics.mu.Unlock()
+	key := fmt.Sprintf("%d:%s", chatID, dir)
+	if topicID, ok := t.topics.topics[key]; ok {
+		return topicID, nil
+	}
+	topicID, err := t.findTopic(ctx, tctx, chatID, peer, dir)
+	if err != nil {
+		return 0, err
+	}
+	if topicID == 0 {
+		topicID, err = createTopic(ctx, tctx, peer, dir)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create topic %s: %w", dir, err)
+		}
+		log.FromContext(ctx).Infof("Created topic %s (%d) in chat %d", dir, topicID, chatID)
+		if index != nil {
+			if err := index.SaveTelegramTopic(ctx, t.Name(), chatID, dir, topicID); err != nil {
+				log.FromContext(ctx).Warnf("Failed to save topic %s: %s", dir, err)
+			}
+		}
+	}
+	if t.topics.topics == nil {
+		t.topics.topics = make(map[string]int)
+	}
+	t.topics.topics[key] = topicID
+	return topicID, nil
+}
+
+// findTopic 返回之前创建的或聊天中已有的同名话题, 没有时返回 0
+func (t *Telegram) findTopic(ctx context.Context, tctx *ext.Context, chatID int64, peer tg.InputPeerClass, title string) (int, error) {
+	if index != nil {
+		topicID, err := index.GetTelegramTopic(ctx, t.Name(), chatID, title)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get topic %s: %w", title, err)
+		}
+		if topicID != 0 {
+			return topicID, nil
+		}
+	}
+	topics, err := tctx.Raw.MessagesGetForumTopics(ctx, &tg.MessagesGetForumTopicsRequest{
+		Peer:  peer,
+		Q:     title,
+		Limit: 100,
+	})
+	if err != nil {
+		// bot 无法列举话题
+		log.FromContext(ctx).Debugf("Failed to search topic %s: %s", title, err)
+		return 0, nil
+	}
+	for _, topic := range topics.Topics {
+		if topic, ok := topic.(*tg.ForumTopic); ok && topic.Title == title {
+			return topic.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+func createTopic(ctx context.Context, tctx *ext.Context, peer tg.InputPeerClass, title string) (int, error) {
+	randomID, err := randomMessageID()
+	if err != nil {
+		return 0, err
+	}
+	updates, err := tctx.Raw.MessagesCreateForumTopic(ctx, &tg.MessagesCreateForumTopicRequest{
+		Peer:     peer,
+		Title:    title,
+		RandomID: randomID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	// 话题的 ID 为创建话题的服务消息的 ID
+	if topicID := sentMessageIDs(updates)[randomID]; topicID != 0 {
+		return topicID, nil
+	}
+	for _, update := range updateList(updates) {
+		u, ok := update.(*tg.UpdateNewChannelMessage)
+		if !ok {
+			continue
+		}
+		if msg, ok := u.Message.(*tg.MessageService); ok {
+			if _, ok := msg.Action.(*tg.MessageActionTopicCreate); ok {
+				return msg.ID, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no topic in the updates")
+}
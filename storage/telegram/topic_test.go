@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/celestix/gotgproto/ext"
+	storconfig "github.com/krau/SaveAny-Bot/config/storage"
+	"github.com/krau/SaveAny-Bot/pkg/storagetypes"
+)
+
+func TestSplitPathTopics(t *testing.T) {
+	stor := &Telegram{config: storconfig.TelegramStorageConfig{
+		ChatID: 100,
+		Topics: map[string]int{"Photos": 5},
+	}}
+	ctx := context.Background()
+	tests := []struct {
+		path   string
+		chatID int64
+		dir    string
+		name   string
+	}{
+		{"/a.jpg", 100, "", "a.jpg"},
+		{"/photos/a.jpg", 100, "photos", "a.jpg"},
+		{"/200/a.jpg", 200, "", "a.jpg"},
+		{"/200/photos/album/a.jpg", 200, "photos", "a.jpg"},
+	}
+	for _, test := range tests {
+		chatID, dir, name := stor.splitPath(ctx, nil, test.path)
+		if chatID != test.chatID || dir != test.dir || name != test.name {
+			t.Errorf("splitPath(%q) = %d, %q, %q, expected %d, %q, %q", test.path, chatID, dir, name, test.chatID, test.dir, test.name)
+		}
+	}
+
+	stor.config.AutoTopic = true
+	if chatID, dir, _ := stor.splitPath(ctx, nil, "/videos/a.mp4"); chatID != 100 || dir != "videos" {
+		t.Errorf("got chat %d and directory %q with auto topics, expected the configured chat and videos", chatID, dir)
+	}
+}
+
+func TestTopicDir(t *testing.T) {
+	stor := &Telegram{config: storconfig.TelegramStorageConfig{
+		ChatID: 100,
+		Topics: map[string]int{"Photos": 5},
+	}}
+	for dir, want := range map[string]string{"": "", "photos": "Photos", "Photos": "Photos", "videos": ""} {
+		if got := stor.topicDir(dir); got != want {
+			t.Errorf("topicDir(%q) = %q, expected %q", dir, got, want)
+		}
+	}
+	stor.config.AutoTopic = true
+	if got := stor.topicDir("videos"); got != "videos" {
+		t.Errorf("topicDir(videos) = %q with auto topics, expected videos", got)
+	}
+}
+
+func TestDirLocation(t *testing.T) {
+	stor := &Telegram{config: storconfig.TelegramStorageConfig{
+		ChatID: 100,
+		Topics: map[string]int{"Photos": 5},
+	}}
+	tests := []struct {
+		dir    string
+		chatID int64
+		topic  string
+	}{
+		{"", 100, ""},
+		{"/", 100, ""},
+		{"/photos", 100, "Photos"},
+		{"/200", 200, ""},
+		{"/200/photos/", 200, "Photos"},
+	}
+	for _, test := range tests {
+		chatID, dir, err := stor.dirLocation(nil, test.dir)
+		if err != nil || chatID != test.chatID || dir != test.topic {
+			t.Errorf("dirLocation(%q) = %d, %q, %v, expected %d, %q", test.dir, chatID, dir, err, test.chatID, test.topic)
+		}
+	}
+	for _, dir := range []string{"/200/videos", "/photos/album", "/200/photos/album"} {
+		if _, _, err := stor.dirLocation(nil, dir); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("got error %v for %q, expected %v", err, dir, os.ErrNotExist)
+		}
+	}
+}
+
+// TestIndexTopicDirs tests that files of the same name in different topic directories are indexed separately
+func TestIndexTopicDirs(t *testing.T) {
+	idx := &fakeIndex{files: make(map[string]storagetypes.TelegramFile)}
+	SetIndex(idx)
+	t.Cleanup(func() { SetIndex(nil) })
+	stor := &Telegram{config: storconfig.TelegramStorageConfig{
+		ChatID: 100,
+		Topics: map[string]int{"photos": 5, "music": 6},
+	}}
+	ctx := &ext.Context{Context: context.Background()}
+
+	stor.indexFile(ctx, storagetypes.TelegramFile{ChatID: 100, Dir: "photos", Name: "a.jpg", MessageIDs: []int{10}})
+	if !stor.Exists(ctx, "/photos/a.jpg") {
+		t.Error("expected /photos/a.jpg to exist")
+	}
+	if stor.Exists(ctx, "/music/a.jpg") || stor.Exists(ctx, "/a.jpg") {
+		t.Error("a file of a topic directory exists in other directories")
+	}
+	if got := stor.uniqueName(ctx, 100, stor.topicDir("music"), "a.jpg"); got != "a.jpg" {
+		t.Errorf("got %s for a file of another topic directory, expected a.jpg", got)
+	}
+	stor.indexFile(ctx, storagetypes.TelegramFile{ChatID: 100, Dir: "music", Name: "a.jpg", MessageIDs: []int{11}})
+	if file := stor.indexedFile(ctx, 100, "photos", "a.jpg"); file == nil || file.MessageIDs[0] != 10 {
+		t.Errorf("got %+v for /photos/a.jpg, expected the file of message 10", file)
+	}
+}
+
+func TestTopicID(t *testing.T) {
+	idx := &fakeIndex{files: make(map[string]storagetypes.TelegramFile), topics: map[string]int{"videos": 9}}
+	SetIndex(idx)
+	t.Cleanup(func() { SetIndex(nil) })
+	stor := &Telegram{config: storconfig.TelegramStorageConfig{
+		ChatID:  100,
+		TopicID: 3,
+		Topics:  map[string]int{"Photos": 5},
+	}}
+	ctx := context.Background()
+
+	for dir, want := range map[string]int{"": 3, "photos": 5, "videos": 3} {
+		if got, err := stor.topicID(ctx, nil, 100, nil, dir); err != nil || got != want {
+			t.Errorf("got topic %d (%v) for directory %q, expected %d", got, err, dir, want)
+		}
+	}
+	stor.config.AutoTopic = true
+	if got, err := stor.topicID(ctx, nil, 100, nil, "videos"); err != nil || got != 9 {
+		t.Errorf("got topic %d (%v) for a created topic, expected 9", got, err)
+	}
+	delete(idx.topics, "videos")
+	if got, err := stor.topicID(ctx, nil, 100, nil, "videos"); err != nil || got != 9 {
+		t.Errorf("got topic %d (%v) for a cached topic, expected 9", got, err)
+	}
+}